@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// searchServer has a search tool that returns a result citing the page of the query and a shared index page.
+func searchServer() testServer {
+	return testServer{calls: map[string]toolHandler{
+		"search": func(_ context.Context, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			query, _ := call.Arguments["query"].(string)
+			return &mcp.CallToolResult{Content: []mcp.Content{{
+				Type: "text",
+				Text: "results for " + query,
+				Meta: map[string]any{
+					types.CitationsMetaKey: []map[string]any{
+						{"title": query, "url": "https://example.com/" + query, "snippet": "about " + query},
+						{"title": "Index", "url": "https://example.com/"},
+					},
+				},
+			}}}, nil
+		},
+	}}
+}
+
+func TestComplete_Citations(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("search", func(string) mcp.MessageHandler {
+		return searchServer()
+	})
+
+	completer := &multiToolCallCompleter{calls: []types.ToolCall{
+		{CallID: "go-call", Name: "search", Arguments: `{"query": "go"}`},
+		{CallID: "mcp-call", Name: "search", Arguments: `{"query": "mcp"}`},
+	}}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"search"}},
+		},
+		MCPServers: map[string]mcp.Server{"search": {}},
+	}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "search"}}},
+		}},
+	}, types.CompletionOptions{Chat: new(bool)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([]types.Citation{
+		{
+			Title:   "go",
+			URL:     "https://example.com/go",
+			Snippet: "about go",
+		},
+		{
+			Title: "Index",
+			URL:   "https://example.com/",
+		},
+		{
+			Title:   "mcp",
+			URL:     "https://example.com/mcp",
+			Snippet: "about mcp",
+		},
+	}).Equal(t, resp.Citations)
+
+	// The model is given the sources of each result as text
+	var texts []string
+	for _, content := range completer.results["mcp-call"].Content {
+		texts = append(texts, content.Text)
+	}
+	autogold.Expect([]string{
+		"results for mcp", `Sources:
+[1] mcp - https://example.com/mcp: "about mcp"
+[2] Index - https://example.com/`,
+	}).Equal(t, texts)
+}
@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// exampleMetaKey marks the content of the messages of types.Agent.Examples, so they are not stored as
+// history.
+const exampleMetaKey = "ai.nanobot.example"
+
+// exampleMessages returns the examples of the agent as user and assistant messages, in order.
+func exampleMessages(agent types.Agent) []types.Message {
+	messages := make([]types.Message, 0, len(agent.Examples)*2)
+	for i, example := range agent.Examples {
+		for _, message := range []struct{ role, text string }{
+			{"user", example.User},
+			{"assistant", example.Assistant},
+		} {
+			if message.text == "" {
+				continue
+			}
+			messages = append(messages, types.Message{
+				ID:   fmt.Sprintf("example-%d-%s", i, message.role),
+				Role: message.role,
+				Items: []types.CompletionItem{{
+					ID: fmt.Sprintf("example-%d-%s-text", i, message.role),
+					Content: &mcp.Content{
+						Type: "text",
+						Text: message.text,
+						Meta: map[string]any{
+							exampleMetaKey: true,
+						},
+					},
+				}},
+			})
+		}
+	}
+	return messages
+}
+
+// withoutExamples returns the messages without the example messages of the agent.
+func withoutExamples(messages []types.Message) []types.Message {
+	return slices.DeleteFunc(slices.Clone(messages), isExample)
+}
+
+func isExample(msg types.Message) bool {
+	for _, item := range msg.Items {
+		if item.Content != nil {
+			if example, _ := item.Content.Meta[exampleMetaKey].(bool); example {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// storedRequest returns the request to store for the run, without the example messages.
+func storedRequest(req types.CompletionRequest) *types.CompletionRequest {
+	req.Input = withoutExamples(req.Input)
+	return &req
+}
+
+// toolDescription appends the examples in the extensions of a tool to its description. The extensions are
+// returned without the examples, so only the other extensions are sent to the provider as attributes.
+func toolDescription(description string, extensions map[string]any) (string, map[string]any, error) {
+	examples, err := types.ToolExamples(extensions)
+	if err != nil || len(examples) == 0 {
+		return description, extensions, err
+	}
+
+	var buf strings.Builder
+	buf.WriteString(description)
+	if description != "" {
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString("Examples:")
+	for _, example := range examples {
+		args := example.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		data, err := json.Marshal(args)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal the arguments of a tool example: %w", err)
+		}
+		buf.WriteString("\n- ")
+		if example.Description != "" {
+			buf.WriteString(example.Description)
+			buf.WriteString(": ")
+		}
+		buf.Write(data)
+	}
+
+	attributes := maps.Clone(extensions)
+	delete(attributes, types.ToolExamplesExtension)
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+	return buf.String(), attributes, nil
+}
@@ -0,0 +1,137 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// recordingCompleter records the input of every request and responds with "ok".
+type recordingCompleter struct {
+	inputs [][]string
+}
+
+func (r *recordingCompleter) Complete(_ context.Context, req types.CompletionRequest, _ ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	r.inputs = append(r.inputs, messageTexts(req.Input))
+	return &types.CompletionResponse{
+		Output: types.Message{
+			ID:    "response",
+			Role:  "assistant",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "ok"}}},
+		},
+	}, nil
+}
+
+func messageTexts(messages []types.Message) (result []string) {
+	for _, msg := range messages {
+		for _, item := range msg.Items {
+			if item.Content != nil {
+				result = append(result, msg.Role+": "+item.Content.Text)
+			}
+		}
+	}
+	return result
+}
+
+func TestRun_Examples(t *testing.T) {
+	completer := &recordingCompleter{}
+	a := New(completer, tools.NewToolsService(tools.Options{}))
+
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {
+				Examples: []types.AgentExample{
+					{User: "2+2", Assistant: "4"},
+					{User: "3+3", Assistant: "6"},
+				},
+			},
+		},
+	}
+	ctx := mcp.WithSession(t.Context(), mcp.NewEmptySession(t.Context()))
+
+	userMessage := func(text string) []types.Message {
+		return []types.Message{{
+			ID:    text,
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: text}}},
+		}}
+	}
+
+	first := &types.Execution{
+		Request: types.CompletionRequest{Agent: "a", Input: userMessage("1+1")},
+	}
+	// Only the examples of the agent are left out of the history, not the messages with a similar ID
+	first.Request.Input[0].ID = "example-0-user"
+	if err := a.run(ctx, config, first, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	second := &types.Execution{
+		Request: types.CompletionRequest{Agent: "a", Input: userMessage("4+4")},
+	}
+	if err := a.run(ctx, config, second, first, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([][]string{
+		{"user: 2+2", "assistant: 4", "user: 3+3", "assistant: 6", "user: 1+1"},
+		{"user: 2+2", "assistant: 4", "user: 3+3", "assistant: 6", "user: 1+1", "assistant: ok", "user: 4+4"},
+	}).Equal(t, completer.inputs)
+
+	autogold.Expect([]string{"user: 1+1"}).Equal(t, messageTexts(first.PopulatedRequest.Input))
+	autogold.Expect([]string{"user: 1+1", "assistant: ok", "user: 4+4"}).Equal(t, messageTexts(second.PopulatedRequest.Input))
+}
+
+func TestAddTools_Examples(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+	a := New(nil, registry)
+
+	agent := types.Agent{
+		Tools: types.StringList{"dump"},
+		ToolExtensions: map[string]map[string]any{
+			"dump": {
+				"cache_control": map[string]any{"type": "ephemeral"},
+				types.ToolExamplesExtension: []any{
+					map[string]any{"description": "Echo a greeting", "arguments": map[string]any{"text": "hello"}},
+					map[string]any{"arguments": map[string]any{"text": ""}},
+				},
+			},
+			"final": {
+				types.ToolExamplesExtension: []any{
+					map[string]any{"description": "Finish without a result"},
+				},
+			},
+		},
+	}
+	config := types.Config{
+		Agents:     map[string]types.Agent{"a": agent},
+		MCPServers: map[string]mcp.Server{"dump": {}},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	var req types.CompletionRequest
+	if _, err := a.addTools(ctx, &req, &agent, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+	for _, tool := range req.Tools {
+		result = append(result, tool.Name+": "+tool.Description, fmt.Sprint(tool.Attributes))
+	}
+	autogold.Expect([]string{
+		"dump: Examples:\n- Echo a greeting: {\"text\":\"hello\"}\n- {\"text\":\"\"}",
+		"map[cache_control:map[type:ephemeral]]",
+		"final: Examples:\n- Finish without a result: {}",
+		"map[]",
+	}).Equal(t, result)
+}
@@ -0,0 +1,10 @@
+package agents
+
+// Feature flags of agents that can be turned on per session with mcp.FeatureHeaderPrefix headers or
+// mcp.FeatureEnvPrefix env vars.
+const (
+	// featureParallelTools runs the tool calls of a single completion concurrently.
+	featureParallelTools = "parallel-tools"
+	// featureStrictSchema requests strict adherence to the agent output schema.
+	featureStrictSchema = "strict-schema"
+)
@@ -0,0 +1,105 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// errHedgeLost cancels the request that did not respond first.
+var errHedgeLost = errors.New("the other hedged request responded first")
+
+type hedgeResult struct {
+	resp   *types.CompletionResponse
+	err    error
+	hedged bool
+}
+
+// complete sends the request to the completer. If the agent configures hedging and the response has not
+// started after the hedge delay, the request is sent again to the hedge model and the first successful
+// response is used. The response has started when the completer sends its first progress, like a streamed
+// token, which stops the timer. The other request is canceled. Only the first request reports progress, so
+// clients do not receive the progress of two responses.
+func (a *Agents) complete(ctx context.Context, agent types.Agent, req types.CompletionRequest, opts []types.CompletionOptions) (*types.CompletionResponse, error) {
+	if agent.Hedge == nil {
+		return a.completer.Complete(ctx, req, opts...)
+	}
+
+	delay, err := time.ParseDuration(agent.Hedge.Delay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hedge delay %q of agent %s: %w", agent.Hedge.Delay, req.Agent, err)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errHedgeLost)
+
+	var (
+		results = make(chan hedgeResult, 2)
+		started = make(chan struct{})
+		pending = 1
+		timer   = time.NewTimer(delay)
+	)
+	defer timer.Stop()
+
+	go func() {
+		ctx := progress.WithFirstProgress(ctx, func() {
+			close(started)
+		})
+		resp, err := a.completer.Complete(ctx, req, opts...)
+		results <- hedgeResult{resp: resp, err: err}
+	}()
+
+	hedge := func() {
+		pending++
+		hedgeReq := req
+		if agent.Hedge.Model != "" {
+			hedgeReq.Model = agent.Hedge.Model
+		}
+		log.Infof(ctx, "agent %s did not start to respond after %s, sending a hedged request to %s", req.Agent, delay, hedgeReq.Model)
+		go func() {
+			resp, err := a.completer.Complete(ctx, hedgeReq, withoutProgress(opts)...)
+			results <- hedgeResult{resp: resp, err: err, hedged: true}
+		}()
+	}
+
+	var firstErr error
+	for {
+		select {
+		case <-started:
+			// The response started in time, so it is not hedged. A nil channel is never ready again.
+			timer.Stop()
+			started = nil
+		case <-timer.C:
+			hedge()
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.hedged {
+					log.Infof(ctx, "hedged request of agent %s responded first", req.Agent)
+				}
+				return result.resp, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// withoutProgress returns the options without the progress token.
+func withoutProgress(opts []types.CompletionOptions) []types.CompletionOptions {
+	result := make([]types.CompletionOptions, 0, len(opts))
+	for _, opt := range opts {
+		opt.ProgressToken = nil
+		result = append(result, opt)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package agents
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// latencyCompleter responds with the model name after the latency of the model, or fails if the request
+// is canceled first. The models that stream send a progress right away.
+type latencyCompleter struct {
+	latency map[string]time.Duration
+	streams map[string]bool
+
+	lock     sync.Mutex
+	models   []string
+	canceled []string
+}
+
+func (l *latencyCompleter) Complete(ctx context.Context, req types.CompletionRequest, _ ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	l.lock.Lock()
+	l.models = append(l.models, req.Model)
+	l.lock.Unlock()
+
+	if l.streams[req.Model] {
+		progress.Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{Partial: true, Content: &mcp.Content{Type: "text", Text: req.Model}},
+		}, nil)
+	}
+
+	select {
+	case <-time.After(l.latency[req.Model]):
+	case <-ctx.Done():
+		l.lock.Lock()
+		l.canceled = append(l.canceled, req.Model)
+		l.lock.Unlock()
+		return nil, context.Cause(ctx)
+	}
+	return &types.CompletionResponse{
+		Output: types.Message{
+			Role:  "assistant",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: req.Model}}},
+		},
+	}, nil
+}
+
+func TestComplete_Hedge(t *testing.T) {
+	completer := &latencyCompleter{latency: map[string]time.Duration{
+		"slow": time.Minute,
+		"fast": 0,
+	}}
+	a := &Agents{completer: completer}
+	agent := types.Agent{Hedge: &types.AgentHedge{Delay: "10ms", Model: "fast"}}
+
+	resp, err := a.complete(t.Context(), agent, types.CompletionRequest{Agent: "a", Model: "slow"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("fast").Equal(t, resp.Output.Items[0].Content.Text)
+
+	// The slow request is canceled when the hedged request responds.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		completer.lock.Lock()
+		done := len(completer.canceled) > 0
+		completer.lock.Unlock()
+		if done {
+			break
+		}
+	}
+	completer.lock.Lock()
+	autogold.Expect([]string{"slow", "fast"}).Equal(t, completer.models)
+	autogold.Expect([]string{"slow"}).Equal(t, completer.canceled)
+	completer.lock.Unlock()
+}
+
+func TestComplete_HedgeNotNeeded(t *testing.T) {
+	completer := &latencyCompleter{}
+	a := &Agents{completer: completer}
+	agent := types.Agent{Hedge: &types.AgentHedge{Delay: "1s", Model: "other"}}
+
+	resp, err := a.complete(t.Context(), agent, types.CompletionRequest{Agent: "a", Model: "primary"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("primary").Equal(t, resp.Output.Items[0].Content.Text)
+	autogold.Expect([]string{"primary"}).Equal(t, completer.models)
+
+	_, err = a.complete(t.Context(), types.Agent{Hedge: &types.AgentHedge{Delay: "soon"}}, types.CompletionRequest{Agent: "a"}, nil)
+	autogold.Expect(`invalid hedge delay "soon" of agent a: time: invalid duration "soon"`).Equal(t, err.Error())
+}
+
+func TestComplete_HedgeFirstToken(t *testing.T) {
+	// The response takes longer than the delay, but its first token is in time
+	completer := &latencyCompleter{
+		latency: map[string]time.Duration{"streaming": 50 * time.Millisecond},
+		streams: map[string]bool{"streaming": true},
+	}
+	a := &Agents{completer: completer}
+	agent := types.Agent{Hedge: &types.AgentHedge{Delay: "10ms", Model: "other"}}
+
+	resp, err := a.complete(t.Context(), agent, types.CompletionRequest{Agent: "a", Model: "streaming"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("streaming").Equal(t, resp.Output.Items[0].Content.Text)
+	autogold.Expect([]string{"streaming"}).Equal(t, completer.models)
+}
@@ -0,0 +1,252 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// crashServer counts the calls of its "step" tool. While crashing is set, its "crash" tool signals started
+// and hangs until release is closed, so the test can take the state of the session at that point as if the
+// process crashed.
+func crashServer(steps *atomic.Int64, crashing *atomic.Bool, started, release chan struct{}) testServer {
+	return testServer{calls: map[string]toolHandler{
+		"step": func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			steps.Add(1)
+			return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "stepped"}}}, nil
+		},
+		"crash": func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if crashing.Load() {
+				close(started)
+				<-release
+			}
+			return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "recovered"}}}, nil
+		},
+	}}
+}
+
+func TestComplete_Resume(t *testing.T) {
+	var (
+		steps    atomic.Int64
+		crashing atomic.Bool
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	crashing.Store(true)
+
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("crash", func(string) mcp.MessageHandler {
+		return crashServer(&steps, &crashing, started, release)
+	})
+
+	completer := &multiToolCallCompleter{calls: []types.ToolCall{
+		{CallID: "step-call", Name: "step", Arguments: `{}`},
+		{CallID: "crash-call", Name: "crash", Arguments: `{}`},
+	}}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"crash"}},
+		},
+		MCPServers: map[string]mcp.Server{"crash": {}},
+	}
+	agents := New(completer, registry)
+
+	session := mcp.NewEmptySession(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = agents.Complete(mcp.WithSession(types.WithConfig(t.Context(), config), session), types.CompletionRequest{
+			Agent: "a",
+			Input: []types.Message{{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+			}},
+		})
+	}()
+
+	// Take the checkpoint while the second call hangs, as it would have been persisted when the process crashed
+	<-started
+	var stored types.Execution
+	if !session.Get(types.PreviousExecutionKey, &stored) {
+		t.Fatal("no execution was stored")
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint types.Execution
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(map[string]bool{"step-call": true}).Equal(t, doneOutputs(checkpoint))
+
+	crashing.Store(false)
+	close(release)
+	<-done
+	completer.results = nil
+
+	// Resume in a new session restored from the checkpoint
+	restored := mcp.NewEmptySession(t.Context())
+	restored.Set(types.PreviousExecutionKey, &checkpoint)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), restored)
+
+	resp, err := agents.Complete(ctx, types.CompletionRequest{
+		Agent:  "a",
+		Resume: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("done").Equal(t, resp.Output.Items[0].Content.Text)
+
+	// The call that was done is not called again and the model gets both results
+	autogold.Expect(int64(1)).Equal(t, steps.Load())
+	autogold.Expect(map[string]types.CallResult{
+		"crash-call": {Content: []mcp.Content{{Type: "text", Text: "recovered"}}},
+		"step-call":  {Content: []mcp.Content{{Type: "text", Text: "stepped"}}},
+	}).Equal(t, completer.results)
+
+	// The turn is done, so there is nothing left to resume
+	_, err = agents.Complete(ctx, types.CompletionRequest{
+		Agent:  "a",
+		Resume: true,
+	})
+	autogold.Expect("there is no interrupted turn to resume").Equal(t, err.Error())
+}
+
+func TestComplete_ResumeFromSessionStore(t *testing.T) {
+	var (
+		steps    atomic.Int64
+		crashing atomic.Bool
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	crashing.Store(true)
+
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("crash", func(string) mcp.MessageHandler {
+		return crashServer(&steps, &crashing, started, release)
+	})
+
+	completer := &multiToolCallCompleter{calls: []types.ToolCall{
+		{CallID: "step-call", Name: "step", Arguments: `{}`},
+		{CallID: "crash-call", Name: "crash", Arguments: `{}`},
+	}}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"crash"}},
+		},
+		MCPServers: map[string]mcp.Server{"crash": {}},
+	}
+	agents := New(completer, registry)
+
+	manager, err := session.NewManager("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverSession, err := mcp.NewServerSession(t.Context(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverSession.SetSessionStore(manager)
+	if err := manager.Store(t.Context(), serverSession.ID(), serverSession); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx := mcp.WithSession(types.WithConfig(t.Context(), config), serverSession.GetSession())
+		_, _ = agents.Complete(ctx, types.CompletionRequest{
+			Agent: "a",
+			Input: []types.Message{{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+			}},
+		})
+	}()
+
+	// The checkpoint is in the store while the second call hangs, before the turn is done
+	<-started
+	stored, err := manager.DB.Get(t.Context(), serverSession.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint types.Execution
+	if err := mcp.JSONCoerce(stored.State.Attributes[types.PreviousExecutionKey], &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(map[string]bool{"step-call": true}).Equal(t, doneOutputs(checkpoint))
+
+	crashing.Store(false)
+	close(release)
+	<-done
+	completer.results = nil
+
+	// Resume in a session loaded from the stored state, as after a restart
+	restored, err := mcp.NewExistingServerSession(t.Context(), mcp.SessionState(stored.State), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := agents.Complete(mcp.WithSession(types.WithConfig(t.Context(), config), restored.GetSession()), types.CompletionRequest{
+		Agent:  "a",
+		Resume: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("done").Equal(t, resp.Output.Items[0].Content.Text)
+	autogold.Expect(int64(1)).Equal(t, steps.Load())
+}
+
+func doneOutputs(run types.Execution) map[string]bool {
+	result := map[string]bool{}
+	for callID, output := range run.ToolOutputs {
+		result[callID] = output.Done
+	}
+	return result
+}
+
+func TestComplete_ResumeMaxIterations(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	completer := &toolCallCompleter{tools: []string{"dump", "dump", "dump"}}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"dump"}, MaxIterations: 2},
+		},
+		MCPServers: map[string]mcp.Server{"dump": {}},
+	}
+	agents := New(completer, registry)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	resp, err := agents.Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("maxIterations").Equal(t, resp.StopReason)
+
+	// The turn was stopped, not interrupted, so it can't be resumed past the maximum iterations
+	_, err = agents.Complete(ctx, types.CompletionRequest{
+		Agent:  "a",
+		Resume: true,
+	})
+	autogold.Expect("there is no interrupted turn to resume").Equal(t, err.Error())
+	autogold.Expect(2).Equal(t, completer.requests)
+}
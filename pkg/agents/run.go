@@ -11,6 +11,7 @@ import (
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/schema"
 	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
@@ -20,8 +21,10 @@ import (
 )
 
 type Agents struct {
-	completer types.Completer
-	registry  *tools.Service
+	completer   types.Completer
+	registry    *tools.Service
+	resources   ResourceCreator
+	recordTurns bool
 }
 
 type ToolListOptions struct {
@@ -36,6 +39,12 @@ func New(completer types.Completer, registry *tools.Service) *Agents {
 	}
 }
 
+// SetRecordTurns makes chat turns keep the execution they started from under types.TurnStartExecutionKey, so
+// it can be compared to the execution after the turn for debugging.
+func (a *Agents) SetRecordTurns(record bool) {
+	a.recordTurns = record
+}
+
 func (a *Agents) addTools(ctx context.Context, req *types.CompletionRequest, agent *types.Agent, opts []types.CompletionOptions) (types.ToolMappings, error) {
 	opt := complete.Complete(opts...)
 
@@ -50,7 +59,12 @@ func (a *Agents) addTools(ctx context.Context, req *types.CompletionRequest, age
 		}
 	}
 
-	toolMappings, err := a.registry.BuildToolMappings(ctx, slices.Concat(agent.Tools, agent.Agents, agent.MCPServers))
+	refs := slices.Concat(agent.Tools, agent.Agents, agent.MCPServers)
+	if a.storesLargeResults(types.ConfigFromContext(ctx), *agent) {
+		refs = append(refs, readResourceTool)
+	}
+
+	toolMappings, err := a.registry.BuildToolMappings(ctx, refs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build tool mappings: %w", err)
 	}
@@ -72,11 +86,15 @@ func (a *Agents) addTools(ctx context.Context, req *types.CompletionRequest, age
 		toolMapping := toolMappings[key]
 
 		tool := toolMapping.Target
+		description, attributes, err := toolDescription(tool.Description, agent.ToolExtensions[toolMapping.Target.Name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe tool %s: %w", key, err)
+		}
 		req.Tools = append(req.Tools, types.ToolUseDefinition{
 			Name:        key,
 			Parameters:  schema.ValidateAndFixToolSchema(tool.InputSchema),
-			Description: tool.Description,
-			Attributes:  agent.ToolExtensions[toolMapping.Target.Name],
+			Description: description,
+			Attributes:  attributes,
 		})
 	}
 
@@ -178,6 +196,7 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 
 	req.Agent = agentName
 	req.Reasoning = agent.Reasoning
+	req.Audio = agent.Audio
 
 	if req.SystemPrompt != "" {
 		var agentInstructions types.DynamicInstructions
@@ -196,6 +215,16 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 		}
 	}
 
+	if !agent.SkipSystemPromptWrap {
+		req.SystemPrompt = wrapSystemPrompt(config, req.SystemPrompt)
+	}
+
+	if examples := exampleMessages(agent); len(examples) > 0 {
+		req.Input = append(examples, withoutExamples(req.Input)...)
+	}
+
+	applySamplingSchedule(&agent, turnIndex(req.Input))
+
 	if req.TopP == nil && agent.TopP != nil {
 		req.TopP = agent.TopP
 	}
@@ -216,15 +245,20 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 		req.ToolChoice = agent.ToolChoice
 	}
 
+	if req.AssistantPrefix == "" {
+		req.AssistantPrefix = agent.AssistantPrefix
+	}
+
 	if previousRun != nil {
 		// Don't allow tool choice if this is a follow-on request
 		req.ToolChoice = ""
 	}
 
-	if req.OutputSchema == nil && agent.Output != nil && len(agent.Output.ToSchema()) > 0 {
+	if req.OutputSchema == nil && agent.Output != nil && (agent.Output.JSONMode() || len(agent.Output.ToSchema()) > 0) {
 		req.OutputSchema = &types.OutputSchema{
 			Name:        agent.Output.Name,
 			Description: agent.Output.Description,
+			Mode:        agent.Output.Mode,
 			Schema:      agent.Output.ToSchema(),
 			Strict:      agent.Output.Strict,
 		}
@@ -234,6 +268,12 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 		req.OutputSchema.Name = "output_schema"
 	}
 
+	if req.OutputSchema != nil && !req.OutputSchema.JSONMode() && mcp.FeatureEnabled(ctx, featureStrictSchema) {
+		outputSchema := *req.OutputSchema
+		outputSchema.Strict = true
+		req.OutputSchema = &outputSchema
+	}
+
 	if req.ThreadName == "" {
 		req.ThreadName = agent.ThreadName
 	}
@@ -245,6 +285,14 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 		return req, nil, fmt.Errorf("failed to add tools: %w", err)
 	}
 
+	if previousRun == nil && agent.FirstToolChoice != "" && run.Request.ToolChoice == "" &&
+		complete.Complete(opts...).ToolChoice == nil {
+		req.ToolChoice, err = firstToolChoice(toolMapping, agent.FirstToolChoice)
+		if err != nil {
+			return req, nil, err
+		}
+	}
+
 	// Validate and fix tool input schemas
 	for i, tool := range req.Tools {
 		fixedSchema := schema.ValidateAndFixToolSchema(tool.Parameters)
@@ -254,6 +302,33 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 	return req, toolMapping, nil
 }
 
+// firstToolChoice returns the name the LLM sees for the tool reference, which is either a tool name or
+// server/tool.
+func firstToolChoice(toolMappings types.ToolMappings, tool string) (string, error) {
+	if _, ok := toolMappings[tool]; ok {
+		return tool, nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(toolMappings)) {
+		mapping := toolMappings[name]
+		if mapping.MCPServer+"/"+mapping.TargetName == tool {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("first tool choice %s is not a tool of the agent", tool)
+}
+
+// wrapSystemPrompt adds the systemPromptPrefix and systemPromptSuffix of the config around the system prompt,
+// separated by a blank line.
+func wrapSystemPrompt(config types.Config, systemPrompt string) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{config.SystemPromptPrefix, systemPrompt, config.SystemPromptSuffix} {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
 func (a *Agents) replacePrompt(ctx context.Context, agentConfig types.Agent, items []types.CompletionItem) (result []types.CompletionItem, messages []mcp.PromptMessage, err error) {
 	if len(items) != 1 || items[0].Content == nil || items[0].Content.Type != "text" {
 		return items, nil, nil
@@ -415,8 +490,12 @@ func (a *Agents) handleUIAction(ctx context.Context, config types.Config, req ty
 }
 
 func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts ...types.CompletionOptions) (_ *types.CompletionResponse, err error) {
+	ctx, done := types.WithCancel(ctx)
+	defer done()
+
 	var (
 		previousExecutionKey = types.PreviousExecutionKey
+		turnStartKey         = types.TurnStartExecutionKey
 		session              = mcp.SessionFromContext(ctx)
 		isChat               = session != nil
 		previousRun          *types.Execution
@@ -439,6 +518,7 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 
 	if req.ThreadName != "" {
 		previousExecutionKey = fmt.Sprintf("%s/%s", previousExecutionKey, req.ThreadName)
+		turnStartKey = fmt.Sprintf("%s/%s", turnStartKey, req.ThreadName)
 	}
 
 	if isChat && baseConfig.Agents[req.Model].Chat != nil && !*baseConfig.Agents[req.Model].Chat {
@@ -468,6 +548,17 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 			session.Set(previousExecutionKey, nil)
 		}
 
+		if a.recordTurns {
+			// The previous run is copied, the run of the turn may share its messages and tool outputs
+			turnStart := &types.Execution{}
+			if previousRun != nil && !req.NewThread {
+				if err := mcp.JSONCoerce(previousRun, turnStart); err != nil {
+					return nil, fmt.Errorf("failed to record the start of the turn: %w", err)
+				}
+			}
+			session.Set(turnStartKey, turnStart)
+		}
+
 		defer func() {
 			if err != nil && fallBack != nil {
 				session.Set(previousExecutionKey, fallBack)
@@ -475,6 +566,25 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		}()
 	}
 
+	resuming := false
+	if req.Resume {
+		if !isChat || previousRun == nil || !interrupted(previousRun) {
+			return nil, fmt.Errorf("there is no interrupted turn to resume")
+		}
+		// Continue the stored run where it left off, the tool calls that are done are not called again
+		currentRun = previousRun
+		previousRun = nil
+		resuming = true
+	}
+
+	// citations are collected from the tool results of all runs of the completion
+	var (
+		citations  []types.Citation
+		usage      *types.Usage
+		cost       float64
+		iterations int
+	)
+
 	for {
 		config, err := a.configHook(ctx, baseConfig, currentRun.Request.GetAgent())
 		if err != nil {
@@ -483,17 +593,34 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 
 		ctx := types.WithConfig(ctx, config)
 
-		if err := a.run(ctx, config, currentRun, previousRun, opts); err != nil {
+		if resuming {
+			resuming = false
+		} else if err := a.run(ctx, config, currentRun, previousRun, opts); err != nil {
 			return nil, err
+		} else if currentRun.Response != nil {
+			currentRun.Response.Cost = responseCost(config, currentRun)
+			usage = usage.Add(currentRun.Response.Usage)
+			cost += currentRun.Response.Cost
+			if token := complete.Complete(opts...).ProgressToken; token != nil && usage != nil {
+				progress.Send(ctx, &types.CompletionProgress{
+					Model: currentRun.Response.Model,
+					Agent: currentRun.Request.GetAgent(),
+					Usage: usage,
+					Cost:  cost,
+				}, token)
+			}
 		}
 
+		var checkpoint func()
 		if isChat {
-			session.Set(previousExecutionKey, currentRun)
+			saveRun(ctx, session, previousExecutionKey, currentRun)
+			checkpoint = func() {
+				saveRun(ctx, session, previousExecutionKey, currentRun)
+			}
 		}
 
-		if err := a.toolCalls(ctx, config, currentRun, opts); err != nil {
-			return nil, err
-		}
+		a.toolCalls(ctx, config, currentRun, checkpoint, opts)
+		citations = types.AppendCitations(citations, runCitations(currentRun)...)
 
 		if isChat {
 			for _, toolOutput := range currentRun.ToolOutputs {
@@ -507,19 +634,33 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 									},
 								},
 							},
+							Usage: usage,
+							Cost:  cost,
 						}, nil
 					}
 				}
 			}
 		}
 
-		if currentRun.Done {
+		iterations++
+		maxIterations := complete.Last(config.Agents[currentRun.Request.GetAgent()].MaxIterations, complete.Complete(opts...).MaxIterations)
+		// The model keeps calling tools, the turn is stopped instead of calling it again
+		stopped := !currentRun.Done && maxIterations > 0 && iterations >= maxIterations
+
+		if currentRun.Done || stopped {
+			if stopped {
+				// Saved with the run, so the stopped turn isn't resumed as an interrupted one
+				currentRun.Response.StopReason = types.StopReasonMaxIterations
+			}
 			if isChat {
 				currentRun.Response.ChatResponse = true
-				session.Set(previousExecutionKey, currentRun)
+				saveRun(ctx, session, previousExecutionKey, currentRun)
 			}
 
 			finalResponse := *currentRun.Response
+			finalResponse.Citations = citations
+			finalResponse.Usage = usage
+			finalResponse.Cost = cost
 
 			if startID != "" && currentRun.PopulatedRequest != nil {
 				i := slices.IndexFunc(currentRun.PopulatedRequest.Input, func(msg types.Message) bool {
@@ -530,16 +671,56 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 				}
 			}
 
+			if results := stopToolResults(config.Agents[currentRun.Request.GetAgent()], currentRun); len(results) > 0 {
+				// The turn ended with a stop tool, so its result is the response and the call is internal.
+				finalResponse.InternalMessages = append(finalResponse.InternalMessages, currentRun.Response.Output)
+				finalResponse.Output = types.Message{
+					Items: results,
+				}
+			}
+
 			return &finalResponse, nil
 		}
 
 		previousRun = currentRun
 		currentRun = &types.Execution{
-			Request: req.Reset(),
+			Request: previousRun.Request.Reset(),
 		}
 	}
 }
 
+// saveRun sets the run in the session and persists the session right away, so the progress of a turn
+// survives a restart of nanobot in the middle of it and the turn can be resumed.
+func saveRun(ctx context.Context, session *mcp.Session, key string, run *types.Execution) {
+	session.Set(key, run)
+	if err := session.Persist(ctx); err != nil {
+		log.Errorf(ctx, "failed to persist the run of session %s: %v", session.ID(), err)
+	}
+}
+
+// interrupted returns true if the run stopped in the middle of a turn, after the model responded but before
+// the turn was done, for example because the process crashed while calling tools. A turn stopped at the
+// maximum iterations is not interrupted.
+func interrupted(run *types.Execution) bool {
+	return !run.Done && run.Response != nil && run.Response.StopReason != types.StopReasonMaxIterations
+}
+
+// responseCost returns the estimated cost of the usage of the run's response, with the price of the model that
+// responded or else the model of the agent. It is 0 if neither has a price.
+func responseCost(config types.Config, run *types.Execution) float64 {
+	if run.Response.Usage == nil {
+		return 0
+	}
+	price, ok := config.Prices[run.Response.Model]
+	if !ok {
+		price, ok = config.Prices[config.Agents[run.Request.GetAgent()].Model]
+	}
+	if !ok {
+		return 0
+	}
+	return price.Cost(*run.Response.Usage)
+}
+
 func (a *Agents) GetConfigForAgent(ctx context.Context, agentName string) (types.Config, error) {
 	config := types.ConfigFromContext(ctx)
 	return a.configHook(ctx, config, agentName)
@@ -609,8 +790,9 @@ func (a *Agents) runAfter(ctx context.Context, config types.Config, req types.Co
 		return nil, fmt.Errorf("failed to invoke response hook: %w", err)
 	}
 	if hookResp.Response != nil {
-		return hookResp.Response, nil
+		resp = hookResp.Response
 	}
+	sanitizeResponse(agent, resp)
 	return resp, nil
 }
 
@@ -634,12 +816,12 @@ func (a *Agents) run(ctx context.Context, config types.Config, run *types.Execut
 	if err != nil {
 		return fmt.Errorf("failed to run before agent: %w", err)
 	} else if resp != nil {
-		run.PopulatedRequest = &completionRequest
+		run.PopulatedRequest = storedRequest(completionRequest)
 		run.Response = resp
 		return nil
 	}
 
-	run.PopulatedRequest = &completionRequest
+	run.PopulatedRequest = storedRequest(completionRequest)
 
 	modifiedRequest, resp, err := a.handleUIAction(ctx, config, completionRequest, opts)
 	if err != nil {
@@ -649,7 +831,11 @@ func (a *Agents) run(ctx context.Context, config types.Config, run *types.Execut
 		return nil
 	}
 
-	resp, err = a.completer.Complete(ctx, modifiedRequest, opts...)
+	// The model streams the arguments of its tool calls, which are masked like those of the calls
+	ctx = progress.WithArgumentMasker(ctx, a.registry.ArgumentMasker(config, allToolMappings))
+	// The streamed text is sanitized like the response
+	ctx = progress.WithTextSanitizer(ctx, textSanitizer(config.Agents[modifiedRequest.GetAgent()]))
+	resp, err = a.complete(ctx, config.Agents[modifiedRequest.GetAgent()], modifiedRequest, opts)
 	if err != nil {
 		return err
 	}
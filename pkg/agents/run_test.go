@@ -0,0 +1,302 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/llm/completions"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestPopulateRequest_SystemPromptWrap(t *testing.T) {
+	a := New(nil, tools.NewToolsService(tools.Options{}))
+
+	config := types.Config{
+		SystemPromptPrefix: "Follow the company policy.",
+		SystemPromptSuffix: "Never share secrets.",
+		Agents: map[string]types.Agent{
+			"wrapped": {
+				Instructions: types.DynamicInstructions{Instructions: "You are a helpful assistant."},
+			},
+			"empty": {},
+			"skipped": {
+				Instructions:         types.DynamicInstructions{Instructions: "You are a helpful assistant."},
+				SkipSystemPromptWrap: true,
+			},
+		},
+	}
+	ctx := mcp.WithSession(t.Context(), mcp.NewEmptySession(t.Context()))
+
+	systemPrompt := func(agent string) string {
+		req, _, err := a.populateRequest(ctx, config, &types.Execution{
+			Request: types.CompletionRequest{Agent: agent},
+		}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req.SystemPrompt
+	}
+
+	autogold.Expect("Follow the company policy.\n\nYou are a helpful assistant.\n\nNever share secrets.").Equal(t, systemPrompt("wrapped"))
+	autogold.Expect("Follow the company policy.\n\nNever share secrets.").Equal(t, systemPrompt("empty"))
+	autogold.Expect("You are a helpful assistant.").Equal(t, systemPrompt("skipped"))
+}
+
+func TestComplete_FirstToolChoice(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	complete := func(firstToolChoice string) ([]string, error) {
+		completer := &toolCallCompleter{tools: []string{"final", "dump"}}
+		config := types.Config{
+			Agents: map[string]types.Agent{
+				"a": {MCPServers: []string{"dump"}, FirstToolChoice: firstToolChoice},
+			},
+			MCPServers: map[string]mcp.Server{"dump": {}},
+		}
+		ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+		_, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+			Agent: "a",
+			Input: []types.Message{{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+			}},
+		}, types.CompletionOptions{Chat: new(bool)})
+		return completer.toolChoices, err
+	}
+
+	for _, firstToolChoice := range []string{"final", "dump/final"} {
+		toolChoices, err := complete(firstToolChoice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		autogold.Expect([]string{"final", "", ""}).Equal(t, toolChoices)
+	}
+
+	_, err := complete("missing")
+	autogold.Expect("first tool choice missing is not a tool of the agent").Equal(t, err.Error())
+}
+
+func TestComplete_MaxIterations(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	complete := func(maxIterations int, opts ...types.CompletionOptions) (int, string) {
+		completer := &toolCallCompleter{tools: []string{"dump", "dump", "dump", "dump"}}
+		config := types.Config{
+			Agents: map[string]types.Agent{
+				"a": {MCPServers: []string{"dump"}, MaxIterations: maxIterations},
+			},
+			MCPServers: map[string]mcp.Server{"dump": {}},
+		}
+		ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+		resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+			Agent: "a",
+			Input: []types.Message{{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+			}},
+		}, append(opts, types.CompletionOptions{Chat: new(bool)})...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return completer.requests, resp.StopReason
+	}
+
+	requests, stopReason := complete(0)
+	autogold.Expect([]any{5, ""}).Equal(t, []any{requests, stopReason})
+
+	requests, stopReason = complete(2)
+	autogold.Expect([]any{2, "maxIterations"}).Equal(t, []any{requests, stopReason})
+
+	requests, stopReason = complete(2, types.CompletionOptions{MaxIterations: 3})
+	autogold.Expect([]any{3, "maxIterations"}).Equal(t, []any{requests, stopReason})
+}
+
+// usageCompleter reports the same usage for every response of the completer.
+type usageCompleter struct {
+	types.Completer
+	usage types.Usage
+}
+
+func (u usageCompleter) Complete(ctx context.Context, req types.CompletionRequest, opts ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	resp, err := u.Completer.Complete(ctx, req, opts...)
+	if err == nil {
+		usage := u.usage
+		resp.Usage = &usage
+	}
+	return resp, err
+}
+
+func TestComplete_Usage(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"dump"}},
+		},
+		MCPServers: map[string]mcp.Server{"dump": {}},
+	}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	completer := usageCompleter{
+		Completer: &toolCallCompleter{tools: []string{"dump", "dump"}},
+		usage:     types.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12, CachedTokens: 4},
+	}
+	resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+		}},
+	}, types.CompletionOptions{Chat: new(bool)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&types.Usage{PromptTokens: 30, CompletionTokens: 6, TotalTokens: 36, CachedTokens: 12}).Equal(t, resp.Usage)
+}
+
+func TestComplete_Cost(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {Model: "gpt", MCPServers: []string{"dump"}},
+		},
+		MCPServers: map[string]mcp.Server{"dump": {}},
+		Prices:     map[string]types.TokenPrice{"gpt": {Input: 2, Output: 10}},
+	}
+	session := mcp.NewEmptySession(t.Context())
+
+	var costs []float64
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var progress mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+			var completion types.CompletionProgress
+			if err := mcp.JSONCoerce(progress.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Usage != nil {
+				costs = append(costs, completion.Cost)
+			}
+		}
+		return nil, nil
+	})
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	completer := usageCompleter{
+		Completer: &toolCallCompleter{tools: []string{"dump", "dump"}},
+		usage:     types.Usage{PromptTokens: 500_000, CompletionTokens: 100_000, TotalTokens: 600_000},
+	}
+	resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+		}},
+	}, types.CompletionOptions{Chat: new(bool), ProgressToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(6.0).Equal(t, resp.Cost)
+	autogold.Expect([]float64{2.0, 4.0, 6.0}).Equal(t, costs)
+}
+
+func TestPopulateRequest_AssistantPrefix(t *testing.T) {
+	a := New(nil, tools.NewToolsService(tools.Options{}))
+
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {AssistantPrefix: "{"},
+		},
+	}
+	ctx := mcp.WithSession(t.Context(), mcp.NewEmptySession(t.Context()))
+
+	assistantPrefix := func(req types.CompletionRequest) string {
+		req.Agent = "a"
+		populated, _, err := a.populateRequest(ctx, config, &types.Execution{Request: req}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return populated.AssistantPrefix
+	}
+
+	autogold.Expect("{").Equal(t, assistantPrefix(types.CompletionRequest{}))
+	autogold.Expect("[").Equal(t, assistantPrefix(types.CompletionRequest{AssistantPrefix: "["}))
+}
+
+func TestComplete_MasksStreamedArguments(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests.Add(1) > 1 {
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"resp_2\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"done\"},\"finish_reason\":\"stop\"}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		for _, delta := range []string{
+			`{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"login","arguments":""}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"{\"user\": \"bob\", \"pass"}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"word\": \"hun"}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"ter2\"}"}}]}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"resp_1\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"resp_1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	session := mcp.NewEmptySession(t.Context())
+	var sent []string
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		if msg.Method == "notifications/progress" {
+			sent = append(sent, string(msg.Params))
+		}
+		return nil, nil
+	})
+
+	registry := tools.NewToolsService(tools.Options{SensitiveArguments: []string{"password"}})
+	agents := New(completions.NewClient(completions.Config{BaseURL: server.URL}), registry)
+	config := types.Config{Agents: map[string]types.Agent{"a": {}}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	_, err := agents.Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Model: "gpt",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "log in"}}},
+		}},
+	}, types.CompletionOptions{ProgressToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) == 0 {
+		t.Fatal("no progress was sent")
+	}
+	for _, params := range sent {
+		if strings.Contains(params, "hun") || strings.Contains(params, "ter2") {
+			t.Errorf("progress has the password: %s", params)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package agents
+
+import (
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// applySamplingSchedule sets the temperature and top P of the agent from the last entry of its sampling
+// schedule that applies to the turn.
+func applySamplingSchedule(agent *types.Agent, turn int) {
+	var current *types.AgentSampling
+	for i, sampling := range agent.SamplingSchedule {
+		if sampling.FromTurn <= turn {
+			current = &agent.SamplingSchedule[i]
+		}
+	}
+	if current == nil {
+		return
+	}
+	if current.Temperature != nil {
+		agent.Temperature = current.Temperature
+	}
+	if current.TopP != nil {
+		agent.TopP = current.TopP
+	}
+}
+
+// turnIndex returns the index of the current turn of the conversation, which is the number of user messages
+// before the last one. Tool results and examples are not counted.
+func turnIndex(input []types.Message) int {
+	turns := 0
+	for _, msg := range withoutExamples(input) {
+		if msg.Role != "user" {
+			continue
+		}
+		for _, item := range msg.Items {
+			if item.Content != nil {
+				turns++
+				break
+			}
+		}
+	}
+	return max(turns-1, 0)
+}
@@ -0,0 +1,73 @@
+package agents
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestPopulateRequest_SamplingSchedule(t *testing.T) {
+	a := New(nil, tools.NewToolsService(tools.Options{}))
+
+	number := func(n string) *json.Number {
+		v := json.Number(n)
+		return &v
+	}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {
+				Temperature: number("0.5"),
+				TopP:        number("0.9"),
+				SamplingSchedule: []types.AgentSampling{
+					{FromTurn: 0, Temperature: number("1.2")},
+					{FromTurn: 1, Temperature: number("0.2")},
+					{FromTurn: 3, TopP: number("0.5")},
+				},
+			},
+		},
+	}
+	ctx := mcp.WithSession(t.Context(), mcp.NewEmptySession(t.Context()))
+
+	var (
+		user = types.Message{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+		}
+		toolResult = types.Message{
+			Role:  "user",
+			Items: []types.CompletionItem{{ToolCallResult: &types.ToolCallResult{CallID: "call"}}},
+		}
+	)
+
+	sampling := func(req types.CompletionRequest) []string {
+		req.Agent = "a"
+		populated, _, err := a.populateRequest(ctx, config, &types.Execution{Request: req}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []string{populated.Temperature.String(), populated.TopP.String()}
+	}
+
+	autogold.Expect([]string{"1.2", "0.9"}).Equal(t, sampling(types.CompletionRequest{
+		Input: []types.Message{user},
+	}))
+	autogold.Expect([]string{"1.2", "0.9"}).Equal(t, sampling(types.CompletionRequest{
+		Input: []types.Message{user, toolResult},
+	}))
+	autogold.Expect([]string{"0.2", "0.9"}).Equal(t, sampling(types.CompletionRequest{
+		Input: []types.Message{user, toolResult, user},
+	}))
+	autogold.Expect([]string{"0.5", "0.5"}).Equal(t, sampling(types.CompletionRequest{
+		Input: []types.Message{user, user, user, user},
+	}))
+
+	// Values of the request are used as is.
+	autogold.Expect([]string{"0.7", "0.9"}).Equal(t, sampling(types.CompletionRequest{
+		Input:       []types.Message{user, user},
+		Temperature: number("0.7"),
+	}))
+}
@@ -0,0 +1,256 @@
+package agents
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+var (
+	defaultAllowedTags = []string{
+		"a", "b", "blockquote", "br", "code", "del", "details", "em", "h1", "h2", "h3", "h4", "h5", "h6", "hr",
+		"i", "img", "kbd", "li", "ol", "p", "pre", "s", "strong", "sub", "summary", "sup", "table", "tbody",
+		"td", "th", "thead", "tr", "ul",
+	}
+	// removedContentTags are removed together with their content, even if they are allowed.
+	removedContentTags = []string{"script", "style", "iframe", "object", "embed", "template", "noscript", "textarea", "title"}
+	allowedAttributes  = []string{"href", "src", "alt", "title"}
+	unsafeURLSchemes   = []string{"javascript:", "vbscript:", "data:", "file:"}
+
+	tagRegexp       = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)(\s[^<>]*|/)?>`)
+	autolinkRegexp  = regexp.MustCompile(`^<[a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*>`)
+	attributeRegexp = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	// referenceRegexp matches a link reference definition, whose destination can be on the next line.
+	referenceRegexp = regexp.MustCompile(`^ {0,3}\[[^\]\n]+\]:[ \t]*\n?[ \t]*(\S+)`)
+)
+
+// textSanitizer returns the sanitizer of the text the agent responds with, or nil if the agent has no
+// sanitize configured.
+func textSanitizer(agent types.Agent) progress.TextSanitizer {
+	if agent.Sanitize == nil {
+		return nil
+	}
+
+	allowed := agent.Sanitize.AllowedTags
+	if allowed == nil {
+		allowed = defaultAllowedTags
+	}
+	return func(text string) string {
+		return sanitizeMarkdown(text, allowed)
+	}
+}
+
+// sanitizeResponse sanitizes the text content of the response if the agent has sanitize configured.
+func sanitizeResponse(agent types.Agent, resp *types.CompletionResponse) {
+	sanitize := textSanitizer(agent)
+	if sanitize == nil || resp == nil {
+		return
+	}
+
+	for i, item := range resp.Output.Items {
+		if item.Content == nil || item.Content.Type != "text" {
+			continue
+		}
+		content := *item.Content
+		content.Text = sanitize(content.Text)
+		resp.Output.Items[i].Content = &content
+	}
+}
+
+// sanitizeMarkdown removes the HTML tags that are not allowed from markdown text and the attributes and
+// links that could run scripts. Code blocks and code spans are kept as is, markdown renderers escape them.
+func sanitizeMarkdown(text string, allowedTags []string) string {
+	var (
+		out         strings.Builder
+		lineStart   = true
+		fence       string
+		lowerText   = strings.ToLower(text)
+		allowedTag  = func(name string) bool { return slices.Contains(allowedTags, name) }
+		removedTags = func(name string) bool { return slices.Contains(removedContentTags, name) }
+	)
+
+	for i := 0; i < len(text); {
+		if lineStart {
+			line, _, _ := strings.Cut(text[i:], "\n")
+			trimmed := strings.TrimLeft(line, " ")
+			switch {
+			case fence != "":
+				if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+					fence = ""
+				}
+				i += copyLine(&out, text[i:])
+				continue
+			case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+				fence = trimmed[:3]
+				i += copyLine(&out, text[i:])
+				continue
+			case referenceRegexp.MatchString(text[i:]):
+				match := referenceRegexp.FindStringSubmatchIndex(text[i:])
+				out.WriteString(text[i : i+match[2]])
+				if unsafeURL(strings.TrimPrefix(text[i+match[2]:i+match[3]], "<")) {
+					out.WriteString("#")
+				} else {
+					out.WriteString(text[i+match[2] : i+match[3]])
+				}
+				i += match[3]
+				lineStart = false
+				continue
+			}
+		}
+
+		c := text[i]
+		lineStart = c == '\n'
+
+		switch {
+		case c == '`':
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			delimiter := text[i : i+n]
+			end := strings.Index(text[i+n:], delimiter)
+			if end < 0 {
+				out.WriteString(delimiter)
+				i += n
+				continue
+			}
+			end += i + 2*n
+			out.WriteString(text[i:end])
+			lineStart = strings.HasSuffix(text[i:end], "\n")
+			i = end
+		case strings.HasPrefix(text[i:], "<!--"):
+			end := strings.Index(text[i:], "-->")
+			if end < 0 {
+				return out.String()
+			}
+			i += end + len("-->")
+		case c == '<' && autolinkRegexp.MatchString(text[i:]):
+			link := autolinkRegexp.FindString(text[i:])
+			if !unsafeURL(link[1 : len(link)-1]) {
+				out.WriteString(link)
+			}
+			i += len(link)
+		case c == '<':
+			match := tagRegexp.FindStringSubmatch(text[i:])
+			if match == nil {
+				// A < before a letter, /, ! or ? could start a tag, like one whose > is missing, and one before
+				// another < could end up before a letter when the markup after it is removed, so those are
+				// escaped. Others, like in a < b, are text.
+				if i+1 == len(text) || startsTag(text[i+1]) || text[i+1] == '<' {
+					out.WriteString("&lt;")
+				} else {
+					out.WriteByte('<')
+				}
+				i++
+				continue
+			}
+			i += len(match[0])
+
+			closing, name, attributes := match[1] == "/", strings.ToLower(match[2]), match[3]
+			if removedTags(name) {
+				if !closing && !strings.HasSuffix(attributes, "/") {
+					end := strings.Index(lowerText[i:], "</"+name)
+					if end < 0 {
+						return out.String()
+					}
+					i += end
+					if gt := strings.IndexByte(text[i:], '>'); gt >= 0 {
+						i += gt + 1
+					} else {
+						i = len(text)
+					}
+				}
+				continue
+			}
+			if !allowedTag(name) {
+				continue
+			}
+
+			out.WriteByte('<')
+			if closing {
+				out.WriteByte('/')
+			}
+			out.WriteString(name)
+			if !closing {
+				out.WriteString(sanitizeAttributes(attributes))
+				if strings.HasSuffix(attributes, "/") {
+					out.WriteString(" /")
+				}
+			}
+			out.WriteByte('>')
+		case strings.HasPrefix(text[i:], "]("):
+			// The destination can follow whitespace and be in angle brackets
+			start := len(text) - len(strings.TrimLeft(text[i+2:], " \t\n"))
+			end := strings.IndexAny(text[start:], ") \t\n")
+			angled := strings.HasPrefix(text[start:], "<")
+			if angled {
+				end = strings.IndexByte(text[start:], '>') + 1
+			}
+			if end <= 0 || !unsafeURL(strings.TrimPrefix(text[start:start+end], "<")) {
+				out.WriteString("](")
+				i += 2
+				continue
+			}
+			out.WriteString("](#")
+			i = start + end
+			// The rest of an unsafe URL with parentheses, like javascript:alert(1), is removed up to the
+			// closing parenthesis of the link.
+			for depth := strings.Count(text[start:i], "("); !angled && depth > 0 && i < len(text) && text[i] == ')'; depth-- {
+				i++
+			}
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.String()
+}
+
+func startsTag(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '!' || c == '?'
+}
+
+func copyLine(out *strings.Builder, text string) int {
+	n := strings.IndexByte(text, '\n') + 1
+	if n == 0 {
+		n = len(text)
+	}
+	out.WriteString(text[:n])
+	return n
+}
+
+// sanitizeAttributes returns the allowed attributes with safe values, each with a leading space.
+func sanitizeAttributes(attributes string) string {
+	var out strings.Builder
+	for _, match := range attributeRegexp.FindAllStringSubmatch(attributes, -1) {
+		name := strings.ToLower(match[1])
+		if !slices.Contains(allowedAttributes, name) {
+			continue
+		}
+		value := html.UnescapeString(match[2] + match[3] + match[4])
+		if (name == "href" || name == "src") && unsafeURL(value) {
+			continue
+		}
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	return out.String()
+}
+
+// unsafeURL returns true if the URL uses a scheme that can run scripts or embed content. Whitespace and
+// control characters are ignored because browsers ignore them in schemes too.
+func unsafeURL(url string) bool {
+	url = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(url)))
+	for _, scheme := range unsafeURLSchemes {
+		if strings.HasPrefix(url, scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// streamingCompleter streams its deltas as the text of its response.
+type streamingCompleter struct {
+	deltas []string
+}
+
+func (s streamingCompleter) Complete(ctx context.Context, _ types.CompletionRequest, opts ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	token := complete.Complete(opts...).ProgressToken
+	for _, delta := range s.deltas {
+		progress.Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{ID: "item-1", Partial: true, Content: &mcp.Content{Type: "text", Text: delta}},
+		}, token)
+	}
+	return &types.CompletionResponse{
+		Output: types.Message{
+			ID:    "response",
+			Role:  "assistant",
+			Items: []types.CompletionItem{{ID: "item-1", Content: &mcp.Content{Type: "text", Text: strings.Join(s.deltas, "")}}},
+		},
+	}, nil
+}
+
+func TestSanitizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want autogold.Value
+	}{
+		{
+			name: "markdown",
+			text: "# Title\n\n**bold** _italic_ [link](https://example.com) ![image](https://example.com/cat.png)\n\n- a < b\n- b > a",
+			want: autogold.Expect(`# Title
+
+**bold** _italic_ [link](https://example.com) ![image](https://example.com/cat.png)
+
+- a < b
+- b > a`),
+		},
+		{
+			name: "disallowed tags",
+			text: `<div class="x">Hello <b onclick="steal()">world</b></div><br/>`,
+			want: autogold.Expect("Hello <b>world</b><br />"),
+		},
+		{
+			name: "script and style",
+			text: "before<script>alert('hi')</script> after <STYLE>body { display: none }</STYLE>end",
+			want: autogold.Expect("before after end"),
+		},
+		{
+			name: "unclosed script",
+			text: "before<script>alert('hi')",
+			want: autogold.Expect("before"),
+		},
+		{
+			name: "comments",
+			text: "visible<!-- <img src=x onerror=alert(1)> -->text",
+			want: autogold.Expect("visibletext"),
+		},
+		{
+			name: "attributes",
+			text: `<a href="https://example.com" target="_blank">ok</a> <a href="java	script:alert(1)">bad</a> <img src='x' onerror=alert(1) alt="a &quot;cat&quot;">`,
+			want: autogold.Expect(`<a href="https://example.com">ok</a> <a>bad</a> <img src="x" alt="a &#34;cat&#34;">`),
+		},
+		{
+			name: "markdown links",
+			text: "[click](javascript:alert(1)) [safe](https://example.com) ![x](data:text/html;base64,PHNjcmlwdD4=) <javascript:alert(1)> <https://example.com>",
+			want: autogold.Expect("[click](#) [safe](https://example.com) ![x](#)  <https://example.com>"),
+		},
+		{
+			name: "lone angle bracket",
+			text: "<<script>alert(1)</script> 1 <2 <-",
+			want: autogold.Expect("&lt; 1 <2 <-"),
+		},
+		{
+			name: "removed markup after angle bracket",
+			text: "<<script></script>img src=x onerror=alert(1)>",
+			want: autogold.Expect("&lt;img src=x onerror=alert(1)>"),
+		},
+		{
+			name: "comparisons",
+			text: "if a < b and `x <y` or `<b>` then 1<2",
+			want: autogold.Expect("if a < b and `x <y` or `<b>` then 1<2"),
+		},
+		{
+			name: "whitespace before destination",
+			text: "[a](  javascript:alert(1)) [b](\n\tjavascript:alert(1)) [c]( <javascript:alert(1)>) [d]( https://example.com)",
+			want: autogold.Expect("[a](#) [b](#) [c](#) [d]( https://example.com)"),
+		},
+		{
+			name: "reference definitions",
+			text: "[a] [b] [c]\n\n[a]: javascript:alert(1)\n  [b]:\n   <JavaScript:alert(1)> \"title\"\n[c]: https://example.com",
+			want: autogold.Expect(`[a] [b] [c]
+
+[a]: #
+  [b]:
+   # "title"
+[c]: https://example.com`),
+		},
+		{
+			name: "code",
+			text: "Use `<script>` tags like this:\n\n```html\n<script>alert(1)</script>\n```\n\n<script>alert(2)</script>done",
+			want: autogold.Expect("Use `<script>` tags like this:\n\n```html\n<script>alert(1)</script>\n```\n\ndone"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Equal(t, sanitizeMarkdown(tt.text, defaultAllowedTags))
+		})
+	}
+}
+
+func TestSanitizeResponse(t *testing.T) {
+	resp := &types.CompletionResponse{
+		Output: types.Message{
+			Items: []types.CompletionItem{
+				{Content: &mcp.Content{Type: "text", Text: "<p>Hi <span>there</span></p>"}},
+				{Content: &mcp.Content{Type: "image", Data: "<b>"}},
+			},
+		},
+	}
+
+	sanitizeResponse(types.Agent{}, resp)
+	autogold.Expect("<p>Hi <span>there</span></p>").Equal(t, resp.Output.Items[0].Content.Text)
+
+	sanitizeResponse(types.Agent{Sanitize: &types.AgentSanitize{AllowedTags: []string{"span"}}}, resp)
+	autogold.Expect("Hi <span>there</span>").Equal(t, resp.Output.Items[0].Content.Text)
+	autogold.Expect("<b>").Equal(t, resp.Output.Items[1].Content.Data)
+}
+
+func TestComplete_SanitizesStreamedText(t *testing.T) {
+	session := mcp.NewEmptySession(t.Context())
+	var streamed strings.Builder
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var req mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &req) == nil {
+			var completion types.CompletionProgress
+			if err := mcp.JSONCoerce(req.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Item.Content != nil {
+				streamed.WriteString(completion.Item.Content.Text)
+			}
+		}
+		return nil, nil
+	})
+
+	completer := streamingCompleter{deltas: []string{"Hi <scr", "ipt>alert(1)</scr", "ipt><b>there</b> a < b"}}
+	agents := New(completer, tools.NewToolsService(tools.Options{}))
+	config := types.Config{Agents: map[string]types.Agent{"a": {Sanitize: &types.AgentSanitize{}}}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	resp, err := agents.Complete(ctx, types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+		}},
+	}, types.CompletionOptions{ProgressToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("Hi <b>there</b> a < b").Equal(t, streamed.String())
+	autogold.Expect("Hi <b>there</b> a < b").Equal(t, resp.Output.Items[0].Content.Text)
+}
@@ -0,0 +1,84 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// toolCallCompleter calls the tools in order, one per request, and then responds with "done". It records
+// the tool choice of every request.
+type toolCallCompleter struct {
+	tools       []string
+	requests    int
+	toolChoices []string
+}
+
+func (c *toolCallCompleter) Complete(_ context.Context, req types.CompletionRequest, _ ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	c.requests++
+	c.toolChoices = append(c.toolChoices, req.ToolChoice)
+	if c.requests > len(c.tools) {
+		return &types.CompletionResponse{
+			Output: types.Message{
+				Role:  "assistant",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "done"}}},
+			},
+		}, nil
+	}
+
+	name := c.tools[c.requests-1]
+	return &types.CompletionResponse{
+		Output: types.Message{
+			Role: "assistant",
+			Items: []types.CompletionItem{{
+				ToolCall: &types.ToolCall{CallID: name + "-call", Name: name, Arguments: `{"text": "` + name + ` result"}`},
+			}},
+		},
+	}, nil
+}
+
+func TestComplete_StopTools(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+
+	complete := func(stopTools ...string) (*types.CompletionResponse, int) {
+		completer := &toolCallCompleter{tools: []string{"dump", "final"}}
+		config := types.Config{
+			Agents: map[string]types.Agent{
+				"a": {MCPServers: []string{"dump"}, StopTools: stopTools},
+			},
+			MCPServers: map[string]mcp.Server{"dump": {}},
+		}
+		ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+		resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+			Agent: "a",
+			Input: []types.Message{{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+			}},
+		}, types.CompletionOptions{Chat: new(bool)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, completer.requests
+	}
+
+	resp, requests := complete()
+	autogold.Expect(3).Equal(t, requests)
+	autogold.Expect("done").Equal(t, resp.Output.Items[0].Content.Text)
+
+	for _, stopTool := range []string{"final", "dump/final"} {
+		resp, requests = complete(stopTool)
+		autogold.Expect(2).Equal(t, requests)
+		autogold.Expect(1).Equal(t, len(resp.Output.Items))
+		autogold.Expect("final-call").Equal(t, resp.Output.Items[0].ToolCallResult.CallID)
+		autogold.Expect([]mcp.Content{{Type: "text", Text: "final result"}}).Equal(t, resp.Output.Items[0].ToolCallResult.Output.Content)
+	}
+}
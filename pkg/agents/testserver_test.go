@@ -0,0 +1,78 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// toolHandler handles the calls of a tool of a testServer.
+type toolHandler func(ctx context.Context, call mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// testServer is an MCP server for tests. It lists a tool with an object schema for each handler, in the
+// order of their names, and calls them with the handler of the tool name.
+type testServer struct {
+	calls map[string]toolHandler
+	// resources, if set, are the text contents of the resources of the server, by URI.
+	resources map[string]string
+}
+
+func (s testServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+			result := &mcp.InitializeResult{
+				ProtocolVersion: params.ProtocolVersion,
+				Capabilities: mcp.ServerCapabilities{
+					Tools: &mcp.ToolsServerCapability{},
+				},
+			}
+			if s.resources != nil {
+				result.Capabilities.Resources = &mcp.ResourcesServerCapability{}
+			}
+			return result, nil
+		})
+	case "notifications/initialized":
+	case "tools/list":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, _ mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+			var tools []mcp.Tool
+			for _, name := range slices.Sorted(maps.Keys(s.calls)) {
+				tools = append(tools, mcp.Tool{Name: name, InputSchema: json.RawMessage(`{"type": "object"}`)})
+			}
+			return &mcp.ListToolsResult{Tools: tools}, nil
+		})
+	case "tools/call":
+		mcp.Invoke(ctx, msg, func(ctx context.Context, _ mcp.Message, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			handler, ok := s.calls[call.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tool %s", call.Name)
+			}
+			return handler(ctx, call)
+		})
+	case "resources/read":
+		if s.resources == nil {
+			msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+			return
+		}
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, params mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []mcp.ResourceContent{{
+				URI:      params.URI,
+				MIMEType: "text/plain",
+				Blob:     base64.StdEncoding.EncodeToString([]byte(s.resources[params.URI])),
+			}}}, nil
+		})
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+// echoText returns the argument "text" of the call as the result.
+func echoText(_ context.Context, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, _ := call.Arguments["text"].(string)
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
@@ -11,7 +13,19 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
-func (a *Agents) toolCalls(ctx context.Context, config types.Config, run *types.Execution, opts []types.CompletionOptions) error {
+type pendingToolCall struct {
+	target     types.TargetMapping[types.TargetTool]
+	invocation tools.ToolCallInvocation
+	output     *types.Message
+}
+
+// toolCalls calls the tools the model asked for in the response of the run that are not done yet. checkpoint,
+// if not nil, is called after each result is recorded in the run.
+func (a *Agents) toolCalls(ctx context.Context, config types.Config, run *types.Execution, checkpoint func(), opts []types.CompletionOptions) {
+	var (
+		agent   = config.Agents[run.Request.GetAgent()]
+		pending []*pendingToolCall
+	)
 	for _, output := range run.Response.Output.Items {
 		functionCall := output.ToolCall
 
@@ -25,7 +39,14 @@ func (a *Agents) toolCalls(ctx context.Context, config types.Config, run *types.
 
 		targetServer, ok := run.ToolToMCPServer[functionCall.Name]
 		if !ok {
-			return fmt.Errorf("can not map tool %s to a MCP server", functionCall.Name)
+			// The model is told about the unknown tool instead of failing the turn, so it can correct itself
+			pending = append(pending, &pendingToolCall{
+				invocation: tools.ToolCallInvocation{
+					ToolCall: *functionCall,
+				},
+				output: errorResult(functionCall.CallID, fmt.Sprintf("Error calling %s: unknown tool", functionCall.Name)),
+			})
+			continue
 		}
 
 		if targetServer.Target.External {
@@ -34,33 +55,98 @@ func (a *Agents) toolCalls(ctx context.Context, config types.Config, run *types.
 			continue
 		}
 
-		callOutput, err := a.invoke(ctx, config, targetServer, tools.ToolCallInvocation{
-			MessageID: run.Response.Output.ID,
-			ItemID:    output.ID,
-			ToolCall:  *functionCall,
-		}, opts)
-		if err != nil {
-			return fmt.Errorf("failed to invoke tool %s on MCP server %s: %w", functionCall.Name, targetServer.MCPServer, err)
-		}
+		pending = append(pending, &pendingToolCall{
+			target: targetServer,
+			invocation: tools.ToolCallInvocation{
+				MessageID: run.Response.Output.ID,
+				ItemID:    output.ID,
+				ToolCall:  *functionCall,
+			},
+		})
+	}
+
+	// Each result is recorded and checkpointed as soon as it is in, so an interrupted turn can be resumed
+	// without calling the tools that are done again.
+	var lock sync.Mutex
+	record := func(call *pendingToolCall) {
+		lock.Lock()
+		defer lock.Unlock()
 
 		if run.ToolOutputs == nil {
 			run.ToolOutputs = make(map[string]types.ToolOutput)
 		}
-
-		run.ToolOutputs[functionCall.CallID] = types.ToolOutput{
-			Output: *callOutput,
+		run.ToolOutputs[call.invocation.ToolCall.CallID] = types.ToolOutput{
+			Output: *call.output,
 			Done:   true,
 		}
+		if checkpoint != nil {
+			checkpoint()
+		}
 	}
 
-	if len(run.ToolOutputs) == 0 {
+	// Every call gets a result, failed calls an error result, so the model can reason about the calls that
+	// succeeded when others failed.
+	if len(pending) > 1 && mcp.FeatureEnabled(ctx, featureParallelTools) {
+		var wg sync.WaitGroup
+		for _, call := range pending {
+			if call.output != nil {
+				record(call)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				call.output = a.invoke(ctx, config, agent, call.target, call.invocation, opts)
+				record(call)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for _, call := range pending {
+			if call.output == nil {
+				call.output = a.invoke(ctx, config, agent, call.target, call.invocation, opts)
+			}
+			record(call)
+		}
+	}
+
+	if len(run.ToolOutputs) == 0 || len(stopToolResults(agent, run)) > 0 {
 		run.Done = true
 	}
+}
+
+// stopToolResults returns the results of the calls in the response of the run to the stop tools of the agent.
+func stopToolResults(agent types.Agent, run *types.Execution) (result []types.CompletionItem) {
+	if len(agent.StopTools) == 0 || run.Response == nil {
+		return nil
+	}
+
+	for _, output := range run.Response.Output.Items {
+		if output.ToolCall == nil {
+			continue
+		}
+
+		target := run.ToolToMCPServer[output.ToolCall.Name]
+		if !slices.Contains(agent.StopTools, output.ToolCall.Name) &&
+			!slices.Contains(agent.StopTools, target.MCPServer+"/"+target.TargetName) {
+			continue
+		}
+
+		toolOutput, ok := run.ToolOutputs[output.ToolCall.CallID]
+		if !ok || !toolOutput.Done {
+			continue
+		}
+		for _, item := range toolOutput.Output.Items {
+			if item.ToolCallResult != nil {
+				result = append(result, item)
+			}
+		}
+	}
 
-	return nil
+	return result
 }
 
-func (a *Agents) invoke(ctx context.Context, config types.Config, target types.TargetMapping[types.TargetTool], funcCall tools.ToolCallInvocation, opts []types.CompletionOptions) (*types.Message, error) {
+func (a *Agents) invoke(ctx context.Context, config types.Config, agent types.Agent, target types.TargetMapping[types.TargetTool], funcCall tools.ToolCallInvocation, opts []types.CompletionOptions) *types.Message {
 	var (
 		data map[string]any
 	)
@@ -68,34 +154,71 @@ func (a *Agents) invoke(ctx context.Context, config types.Config, target types.T
 	if funcCall.ToolCall.Arguments != "" {
 		data = make(map[string]any)
 		if err := json.Unmarshal([]byte(funcCall.ToolCall.Arguments), &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal function call arguments: %w", err)
+			return errorResult(funcCall.ToolCall.CallID, fmt.Sprintf("Error calling %s: invalid arguments: %v", target.TargetName, err))
 		}
 	}
 
 	response, err := a.registry.Call(ctx, target.MCPServer, target.TargetName, data, tools.CallOptions{
 		ProgressToken:      complete.Complete(opts...).ProgressToken,
+		Target:             target.Target,
 		ToolCallInvocation: &funcCall,
 	})
 	if err != nil {
-		response = &types.CallResult{
-			Content: []mcp.Content{
-				{
-					Type: "text",
-					Text: fmt.Sprintf("Error calling %s: %v", target.TargetName, err),
-				},
-			},
-			IsError: true,
+		return errorResult(funcCall.ToolCall.CallID, fmt.Sprintf("Error calling %s: %v", target.TargetName, err))
+	}
+	a.inlineResourceLinks(ctx, agent, target.MCPServer, response)
+	a.storeLargeResults(ctx, config, agent, target.TargetName, response)
+	if citations := response.Citations(); len(citations) > 0 {
+		// The model only sees the text of the result, so the sources are listed for it to cite.
+		response.Content = append(response.Content, mcp.Content{
+			Type: "text",
+			Text: types.CitationsText(citations),
+		})
+	}
+	return toolResult(funcCall.ToolCall.CallID, *response)
+}
+
+// runCitations returns the citations of the tool results of the run, in the order the tools were called.
+func runCitations(run *types.Execution) (result []types.Citation) {
+	if run.Response == nil {
+		return nil
+	}
+	for _, output := range run.Response.Output.Items {
+		if output.ToolCall == nil {
+			continue
+		}
+		for _, item := range run.ToolOutputs[output.ToolCall.CallID].Output.Items {
+			if item.ToolCallResult != nil {
+				result = types.AppendCitations(result, item.ToolCallResult.Output.Citations()...)
+			}
 		}
 	}
+	return result
+}
+
+func toolResult(callID string, output types.CallResult) *types.Message {
 	return &types.Message{
 		Role: "user",
 		Items: []types.CompletionItem{
 			{
 				ToolCallResult: &types.ToolCallResult{
-					CallID: funcCall.ToolCall.CallID,
-					Output: *response,
+					CallID: callID,
+					Output: output,
 				},
 			},
 		},
-	}, nil
+	}
+}
+
+// errorResult is the result of a tool call that failed, which is passed to the model like any other result.
+func errorResult(callID, text string) *types.Message {
+	return toolResult(callID, types.CallResult{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+		IsError: true,
+	})
 }
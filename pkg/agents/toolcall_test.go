@@ -0,0 +1,117 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// partialServer has a tool that echoes its text and one that always fails.
+func partialServer() testServer {
+	return testServer{calls: map[string]toolHandler{
+		"echo": echoText,
+		"fail": func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return nil, fmt.Errorf("tool failed")
+		},
+	}}
+}
+
+// multiToolCallCompleter makes all tool calls in its first response and records the tool results it is
+// sent in the next request.
+type multiToolCallCompleter struct {
+	calls   []types.ToolCall
+	results map[string]types.CallResult
+}
+
+func (c *multiToolCallCompleter) Complete(_ context.Context, req types.CompletionRequest, _ ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	results := map[string]types.CallResult{}
+	for _, msg := range req.Input {
+		for _, item := range msg.Items {
+			if item.ToolCallResult != nil {
+				results[item.ToolCallResult.CallID] = item.ToolCallResult.Output
+			}
+		}
+	}
+	if len(results) > 0 {
+		c.results = results
+		return &types.CompletionResponse{
+			Output: types.Message{
+				Role:  "assistant",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "done"}}},
+			},
+		}, nil
+	}
+
+	var items []types.CompletionItem
+	for _, call := range c.calls {
+		items = append(items, types.CompletionItem{ToolCall: &call})
+	}
+	return &types.CompletionResponse{
+		Output: types.Message{
+			Role:  "assistant",
+			Items: items,
+		},
+	}, nil
+}
+
+func TestToolCalls_PartialFailure(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("partial", func(string) mcp.MessageHandler {
+		return partialServer()
+	})
+
+	for _, parallel := range []string{"true", "false"} {
+		t.Run("parallel="+parallel, func(t *testing.T) {
+			completer := &multiToolCallCompleter{calls: []types.ToolCall{
+				{CallID: "echo-call", Name: "echo", Arguments: `{"text": "hello"}`},
+				{CallID: "fail-call", Name: "fail", Arguments: `{}`},
+				{CallID: "invalid-call", Name: "echo", Arguments: `{"text":`},
+				{CallID: "unknown-call", Name: "unknown", Arguments: `{}`},
+			}}
+			config := types.Config{
+				Agents: map[string]types.Agent{
+					"a": {MCPServers: []string{"partial"}},
+				},
+				MCPServers: map[string]mcp.Server{"partial": {}},
+			}
+			session := mcp.NewEmptySession(t.Context())
+			session.Set(mcp.SessionEnvMapKey, map[string]string{
+				mcp.FeatureEnvKey(featureParallelTools): parallel,
+			})
+			ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+			resp, err := New(completer, registry).Complete(ctx, types.CompletionRequest{
+				Agent: "a",
+				Input: []types.Message{{
+					Role:  "user",
+					Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+				}},
+			}, types.CompletionOptions{Chat: new(bool)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			autogold.Expect("done").Equal(t, resp.Output.Items[0].Content.Text)
+
+			autogold.Expect(map[string]types.CallResult{
+				"echo-call": {Content: []mcp.Content{{Type: "text", Text: "hello"}}},
+				"fail-call": {
+					IsError: true,
+					Content: []mcp.Content{{Type: "text", Text: "Error calling fail: error from server: JSON RPC internal error"}},
+				},
+				"invalid-call": {
+					IsError: true,
+					Content: []mcp.Content{{Type: "text", Text: "Error calling echo: invalid arguments: unexpected end of JSON input"}},
+				},
+				"unknown-call": {
+					IsError: true,
+					Content: []mcp.Content{{Type: "text", Text: "Error calling unknown: unknown tool"}},
+				},
+			}).Equal(t, completer.results)
+		})
+	}
+}
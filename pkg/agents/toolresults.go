@@ -0,0 +1,167 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/servers/resources"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	resourcesServer = "nanobot.resources"
+	// readResourceTool is added to agents that store large tool results, so they can read them.
+	readResourceTool = resourcesServer + "/read_resource"
+	// maxToolResultPreview is the number of bytes of a stored tool result that are kept inline.
+	maxToolResultPreview = 1000
+)
+
+// ResourceCreator stores content as a resource of the session in ctx.
+type ResourceCreator interface {
+	CreateResource(ctx context.Context, params resources.CreateArtifactParams) (*mcp.Resource, error)
+}
+
+// SetResourceCreator enables storing large tool results as resources, see types.Agent.MaxToolResultSize.
+func (a *Agents) SetResourceCreator(resources ResourceCreator) {
+	a.resources = resources
+}
+
+// storesLargeResults returns true if large tool results of the agent are stored as resources, which requires
+// the resources server to read them.
+func (a *Agents) storesLargeResults(config types.Config, agent types.Agent) bool {
+	_, ok := config.MCPServers[resourcesServer]
+	return ok && a.resources != nil && agent.MaxToolResultSize > 0
+}
+
+// storeLargeResults replaces the text content of the tool result that is larger than the maxToolResultSize
+// of the agent with the start of the text and a link to a resource with all of it. If the resource can not
+// be created the content is kept as is.
+func (a *Agents) storeLargeResults(ctx context.Context, config types.Config, agent types.Agent, toolName string, result *types.CallResult) {
+	if !a.storesLargeResults(config, agent) {
+		return
+	}
+
+	content := make([]mcp.Content, 0, len(result.Content))
+	for _, c := range result.Content {
+		if c.Type != "text" || len(c.Text) <= agent.MaxToolResultSize {
+			content = append(content, c)
+			continue
+		}
+
+		resource, err := a.resources.CreateResource(ctx, resources.CreateArtifactParams{
+			Name:        toolName + " result",
+			Description: fmt.Sprintf("The result of calling %s", toolName),
+			Blob:        base64.StdEncoding.EncodeToString([]byte(c.Text)),
+			MimeType:    "text/plain",
+		})
+		if err != nil {
+			log.Errorf(ctx, "failed to store result of %s as a resource: %v", toolName, err)
+			content = append(content, c)
+			continue
+		}
+
+		n := min(maxToolResultPreview, agent.MaxToolResultSize)
+		for n > 0 && !utf8.RuneStart(c.Text[n]) {
+			n--
+		}
+		preview := c.Text[:n]
+
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("The result is %d bytes, too large to include, and was stored as the resource %s. "+
+				"Use the read_resource tool to read more of it. It starts with:\n\n%s", len(c.Text), resource.URI, preview),
+			Meta: c.Meta,
+		}, mcp.Content{
+			Type:        "resource_link",
+			URI:         resource.URI,
+			Name:        resource.Name,
+			Description: resource.Description,
+			MIMEType:    resource.MimeType,
+		})
+	}
+	result.Content = content
+}
+
+// inlineResourceLinks replaces the resource links of the tool result with the content of the resource, if it is
+// text or an image of at most inlineResourceLinks bytes of the agent. The resources are read from the server of
+// the tool, or nanobot.resources for nanobot:// URIs. Links that can not be read or are too large are kept.
+func (a *Agents) inlineResourceLinks(ctx context.Context, agent types.Agent, server string, result *types.CallResult) {
+	if agent.InlineResourceLinks <= 0 {
+		return
+	}
+
+	content := make([]mcp.Content, 0, len(result.Content))
+	for _, c := range result.Content {
+		if c.Type != "resource_link" || c.URI == "" {
+			content = append(content, c)
+			continue
+		}
+
+		inlined, err := a.readResourceLink(ctx, server, c, agent.InlineResourceLinks)
+		if err != nil {
+			log.Errorf(ctx, "failed to read resource link %s: %v", c.URI, err)
+		}
+		if len(inlined) == 0 {
+			content = append(content, c)
+			continue
+		}
+		content = append(content, inlined...)
+	}
+	result.Content = content
+}
+
+// readResourceLink returns the contents of the linked resource as text and image content. Nothing is returned if
+// the resource is larger than maxSize bytes or has content that can not be inlined.
+func (a *Agents) readResourceLink(ctx context.Context, server string, link mcp.Content, maxSize int) ([]mcp.Content, error) {
+	if strings.HasPrefix(link.URI, "nanobot://") {
+		server = resourcesServer
+	}
+
+	client, err := a.registry.GetClient(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := client.ReadResource(ctx, link.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		size     int
+		contents []mcp.Content
+	)
+	for _, rc := range resource.Contents {
+		mimeType := rc.MIMEType
+		if mimeType == "" {
+			mimeType = link.MIMEType
+		}
+
+		if rc.Blob == "" {
+			size += len(rc.Text)
+			contents = append(contents, mcp.Content{Type: "text", Text: rc.Text})
+		} else if _, ok := types.ImageMimeTypes[mimeType]; ok {
+			size += base64.StdEncoding.DecodedLen(len(rc.Blob))
+			contents = append(contents, mcp.Content{Type: "image", Data: rc.Blob, MIMEType: mimeType})
+		} else if _, ok := types.TextMimeTypes[mimeType]; ok {
+			data, err := base64.StdEncoding.DecodeString(rc.Blob)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blob of %s: %w", rc.URI, err)
+			}
+			size += len(data)
+			contents = append(contents, mcp.Content{Type: "text", Text: string(data)})
+		} else {
+			return nil, nil
+		}
+
+		if size > maxSize {
+			return nil, nil
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,135 @@
+package agents
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/servers/resources"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// dumpServer has the tools dump and final, which return their argument "text" as the result.
+func dumpServer() testServer {
+	return testServer{calls: map[string]toolHandler{
+		"dump":  echoText,
+		"final": echoText,
+	}}
+}
+
+func TestInvoke_LargeResult(t *testing.T) {
+	store, err := resources.NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourcesServer := resources.NewServer(store)
+
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("dump", func(string) mcp.MessageHandler {
+		return dumpServer()
+	})
+	registry.AddServer("nanobot.resources", func(string) mcp.MessageHandler {
+		return resources.NewServer(store)
+	})
+
+	a := New(nil, registry)
+	a.SetResourceCreator(resourcesServer)
+
+	agent := types.Agent{MaxToolResultSize: 20}
+	config := types.Config{
+		Agents:     map[string]types.Agent{"a": agent},
+		MCPServers: map[string]mcp.Server{"nanobot.resources": {}},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	session.Set(types.AccountIDSessionKey, "account")
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	invoke := func(text string) []mcp.Content {
+		msg := a.invoke(ctx, config, agent, types.TargetMapping[types.TargetTool]{
+			MCPServer:  "dump",
+			TargetName: "dump",
+		}, tools.ToolCallInvocation{
+			ToolCall: types.ToolCall{CallID: "call", Name: "dump", Arguments: `{"text": "` + text + `"}`},
+		}, nil)
+		return msg.Items[0].ToolCallResult.Output.Content
+	}
+
+	autogold.Expect([]mcp.Content{{Type: "text", Text: "small result"}}).Equal(t, invoke("small result"))
+
+	large := strings.Repeat("large result ", 10)
+	content := invoke(large)
+	autogold.Expect(2).Equal(t, len(content))
+
+	link := content[1]
+	autogold.Expect("resource_link").Equal(t, link.Type)
+	autogold.Expect(true).Equal(t, strings.HasPrefix(link.URI, "nanobot://resource/"))
+	autogold.Expect("The result is 130 bytes, too large to include, and was stored as the resource "+link.URI+
+		". Use the read_resource tool to read more of it. It starts with:\n\nlarge result large r").Equal(t, content[0].Text)
+
+	result, err := registry.Call(ctx, "nanobot.resources", "read_resource", map[string]any{"uri": link.URI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(large).Equal(t, result.Content[0].Text)
+
+	mappings, err := a.addTools(ctx, &types.CompletionRequest{}, &agent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("nanobot.resources").Equal(t, mappings["read_resource"].MCPServer)
+}
+
+// linkServer has the tool links, which returns links to the resources of the server.
+func linkServer(resources map[string]string) testServer {
+	return testServer{
+		calls: map[string]toolHandler{
+			"links": func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				var content []mcp.Content
+				for _, uri := range slices.Sorted(maps.Keys(resources)) {
+					content = append(content, mcp.Content{Type: "resource_link", URI: uri, MIMEType: "text/plain"})
+				}
+				return &mcp.CallToolResult{Content: content}, nil
+			},
+		},
+		resources: resources,
+	}
+}
+
+func TestInvoke_InlineResourceLinks(t *testing.T) {
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("links", func(string) mcp.MessageHandler {
+		return linkServer(map[string]string{
+			"file:///large.txt": strings.Repeat("large resource ", 10),
+			"file:///small.txt": "small resource",
+		})
+	})
+
+	a := New(nil, registry)
+	agent := types.Agent{InlineResourceLinks: 20}
+	config := types.Config{Agents: map[string]types.Agent{"a": agent}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	msg := a.invoke(ctx, config, agent, types.TargetMapping[types.TargetTool]{
+		MCPServer:  "links",
+		TargetName: "links",
+	}, tools.ToolCallInvocation{
+		ToolCall: types.ToolCall{CallID: "call", Name: "links"},
+	}, nil)
+	autogold.Expect([]mcp.Content{
+		{
+			Type:     "resource_link",
+			URI:      "file:///large.txt",
+			MIMEType: "text/plain",
+		},
+		{
+			Type: "text",
+			Text: "small resource",
+		},
+	}).Equal(t, msg.Items[0].ToolCallResult.Output.Content)
+}
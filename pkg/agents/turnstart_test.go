@@ -0,0 +1,67 @@
+package agents
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestComplete_RecordTurns(t *testing.T) {
+	var (
+		steps    atomic.Int64
+		crashing atomic.Bool
+	)
+	registry := tools.NewToolsService(tools.Options{})
+	registry.AddServer("crash", func(string) mcp.MessageHandler {
+		return crashServer(&steps, &crashing, nil, nil)
+	})
+
+	completer := &multiToolCallCompleter{calls: []types.ToolCall{
+		{CallID: "step-call", Name: "step", Arguments: `{}`},
+	}}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MCPServers: []string{"crash"}},
+		},
+		MCPServers: map[string]mcp.Server{"crash": {}},
+	}
+	agents := New(completer, registry)
+	agents.SetRecordTurns(true)
+
+	session := mcp.NewEmptySession(t.Context())
+	_, err := agents.Complete(mcp.WithSession(types.WithConfig(t.Context(), config), session), types.CompletionRequest{
+		Agent: "a",
+		Input: []types.Message{{
+			Role:  "user",
+			Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "go"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after types.Execution
+	if !session.Get(types.TurnStartExecutionKey, &before) {
+		t.Fatal("the start of the turn was not recorded")
+	}
+	session.Get(types.PreviousExecutionKey, &after)
+
+	changes, err := types.DiffExecutions(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Op+" "+change.Path)
+	}
+	autogold.Expect([]string{
+		"add /done", "add /populatedRequest", "add /request/agent",
+		"add /request/inputAsToolResult",
+		"add /response",
+		"add /toolToMCPServer",
+	}).Equal(t, paths)
+}
@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+type schemaNode struct {
+	Type        any                    `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*schemaNode `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *schemaNode            `json:"items,omitempty"`
+	Enum        []any                  `json:"enum,omitempty"`
+	Default     any                    `json:"default,omitempty"`
+}
+
+func (s *schemaNode) typeName() string {
+	switch t := s.Type.(type) {
+	case string:
+		if t == "array" && s.Items != nil {
+			return s.Items.typeName() + "[]"
+		}
+		return t
+	case []any:
+		var types []string
+		for _, v := range t {
+			types = append(types, fmt.Sprint(v))
+		}
+		return strings.Join(types, "|")
+	}
+	if len(s.Properties) > 0 {
+		return "object"
+	}
+	return "any"
+}
+
+// PrintTool writes a human-readable description of the tool, its arguments and annotations.
+func PrintTool(output io.Writer, server string, tool mcp.Tool) error {
+	name := tool.Name
+	if server != "" && server != tool.Name {
+		name = server + "/" + tool.Name
+	}
+	_, _ = fmt.Fprintf(output, "Name: %s\n", name)
+	if tool.Title != "" {
+		_, _ = fmt.Fprintf(output, "Title: %s\n", tool.Title)
+	}
+	if tool.Description != "" {
+		_, _ = fmt.Fprintf(output, "Description: %s\n", strings.TrimSpace(tool.Description))
+	}
+
+	if len(tool.InputSchema) > 0 {
+		var schema schemaNode
+		if err := json.Unmarshal(tool.InputSchema, &schema); err != nil {
+			return fmt.Errorf("failed to parse input schema for %s: %w", name, err)
+		}
+		_, _ = fmt.Fprintln(output, "Arguments:")
+		if len(schema.Properties) == 0 {
+			_, _ = fmt.Fprintln(output, "  (none)")
+		}
+		printProperties(output, &schema, "  ")
+	}
+
+	if a := tool.Annotations; a != nil {
+		_, _ = fmt.Fprintln(output, "Annotations:")
+		_, _ = fmt.Fprintf(output, "  readOnly: %v\n", a.ReadOnlyHint)
+		_, _ = fmt.Fprintf(output, "  destructive: %v\n", a.IsDestructive())
+		_, _ = fmt.Fprintf(output, "  idempotent: %v\n", a.IdempotentHint)
+		_, _ = fmt.Fprintf(output, "  openWorld: %v\n", a.IsOpenWorld())
+	}
+
+	return nil
+}
+
+func printProperties(output io.Writer, schema *schemaNode, indent string) {
+	for _, key := range slices.Sorted(maps.Keys(schema.Properties)) {
+		prop := schema.Properties[key]
+		if prop == nil {
+			continue
+		}
+
+		line := fmt.Sprintf("%s%s (%s", indent, key, prop.typeName())
+		if slices.Contains(schema.Required, key) {
+			line += ", required"
+		}
+		line += ")"
+		if prop.Description != "" {
+			line += ": " + strings.TrimSpace(prop.Description)
+		}
+		if len(prop.Enum) > 0 {
+			var values []string
+			for _, v := range prop.Enum {
+				values = append(values, fmt.Sprint(v))
+			}
+			line += " [one of: " + strings.Join(values, ", ") + "]"
+		}
+		if prop.Default != nil {
+			line += fmt.Sprintf(" [default: %v]", prop.Default)
+		}
+		_, _ = fmt.Fprintln(output, line)
+
+		if len(prop.Properties) > 0 {
+			printProperties(output, prop, indent+"    ")
+		} else if prop.Items != nil && len(prop.Items.Properties) > 0 {
+			printProperties(output, prop.Items, indent+"    ")
+		}
+	}
+}
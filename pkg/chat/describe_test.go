@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func TestPrintTool_NestedSchema(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := PrintTool(&buf, "github", mcp.Tool{
+		Name:        "create_issue",
+		Description: "Create a new issue",
+		InputSchema: []byte(`{
+			"type": "object",
+			"required": ["repo", "title"],
+			"properties": {
+				"repo": {"type": "string", "description": "owner/name of the repository"},
+				"title": {"type": "string"},
+				"labels": {"type": "array", "items": {"type": "string"}},
+				"state": {"type": "string", "enum": ["open", "closed"], "default": "open"},
+				"assignee": {
+					"type": "object",
+					"description": "User to assign",
+					"required": ["login"],
+					"properties": {
+						"login": {"type": "string"},
+						"team": {"type": "object", "properties": {"id": {"type": "integer"}}}
+					}
+				}
+			}
+		}`),
+		Annotations: &mcp.ToolAnnotations{
+			IdempotentHint: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(`Name: github/create_issue
+Description: Create a new issue
+Arguments:
+  assignee (object): User to assign
+      login (string, required)
+      team (object)
+          id (integer)
+  labels (string[])
+  repo (string, required): owner/name of the repository
+  state (string) [one of: open, closed] [default: open]
+  title (string, required)
+Annotations:
+  readOnly: false
+  destructive: true
+  idempotent: true
+  openWorld: true
+`).Equal(t, buf.String())
+}
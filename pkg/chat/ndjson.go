@@ -0,0 +1,89 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// Event is a single line of newline-delimited JSON written by NDJSONWriter.
+type Event struct {
+	// Type is one of "tool_call", "tool_result", "content", "reasoning", "progress" or "result"
+	Type     string                    `json:"type"`
+	Progress *types.CompletionProgress `json:"progress,omitempty"`
+	Message  string                    `json:"message,omitempty"`
+	Result   *mcp.CallToolResult       `json:"result,omitempty"`
+}
+
+type NDJSONWriter struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func NewNDJSONWriter(out io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{
+		out: out,
+	}
+}
+
+// Filter returns a session message filter that writes progress notifications as events and
+// swallows them so they are not sent any further.
+func (n *NDJSONWriter) Filter(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+	if msg.Method != "notifications/progress" {
+		return msg, nil
+	}
+
+	var payload struct {
+		Message string `json:"message,omitempty"`
+		Meta    struct {
+			Progress *types.CompletionProgress `json:"ai.nanobot.progress/completion,omitempty"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(msg.Params, &payload); err != nil {
+		return nil, nil
+	}
+
+	event := Event{
+		Type:     "progress",
+		Progress: payload.Meta.Progress,
+		Message:  payload.Message,
+	}
+	if item := payload.Meta.Progress; item != nil {
+		switch {
+		case item.Item.ToolCallResult != nil:
+			event.Type = "tool_result"
+		case item.Item.ToolCall != nil:
+			event.Type = "tool_call"
+		case item.Item.Reasoning != nil:
+			event.Type = "reasoning"
+		case item.Item.Content != nil:
+			event.Type = "content"
+		}
+	}
+
+	return nil, n.Write(event)
+}
+
+// WriteResult writes the final result of a call as an event.
+func (n *NDJSONWriter) WriteResult(result *mcp.CallToolResult) error {
+	return n.Write(Event{
+		Type:   "result",
+		Result: result,
+	})
+}
+
+func (n *NDJSONWriter) Write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	_, err = n.out.Write(append(data, '\n'))
+	return err
+}
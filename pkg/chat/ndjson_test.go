@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestNDJSONWriter_ToolCall(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		out     = NewNDJSONWriter(&buf)
+		session = mcp.NewEmptySession(t.Context())
+		ctx     = session.Context()
+		tc      = types.ToolCall{
+			CallID:    "call1",
+			Name:      "read",
+			Arguments: `{"path":"a.txt"}`,
+		}
+	)
+
+	defer session.AddFilter(out.Filter)()
+
+	for _, item := range []types.CompletionItem{
+		{ID: "1", ToolCall: &tc, HasMore: true},
+		{ID: "1", ToolCall: &tc, ToolCallResult: &types.ToolCallResult{
+			CallID: "call1",
+			Output: types.CallResult{Content: []mcp.Content{{Type: "text", Text: "hello"}}},
+		}},
+	} {
+		if err := session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+			ProgressToken: "token",
+			Meta: map[string]any{
+				types.CompletionProgressMetaKey: types.CompletionProgress{
+					MessageID: "m1",
+					Item:      item,
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Anything other than progress is not written
+	if err := session.SendPayload(ctx, "notifications/message", map[string]any{}); err == nil {
+		t.Fatal("expected error sending on empty session")
+	}
+
+	if err := out.WriteResult(&mcp.CallToolResult{
+		Content: []mcp.Content{{Type: "text", Text: "hello"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(`{"type":"tool_call","progress":{"messageID":"m1","item":{"id":"1","hasMore":true,"type":"tool","arguments":"{\"path\":\"a.txt\"}","callID":"call1","name":"read"}}}
+{"type":"tool_result","progress":{"messageID":"m1","item":{"id":"1","type":"tool","output":{"content":[{"type":"text","text":"hello"}]},"arguments":"{\"path\":\"a.txt\"}","callID":"call1","name":"read"}}}
+{"type":"result","result":{"isError":false,"content":[{"type":"text","text":"hello"}]}}
+`).Equal(t, buf.String())
+}
@@ -0,0 +1,271 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+)
+
+const (
+	// UnitCharacters measures chunks in characters (runes).
+	UnitCharacters = "characters"
+	// UnitTokens measures chunks in estimated tokens.
+	UnitTokens = "tokens"
+
+	// charactersPerToken is the average number of characters per token used to estimate token counts.
+	charactersPerToken = 4
+)
+
+type Options struct {
+	// Size is the maximum size of a chunk, in Unit. Defaults to 1000.
+	Size int
+	// Overlap is the maximum size of the text at the end of a chunk that is repeated at the start of the
+	// next chunk, in Unit. Overlapping text always starts at a sentence boundary.
+	Overlap int
+	// Unit is either "characters" (the default) or "tokens".
+	Unit string
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.Size = complete.Last(o.Size, other.Size)
+	result.Overlap = complete.Last(o.Overlap, other.Overlap)
+	result.Unit = complete.Last(o.Unit, other.Unit)
+	return
+}
+
+func (o Options) Complete() Options {
+	if o.Size == 0 {
+		o.Size = 1000
+	}
+	if o.Unit == "" {
+		o.Unit = UnitCharacters
+	}
+	return o
+}
+
+type Chunk struct {
+	// ID is derived from the document ID and the position of the chunk, so chunking the same document
+	// again yields the same IDs.
+	ID string `json:"id"`
+	// Index is the position of the chunk in the document, starting at 0.
+	Index int `json:"index"`
+	// Start and End are the byte offsets of Text in the document.
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// segment is a span of the text that should not be split, usually a sentence including the whitespace
+// after it.
+type segment struct {
+	start, end int
+	// paragraph is true if the segment ends a paragraph.
+	paragraph bool
+}
+
+// Split splits text into chunks no larger than the configured size. Chunks end at paragraph boundaries
+// when that does not make them less than half the size, otherwise at sentence boundaries. Only
+// sentences that are larger than a chunk are split between words, and words larger than a chunk
+// between characters. If documentID is empty, it is derived from the text.
+func Split(documentID, text string, opts ...Options) ([]Chunk, error) {
+	opt := complete.Complete(opts...)
+	if opt.Size < 0 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", opt.Size)
+	}
+	if opt.Overlap < 0 || opt.Overlap >= opt.Size {
+		return nil, fmt.Errorf("chunk overlap must be between 0 and the chunk size %d, got %d", opt.Size, opt.Overlap)
+	}
+	if opt.Unit != UnitCharacters && opt.Unit != UnitTokens {
+		return nil, fmt.Errorf("invalid chunk unit %q, must be %s or %s", opt.Unit, UnitCharacters, UnitTokens)
+	}
+
+	if documentID == "" {
+		hash := sha256.Sum256([]byte(text))
+		documentID = hex.EncodeToString(hash[:])[:12]
+	}
+
+	var (
+		measure = func(start, end int) int {
+			return size(opt.Unit, strings.TrimSpace(text[start:end]))
+		}
+		pieces = splitLarge(text, segments(text), opt, measure)
+		result []Chunk
+	)
+
+	for i := 0; i < len(pieces); {
+		j := i
+		for j+1 < len(pieces) && measure(pieces[i].start, pieces[j+1].end) <= opt.Size {
+			j++
+		}
+
+		if j < len(pieces)-1 && !pieces[j].paragraph {
+			for k := j - 1; k >= i; k-- {
+				if pieces[k].paragraph && measure(pieces[i].start, pieces[k].end)*2 >= opt.Size {
+					j = k
+					break
+				}
+			}
+		}
+
+		if c, ok := newChunk(documentID, len(result), text, pieces[i].start, pieces[j].end); ok {
+			result = append(result, c)
+		}
+
+		if j == len(pieces)-1 {
+			break
+		}
+
+		next := j + 1
+		for k := j; k > i; k-- {
+			if measure(pieces[k].start, pieces[j].end) > opt.Overlap ||
+				measure(pieces[k].start, pieces[j+1].end) > opt.Size {
+				break
+			}
+			next = k
+		}
+		i = next
+	}
+
+	return result, nil
+}
+
+func newChunk(documentID string, index int, text string, start, end int) (Chunk, bool) {
+	for start < end {
+		r, n := utf8.DecodeRuneInString(text[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		start += n
+	}
+	for end > start {
+		r, n := utf8.DecodeLastRuneInString(text[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		end -= n
+	}
+	if start == end {
+		return Chunk{}, false
+	}
+	return Chunk{
+		ID:    documentID + "#" + strconv.Itoa(index),
+		Index: index,
+		Start: start,
+		End:   end,
+		Text:  text[start:end],
+	}, true
+}
+
+func size(unit, text string) int {
+	n := utf8.RuneCountInString(text)
+	if unit == UnitTokens {
+		return (n + charactersPerToken - 1) / charactersPerToken
+	}
+	return n
+}
+
+// segments splits text into sentences. A sentence ends after a ".", "!" or "?" followed by whitespace, or
+// at a blank line, and includes the whitespace that follows it.
+func segments(text string) (result []segment) {
+	start := 0
+	for i := 0; i < len(text); {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		if r != '.' && r != '!' && r != '?' && r != '\n' {
+			i += n
+			continue
+		}
+
+		end := i + n
+		if r == '\n' {
+			end = i
+		}
+		newlines := 0
+		for end < len(text) {
+			r, n := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			if r == '\n' {
+				newlines++
+			}
+			end += n
+		}
+
+		switch {
+		case newlines >= 2:
+			result = append(result, segment{start: start, end: end, paragraph: true})
+			start = end
+		case r != '\n' && (end > i+n || end == len(text)):
+			result = append(result, segment{start: start, end: end})
+			start = end
+		}
+		i = max(end, i+n)
+	}
+
+	if start < len(text) {
+		result = append(result, segment{start: start, end: len(text)})
+	}
+	return result
+}
+
+// splitLarge splits segments that are larger than a chunk between words, and words that are larger than a
+// chunk between characters.
+func splitLarge(text string, segments []segment, opt Options, measure func(start, end int) int) (result []segment) {
+	for _, seg := range segments {
+		if measure(seg.start, seg.end) <= opt.Size {
+			result = append(result, seg)
+			continue
+		}
+
+		var words []segment
+		start := seg.start
+		for i := seg.start; i < seg.end; {
+			r, n := utf8.DecodeRuneInString(text[i:])
+			i += n
+			if !unicode.IsSpace(r) {
+				continue
+			}
+			for i < seg.end {
+				r, n := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				i += n
+			}
+			words = append(words, segment{start: start, end: i})
+			start = i
+		}
+		if start < seg.end {
+			words = append(words, segment{start: start, end: seg.end})
+		}
+		words[len(words)-1].paragraph = seg.paragraph
+
+		for _, word := range words {
+			if measure(word.start, word.end) <= opt.Size {
+				result = append(result, word)
+				continue
+			}
+
+			maxRunes := opt.Size
+			if opt.Unit == UnitTokens {
+				maxRunes *= charactersPerToken
+			}
+			for start, runes, i := word.start, 0, word.start; i < word.end; {
+				_, n := utf8.DecodeRuneInString(text[i:])
+				i += n
+				runes++
+				if runes == maxRunes || i == word.end {
+					result = append(result, segment{start: start, end: i, paragraph: i == word.end && word.paragraph})
+					start, runes = i, 0
+				}
+			}
+		}
+	}
+	return result
+}
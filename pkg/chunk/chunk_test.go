@@ -0,0 +1,138 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+const text = "The cat sat on the mat. It was a sunny day! Was it warm? Yes.\n\n" +
+	"A new paragraph starts here. It has two sentences.\n\n" +
+	"Last one is short. supercalifragilisticexpialidocious word."
+
+func texts(chunks []Chunk) (result []string) {
+	for _, c := range chunks {
+		result = append(result, c.Text)
+	}
+	return result
+}
+
+func TestSplit_Boundaries(t *testing.T) {
+	chunks, err := Split("doc", text, Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{
+		"The cat sat on the mat. It was a sunny day! Was it warm?",
+		"Yes.\n\nA new paragraph starts here. It has two sentences.",
+		"Last one is short. supercalifragilisticexpialidocious word.",
+	}).Equal(t, texts(chunks))
+
+	for _, c := range chunks {
+		autogold.Expect(c.Text).Equal(t, text[c.Start:c.End])
+	}
+}
+
+func TestSplit_Paragraphs(t *testing.T) {
+	chunks, err := Split("doc", "First paragraph is here.\n\nSecond paragraph. It is the longer one.", Options{Size: 46})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{
+		"First paragraph is here.",
+		"Second paragraph. It is the longer one.",
+	}).Equal(t, texts(chunks))
+}
+
+func TestSplit_LargeSentences(t *testing.T) {
+	chunks, err := Split("doc", text, Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if n := len([]rune(c.Text)); n > 10 {
+			t.Errorf("chunk %q has %d characters", c.Text, n)
+		}
+	}
+	autogold.Expect([]string{
+		"Last one", "is short.", "supercalif", "ragilistic", "expialidoc",
+		"ious word.",
+	}).Equal(t, texts(chunks[13:]))
+}
+
+func TestSplit_Overlap(t *testing.T) {
+	chunks, err := Split("doc", text, Options{Size: 60, Overlap: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{
+		"The cat sat on the mat. It was a sunny day! Was it warm?",
+		"Was it warm? Yes.\n\nA new paragraph starts here.",
+		"A new paragraph starts here. It has two sentences.",
+		"It has two sentences.\n\nLast one is short.",
+		"Last one is short. supercalifragilisticexpialidocious word.",
+	}).Equal(t, texts(chunks))
+
+	for i := 1; i < len(chunks); i++ {
+		prev, next := chunks[i-1], chunks[i]
+		overlap := text[next.Start:prev.End]
+		if next.Start >= prev.End || len(overlap) > 30 || !strings.HasPrefix(prev.Text[len(prev.Text)-len(overlap):], overlap) {
+			t.Errorf("chunk %d does not overlap with the previous chunk by at most 30 characters: %q", i, overlap)
+		}
+	}
+}
+
+func TestSplit_Tokens(t *testing.T) {
+	chunks, err := Split("doc", text, Options{Size: 8, Unit: UnitTokens})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{
+		"The cat sat on the mat.",
+		"It was a sunny day! Was it warm?",
+		"Yes.",
+		"A new paragraph starts here.",
+		"It has two sentences.",
+		"Last one is short.",
+		"supercalifragilisticexpialidocio",
+		"us word.",
+	}).Equal(t, texts(chunks))
+}
+
+func TestSplit_IDs(t *testing.T) {
+	first, err := Split("doc", text, Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Split("doc", text, Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(first).Equal(t, second)
+	autogold.Expect("doc#1").Equal(t, first[1].ID)
+
+	anonymous, err := Split("", text, Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Split("", text, Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(anonymous[0].ID).Equal(t, again[0].ID)
+
+	other, err := Split("", "Some other text.", Options{Size: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(false).Equal(t, other[0].ID == anonymous[0].ID)
+}
+
+func TestSplit_InvalidOptions(t *testing.T) {
+	_, err := Split("doc", text, Options{Size: 10, Overlap: 10})
+	autogold.Expect("chunk overlap must be between 0 and the chunk size 10, got 10").Equal(t, err.Error())
+
+	_, err = Split("doc", text, Options{Unit: "words"})
+	autogold.Expect(`invalid chunk unit "words", must be characters or tokens`).Equal(t, err.Error())
+}
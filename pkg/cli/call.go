@@ -1,17 +1,29 @@
 package cli
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/chat"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"github.com/spf13/cobra"
 )
 
 type Call struct {
-	File   string `usage:"File to read input from" default:"" short:"f"`
-	Output string `usage:"Output format (json, pretty)" default:"pretty" short:"o"`
-	n      *Nanobot
+	File    string        `usage:"File to read input from" default:"" short:"f"`
+	Output  string        `usage:"Output format (json, pretty)" default:"pretty" short:"o"`
+	JSON    bool          `usage:"Stream progress events and the final result as newline-delimited JSON"`
+	Timeout time.Duration `usage:"Maximum time to wait for the call to complete (e.g. 30s, 5m), 0 for no limit"`
+	Session string        `usage:"ID of a stored session to make the call in, continuing its chat"`
+	Resume  bool          `usage:"Resume the interrupted turn of the session instead of starting a new one, requires --session"`
+	n       *Nanobot
 }
 
 func NewCall(n *Nanobot) *Call {
@@ -33,12 +45,23 @@ func (e *Call) Customize(cmd *cobra.Command) {
 
   # Run an agent, passing in a string as input. If the input is JSON it will be based as is.
   nanobot call . agent1 "What is the weather like today?"
+
+  # Run an agent, streaming tool calls, partial content and the final result as newline-delimited JSON.
+  nanobot call --json . agent1 "What is the weather like today?"
+
+  # Resume the turn of an agent that was interrupted in a stored session.
+  nanobot call --session 5f0c... --resume . agent1
 `
 	cmd.Args = cobra.MinimumNArgs(2)
+	cmd.ValidArgsFunction = e.n.completeTargets
 	cmd.Flags().SetInterspersed(false)
 }
 
 func (e *Call) Run(cmd *cobra.Command, args []string) error {
+	return runWithTimeout(cmd, args, e.Timeout, e.run)
+}
+
+func (e *Call) run(cmd *cobra.Command, args []string) (retErr error) {
 	cfg, err := e.n.ReadConfig(cmd.Context(), args[0])
 	if err != nil {
 		return err
@@ -52,8 +75,28 @@ func (e *Call) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := runtime.WithTempSession(cmd.Context(), cfg)
+	if e.Session != "" {
+		var store func() error
+		ctx, store, err = e.withStoredSession(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if storeErr := store(); storeErr != nil && retErr == nil {
+				retErr = storeErr
+			}
+		}()
+	} else if e.Resume {
+		return fmt.Errorf("--resume requires --session")
+	}
+
+	if e.JSON {
+		return e.runJSON(ctx, runtime, args)
+	}
 
-	result, err := runtime.CallFromCLI(ctx, args[1], args[2:]...)
+	result, err := runtime.CallFromCLI(ctx, args[1], args[2:], tools.CallOptions{
+		Resume: e.Resume,
+	})
 	if err != nil {
 		return err
 	}
@@ -64,3 +107,44 @@ func (e *Call) Run(cmd *cobra.Command, args []string) error {
 
 	return chat.PrintResult(os.Stdout, result)
 }
+
+func (e *Call) runJSON(ctx context.Context, r *runtime.Runtime, args []string) error {
+	out := chat.NewNDJSONWriter(os.Stdout)
+	defer mcp.SessionFromContext(ctx).AddFilter(out.Filter)()
+
+	result, err := r.CallFromCLI(ctx, args[1], args[2:], tools.CallOptions{
+		ProgressToken: uuid.String(),
+		Resume:        e.Resume,
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.WriteResult(result)
+}
+
+// withStoredSession returns a context to make the call in the stored session. The session is persisted as
+// the call progresses and stored again by the returned func when the call is done.
+func (e *Call) withStoredSession(ctx context.Context, cfg *types.Config) (context.Context, func() error, error) {
+	manager, err := session.NewManager(e.n.DSN())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serverSession, ok, err := manager.Acquire(ctx, nil, e.Session)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session %s: %w", e.Session, err)
+	} else if !ok {
+		return nil, nil, fmt.Errorf("session %s not found", e.Session)
+	}
+	serverSession.SetSessionStore(manager)
+
+	callSession := serverSession.GetSession()
+	callSession.Set(types.ConfigSessionKey, cfg)
+	ctx = mcp.WithSession(types.WithConfig(ctx, *cfg), callSession)
+
+	return ctx, func() error {
+		defer manager.Release(serverSession)
+		return manager.Store(context.WithoutCancel(ctx), e.Session, serverSession)
+	}, nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// completeTargets is a cobra ValidArgsFunction for commands that take NANOBOT_CONFIG TARGET_NAME
+// as their first two arguments.
+func (n *Nanobot) completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return nil, cobra.ShellCompDirectiveDefault
+	case 1:
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	log.EnableMessages = false
+
+	c, err := n.ReadConfig(cmd.Context(), args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var toolList []tools.ListToolsResult
+	if server, _, ok := strings.Cut(toComplete, "/"); ok {
+		if _, ok := c.MCPServers[server]; ok {
+			r, err := n.GetRuntime()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			toolList, _ = r.ListTools(r.WithTempSession(cmd.Context(), c), tools.ListToolsOptions{
+				Servers: []string{server},
+			})
+		}
+	}
+
+	candidates := targetCompletions(*c, toolList, toComplete)
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, candidate := range candidates {
+		if strings.HasSuffix(candidate, "/") {
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+	}
+	return candidates, directive
+}
+
+// targetCompletions returns the agents, "server/" prefixes and "server/tool" references that start with toComplete.
+func targetCompletions(c types.Config, toolList []tools.ListToolsResult, toComplete string) (result []string) {
+	for _, name := range slices.Sorted(maps.Keys(c.Agents)) {
+		if strings.HasPrefix(name, toComplete) {
+			result = append(result, name)
+		}
+	}
+
+	if !strings.Contains(toComplete, "/") {
+		for _, name := range slices.Sorted(maps.Keys(c.MCPServers)) {
+			if strings.HasPrefix(name+"/", toComplete) {
+				result = append(result, name+"/")
+			}
+		}
+		return
+	}
+
+	for _, list := range toolList {
+		if _, ok := c.MCPServers[list.Server]; !ok {
+			continue
+		}
+		for _, tool := range list.Tools {
+			if ref := list.Server + "/" + tool.Name; strings.HasPrefix(ref, toComplete) {
+				result = append(result, ref)
+			}
+		}
+	}
+
+	return
+}
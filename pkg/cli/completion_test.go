@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestTargetCompletions(t *testing.T) {
+	c := types.Config{
+		Agents: map[string]types.Agent{
+			"main":    {},
+			"planner": {},
+		},
+		MCPServers: map[string]mcp.Server{
+			"fs":     {},
+			"search": {},
+		},
+	}
+	toolList := []tools.ListToolsResult{
+		{
+			Server: "fs",
+			Tools:  []mcp.Tool{{Name: "read"}, {Name: "write"}, {Name: "remove"}},
+		},
+	}
+
+	autogold.Expect([]string{"main", "planner", "fs/", "search/"}).Equal(t, targetCompletions(c, nil, ""))
+	autogold.Expect([]string{"planner"}).Equal(t, targetCompletions(c, nil, "p"))
+	autogold.Expect([]string{"fs/"}).Equal(t, targetCompletions(c, nil, "f"))
+	autogold.Expect([]string{"fs/read", "fs/write", "fs/remove"}).Equal(t, targetCompletions(c, toolList, "fs/"))
+	autogold.Expect([]string{"fs/read", "fs/remove"}).Equal(t, targetCompletions(c, toolList, "fs/re"))
+	autogold.Expect([]string(nil)).Equal(t, targetCompletions(c, toolList, "search/x"))
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type ConfigCommand struct{}
+
+func (c *ConfigCommand) Customize(cmd *cobra.Command) {
+	cmd.Use = "config"
+	cmd.Short = "Work with nanobot configs"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *ConfigCommand) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type Validate struct {
+	n       *Nanobot
+	Profile []string `usage:"Profiles to apply to the config before validating it"`
+	Format  string   `usage:"Output format (text, json)" default:"text"`
+}
+
+func NewValidate(n *Nanobot) *Validate {
+	return &Validate{
+		n: n,
+	}
+}
+
+func (v *Validate) Customize(cmd *cobra.Command) {
+	cmd.Use = "validate [flags] [NANOBOT_CONFIG]"
+	cmd.Short = "Check a config for problems, exiting non-zero if there are any"
+	cmd.Args = cobra.MaximumNArgs(1)
+	cmd.Example = `
+  # Validate nanobot.yaml in the current directory
+  nanobot config validate .
+
+  # Validate the config with the "prod" profile applied, listing the problems as JSON
+  nanobot config validate --profile prod --format json .
+`
+}
+
+// validationProblem is a problem found validating a config, see types.ValidationError.
+type validationProblem struct {
+	Agent     string `json:"agent,omitempty"`
+	MCPServer string `json:"mcpServer,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (p validationProblem) String() string {
+	switch {
+	case p.Agent != "":
+		return fmt.Sprintf("agents/%s: %s", p.Agent, p.Message)
+	case p.MCPServer != "":
+		return fmt.Sprintf("mcpServers/%s: %s", p.MCPServer, p.Message)
+	default:
+		return p.Message
+	}
+}
+
+func (v *Validate) Run(cmd *cobra.Command, args []string) error {
+	if v.Format != "text" && v.Format != "json" {
+		return fmt.Errorf("invalid format %q, must be text or json", v.Format)
+	}
+
+	log.EnableMessages = false
+
+	cfgPath := "nanobot.default"
+	if len(args) > 0 {
+		cfgPath = args[0]
+	}
+
+	_, err := v.n.ReadConfig(cmd.Context(), cfgPath, runtime.Options{
+		Profiles: v.Profile,
+	})
+	validationErrs := types.ValidationErrors(err)
+	if err != nil && len(validationErrs) == 0 {
+		// The config could not be loaded, for example it doesn't match the schema, which is a problem too
+		validationErrs = append(validationErrs, &types.ValidationError{Err: err})
+	}
+
+	problems := make([]validationProblem, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		problems = append(problems, validationProblem{
+			Agent:     validationErr.Agent,
+			MCPServer: validationErr.MCPServer,
+			Message:   validationErr.Error(),
+		})
+	}
+	slices.SortFunc(problems, func(a, b validationProblem) int {
+		return cmp.Or(
+			cmp.Compare(a.Agent, b.Agent),
+			cmp.Compare(a.MCPServer, b.MCPServer),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+
+	if err := printProblems(cmd.OutOrStdout(), problems, v.Format); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config %s has %d problem(s)", cfgPath, len(problems))
+	}
+	return nil
+}
+
+func printProblems(out io.Writer, problems []validationProblem, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	if len(problems) == 0 {
+		_, err := fmt.Fprintln(out, "config is valid")
+		return err
+	}
+	var errs []error
+	for _, problem := range problems {
+		_, err := fmt.Fprintln(out, problem.String())
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+type Schema struct {
+	n *Nanobot
+}
+
+func NewSchema(n *Nanobot) *Schema {
+	return &Schema{
+		n: n,
+	}
+}
+
+func (s *Schema) Customize(cmd *cobra.Command) {
+	cmd.Use = "schema"
+	cmd.Short = "Print the JSON Schema of nanobot configs"
+	cmd.Args = cobra.NoArgs
+	cmd.Example = `
+  # Save the schema, so nanobot.yaml can reference it with "$schema: ./nanobot.schema.json"
+  nanobot config schema > nanobot.schema.json
+`
+}
+
+func (s *Schema) Run(cmd *cobra.Command, _ []string) error {
+	data, err := config.SchemaJSON()
+	if err != nil {
+		return fmt.Errorf("failed to get config schema: %w", err)
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return err
+}
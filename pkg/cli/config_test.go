@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func runValidate(t *testing.T, config string, args ...string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nanobot.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := New()
+	root.SetOut(&out)
+	root.SetArgs(append(append([]string{"config", "validate", "--empty-env"}, args...), path))
+	err := root.ExecuteContext(t.Context())
+	return out.String(), err
+}
+
+const invalidConfig = `
+agents:
+  a:
+    mcpServers: [missing]
+  b: {}
+mcpServers:
+  fs:
+    command: fs
+profiles:
+  entrypoint:
+    publish:
+      entrypoint: [a]
+`
+
+func TestValidate(t *testing.T) {
+	out, err := runValidate(t, invalidConfig)
+	autogold.Expect(`publish must have at least one entrypoint agent set if there are multiple agents
+agents/a: agent "a" has MCP server "missing" that is not defined in config
+`).Equal(t, out)
+	if err == nil {
+		t.Fatal("expected an error for an invalid config")
+	}
+}
+
+func TestValidate_ProfileJSON(t *testing.T) {
+	out, err := runValidate(t, invalidConfig, "--profile", "entrypoint", "--format", "json")
+	autogold.Expect(`[
+  {
+    "agent": "a",
+    "message": "agent \"a\" has MCP server \"missing\" that is not defined in config"
+  }
+]
+`).Equal(t, out)
+	if err == nil {
+		t.Fatal("expected an error for an invalid config")
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	out, err := runValidate(t, `
+agents:
+  a:
+    mcpServers: [fs]
+mcpServers:
+  fs:
+    command: fs
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("config is valid\n").Equal(t, out)
+}
+
+func TestSchema(t *testing.T) {
+	var out bytes.Buffer
+	root := New()
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "schema"})
+	if err := root.ExecuteContext(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Title       string                     `json:"title"`
+		Definitions map[string]json.RawMessage `json:"definitions"`
+		Properties  map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("Nanobot Configuration Schema").Equal(t, schema.Title)
+	if _, ok := schema.Properties["agents"]; !ok {
+		t.Fatal("expected the schema to describe agents")
+	}
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/nanobot-ai/nanobot/pkg/chat"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+type Describe struct {
+	Output string `usage:"Output format (json, yaml, pretty)" short:"o" default:"pretty"`
+	n      *Nanobot
+}
+
+func NewDescribe(n *Nanobot) *Describe {
+	return &Describe{
+		n: n,
+	}
+}
+
+func (d *Describe) Customize(cmd *cobra.Command) {
+	cmd.Hidden = true
+	cmd.Use = "describe [flags] NANOBOT_CONFIG TARGET_NAME"
+	cmd.Short = "Show the description, arguments, and annotations of a target that can be called using \"nanobot call\"."
+	cmd.Aliases = []string{"desc"}
+	cmd.Args = cobra.ExactArgs(2)
+	cmd.ValidArgsFunction = d.n.completeTargets
+	cmd.Example = `
+  # Describe a tool
+  nanobot describe . server1/tool1
+
+  # Describe an agent
+  nanobot describe . agent1
+`
+}
+
+func (d *Describe) Run(cmd *cobra.Command, args []string) error {
+	log.EnableMessages = false
+	r, err := d.n.GetRuntime()
+	if err != nil {
+		return err
+	}
+
+	c, err := d.n.ReadConfig(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	target, err := r.GetTarget(r.WithTempSession(cmd.Context(), c), args[1])
+	if err != nil {
+		return err
+	}
+
+	if display(target, d.Output) {
+		return nil
+	}
+
+	return chat.PrintTool(os.Stdout, target.Server, target.Tools[0])
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/spf13/cobra"
+)
+
+type Hook struct {
+	MCPServer string `usage:"Run the hooks configured on this MCP server instead of the top level hooks" short:"s" name:"mcp-server"`
+	Target    string `usage:"Run only this hook target (server/tool or webhook URL) for any message"`
+	Direction string `usage:"Direction of the message (request, response)" default:"request"`
+	Output    string `usage:"Output format (json, yaml)" default:"json" short:"o"`
+	n         *Nanobot
+}
+
+func NewHook(n *Nanobot) *Hook {
+	return &Hook{
+		n: n,
+	}
+}
+
+func (h *Hook) Customize(cmd *cobra.Command) {
+	cmd.Hidden = true
+	cmd.Use = "hook [flags] NANOBOT_CONFIG MESSAGE"
+	cmd.Short = "Run the hooks that match a sample MCP message and print the decision, without sending the message."
+	cmd.Example = `
+  # Run the hooks of the server "fs" against a tools/call request
+  nanobot hook --mcp-server fs . '{"method":"tools/call","params":{"name":"read","arguments":{"path":"/etc"}}}'
+
+  # Run a single hook against a tools/call response
+  nanobot hook --target policy/check --direction response . '{"method":"tools/call","params":{"name":"read"},"result":{"content":[]}}'
+`
+	cmd.Args = cobra.ExactArgs(2)
+}
+
+func (h *Hook) Run(cmd *cobra.Command, args []string) error {
+	log.EnableMessages = false
+
+	var msg mcp.Message
+	if err := json.Unmarshal([]byte(args[1]), &msg); err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+	msg.JSONRPC = "2.0"
+
+	cfg, err := h.n.ReadConfig(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	hooks := cfg.Hooks
+	if h.MCPServer != "" {
+		server, ok := cfg.MCPServers[h.MCPServer]
+		if !ok {
+			return fmt.Errorf("unknown MCP server %s", h.MCPServer)
+		}
+		hooks = server.Hooks
+	}
+	if h.Target != "" {
+		hooks = mcp.Hooks{{
+			Name:    "*",
+			Targets: []string{h.Target},
+		}}
+	}
+
+	r, err := h.n.GetRuntime()
+	if err != nil {
+		return err
+	}
+
+	decision := mcp.RunMessageHooks(r.WithTempSession(cmd.Context(), cfg), r, hooks, msg, h.Direction)
+	if !display(decision, h.Output) {
+		return fmt.Errorf("unsupported output format %s", h.Output)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -17,12 +18,17 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/cmd"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
 	"github.com/nanobot-ai/nanobot/pkg/llm"
 	"github.com/nanobot-ai/nanobot/pkg/llm/anthropic"
+	"github.com/nanobot-ai/nanobot/pkg/llm/completions"
+	"github.com/nanobot-ai/nanobot/pkg/llm/gemini"
 	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
 	"github.com/nanobot-ai/nanobot/pkg/server"
 	"github.com/nanobot-ai/nanobot/pkg/session"
@@ -38,7 +44,10 @@ func New() *cobra.Command {
 	root := cmd.Command(n,
 		NewCall(n),
 		NewTargets(n),
+		NewHook(n),
+		NewDescribe(n),
 		NewSessions(n),
+		cmd.Command(&ConfigCommand{}, NewValidate(n), NewSchema(n)),
 		NewRun(n))
 	return root
 }
@@ -47,17 +56,43 @@ type Nanobot struct {
 	Debug                   bool              `usage:"Enable debug logging"`
 	Trace                   bool              `usage:"Enable trace logging"`
 	Quiet                   bool              `usage:"Disable most output" short:"q"`
+	Record                  string            `usage:"Record all MCP messages with timing to this file, one JSON object per line" env:"NANOBOT_RECORD"`
 	Env                     []string          `usage:"Environment variables to set in the form of KEY=VALUE, or KEY to load from current environ" short:"e"`
 	EnvFile                 string            `usage:"Path to the environment file (default: ./nanobot.env)"`
+	EnvFileStrict           bool              `usage:"Fail if a variable referenced in the environment file is not defined"`
 	EmptyEnv                bool              `usage:"Do not load environment variables from the environment by default"`
+	ConfigAllowedHosts      []string          `usage:"Hosts that remote configs and their extends may be loaded from (default: any)"`
 	DefaultModel            string            `usage:"Default model to use for completions" default:"gpt-4.1" env:"NANOBOT_DEFAULT_MODEL" name:"default-model"`
+	DefaultEmbeddingModel   string            `usage:"Default model to use for embeddings" default:"text-embedding-3-small" env:"NANOBOT_DEFAULT_EMBEDDING_MODEL" name:"default-embedding-model"`
 	OpenAIAPIKey            string            `usage:"OpenAI API key" env:"OPENAI_API_KEY" name:"openai-api-key"`
 	OpenAIBaseURL           string            `usage:"OpenAI API URL" env:"OPENAI_BASE_URL" name:"openai-base-url"`
 	OpenAIHeaders           map[string]string `usage:"OpenAI API headers" env:"OPENAI_HEADERS" name:"openai-headers"`
+	OpenAIFailoverURLs      []string          `usage:"OpenAI API URLs to try in order when the OpenAI API URL is down" env:"OPENAI_FAILOVER_BASE_URLS" name:"openai-failover-base-urls"`
 	OpenAIChatCompletionAPI bool              `usage:"Use OpenAI Chat Completion API instead of the newer Responses API" env:"OPENAI_CHAT_COMPLETION_API" name:"openai-chat-completion-api"`
 	AnthropicAPIKey         string            `usage:"Anthropic API key" env:"ANTHROPIC_API_KEY" name:"anthropic-api-key"`
 	AnthropicBaseURL        string            `usage:"Anthropic API URL" env:"ANTHROPIC_BASE_URL" name:"anthropic-base-url"`
 	AnthropicHeaders        map[string]string `usage:"Anthropic API headers" env:"ANTHROPIC_HEADERS" name:"anthropic-headers"`
+	AnthropicFailoverURLs   []string          `usage:"Anthropic API URLs to try in order when the Anthropic API URL is down" env:"ANTHROPIC_FAILOVER_BASE_URLS" name:"anthropic-failover-base-urls"`
+	GeminiAPIKey            string            `usage:"Gemini API key" env:"GEMINI_API_KEY" name:"gemini-api-key"`
+	GeminiBaseURL           string            `usage:"Gemini API URL" env:"GEMINI_BASE_URL" name:"gemini-base-url"`
+	GeminiHeaders           map[string]string `usage:"Gemini API headers" env:"GEMINI_HEADERS" name:"gemini-headers"`
+	GeminiFailoverURLs      []string          `usage:"Gemini API URLs to try in order when the Gemini API URL is down" env:"GEMINI_FAILOVER_BASE_URLS" name:"gemini-failover-base-urls"`
+	OllamaBaseURL           string            `usage:"Ollama OpenAI compatible API URL, used for models prefixed with ollama/" env:"OLLAMA_BASE_URL" name:"ollama-base-url"`
+	OllamaHeaders           map[string]string `usage:"Ollama API headers" env:"OLLAMA_HEADERS" name:"ollama-headers"`
+	ExchangeTimeout         time.Duration     `usage:"Default time to wait for a response to an MCP request (default: no limit)"`
+	LLMFirstTokenTimeout    time.Duration     `usage:"Time to wait for a streamed LLM response to start (default: no limit)"`
+	LLMStallTimeout         time.Duration     `usage:"Time to wait for more data of a streamed LLM response before aborting it (default: no limit)"`
+	DebugServer             bool              `usage:"Enable the built-in nanobot.debug server for testing MCP clients" hidden:"true"`
+	MaxAgentDepth           int               `usage:"The maximum depth of agents calling other agents" default:"10" hidden:"true"`
+	MaxImageDimension       int               `usage:"Downscale image attachments so neither side is larger than this many pixels (default: no downscaling)"`
+	ImageQuality            int               `usage:"The JPEG quality of downscaled image attachments" default:"85"`
+	MaxAttachmentSize       int64             `usage:"The maximum size in bytes of http(s) attachments that are fetched" default:"20971520"`
+	AttachmentFetchTimeout  time.Duration     `usage:"The time to wait for an http(s) attachment to be fetched" default:"30s"`
+	FetchAttachments        bool              `usage:"Fetch http(s) attachment URLs, only from public addresses (default: only data URIs are accepted)"`
+	MaxProgressSize         int               `usage:"The maximum size in bytes of a completion progress notification, larger ones are split or truncated (default: no limit)"`
+	SensitiveArguments      []string          `usage:"Glob patterns of tool argument names whose values are masked in progress notifications, for example *token*"`
+	WebhookSecret           string            `usage:"Secret to sign the requests of webhook hooks with, they are not signed if it is not set" env:"NANOBOT_WEBHOOK_SECRET" name:"webhook-secret"`
+	WebhookTimeout          time.Duration     `usage:"Time to wait for a webhook hook to respond (default: 10s)" env:"NANOBOT_WEBHOOK_TIMEOUT" name:"webhook-timeout"`
 	MaxConcurrency          int               `usage:"The maximum number of concurrent tasks in a parallel loop" default:"10" hidden:"true"`
 	Chdir                   string            `usage:"Change directory to this path before running the nanobot" default:"." short:"C"`
 	State                   string            `usage:"Path to the state file" default:"./nanobot.db"`
@@ -105,7 +140,6 @@ func (n *Nanobot) DSN() string {
 
 func (n *Nanobot) Customize(cmd *cobra.Command) {
 	cmd.Short = "Nanobot: Build MCP Agents"
-	cmd.CompletionOptions.HiddenDefaultCmd = true
 	cmd.Version = version.Get().String()
 }
 
@@ -127,6 +161,14 @@ func (n *Nanobot) PersistentPre(cmd *cobra.Command, _ []string) error {
 
 	log.EnableMessages = n.Debug || n.Trace || !n.Quiet
 
+	mcp.DefaultExchangeTimeout = n.ExchangeTimeout
+
+	if n.Record != "" {
+		if err := log.StartRecording(n.Record); err != nil {
+			return err
+		}
+	}
+
 	for _, sub := range cmd.Commands() {
 		if sub.Name() == "help" {
 			sub.Hidden = true
@@ -151,21 +193,46 @@ func display(obj any, format string) bool {
 
 func (n *Nanobot) llmConfig() llm.Config {
 	return llm.Config{
-		DefaultModel: n.DefaultModel,
+		DefaultModel:          n.DefaultModel,
+		DefaultEmbeddingModel: n.DefaultEmbeddingModel,
+		MaxProgressSize:       n.MaxProgressSize,
 		Responses: responses.Config{
 			APIKey:            n.OpenAIAPIKey,
 			BaseURL:           n.OpenAIBaseURL,
+			FailoverBaseURLs:  n.OpenAIFailoverURLs,
+			StreamTimeouts:    n.streamTimeouts(),
 			Headers:           n.OpenAIHeaders,
 			ChatCompletionAPI: n.OpenAIChatCompletionAPI,
 		},
 		Anthropic: anthropic.Config{
-			APIKey:  n.AnthropicAPIKey,
-			BaseURL: n.AnthropicBaseURL,
-			Headers: n.AnthropicHeaders,
+			APIKey:           n.AnthropicAPIKey,
+			BaseURL:          n.AnthropicBaseURL,
+			FailoverBaseURLs: n.AnthropicFailoverURLs,
+			StreamTimeouts:   n.streamTimeouts(),
+			Headers:          n.AnthropicHeaders,
+		},
+		Gemini: gemini.Config{
+			APIKey:           n.GeminiAPIKey,
+			BaseURL:          n.GeminiBaseURL,
+			FailoverBaseURLs: n.GeminiFailoverURLs,
+			StreamTimeouts:   n.streamTimeouts(),
+			Headers:          n.GeminiHeaders,
+		},
+		Ollama: completions.Config{
+			BaseURL:        n.OllamaBaseURL,
+			Headers:        n.OllamaHeaders,
+			StreamTimeouts: n.streamTimeouts(),
 		},
 	}
 }
 
+func (n *Nanobot) streamTimeouts() stream.Timeouts {
+	return stream.Timeouts{
+		FirstToken: n.LLMFirstTokenTimeout,
+		Stall:      n.LLMStallTimeout,
+	}
+}
+
 func (n *Nanobot) loadEnv() (map[string]string, error) {
 	if n.env != nil {
 		return n.env, nil
@@ -185,6 +252,10 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 		}
 	}
 
+	// Only the values set for nanobot, by the env file and flags, are resolved as secret references. An
+	// inherited variable that happens to look like one is kept as is.
+	configured := map[string]string{}
+
 	defaultFile := n.EnvFile == ""
 	if defaultFile {
 		n.EnvFile = "./nanobot.env"
@@ -195,15 +266,18 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 	} else if err != nil {
 		return nil, err
 	} else {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
-			}
-			k, v, _ := strings.Cut(line, "=")
-			env[k] = v
+		fileEnv, err := envvar.ParseDotEnv(string(data), envvar.DotEnvOptions{
+			Lookup: func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			},
+			ErrorOnUndefined: n.EnvFileStrict,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", n.EnvFile, err)
 		}
+		maps.Copy(env, fileEnv)
+		maps.Copy(configured, fileEnv)
 	}
 
 	if _, ok := env["NANOBOT_MCP"]; !ok {
@@ -215,20 +289,42 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 		if !ok {
 			v = os.Getenv(k)
 		}
-		env[k] = v
+		configured[k] = v
+	}
+
+	if err := envvar.ResolveSecrets(context.Background(), configured); err != nil {
+		return nil, err
 	}
+	maps.Copy(env, configured)
 
 	n.env = env
 	return env, nil
 }
 
 func (n *Nanobot) ReadConfig(ctx context.Context, cfgPath string, opts ...runtime.Options) (*types.Config, error) {
-	cfg, _, err := config.Load(ctx, cfgPath, complete.Complete(opts...).Profiles...)
+	env, err := n.loadEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	config.AllowedRemoteHosts = n.ConfigAllowedHosts
+	cfg, _, err := config.Load(config.WithEnv(ctx, env), cfgPath, complete.Complete(opts...).Profiles...)
 	return cfg, err
 }
 
 func (n *Nanobot) GetRuntime(opts ...runtime.Options) (*runtime.Runtime, error) {
-	return runtime.NewRuntime(n.llmConfig(), opts...)
+	return runtime.NewRuntime(n.llmConfig(), append(opts, runtime.Options{
+		DebugServer:            n.DebugServer,
+		MaxAgentDepth:          n.MaxAgentDepth,
+		MaxImageDimension:      n.MaxImageDimension,
+		ImageQuality:           n.ImageQuality,
+		MaxAttachmentSize:      n.MaxAttachmentSize,
+		AttachmentFetchTimeout: n.AttachmentFetchTimeout,
+		FetchAttachments:       n.FetchAttachments,
+		SensitiveArguments:     n.SensitiveArguments,
+		WebhookSecret:          n.WebhookSecret,
+		WebhookTimeout:         n.WebhookTimeout,
+	})...)
 }
 
 func (n *Nanobot) Run(cmd *cobra.Command, _ []string) error {
@@ -241,6 +337,7 @@ type mcpOpts struct {
 	TrustedAudiences   []string
 	ListenAddress      string
 	HealthzPath        string
+	MetricsPath        string
 	ForceFetchToolList bool
 	StartUI            bool
 }
@@ -307,6 +404,9 @@ func (n *Nanobot) runMCP(ctx context.Context, baseConfig types.ConfigFactory, ru
 	if oauthCallbackHandler != nil {
 		mux.Handle("/oauth/callback", oauthCallbackHandler)
 	}
+	if opts.MetricsPath != "" {
+		mux.Handle("GET /"+strings.TrimPrefix(opts.MetricsPath, "/"), metrics.Handler())
+	}
 	if opts.StartUI {
 		mux.Handle("/", session.UISession(httpServer, sessionManager, api.Handler(sessionManager, address)))
 	} else {
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestLoadEnv_ResolvesConfiguredSecrets(t *testing.T) {
+	dir := t.TempDir()
+	token := filepath.Join(dir, "token")
+	if err := os.WriteFile(token, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(dir, "nanobot.env")
+	if err := os.WriteFile(envFile, []byte("FILE_TOKEN=file://"+token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// An inherited variable that looks like a secret reference, and can't be resolved, is kept as is
+	t.Setenv("INHERITED_TOKEN", "file:///does/not/exist")
+
+	n := &Nanobot{
+		EnvFile: envFile,
+		Env:     []string{"FLAG_TOKEN=file://" + token},
+	}
+	env, err := n.loadEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"s3cr3t", "s3cr3t", "file:///does/not/exist"}).Equal(t, []string{
+		env["FILE_TOKEN"], env["FLAG_TOKEN"], env["INHERITED_TOKEN"],
+	})
+}
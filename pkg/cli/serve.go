@@ -9,7 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/config"
 	"github.com/nanobot-ai/nanobot/pkg/confirm"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
 	"github.com/nanobot-ai/nanobot/pkg/printer"
@@ -18,11 +20,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// watchInterval is how often the config files are checked for changes with --watch.
+const watchInterval = time.Second
+
 type Run struct {
 	ListenAddress                string            `usage:"Address to listen on" default:"localhost:8080" short:"a"`
 	DisableUI                    bool              `usage:"Disable the UI"`
 	ForceFetchToolList           bool              `usage:"Always fetch tools when listing instead of using session cache"`
 	HealthzPath                  string            `usage:"Path to serve healthz on"`
+	MetricsPath                  string            `usage:"Path to serve Prometheus metrics on"`
 	TrustedIssuer                string            `usage:"Trusted issuer for JWT tokens"`
 	JWKS                         string            `usage:"Base64 encoded JWKS blob for validating JWT tokens"`
 	TrustedAudiences             []string          `usage:"Trusted audiences for JWT tokens"`
@@ -35,6 +41,8 @@ type Run struct {
 	AuditLogBatchSize            int               `usage:"Batch size for sending audit logs" default:"1000"`
 	AuditLogFlushIntervalSeconds int               `usage:"Interval for flushing audit logs" default:"5"`
 	Roots                        []string          `usage:"Roots to expose the MCP server in the form of name:directory" short:"r"`
+	Timeout                      time.Duration     `usage:"Stop the nanobot after this amount of time (e.g. 30s, 5m), 0 for no limit"`
+	Watch                        bool              `usage:"Reload the config for new sessions when its file, or a file it extends, changes"`
 	n                            *Nanobot
 }
 
@@ -91,7 +99,11 @@ func (r *Run) getRoots() ([]mcp.Root, error) {
 	return roots, nil
 }
 
-func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
+func (r *Run) Run(cmd *cobra.Command, args []string) error {
+	return runWithTimeout(cmd, args, r.Timeout, r.run)
+}
+
+func (r *Run) run(cmd *cobra.Command, args []string) (err error) {
 	if (r.TrustedIssuer != "") != (len(r.TrustedAudiences) != 0) {
 		return fmt.Errorf("trusted issuer and audience must be set together")
 	}
@@ -128,6 +140,21 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		return *cfg, nil
 	})
 
+	if r.Watch {
+		reloader := config.NewReloader(cfgFactory)
+		err := config.Watch(cmd.Context(), cfgPath, watchInterval, func() {
+			if err := reloader.Reload(cmd.Context()); err != nil {
+				log.Errorf(cmd.Context(), "failed to reload config %s, keeping the previous config: %v", cfgPath, err)
+				return
+			}
+			log.Infof(cmd.Context(), "reloaded config %s", cfgPath)
+		})
+		if err != nil {
+			return err
+		}
+		cfgFactory = reloader.Config
+	}
+
 	once, err := cfgFactory(cmd.Context(), "")
 	if err != nil {
 		return fmt.Errorf("failed to read config file %q: %w", args[0], err)
@@ -157,6 +184,7 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		TrustedAudiences:   r.TrustedAudiences,
 		ListenAddress:      r.ListenAddress,
 		HealthzPath:        r.HealthzPath,
+		MetricsPath:        r.MetricsPath,
 		ForceFetchToolList: r.ForceFetchToolList,
 		StartUI:            !r.DisableUI,
 	})
@@ -1,11 +1,17 @@
 package cli
 
 import (
+	"context"
+	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"text/tabwriter"
 
 	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +19,7 @@ type Targets struct {
 	n         *Nanobot
 	MCPServer []string `usage:"Specific MCP server name to query (default: all)" short:"s" name:"mcp-server"`
 	Output    string   `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+	Agent     string   `usage:"Print the resolved tool mappings for the given agent" short:"a"`
 }
 
 func NewTargets(n *Nanobot) *Targets {
@@ -29,7 +36,10 @@ func (t *Targets) Customize(cmd *cobra.Command) {
 	cmd.Args = cobra.ExactArgs(1)
 	cmd.Example = `
   # List the tools from nanobot.yaml in the current directory
-  nanobot run .
+  nanobot targets .
+
+  # Show which tools the agent "main" will see and where they resolve to
+  nanobot targets --agent main .
 `
 }
 
@@ -45,6 +55,10 @@ func (t *Targets) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if t.Agent != "" {
+		return t.printAgentMappings(r.WithTempSession(cmd.Context(), c), r, c)
+	}
+
 	tools, err := r.ListTools(r.WithTempSession(cmd.Context(), c), tools.ListToolsOptions{
 		Servers: t.MCPServer,
 	})
@@ -78,6 +92,39 @@ func (t *Targets) Run(cmd *cobra.Command, args []string) error {
 	return tw.Flush()
 }
 
+func (t *Targets) printAgentMappings(ctx context.Context, r *runtime.Runtime, c *types.Config) error {
+	agent, ok := c.Agents[t.Agent]
+	if !ok {
+		return fmt.Errorf("agent %s not found", t.Agent)
+	}
+
+	mappings, err := r.BuildToolMappings(ctx, slices.Concat(agent.Tools, agent.Agents, agent.MCPServers))
+	if err != nil {
+		return err
+	}
+
+	if display(mappings, t.Output) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = tw.Write([]byte("NAME\tTARGET\tDESCRIPTION\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(mappings)) {
+		mapping := mappings[name]
+		target := mapping.MCPServer
+		if _, ok := c.MCPServers[target]; ok {
+			target = target + "/" + mapping.TargetName
+		}
+		_, _ = tw.Write([]byte(name + "\t" + target + "\t" + trim(mapping.Target.Description) + "\n"))
+	}
+
+	return tw.Flush()
+}
+
 func trim(s string) string {
 	if len(s) > 70 {
 		return s[:70] + "..."
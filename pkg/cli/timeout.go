@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// runWithTimeout runs the command with a deadline on its context. If the deadline passes, a timeout error is
+// returned even if run has not yet returned, so that a stalled server or model can not hang the CLI.
+func runWithTimeout(cmd *cobra.Command, args []string, timeout time.Duration, run func(cmd *cobra.Command, args []string) error) error {
+	if timeout <= 0 {
+		return run(cmd, args)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+	cmd.SetContext(ctx)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- run(cmd, args)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRunWithTimeout_Stalled(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+
+	stalled := make(chan struct{})
+	defer close(stalled)
+
+	err := runWithTimeout(cmd, nil, 10*time.Millisecond, func(*cobra.Command, []string) error {
+		// Simulates a server that never responds and ignores the context
+		<-stalled
+		return nil
+	})
+	if err == nil || err.Error() != "timed out after 10ms" {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestRunWithTimeout_ContextAware(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+
+	err := runWithTimeout(cmd, nil, 10*time.Millisecond, func(cmd *cobra.Command, _ []string) error {
+		<-cmd.Context().Done()
+		return cmd.Context().Err()
+	})
+	if err == nil || err.Error() != "timed out after 10ms" {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestRunWithTimeout_Completes(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(t.Context())
+
+	called := false
+	err := runWithTimeout(cmd, nil, time.Second, func(*cobra.Command, []string) error {
+		called = true
+		return nil
+	})
+	if err != nil || !called {
+		t.Fatalf("expected run to complete, got called=%v err=%v", called, err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/nanobot-ai/nanobot/pkg/expr"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
@@ -78,6 +80,11 @@ func loadResource(ctx context.Context, configResource *resource, profiles ...str
 		}
 	}
 
+	last, err = applyConditionals(ctx, last)
+	if err != nil {
+		return nil, "", err
+	}
+
 	for _, profile := range profiles {
 		profileName, _, optional := strings.Cut(profile, "?")
 		profileConfig, found := last.Profiles[profileName]
@@ -93,6 +100,16 @@ func loadResource(ctx context.Context, configResource *resource, profiles ...str
 		}
 	}
 
+	last, err = removeConfigMergeMarkers(last)
+	if err != nil {
+		return nil, "", fmt.Errorf("error normalizing config: %w", err)
+	}
+
+	last, err = interpolateEnv(ctx, last)
+	if err != nil {
+		return nil, "", err
+	}
+
 	last = rewriteCwd(last, targetCwd)
 
 	last, err = rewriteSourceReferences(last, configResource)
@@ -109,6 +126,118 @@ func loadResource(ctx context.Context, configResource *resource, profiles ...str
 	return &last, targetCwd, last.Validate(configResource.resourceType == "path")
 }
 
+type envKey struct{}
+
+// WithEnv sets the environment used to evaluate conditional config sections. If not set, the process
+// environment is used.
+func WithEnv(ctx context.Context, env map[string]string) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+func envFromContext(ctx context.Context) map[string]string {
+	if env, ok := ctx.Value(envKey{}).(map[string]string); ok {
+		return env
+	}
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	return env
+}
+
+func applyConditionals(ctx context.Context, cfg types.Config) (types.Config, error) {
+	if len(cfg.When) == 0 {
+		return cfg, nil
+	}
+
+	var (
+		conditionals = cfg.When
+		env          = envFromContext(ctx)
+		data         = map[string]any{
+			"env": env,
+		}
+	)
+
+	cfg.When = nil
+	for i, conditional := range conditionals {
+		ok, err := expr.EvalBool(ctx, env, data, conditional.If)
+		if err != nil {
+			return cfg, fmt.Errorf("error evaluating condition %d (%s): %w", i, conditional.If, err)
+		}
+		if !ok {
+			continue
+		}
+		cfg, err = Merge(cfg, conditional.Config)
+		if err != nil {
+			return cfg, fmt.Errorf("error merging conditional config %d (%s): %w", i, conditional.If, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// interpolateEnv replaces ${VAR} references in the agent fields that are used as is after loading, like the
+// model, with the values of the environment. The fields of MCP servers and the instructions are not
+// interpolated here, because they are evaluated at runtime with the environment of the session.
+func interpolateEnv(ctx context.Context, cfg types.Config) (types.Config, error) {
+	if len(cfg.Agents) == 0 {
+		return cfg, nil
+	}
+
+	var (
+		env    = envFromContext(ctx)
+		errs   []error
+		agents = make(map[string]types.Agent, len(cfg.Agents))
+	)
+
+	for _, agentName := range slices.Sorted(maps.Keys(cfg.Agents)) {
+		agent := cfg.Agents[agentName]
+		for _, field := range []struct {
+			name  string
+			value *string
+		}{
+			{"name", &agent.Name},
+			{"shortName", &agent.ShortName},
+			{"description", &agent.Description},
+			{"icon", &agent.Icon},
+			{"iconDark", &agent.IconDark},
+			{"model", &agent.Model},
+		} {
+			var err error
+			*field.value, err = expandEnv(env, cfg.Env, *field.value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error interpolating %s of agent %s: %w", field.name, agentName, err))
+			}
+		}
+		agents[agentName] = agent
+	}
+
+	cfg.Agents = agents
+	return cfg, errors.Join(errs...)
+}
+
+// expandEnv replaces the ${VAR} references in value. A variable that is not set is replaced with its
+// default if it is marked optional in envDefs, otherwise an error is returned.
+func expandEnv(env map[string]string, envDefs map[string]types.EnvDef, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var errs []error
+	result := expr.Expand(value, func(name string) string {
+		if val, ok := expr.Lookup(env, name); ok {
+			return val
+		}
+		if envDef, ok := envDefs[name]; ok && envDef.Optional {
+			return envDef.Default
+		}
+		errs = append(errs, fmt.Errorf("environment variable %s is not set and not marked optional in env", name))
+		return ""
+	})
+	return result, errors.Join(errs...)
+}
+
 func rewriteCwd(cfg types.Config, cwd string) types.Config {
 	newMCPServers := map[string]mcp.Server{}
 	for name, mcpServer := range cfg.MCPServers {
@@ -140,6 +269,14 @@ func toMap(cfg types.Config) (map[string]any, error) {
 	return result, json.Unmarshal(data, &result)
 }
 
+// ReplaceListMarker can be used as the first element of a list in an overriding config (profile or a config
+// that uses extends) to replace the list from the base config instead of appending to it.
+const ReplaceListMarker = "$replace"
+
+func isReplaceList(list []any) bool {
+	return len(list) > 0 && list[0] == ReplaceListMarker
+}
+
 func mergeObject(base, overlay any) any {
 	if baseMap, ok := base.(map[string]any); ok {
 		if overlayMap, ok := overlay.(map[string]any); ok {
@@ -150,6 +287,9 @@ func mergeObject(base, overlay any) any {
 			return newMap
 		}
 	}
+	if overlayArray, ok := overlay.([]any); ok && isReplaceList(overlayArray) {
+		return overlayArray[1:]
+	}
 	if baseArray, ok := base.([]any); ok {
 		if overlayArray, ok := overlay.([]any); ok {
 			return slices.Concat(baseArray, overlayArray)
@@ -158,6 +298,40 @@ func mergeObject(base, overlay any) any {
 	return overlay
 }
 
+// removeConfigMergeMarkers strips any ReplaceListMarker left in lists that were not merged with a base list.
+func removeConfigMergeMarkers(cfg types.Config) (types.Config, error) {
+	cfgMap, err := toMap(cfg)
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	data, err := json.Marshal(removeMergeMarkers(cfgMap))
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	var result types.Config
+	return result, json.Unmarshal(data, &result)
+}
+
+func removeMergeMarkers(obj any) any {
+	switch v := obj.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = removeMergeMarkers(item)
+		}
+	case []any:
+		if isReplaceList(v) {
+			v = v[1:]
+		}
+		for i, item := range v {
+			v[i] = removeMergeMarkers(item)
+		}
+		return v
+	}
+	return obj
+}
+
 func Merge(base, overlay types.Config) (types.Config, error) {
 	baseMap, err := toMap(base)
 	if err != nil {
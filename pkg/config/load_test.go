@@ -2,9 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/santhosh-tekuri/jsonschema/v6"
 	"sigs.k8s.io/yaml"
 )
@@ -153,6 +158,9 @@ func TestSchema(t *testing.T) {
 		"agent2": {
 			"threadName": "a different thread",
 			"tools": ["tool1", "tool2"],
+			"output": {
+				"mode": "json"
+			},
 			"agents": ["tool1", "tool2"],
 			"instructions": {
 				"mcpServer": "aserver",
@@ -186,3 +194,153 @@ func TestSchema(t *testing.T) {
 		t.Fatalf("Failed to validate schema: %v", err)
 	}
 }
+
+func TestLoad_ListMergeStrategy(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "nanobot.yaml"), []byte(`
+agents:
+  main:
+    model: gpt-4.1
+    tools: [fs/read, search]
+mcpServers:
+  fs:
+    url: http://localhost:9999/fs
+  search:
+    url: http://localhost:9999/search
+  shell:
+    url: http://localhost:9999/shell
+profiles:
+  more:
+    agents:
+      main:
+        tools: [shell]
+  only:
+    agents:
+      main:
+        tools: [$replace, shell]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		profile string
+		tools   autogold.Value
+	}{
+		{"", autogold.Expect(types.StringList{"fs/read", "search"})},
+		{"more", autogold.Expect(types.StringList{"fs/read", "search", "shell"})},
+		{"only", autogold.Expect(types.StringList{"shell"})},
+	} {
+		t.Run(test.profile, func(t *testing.T) {
+			var profiles []string
+			if test.profile != "" {
+				profiles = append(profiles, test.profile)
+			}
+			cfg, _, err := Load(t.Context(), dir, profiles...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.tools.Equal(t, cfg.Agents["main"].Tools)
+		})
+	}
+}
+
+func TestLoad_When(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "nanobot.yaml"), []byte(`
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+when:
+- if: ${env.ENV === "prod"}
+  config:
+    agents:
+      main:
+        model: gpt-5
+    mcpServers:
+      audit:
+        url: https://audit.example.com/mcp
+- if: ${env.ENV !== "prod"}
+  config:
+    agents:
+      debug:
+        model: gpt-4.1-mini
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		env    string
+		result autogold.Value
+	}{
+		{"prod", autogold.Expect([]string{"main:gpt-5", "server:audit"})},
+		{"dev", autogold.Expect([]string{"debug:gpt-4.1-mini", "main:gpt-4.1"})},
+	} {
+		t.Run(test.env, func(t *testing.T) {
+			cfg, _, err := Load(WithEnv(t.Context(), map[string]string{"ENV": test.env}), dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.When) != 0 {
+				t.Fatalf("expected conditional sections to be removed, got %d", len(cfg.When))
+			}
+
+			var result []string
+			for _, name := range slices.Sorted(maps.Keys(cfg.Agents)) {
+				result = append(result, name+":"+cfg.Agents[name].Model)
+			}
+			for _, name := range slices.Sorted(maps.Keys(cfg.MCPServers)) {
+				result = append(result, "server:"+name)
+			}
+			test.result.Equal(t, result)
+		})
+	}
+}
+
+func TestLoad_InterpolateEnv(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "nanobot.yaml"), []byte(`
+publish:
+  entrypoint: main
+env:
+  MODEL: The model of the agents
+  FALLBACK_MODEL:
+    optional: true
+    default: gpt-4.1-mini
+  TEAM:
+    optional: true
+agents:
+  main:
+    name: Main${TEAM}
+    model: ${MODEL}
+    instructions: You help ${USER}
+  fallback:
+    model: ${FALLBACK_MODEL}
+mcpServers:
+  api:
+    url: http://localhost:9999/mcp
+    headers:
+      Authorization: Bearer ${API_TOKEN}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load(WithEnv(t.Context(), map[string]string{"MODEL": "gpt-5"}), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"Main", "gpt-5", "You help ${USER}", "gpt-4.1-mini", "Bearer ${API_TOKEN}"}).Equal(t, []string{
+		cfg.Agents["main"].Name,
+		cfg.Agents["main"].Model,
+		cfg.Agents["main"].Instructions.Instructions,
+		cfg.Agents["fallback"].Model,
+		cfg.MCPServers["api"].Headers["Authorization"],
+	})
+
+	_, _, err = Load(WithEnv(t.Context(), map[string]string{}), dir)
+	autogold.Expect("error interpolating model of agent main: environment variable MODEL is not set and not marked optional in env").Equal(t, err.Error())
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -24,40 +26,55 @@ type resource struct {
 	static       *types.Config
 }
 
+type httpCacheEntry struct {
+	data []byte
+	etag string
+	last time.Time
+}
+
 var (
-	httpCache map[string]struct {
-		data []byte
-		last time.Time
-	}
+	httpCache     map[string]httpCacheEntry
 	httpCacheLock sync.Mutex
 	refreshTime   = time.Minute * 15
+
+	// AllowedRemoteHosts restricts the hosts that remote configs (http, https, and git) can be loaded from.
+	// An empty list allows any host.
+	AllowedRemoteHosts []string
 )
 
+func checkAllowedHost(host string) error {
+	if len(AllowedRemoteHosts) == 0 || slices.Contains(AllowedRemoteHosts, host) {
+		return nil
+	}
+	return fmt.Errorf("loading config from host %s is not allowed, allowed hosts: %s", host, strings.Join(AllowedRemoteHosts, ", "))
+}
+
 func httpRefresh() {
 	for {
 		time.Sleep(refreshTime)
-		var toRefresh []string
+		toRefresh := map[string]string{}
 
 		httpCacheLock.Lock()
 		for k, v := range httpCache {
 			if time.Since(v.last) > refreshTime {
-				toRefresh = append(toRefresh, k)
+				toRefresh[k] = v.etag
 			}
 		}
 		httpCacheLock.Unlock()
 
-		for _, k := range toRefresh {
-			newData, err := httpGetRaw(context.Background(), k)
+		for k, etag := range toRefresh {
+			newData, newEtag, err := httpGetRaw(context.Background(), k, etag)
 			if err != nil {
 				continue
 			}
 			httpCacheLock.Lock()
-			httpCache[k] = struct {
-				data []byte
-				last time.Time
-			}{
-				data: newData,
+			entry := httpCache[k]
+			if newData != nil {
+				entry.data = newData
+				entry.etag = newEtag
 			}
+			entry.last = time.Now()
+			httpCache[k] = entry
 			httpCacheLock.Unlock()
 		}
 	}
@@ -73,7 +90,7 @@ func httpGet(ctx context.Context, url string) ([]byte, error) {
 	}
 
 	httpCacheLock.Unlock()
-	newContent, err := httpGetRaw(ctx, url)
+	newContent, etag, err := httpGetRaw(ctx, url, "")
 	httpCacheLock.Lock()
 
 	if err != nil {
@@ -81,46 +98,50 @@ func httpGet(ctx context.Context, url string) ([]byte, error) {
 	}
 
 	if httpCache == nil {
-		httpCache = map[string]struct {
-			data []byte
-			last time.Time
-		}{}
+		httpCache = map[string]httpCacheEntry{}
 		go httpRefresh()
 	}
 
-	httpCache[url] = struct {
-		data []byte
-		last time.Time
-	}{
+	httpCache[url] = httpCacheEntry{
 		data: newContent,
+		etag: etag,
 		last: time.Now(),
 	}
 
 	return newContent, nil
 }
 
-func httpGetRaw(ctx context.Context, url string) ([]byte, error) {
+// httpGetRaw fetches the url. If etag is set and the server reports the content as not modified, nil data
+// is returned.
+func httpGetRaw(ctx context.Context, url, etag string) ([]byte, string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request for %s: %w", url, err)
+		return nil, "", fmt.Errorf("error creating request for %s: %w", url, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching %s: %w", url, err)
+		return nil, "", fmt.Errorf("error fetching %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error fetching %s: status code %d", url, resp.StatusCode)
+		return nil, "", fmt.Errorf("error fetching %s: status code %d", url, resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response from %s: %w", url, err)
+		return nil, "", fmt.Errorf("error reading response from %s: %w", url, err)
 	}
 
-	return data, nil
+	return data, resp.Header.Get("ETag"), nil
 }
 
 func (r *resource) Load(ctx context.Context) (result types.Config, _ error) {
@@ -197,6 +218,13 @@ func (r *resource) String() string {
 
 func (r *resource) read(ctx context.Context) ([]byte, error) {
 	if r.resourceType == "http" {
+		u, err := url.Parse(r.url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %s: %w", r.url, err)
+		}
+		if err := checkAllowedHost(u.Hostname()); err != nil {
+			return nil, err
+		}
 		return httpGet(ctx, r.url)
 	}
 
@@ -209,6 +237,9 @@ func (r *resource) read(ctx context.Context) ([]byte, error) {
 	}
 
 	if r.resourceType == "git" {
+		if err := checkAllowedHost(r.parts[0]); err != nil {
+			return nil, err
+		}
 		return gitRead(ctx, r.parts, r.ref)
 	}
 
@@ -265,6 +296,11 @@ func (r *resource) Rel(path string) (*resource, error) {
 		return staticCfg, nil
 	}
 
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		// Remote references can be used from any config
+		return resolve(path)
+	}
+
 	switch r.resourceType {
 	case "http":
 		return &resource{
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func newConfigServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		content, ok := files[req.URL.Path]
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+		etag := `"` + req.URL.Path + `"`
+		rw.Header().Set("ETag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = rw.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLoad_RemoteExtends(t *testing.T) {
+	remote := newConfigServer(t, map[string]string{
+		"/shared/agents.yaml": `
+agents:
+  shared:
+    model: gpt-4.1
+    instructions: from another host
+`,
+	})
+	s := newConfigServer(t, map[string]string{
+		"/config/nanobot.yaml": `
+extends:
+- ./base.yaml
+- ` + remote.URL + `/shared/agents.yaml
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+`,
+		"/config/base.yaml": `
+agents:
+  base:
+    model: gpt-4o
+`,
+	})
+
+	cfg, _, err := Load(t.Context(), s.URL+"/config/nanobot.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var agents []string
+	for name := range cfg.Agents {
+		agents = append(agents, name)
+	}
+	slices.Sort(agents)
+	autogold.Expect([]string{"base", "main", "shared"}).Equal(t, agents)
+	autogold.Expect("from another host").Equal(t, cfg.Agents["shared"].Instructions.Instructions)
+}
+
+func TestLoad_RemoteAllowedHosts(t *testing.T) {
+	s := newConfigServer(t, map[string]string{
+		"/nanobot.yaml": `
+agents:
+  main:
+    model: gpt-4.1
+`,
+	})
+
+	defer func() {
+		AllowedRemoteHosts = nil
+	}()
+	AllowedRemoteHosts = []string{"config.example.com"}
+
+	_, _, err := Load(t.Context(), s.URL+"/nanobot.yaml")
+	if err == nil {
+		t.Fatal("expected error loading config from a host that is not allowed")
+	}
+
+	u, _ := url.Parse(s.URL)
+	AllowedRemoteHosts = []string{u.Hostname()}
+	if _, _, err = Load(t.Context(), s.URL+"/nanobot.yaml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPGetRaw_ETag(t *testing.T) {
+	s := newConfigServer(t, map[string]string{
+		"/nanobot.yaml": "agents: {}",
+	})
+
+	data, etag, err := httpGetRaw(t.Context(), s.URL+"/nanobot.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("agents: {}").Equal(t, string(data))
+	autogold.Expect(`"/nanobot.yaml"`).Equal(t, etag)
+
+	data, etag, err = httpGetRaw(t.Context(), s.URL+"/nanobot.yaml", etag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != nil {
+		t.Fatalf("expected no data for a not modified response, got %q", data)
+	}
+	autogold.Expect(`"/nanobot.yaml"`).Equal(t, etag)
+}
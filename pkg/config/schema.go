@@ -1,8 +1,10 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"sync"
 
@@ -47,3 +49,17 @@ func initSchema() (*jsonschema.Schema, error) {
 
 	return s, nil
 }
+
+// SchemaJSON returns the schema that configs are validated with, as indented JSON, so editors can complete
+// and check config files that point $schema at it.
+func SchemaJSON() ([]byte, error) {
+	data, err := yaml.YAMLToJSON(schemaByte)
+	if err != nil {
+		return nil, fmt.Errorf("error converting schema to JSON: %w", err)
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, fmt.Errorf("error formatting schema: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch calls onChange when the config file at path, or a local file it extends, changes. The files are
+// polled every interval until ctx is done. Only local configs can be watched.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) error {
+	configResource, err := resolve(path)
+	if err != nil {
+		return fmt.Errorf("error resolving config path %s: %w", path, err)
+	}
+	if configResource.resourceType != "path" {
+		return fmt.Errorf("only local configs can be watched, %s is not a local file", path)
+	}
+
+	last := statFiles(watchedFiles(ctx, configResource))
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// The files are found again, because the changed config could extend different files
+			current := statFiles(watchedFiles(ctx, configResource))
+			if !maps.Equal(last, current) {
+				last = current
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
+
+// watchedFiles returns the file of the config and the local files it extends.
+func watchedFiles(ctx context.Context, configResource *resource) []string {
+	file, err := configResource.fileToRead()
+	if err != nil {
+		// Watch the path itself, so it is noticed when the file is created again
+		return []string{configResource.url}
+	}
+
+	files := []string{file}
+	cfg, err := configResource.Load(ctx)
+	if err != nil {
+		return files
+	}
+	for _, parentRef := range cfg.Extends {
+		parentResource, err := configResource.Rel(parentRef)
+		if err != nil || parentResource.resourceType != "path" {
+			continue
+		}
+		if parentFile, err := parentResource.fileToRead(); err == nil {
+			files = append(files, parentFile)
+		} else {
+			files = append(files, parentResource.url)
+		}
+	}
+	return files
+}
+
+func statFiles(files []string) map[string]fileState {
+	result := make(map[string]fileState, len(files))
+	for _, file := range files {
+		var state fileState
+		if info, err := os.Stat(file); err == nil {
+			state = fileState{
+				modTime: info.ModTime(),
+				size:    info.Size(),
+			}
+		}
+		result[file] = state
+	}
+	return result
+}
+
+// Reloader caches the configs read by a ConfigFactory, per profiles, so they are only read again on Reload.
+// A config that fails to read or validate on Reload is reported and the previous config is kept.
+type Reloader struct {
+	read    types.ConfigFactory
+	lock    sync.Mutex
+	configs map[string]types.Config
+}
+
+func NewReloader(read types.ConfigFactory) *Reloader {
+	return &Reloader{
+		read:    read,
+		configs: map[string]types.Config{},
+	}
+}
+
+// reloadedConfigSessionKey is the prefix of the session keys of the configs a session got from a Reloader, by
+// profiles.
+const reloadedConfigSessionKey = "reloadedConfig/"
+
+// Config is a ConfigFactory that returns the cached config for the profiles. A session keeps the config it
+// got for the profiles, so only new sessions, or new profiles of a session, use a reloaded config.
+func (r *Reloader) Config(ctx context.Context, profiles string) (types.Config, error) {
+	session := mcp.SessionFromContext(ctx)
+	sessionKey := reloadedConfigSessionKey + profiles
+	if session != nil {
+		var existing types.Config
+		if session.Get(sessionKey, &existing) {
+			return existing, nil
+		}
+	}
+
+	cfg, err := r.cached(ctx, profiles)
+	if err != nil {
+		return cfg, err
+	}
+	if session != nil {
+		session.Set(sessionKey, &cfg)
+	}
+	return cfg, nil
+}
+
+func (r *Reloader) cached(ctx context.Context, profiles string) (types.Config, error) {
+	r.lock.Lock()
+	cfg, ok := r.configs[profiles]
+	r.lock.Unlock()
+	if ok {
+		return cfg, nil
+	}
+
+	cfg, err := r.read(ctx, profiles)
+	if err != nil {
+		return cfg, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if existing, ok := r.configs[profiles]; ok {
+		return existing, nil
+	}
+	r.configs[profiles] = cfg
+	return cfg, nil
+}
+
+// Reload reads the cached configs again. The configs are swapped together if all of them were read, otherwise
+// the errors are returned and the previous configs are kept.
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.lock.Lock()
+	profiles := slices.Sorted(maps.Keys(r.configs))
+	r.lock.Unlock()
+
+	var (
+		errs    []error
+		configs = make(map[string]types.Config, len(profiles))
+	)
+	for _, profile := range profiles {
+		cfg, err := r.read(ctx, profile)
+		if err != nil {
+			if profile != "" {
+				err = fmt.Errorf("profiles %s: %w", profile, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		configs[profile] = cfg
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.configs = configs
+	return nil
+}
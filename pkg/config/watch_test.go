@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("nanobot.yaml", `
+extends: ./base.yaml
+agents:
+  main:
+    model: gpt-4.1
+`)
+	writeFile("base.yaml", `
+agents:
+  main:
+    instructions: Be brief.
+`)
+
+	changes := make(chan struct{}, 10)
+	if err := Watch(t.Context(), dir, 10*time.Millisecond, func() {
+		changes <- struct{}{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"base.yaml", "nanobot.yaml"} {
+		writeFile(name, `
+agents:
+  main:
+    model: gpt-5
+`)
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("change of %s was not noticed", name)
+		}
+	}
+
+	if err := Watch(t.Context(), "nanobot.default", time.Second, func() {}); err == nil {
+		t.Fatal("expected an error watching a static config")
+	}
+}
+
+func TestReloader(t *testing.T) {
+	var (
+		model = "gpt-4.1"
+		err   error
+	)
+	reloader := NewReloader(func(_ context.Context, profiles string) (types.Config, error) {
+		agent := types.Agent{Model: model}
+		if profiles != "" {
+			agent.Model += "-" + profiles
+		}
+		return types.Config{
+			Agents: map[string]types.Agent{
+				"main": agent,
+			},
+		}, err
+	})
+
+	ctx := t.Context()
+	sessionCtx := mcp.WithSession(ctx, mcp.NewEmptySession(ctx))
+	if _, err := reloader.Config(sessionCtx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	model = "gpt-5"
+	if err := reloader.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed reload keeps the previous config
+	model, err = "broken", os.ErrNotExist
+	if err := reloader.Reload(ctx); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+	model, err = "gpt-5", nil
+
+	newCfg, _ := reloader.Config(ctx, "")
+	sessionCfg, _ := reloader.Config(sessionCtx, "")
+	// A session that asks for other profiles gets the config of those profiles
+	profileCfg, _ := reloader.Config(sessionCtx, "mini")
+	autogold.Expect([]string{"gpt-5", "gpt-4.1", "gpt-5-mini"}).Equal(t, []string{
+		newCfg.Agents["main"].Model,
+		sessionCfg.Agents["main"].Model,
+		profileCfg.Agents["main"].Model,
+	})
+}
@@ -0,0 +1,195 @@
+package envvar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+)
+
+type DotEnvOptions struct {
+	// Lookup resolves variables that are referenced but not defined earlier in the file, typically
+	// from the process environment.
+	Lookup func(key string) (string, bool)
+	// ErrorOnUndefined causes references to undefined variables to fail instead of expanding to "".
+	ErrorOnUndefined bool
+}
+
+func (d DotEnvOptions) Merge(other DotEnvOptions) (result DotEnvOptions) {
+	result.Lookup = d.Lookup
+	if other.Lookup != nil {
+		result.Lookup = other.Lookup
+	}
+	result.ErrorOnUndefined = complete.Last(d.ErrorOnUndefined, other.ErrorOnUndefined)
+	return
+}
+
+// ParseDotEnv parses the contents of a dotenv style file. Lines are of the form KEY=VALUE, optionally
+// prefixed with "export". Values may be double-quoted (supporting escapes and spanning multiple lines),
+// single-quoted (taken literally), or unquoted. A # starts a comment when it begins a line or, for
+// unquoted values, when it is preceded by whitespace.
+//
+// Unquoted and double-quoted values have ${VAR} and $VAR references expanded, in order, from the
+// entries above them and then from opts.Lookup.
+func ParseDotEnv(data string, opts ...DotEnvOptions) (map[string]string, error) {
+	opt := complete.Complete(opts...)
+
+	var (
+		result = map[string]string{}
+		lines  = strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+		undef  []string
+	)
+
+	lookup := func(key string) string {
+		if v, ok := result[key]; ok {
+			return v
+		}
+		if opt.Lookup != nil {
+			if v, ok := opt.Lookup(key); ok {
+				return v
+			}
+		}
+		undef = append(undef, key)
+		return ""
+	}
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "export"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return nil, fmt.Errorf("line %d: missing variable name", lineNo)
+		}
+		if !ok {
+			result[k] = ""
+			continue
+		}
+
+		undef = nil
+		v = strings.TrimLeft(v, " \t")
+		if v == "" || (v[0] != '"' && v[0] != '\'') {
+			v = expand(unquotedValue(v), lookup)
+		} else {
+			var (
+				quote             = v[0]
+				value, rest, done = quotedValue(v[1:], quote, lookup)
+			)
+			for !done && i+1 < len(lines) {
+				i++
+				var more string
+				more, rest, done = quotedValue(lines[i], quote, lookup)
+				value += "\n" + more
+			}
+			if !done {
+				return nil, fmt.Errorf("line %d: unterminated quoted value for %s", lineNo, k)
+			}
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected characters after quoted value for %s: %s", lineNo, k, rest)
+			}
+			v = value
+		}
+
+		if opt.ErrorOnUndefined && len(undef) > 0 {
+			return nil, fmt.Errorf("line %d: %s references undefined variable(s): %s", lineNo, k, strings.Join(undef, ", "))
+		}
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+func unquotedValue(v string) string {
+	for i := 1; i < len(v); i++ {
+		if v[i] == '#' && (v[i-1] == ' ' || v[i-1] == '\t') {
+			v = v[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(v)
+}
+
+// expand replaces ${NAME} and $NAME references in s, in the style of os.Expand. A $ that does not start
+// a valid reference is left as is.
+func expand(s string, lookup func(string) string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		name, n := variableName(s[i+1:])
+		if n == 0 {
+			buf.WriteByte(s[i])
+			continue
+		}
+		buf.WriteString(lookup(name))
+		i += n
+	}
+	return buf.String()
+}
+
+// quotedValue reads s up to the closing quote, returning the value, the remainder after the quote,
+// and whether the closing quote was found. Double-quoted values have escapes and variable references
+// processed.
+func quotedValue(s string, quote byte, lookup func(string) string) (string, string, bool) {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return buf.String(), s[i+1:], true
+		case quote == '"' && c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case '"', '\\', '$':
+				buf.WriteByte(s[i])
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(s[i])
+			}
+		case quote == '"' && c == '$':
+			name, n := variableName(s[i+1:])
+			if n == 0 {
+				buf.WriteByte(c)
+				continue
+			}
+			buf.WriteString(lookup(name))
+			i += n
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), "", false
+}
+
+// variableName parses a ${NAME} or $NAME reference from the text after the $, returning the name and
+// the number of bytes consumed.
+func variableName(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end <= 1 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	n := 0
+	for n < len(s) && (s[n] == '_' || s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z' || n > 0 && s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	return s[:n], n
+}
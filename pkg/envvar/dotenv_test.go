@@ -0,0 +1,100 @@
+package envvar
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	env, err := ParseDotEnv(`# full line comment
+PLAIN=value
+EMPTY=
+NO_VALUE
+SPACES = padded value   
+export EXPORTED=yes
+export   EXPORTED_QUOTED="hello world"
+DOUBLE="  keep  spaces  "
+SINGLE='literal \n $HOME'
+ESCAPED="line1\nline2\t\"quoted\" \\ \$HOME"
+INLINE=value # a comment
+HASH=abc#def
+QUOTED_HASH="value # not a comment" # but this is
+SINGLE_HASH='a # b'
+MULTILINE="first
+second"
+exported=lowercase key
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(map[string]string{
+		"DOUBLE": "  keep  spaces  ", "EMPTY": "", "ESCAPED": "line1\nline2\t\"quoted\" \\ $HOME",
+		"EXPORTED":        "yes",
+		"EXPORTED_QUOTED": "hello world",
+		"HASH":            "abc#def",
+		"INLINE":          "value",
+		"MULTILINE":       "first\nsecond",
+		"NO_VALUE":        "",
+		"PLAIN":           "value",
+		"QUOTED_HASH":     "value # not a comment",
+		"SINGLE":          `literal \n $HOME`,
+		"SINGLE_HASH":     "a # b",
+		"SPACES":          "padded value",
+		"exported":        "lowercase key",
+	}).Equal(t, env)
+}
+
+func TestParseDotEnv_Errors(t *testing.T) {
+	for _, input := range []string{
+		`KEY="unterminated`,
+		`KEY="value" trailing`,
+		`=value`,
+	} {
+		if _, err := ParseDotEnv(input); err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+}
+
+func TestParseDotEnv_Interpolation(t *testing.T) {
+	processEnv := map[string]string{
+		"HOME": "/home/user",
+		"BASE": "from-process",
+	}
+	env, err := ParseDotEnv(`BASE=http://localhost:8080
+API=${BASE}/api
+V1="$API/v1"
+LITERAL='${BASE}'
+ESCAPED="\${BASE}"
+CACHE=$HOME/.cache
+PRICE=5$ or $5
+MISSING=[${NOT_SET}]
+`, DotEnvOptions{
+		Lookup: func(key string) (string, bool) {
+			v, ok := processEnv[key]
+			return v, ok
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(map[string]string{
+		"API": "http://localhost:8080/api", "BASE": "http://localhost:8080",
+		"CACHE":   "/home/user/.cache",
+		"ESCAPED": "${BASE}",
+		"LITERAL": "${BASE}",
+		"MISSING": "[]",
+		"PRICE":   "5$ or $5",
+		"V1":      "http://localhost:8080/api/v1",
+	}).Equal(t, env)
+}
+
+func TestParseDotEnv_ErrorOnUndefined(t *testing.T) {
+	_, err := ParseDotEnv("A=1\nB=${A}-${NOT_SET}\n", DotEnvOptions{
+		ErrorOnUndefined: true,
+	})
+	autogold.Expect("line 2: B references undefined variable(s): NOT_SET").Equal(t, err.Error())
+}
@@ -0,0 +1,134 @@
+package envvar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SecretProvider resolves a secret reference such as file:///run/secrets/token to its value.
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+type SecretProviderFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretProvidersLock sync.RWMutex
+	secretProviders     = map[string]SecretProvider{
+		"file": SecretProviderFunc(resolveFileSecret),
+		"env":  SecretProviderFunc(resolveEnvSecret),
+	}
+	// reservedSecretSchemes are treated as secret references even when no provider is registered, so that
+	// a missing provider is reported instead of the reference being silently used as the value.
+	reservedSecretSchemes = []string{"vault"}
+)
+
+// RegisterSecretProvider adds or replaces the provider for the given URL scheme.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+	secretProviders[scheme] = provider
+}
+
+// IsSecretRef returns true if value is a reference that should be resolved by a secret provider.
+func IsSecretRef(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	if slices.Contains(reservedSecretSchemes, scheme) {
+		return true
+	}
+	secretProvidersLock.RLock()
+	defer secretProvidersLock.RUnlock()
+	_, ok = secretProviders[scheme]
+	return ok
+}
+
+// ResolveSecret resolves the secret reference using the provider registered for its scheme.
+func ResolveSecret(ctx context.Context, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference: %w", err)
+	}
+
+	secretProvidersLock.RLock()
+	provider, ok := secretProviders[u.Scheme]
+	secretProvidersLock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no secret provider registered for scheme %q", u.Scheme)
+	}
+
+	return provider.Resolve(ctx, u)
+}
+
+// ResolveSecrets replaces every value in env that is a secret reference with the resolved secret.
+func ResolveSecrets(ctx context.Context, env map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		if !IsSecretRef(env[k]) {
+			continue
+		}
+		v, err := ResolveSecret(ctx, env[k])
+		if err != nil {
+			return fmt.Errorf("failed to resolve secret for %s: %w", k, err)
+		}
+		env[k] = v
+	}
+	return nil
+}
+
+// resolveFileSecret reads file:///path. With a #key fragment the file is parsed as a JSON object or a
+// dotenv file and the value of key is returned, otherwise the contents with trailing newlines removed.
+func resolveFileSecret(_ context.Context, ref *url.URL) (string, error) {
+	if ref.Host != "" && ref.Host != "localhost" {
+		return "", fmt.Errorf("file secret reference must be an absolute path (file:///path): %s", ref.Redacted())
+	}
+
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Fragment == "" {
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	values := map[string]any{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		env, err := ParseDotEnv(string(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s as JSON or dotenv: %w", ref.Path, err)
+		}
+		for k, v := range env {
+			values[k] = v
+		}
+	}
+
+	v, ok := values[ref.Fragment]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in %s", ref.Fragment, ref.Path)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func resolveEnvSecret(_ context.Context, ref *url.URL) (string, error) {
+	v, ok := os.LookupEnv(ref.Host)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Host)
+	}
+	return v, nil
+}
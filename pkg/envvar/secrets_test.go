@@ -0,0 +1,46 @@
+package envvar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestResolveSecrets_File(t *testing.T) {
+	dir := t.TempDir()
+	token := filepath.Join(dir, "token")
+	if err := os.WriteFile(token, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds := filepath.Join(dir, "creds.json")
+	if err := os.WriteFile(creds, []byte(`{"user": "admin", "password": "hunter2"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"TOKEN":    "file://" + token,
+		"PASSWORD": "file://" + creds + "#password",
+		"URL":      "https://example.com",
+		"PLAIN":    "value",
+	}
+	if err := ResolveSecrets(t.Context(), env); err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(map[string]string{
+		"PASSWORD": "hunter2", "PLAIN": "value", "TOKEN": "s3cr3t",
+		"URL": "https://example.com",
+	}).Equal(t, env)
+}
+
+func TestResolveSecrets_UnknownScheme(t *testing.T) {
+	err := ResolveSecrets(t.Context(), map[string]string{
+		"TOKEN": "vault://secret/data/app#token",
+	})
+	autogold.Expect(`failed to resolve secret for TOKEN: no secret provider registered for scheme "vault"`).Equal(t, err.Error())
+
+	_, err = ResolveSecret(t.Context(), "unknown://x")
+	autogold.Expect(`no secret provider registered for scheme "unknown"`).Equal(t, err.Error())
+}
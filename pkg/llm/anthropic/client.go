@@ -2,7 +2,6 @@ package anthropic
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,7 +11,9 @@ import (
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/failover"
 	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
@@ -26,6 +27,12 @@ type Config struct {
 	APIKey  string
 	BaseURL string
 	Headers map[string]string
+	// FailoverBaseURLs are tried in order when BaseURL can not be reached or responds with a server error.
+	FailoverBaseURLs []string
+	// StreamTimeouts abort responses that do not start or stall.
+	StreamTimeouts stream.Timeouts
+	// HTTPClient sends the requests to the API, defaults to http.DefaultClient.
+	HTTPClient *http.Client
 }
 
 // NewClient creates a new OpenAI client with the provided API key and base URL.
@@ -33,9 +40,13 @@ func NewClient(cfg Config) *Client {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://api.anthropic.com/v1"
 	}
+	cfg.BaseURL, cfg.FailoverBaseURLs = failover.Normalize(cfg.BaseURL, cfg.FailoverBaseURLs)
 	if cfg.Headers == nil {
 		cfg.Headers = map[string]string{}
 	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
 	if _, ok := cfg.Headers["x-api-key"]; !ok && cfg.APIKey != "" {
 		cfg.Headers["x-api-key"] = cfg.APIKey
 	}
@@ -58,7 +69,7 @@ func (c *Client) Complete(ctx context.Context, completionRequest types.Completio
 	}
 
 	ts := time.Now()
-	resp, err := c.complete(ctx, completionRequest.Agent, req, opts...)
+	resp, err := c.complete(ctx, completionRequest.Agent, req, assistantPrefix(&completionRequest), opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +78,8 @@ func (c *Client) Complete(ctx context.Context, completionRequest types.Completio
 
 }
 
-func (c *Client) complete(ctx context.Context, agentName string, req Request, opts ...types.CompletionOptions) (*Response, error) {
+// complete streams the response to req. The response starts with prefix, which the request prefilled.
+func (c *Client) complete(ctx context.Context, agentName string, req Request, prefix string, opts ...types.CompletionOptions) (*Response, error) {
 	var (
 		opt = complete.Complete(opts...)
 	)
@@ -76,19 +88,15 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 
 	data, _ := json.Marshal(req)
 	log.Messages(ctx, "anthropic-api", true, data)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/messages", bytes.NewBuffer(data))
-	if err != nil {
-		return nil, err
-	}
-	for key, value := range c.Headers {
-		httpReq.Header.Set(key, value)
-	}
+	reqCtx, watchdog := stream.Watch(ctx, c.StreamTimeouts)
+	defer watchdog.Stop()
 
-	httpResp, err := http.DefaultClient.Do(httpReq)
+	httpResp, err := failover.Post(reqCtx, c.HTTPClient, append([]string{c.BaseURL}, c.FailoverBaseURLs...), "/messages", c.Headers, data)
 	if err != nil {
-		return nil, err
+		return nil, watchdog.Err(err)
 	}
 	defer httpResp.Body.Close()
+	httpResp.Body = watchdog.Body(httpResp.Body)
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
 		return nil, fmt.Errorf("failed to get response from Anthropic API: %s %q", httpResp.Status, string(body))
@@ -121,6 +129,43 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 		case "content_block_start":
 			partialJSON = ""
 			resp.Content = append(resp.Content, delta.ContentBlock)
+			if prefix != "" && delta.ContentBlock.Type == "text" && delta.ContentBlock.Text != nil {
+				// The API only returns the continuation of the prefilled text.
+				text := prefix + *delta.ContentBlock.Text
+				resp.Content[contentIndex+1].Text = &text
+				progress.Send(ctx, &types.CompletionProgress{
+					Model:     resp.Model,
+					Agent:     agentName,
+					MessageID: resp.ID,
+					Item: types.CompletionItem{
+						ID:      fmt.Sprintf("%s-%d", resp.ID, contentIndex+1),
+						Partial: true,
+						HasMore: true,
+						Content: &mcp.Content{
+							Type: "text",
+							Text: prefix,
+						},
+					},
+				}, opt.ProgressToken)
+				prefix = ""
+			}
+			if delta.ContentBlock.Type == "tool_use" {
+				// The name is streamed before the arguments, so the call can be shown while they stream
+				progress.Send(ctx, &types.CompletionProgress{
+					Model:     resp.Model,
+					Agent:     agentName,
+					MessageID: resp.ID,
+					Item: types.CompletionItem{
+						ID:      fmt.Sprintf("%s-%d", resp.ID, contentIndex+1),
+						Partial: true,
+						HasMore: true,
+						ToolCall: &types.ToolCall{
+							CallID: delta.ContentBlock.ID,
+							Name:   delta.ContentBlock.Name,
+						},
+					},
+				}, opt.ProgressToken)
+			}
 		case "content_block_delta":
 			switch delta.Delta.Type {
 			case "text_delta":
@@ -189,6 +234,9 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 			if err != nil {
 				return nil, fmt.Errorf("failed to unmarshal message delta: %w", err)
 			}
+			if delta.Usage != nil {
+				resp.Usage = mergeUsage(resp.Usage, delta.Usage)
+			}
 		case "message_stop":
 			// nothing to do, but here for completeness
 		}
@@ -205,3 +253,26 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 
 	return &resp, nil
 }
+
+// mergeUsage returns the usage of message_start updated with the counts that are set in the usage of a
+// message_delta event, which are cumulative.
+func mergeUsage(usage, delta *Usage) *Usage {
+	if usage == nil {
+		return delta
+	}
+	result := *usage
+	for _, field := range []struct{ value, delta **int }{
+		{&result.InputTokens, &delta.InputTokens},
+		{&result.OutputTokens, &delta.OutputTokens},
+		{&result.CacheReadInputTokens, &delta.CacheReadInputTokens},
+		{&result.CacheCreationInputTokens, &delta.CacheCreationInputTokens},
+	} {
+		if *field.delta != nil {
+			*field.value = *field.delta
+		}
+	}
+	if delta.ServerToolUse != nil {
+		result.ServerToolUse = delta.ServerToolUse
+	}
+	return &result
+}
@@ -0,0 +1,202 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestClient_AssistantPrefix(t *testing.T) {
+	var request Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude","role":"assistant","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"\"answer\": 42"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL})
+	resp, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model:           "claude",
+		AssistantPrefix: "{ \n",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "answer in JSON"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefill := request.Messages[len(request.Messages)-1]
+	autogold.Expect("assistant").Equal(t, prefill.Role)
+	autogold.Expect("{").Equal(t, *prefill.Content[0].Text)
+	autogold.Expect(`{"answer": 42}`).Equal(t, resp.Output.Items[0].Content.Text)
+}
+
+func TestClient_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude","role":"assistant","content":[],"usage":{"input_tokens":10,"cache_read_input_tokens":5,"cache_creation_input_tokens":0,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(Config{BaseURL: server.URL}).Complete(t.Context(), types.CompletionRequest{
+		Model: "claude",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&types.Usage{PromptTokens: 15, CompletionTokens: 7, TotalTokens: 22, CachedTokens: 5}).Equal(t, resp.Usage)
+}
+
+func TestClient_FailoverBaseURLs(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude","role":"assistant","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"secondary"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer secondary.Close()
+
+	client := NewClient(Config{BaseURL: primary.URL, FailoverBaseURLs: []string{secondary.URL}})
+	resp, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "claude",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("secondary").Equal(t, resp.Output.Items[0].Content.Text)
+}
+
+func TestClient_StallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, `data: {"type":"message_start","message":{"id":"msg_1","model":"claude","role":"assistant","content":[]}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL, StreamTimeouts: stream.Timeouts{Stall: 50 * time.Millisecond}})
+	_, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "claude",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	autogold.Expect("failed to read response: the response stalled for 50ms").Equal(t, err.Error())
+}
+
+func TestClient_ToolCallProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude","role":"assistant","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":" \"nanobot\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	session := mcp.NewEmptySession(t.Context())
+	var toolCalls []types.ToolCall
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var progress mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+			var completion types.CompletionProgress
+			if err := mcp.JSONCoerce(progress.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Item.ToolCall != nil {
+				toolCalls = append(toolCalls, *completion.Item.ToolCall)
+			}
+		}
+		return nil, nil
+	})
+
+	_, err := NewClient(Config{BaseURL: server.URL}).Complete(mcp.WithSession(t.Context(), session), types.CompletionRequest{
+		Model: "claude",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "search for nanobot"}}},
+			},
+		},
+	}, types.CompletionOptions{ProgressToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]types.ToolCall{
+		{
+			CallID: "toolu_1",
+			Name:   "search",
+		},
+		{
+			Arguments: `{"query":`,
+			CallID:    "toolu_1",
+			Name:      "search",
+		},
+		{
+			Arguments: ` "nanobot"}`,
+			CallID:    "toolu_1",
+			Name:      "search",
+		},
+	}).Equal(t, toolCalls)
+}
@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
@@ -15,6 +16,7 @@ import (
 func toResponse(resp *Response, created time.Time) (*types.CompletionResponse, error) {
 	result := &types.CompletionResponse{
 		Model: resp.Model,
+		Usage: toUsage(resp.Usage),
 		Output: types.Message{
 			ID:      resp.ID,
 			Created: &created,
@@ -147,9 +149,28 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 		}
 	}
 
+	if prefix := assistantPrefix(req); prefix != "" {
+		// Prefill the response, the LLM continues from the last assistant message.
+		result.Messages = append(result.Messages, Message{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: &prefix,
+				},
+			},
+			Role: "assistant",
+		})
+	}
+
 	return result, nil
 }
 
+// assistantPrefix returns the prefix of the response without trailing whitespace, which the API does not
+// allow at the end of the last assistant message.
+func assistantPrefix(req *types.CompletionRequest) string {
+	return strings.TrimRightFunc(req.AssistantPrefix, unicode.IsSpace)
+}
+
 func contentToContent(content []mcp.Content) (result []Content) {
 	for _, item := range content {
 		if item.Type == "text" || item.Type == "" {
@@ -203,3 +224,24 @@ func contentToContent(content []mcp.Content) (result []Content) {
 	}
 	return
 }
+
+// toUsage converts the usage of a response. The input tokens of Anthropic don't include the tokens read from
+// or written to the cache, so they are added to the prompt tokens.
+func toUsage(usage *Usage) *types.Usage {
+	if usage == nil {
+		return nil
+	}
+	value := func(tokens *int) int {
+		if tokens == nil {
+			return 0
+		}
+		return *tokens
+	}
+	result := &types.Usage{
+		PromptTokens:     value(usage.InputTokens) + value(usage.CacheReadInputTokens) + value(usage.CacheCreationInputTokens),
+		CompletionTokens: value(usage.OutputTokens),
+		CachedTokens:     value(usage.CacheReadInputTokens),
+	}
+	result.TotalTokens = result.PromptTokens + result.CompletionTokens
+	return result
+}
@@ -159,6 +159,8 @@ type DeltaEvent struct {
 	Message      Response `json:"message"`
 	ContentBlock Content  `json:"content_block"`
 	Delta        Delta    `json:"delta"`
+	// Usage is the cumulative usage of the message in message_delta events.
+	Usage *Usage `json:"usage"`
 }
 
 type Delta struct {
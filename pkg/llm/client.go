@@ -7,30 +7,72 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/llm/anthropic"
 	"github.com/nanobot-ai/nanobot/pkg/llm/completions"
+	"github.com/nanobot-ai/nanobot/pkg/llm/embeddings"
+	"github.com/nanobot-ai/nanobot/pkg/llm/gemini"
 	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
 	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
-var _ types.Completer = (*Client)(nil)
+// OllamaModelPrefix routes the models that start with it to the Ollama API, without the prefix.
+const OllamaModelPrefix = "ollama/"
+
+var (
+	_ types.Completer = (*Client)(nil)
+	_ types.Embedder  = (*Client)(nil)
+)
 
 type Config struct {
 	DefaultModel string
-	Responses    responses.Config
-	Anthropic    anthropic.Config
+	// DefaultEmbeddingModel is used for embeddings requests that do not set a model. Embeddings use the
+	// OpenAI API settings in Responses.
+	DefaultEmbeddingModel string
+	Responses             responses.Config
+	Anthropic             anthropic.Config
+	Gemini                gemini.Config
+	// Ollama is the OpenAI compatible API of local models, which are the models with the OllamaModelPrefix.
+	// They always use the Chat Completions API, BaseURL defaults to the local Ollama server.
+	Ollama completions.Config
+	// Interceptors wrap the transport of the requests to all providers, see Interceptor.
+	Interceptors []Interceptor
+	// MaxProgressSize limits the size in bytes of each progress notification of a completion, see
+	// progress.WithMaxSize. Zero is no limit.
+	MaxProgressSize int
 }
 
 func NewClient(cfg Config) *Client {
+	cfg.Responses.HTTPClient = interceptedClient(cfg.Responses.HTTPClient, cfg.Interceptors)
+	cfg.Anthropic.HTTPClient = interceptedClient(cfg.Anthropic.HTTPClient, cfg.Interceptors)
+	cfg.Gemini.HTTPClient = interceptedClient(cfg.Gemini.HTTPClient, cfg.Interceptors)
+	cfg.Ollama.HTTPClient = interceptedClient(cfg.Ollama.HTTPClient, cfg.Interceptors)
+	if cfg.Ollama.BaseURL == "" {
+		cfg.Ollama.BaseURL = "http://localhost:11434/v1"
+	}
+
 	return &Client{
 		useCompletions: cfg.Responses.ChatCompletionAPI,
 		defaultModel:   cfg.DefaultModel,
 		completions: completions.NewClient(completions.Config{
-			APIKey:  cfg.Responses.APIKey,
-			BaseURL: cfg.Responses.BaseURL,
-			Headers: cfg.Responses.Headers,
+			APIKey:           cfg.Responses.APIKey,
+			BaseURL:          cfg.Responses.BaseURL,
+			FailoverBaseURLs: cfg.Responses.FailoverBaseURLs,
+			StreamTimeouts:   cfg.Responses.StreamTimeouts,
+			Headers:          cfg.Responses.Headers,
+			HTTPClient:       cfg.Responses.HTTPClient,
 		}),
-		responses: responses.NewClient(cfg.Responses),
-		anthropic: anthropic.NewClient(cfg.Anthropic),
+		embeddings: embeddings.NewClient(embeddings.Config{
+			APIKey:           cfg.Responses.APIKey,
+			BaseURL:          cfg.Responses.BaseURL,
+			FailoverBaseURLs: cfg.Responses.FailoverBaseURLs,
+			Headers:          cfg.Responses.Headers,
+			HTTPClient:       cfg.Responses.HTTPClient,
+		}),
+		defaultEmbeddingModel: cfg.DefaultEmbeddingModel,
+		responses:             responses.NewClient(cfg.Responses),
+		anthropic:             anthropic.NewClient(cfg.Anthropic),
+		gemini:                gemini.NewClient(cfg.Gemini),
+		ollama:                completions.NewClient(cfg.Ollama),
+		maxProgressSize:       cfg.MaxProgressSize,
 	}
 }
 
@@ -40,6 +82,19 @@ type Client struct {
 	completions    *completions.Client
 	responses      *responses.Client
 	anthropic      *anthropic.Client
+	gemini         *gemini.Client
+	ollama         *completions.Client
+
+	embeddings            *embeddings.Client
+	defaultEmbeddingModel string
+	maxProgressSize       int
+}
+
+func (c Client) Embed(ctx context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	if req.Model == "default" || req.Model == "" {
+		req.Model = c.defaultEmbeddingModel
+	}
+	return c.embeddings.Embed(ctx, req)
 }
 
 func (c Client) Complete(ctx context.Context, req types.CompletionRequest, opts ...types.CompletionOptions) (ret *types.CompletionResponse, _ error) {
@@ -53,6 +108,10 @@ func (c Client) Complete(ctx context.Context, req types.CompletionRequest, opts
 	}
 
 	opt := complete.Complete(opts...)
+	ctx = progress.WithMaxSize(ctx, c.maxProgressSize)
+	if opt.ProgressToken != nil && req.OutputSchema != nil {
+		ctx = progress.WithStructuredOutput(ctx)
+	}
 	if opt.ProgressToken != nil && len(req.Input) > 0 {
 		lastMsg := req.Input[len(req.Input)-1]
 		if lastMsg.ID != "" && lastMsg.Role == "user" {
@@ -70,6 +129,13 @@ func (c Client) Complete(ctx context.Context, req types.CompletionRequest, opts
 	if strings.HasPrefix(req.Model, "claude") {
 		return c.anthropic.Complete(ctx, req, opts...)
 	}
+	if strings.HasPrefix(req.Model, "gemini") {
+		return c.gemini.Complete(ctx, req, opts...)
+	}
+	if model, ok := strings.CutPrefix(req.Model, OllamaModelPrefix); ok {
+		req.Model = model
+		return c.ollama.Complete(ctx, req, opts...)
+	}
 	if c.useCompletions {
 		return c.completions.Complete(ctx, req, opts...)
 	}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/llm/completions"
+	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestClient_OllamaModels(t *testing.T) {
+	var requests []string
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request completions.Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.URL.Path, request.Model, r.Header.Get("X-Local")))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, `data: {"id":"resp_1","model":"llama3","choices":[{"index":0,"delta":{"role":"assistant","content":"local"},"finish_reason":"stop"}]}`+"\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ollama.Close)
+
+	openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(openai.Close)
+
+	client := NewClient(Config{
+		Responses: responses.Config{BaseURL: openai.URL},
+		Ollama: completions.Config{
+			BaseURL: ollama.URL,
+			Headers: map[string]string{"X-Local": "true"},
+		},
+	})
+	resp, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "ollama/llama3",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Chat Completions API is used even though the OpenAI models use the Responses API
+	autogold.Expect([]string{"/chat/completions llama3 true"}).Equal(t, requests)
+	autogold.Expect("local").Equal(t, resp.Output.Items[0].Content.Text)
+}
@@ -2,17 +2,21 @@ package completions
 
 import (
 	"bufio"
-	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/failover"
 	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
@@ -26,24 +30,43 @@ type Config struct {
 	APIKey  string
 	BaseURL string
 	Headers map[string]string
+	// FailoverBaseURLs are tried in order when BaseURL can not be reached or responds with a server error.
+	FailoverBaseURLs []string
+	// StreamTimeouts abort responses that do not start or stall.
+	StreamTimeouts stream.Timeouts
+	// HTTPClient sends the requests to the API, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// MaxStreamResumes is how many times a stream that is interrupted before it completes is resumed by
+	// requesting the rest of the response with the text received so far as a partial assistant message.
+	// Defaults to 2, a negative value disables resuming.
+	MaxStreamResumes int
 }
 
+// errStreamInterrupted is returned when the stream ends before the response is complete.
+var errStreamInterrupted = errors.New("stream ended before the response was complete")
+
 // NewClient creates a new OpenAI Chat Completions client with the provided API key and base URL.
 func NewClient(cfg Config) *Client {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://api.openai.com/v1"
 	}
-	// Remove trailing slash from BaseURL to avoid double slashes in URL construction
-	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	// Remove trailing slashes from the base URLs to avoid double slashes in URL construction
+	cfg.BaseURL, cfg.FailoverBaseURLs = failover.Normalize(cfg.BaseURL, cfg.FailoverBaseURLs)
 	if cfg.Headers == nil {
 		cfg.Headers = map[string]string{}
 	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
 	if _, ok := cfg.Headers["Authorization"]; !ok && cfg.APIKey != "" {
 		cfg.Headers["Authorization"] = "Bearer " + cfg.APIKey
 	}
 	if _, ok := cfg.Headers["Content-Type"]; !ok {
 		cfg.Headers["Content-Type"] = "application/json"
 	}
+	if cfg.MaxStreamResumes == 0 {
+		cfg.MaxStreamResumes = 2
+	}
 
 	return &Client{
 		Config: cfg,
@@ -62,7 +85,7 @@ func (c *Client) Complete(ctx context.Context, completionRequest types.Completio
 		return nil, err
 	}
 
-	return toResponse(resp, ts)
+	return toResponse(resp, ts, req.Audio)
 }
 
 func (c *Client) complete(ctx context.Context, agentName string, req Request, opts ...types.CompletionOptions) (*Response, error) {
@@ -73,21 +96,77 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 	req.Stream = true
 	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 
-	data, _ := json.Marshal(req)
-	log.Messages(ctx, "completions-api", true, data)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewBuffer(data))
+	var (
+		messages = req.Messages
+		resp     *Response
+		err      error
+	)
+	for resumes := 0; ; resumes++ {
+		resp, err = c.stream(ctx, agentName, req, resp, opt)
+		if !errors.Is(err, errStreamInterrupted) || resumes >= c.MaxStreamResumes || ctx.Err() != nil || !resumable(resp) {
+			break
+		}
+
+		log.Infof(ctx, "resuming interrupted completions stream (%d/%d): %v", resumes+1, c.MaxStreamResumes, err)
+		req.Messages = messages
+		if text := partialText(resp); text != "" {
+			req.Messages = append(slices.Clone(messages), Message{
+				Role:    "assistant",
+				Content: MessageContent{Text: &text},
+			})
+		} else if resp != nil {
+			// Nothing worth continuing from, so the response is requested again from the start.
+			resp.Choices[0].Message.Reasoning = nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
-	for key, value := range c.Headers {
-		httpReq.Header.Set(key, value)
+
+	respData, err := json.Marshal(resp)
+	if err == nil {
+		log.Messages(ctx, "completions-api", false, respData)
+	}
+
+	return resp, nil
+}
+
+// resumable returns true if an interrupted response can be continued, which is not the case once tool
+// calls or audio have been received because those can not be passed back as a partial message.
+func resumable(resp *Response) bool {
+	if resp == nil {
+		return true
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message == nil {
+		return false
+	}
+	message := resp.Choices[0].Message
+	return len(message.ToolCalls) == 0 && message.Audio == nil && resp.Choices[0].FinishReason == nil
+}
+
+// partialText returns the text content received so far of an interrupted response.
+func partialText(resp *Response) string {
+	if resp == nil || resp.Choices[0].Message.Content.Text == nil {
+		return ""
 	}
+	return *resp.Choices[0].Message.Content.Text
+}
 
-	httpResp, err := http.DefaultClient.Do(httpReq)
+// stream sends the request and reads the streamed response. If resume is set, the streamed deltas are
+// added to it, continuing a response that was interrupted. If the stream ends before the response is
+// complete, the partial response is returned with an error wrapping errStreamInterrupted.
+func (c *Client) stream(ctx context.Context, agentName string, req Request, resume *Response, opt types.CompletionOptions) (*Response, error) {
+	data, _ := json.Marshal(req)
+	log.Messages(ctx, "completions-api", true, data)
+	reqCtx, watchdog := stream.Watch(ctx, c.StreamTimeouts)
+	defer watchdog.Stop()
+
+	httpResp, err := failover.Post(reqCtx, c.HTTPClient, append([]string{c.BaseURL}, c.FailoverBaseURLs...), "/chat/completions", c.Headers, data)
 	if err != nil {
-		return nil, err
+		return nil, watchdog.Err(err)
 	}
 	defer httpResp.Body.Close()
+	httpResp.Body = watchdog.Body(httpResp.Body)
 
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
@@ -97,9 +176,15 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 	var (
 		lines       = bufio.NewScanner(httpResp.Body)
 		resp        Response
-		initialized = false
+		initialized = resume != nil
+		done        = false
 		toolCalls   = make(map[int]*ToolCall)
+		// audio is the decoded audio output, streamed as base64 chunks
+		audio []byte
 	)
+	if resume != nil {
+		resp = *resume
+	}
 
 	for lines.Scan() {
 		line := lines.Text()
@@ -113,6 +198,7 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 		data = strings.TrimSpace(data)
 
 		if data == "[DONE]" {
+			done = true
 			break
 		}
 
@@ -208,6 +294,45 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 				}, opt.ProgressToken)
 			}
 
+			// Handle audio output
+			if delta.Audio != nil && resp.Choices[choice.Index].Message != nil {
+				message := resp.Choices[choice.Index].Message
+				if message.Audio == nil {
+					message.Audio = &MessageAudio{}
+				}
+				if delta.Audio.ID != "" {
+					message.Audio.ID = delta.Audio.ID
+				}
+				if delta.Audio.ExpiresAt != 0 {
+					message.Audio.ExpiresAt = delta.Audio.ExpiresAt
+				}
+				if delta.Audio.Data != "" {
+					data, err := base64.StdEncoding.DecodeString(delta.Audio.Data)
+					if err != nil {
+						return nil, fmt.Errorf("failed to decode streamed audio: %w", err)
+					}
+					audio = append(audio, data...)
+				}
+				if delta.Audio.Transcript != "" {
+					message.Audio.Transcript += delta.Audio.Transcript
+
+					progress.Send(ctx, &types.CompletionProgress{
+						Model:     resp.Model,
+						Agent:     agentName,
+						MessageID: resp.ID,
+						Item: types.CompletionItem{
+							ID:      fmt.Sprintf("%s-transcript", resp.ID),
+							Partial: true,
+							HasMore: !isFinished,
+							Content: &mcp.Content{
+								Type: "text",
+								Text: delta.Audio.Transcript,
+							},
+						},
+					}, opt.ProgressToken)
+				}
+			}
+
 			// Handle tool calls
 			if delta.ToolCalls != nil {
 				for i, toolCall := range delta.ToolCalls {
@@ -224,6 +349,9 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 								Arguments: toolCall.Function.Arguments,
 							},
 						}
+					} else if toolCall.Function.Arguments == "" {
+						// Only the first partial of a call, with its name, is sent without arguments
+						continue
 					} else {
 						// Append to existing tool call arguments
 						toolCalls[index].Function.Arguments += toolCall.Function.Arguments
@@ -250,6 +378,7 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 			// Handle finish reason
 			if choice.FinishReason != nil {
 				resp.Choices[choice.Index].FinishReason = choice.FinishReason
+				done = true
 			}
 
 			// Handle refusal
@@ -259,8 +388,8 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 		}
 	}
 
-	if err := lines.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read streaming response: %w", err)
+	if len(audio) > 0 && resp.Choices[0].Message.Audio != nil {
+		resp.Choices[0].Message.Audio.Data = base64.StdEncoding.EncodeToString(audio)
 	}
 
 	// Convert tool calls map to slice
@@ -271,10 +400,20 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 		}
 	}
 
-	respData, err := json.Marshal(resp)
-	if err == nil {
-		log.Messages(ctx, "completions-api", false, respData)
+	if err := lines.Err(); err != nil {
+		return interrupted(resp, initialized), fmt.Errorf("failed to read streaming response: %w: %w", errStreamInterrupted, err)
+	}
+	if !done {
+		return interrupted(resp, initialized), errStreamInterrupted
 	}
 
 	return &resp, nil
 }
+
+// interrupted returns the partial response of an interrupted stream, nil if nothing was received.
+func interrupted(resp Response, initialized bool) *Response {
+	if !initialized {
+		return nil
+	}
+	return &resp
+}
@@ -0,0 +1,251 @@
+package completions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestClient_Audio(t *testing.T) {
+	var request Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{
+			`{"role":"assistant","audio":{"id":"audio_1","transcript":"Hel"}}`,
+			`{"audio":{"data":"AAEC","transcript":"lo"}}`,
+			`{"audio":{"data":"AwQF"}}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"resp_1\",\"model\":\"gpt-audio\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL})
+	resp, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "gpt-audio",
+		Audio: &types.AgentAudio{Voice: "verse"},
+		Input: []types.Message{
+			{
+				Role: "user",
+				Items: []types.CompletionItem{
+					{Content: &mcp.Content{Type: "text", Text: "what is said here?"}},
+					{Content: &mcp.Content{Type: "audio", Data: "UklGRg==", MIMEType: "audio/wav"}},
+					{Content: &mcp.Content{Type: "audio", Data: "T2dnUw==", MIMEType: "audio/ogg"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([]string{"text", "audio"}).Equal(t, request.Modalities)
+	autogold.Expect(&AudioOutput{Voice: "verse", Format: "pcm16"}).Equal(t, request.Audio)
+	autogold.Expect([]ContentPart{
+		{
+			Type: "text",
+			Text: "what is said here?",
+		},
+		{
+			Type: "input_audio",
+			InputAudio: &InputAudio{
+				Data:   "UklGRg==",
+				Format: "wav",
+			},
+		},
+		{
+			Type: "text",
+			Text: "[Audio: audio/ogg is not supported]",
+		},
+	}).Equal(t, request.Messages[0].Content.ContentParts)
+
+	var contents []mcp.Content
+	for _, item := range resp.Output.Items {
+		contents = append(contents, *item.Content)
+	}
+	autogold.Expect([]mcp.Content{
+		{
+			Type: "text",
+			Text: "Hello",
+		},
+		{
+			Type:     "audio",
+			Data:     "AAECAwQF",
+			MIMEType: "audio/pcm",
+		},
+	}).Equal(t, contents)
+}
+
+// interruptingServer streams the deltas of each response in turn, dropping the connection after the
+// deltas of every response but the last.
+func interruptingServer(t *testing.T, requests *[]Request, responses ...[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		*requests = append(*requests, request)
+		deltas := responses[min(len(*requests), len(responses))-1]
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range deltas {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"resp_%d\",\"model\":\"gpt\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", len(*requests), delta)
+		}
+		if len(*requests) < len(responses) {
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"resp\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestClient_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"resp_1\",\"model\":\"gpt\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"hi\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"resp_1\",\"model\":\"gpt\",\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":8,\"total_tokens\":20,"+
+			"\"prompt_tokens_details\":{\"cached_tokens\":4},\"completion_tokens_details\":{\"reasoning_tokens\":3}}}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(Config{BaseURL: server.URL}).Complete(t.Context(), types.CompletionRequest{
+		Model: "gpt",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&types.Usage{
+		PromptTokens: 12, CompletionTokens: 8, TotalTokens: 20,
+		CachedTokens: 4, ReasoningTokens: 3,
+	}).Equal(t, resp.Usage)
+}
+
+func TestClient_ToolCallProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{
+			`{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"search","arguments":""}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":""}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"{\"query\": \"nanobot\"}"}}]}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"resp_1\",\"model\":\"gpt\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"resp_1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	session := mcp.NewEmptySession(t.Context())
+	var toolCalls []types.ToolCall
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var progress mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+			var completion types.CompletionProgress
+			if err := mcp.JSONCoerce(progress.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Item.ToolCall != nil {
+				toolCalls = append(toolCalls, *completion.Item.ToolCall)
+			}
+		}
+		return nil, nil
+	})
+
+	_, err := NewClient(Config{BaseURL: server.URL}).Complete(mcp.WithSession(t.Context(), session), types.CompletionRequest{
+		Model: "gpt",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "search for nanobot"}}},
+			},
+		},
+	}, types.CompletionOptions{ProgressToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first partial has the name without arguments, later ones without arguments are skipped
+	autogold.Expect([]types.ToolCall{
+		{
+			CallID: "call_1",
+			Name:   "search",
+		},
+		{
+			Arguments: `{"query": "nanobot"}`,
+			CallID:    "call_1",
+			Name:      "search",
+		},
+	}).Equal(t, toolCalls)
+}
+
+func TestClient_ResumeInterruptedStream(t *testing.T) {
+	var requests []Request
+	server := interruptingServer(t, &requests,
+		[]string{`{"role":"assistant","content":"The quick brown"}`},
+		[]string{`{"role":"assistant","content":" fox jumps"}`},
+	)
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL})
+	resp, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "gpt",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "finish the sentence"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(2).Equal(t, len(requests))
+	autogold.Expect("The quick brown").Equal(t, *requests[1].Messages[len(requests[1].Messages)-1].Content.Text)
+	autogold.Expect("assistant").Equal(t, requests[1].Messages[len(requests[1].Messages)-1].Role)
+	autogold.Expect(len(requests[0].Messages)+1).Equal(t, len(requests[1].Messages))
+
+	autogold.Expect("resp_1").Equal(t, resp.Output.ID)
+	autogold.Expect("The quick brown fox jumps").Equal(t, resp.Output.Items[0].Content.Text)
+}
+
+func TestClient_ResumeInterruptedStream_Limit(t *testing.T) {
+	var requests []Request
+	server := interruptingServer(t, &requests,
+		[]string{`{"role":"assistant","content":"a"}`},
+		[]string{`{"content":"b"}`},
+		[]string{`{"content":"c"}`},
+		[]string{`{"content":"d"}`},
+	)
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL})
+	_, err := client.Complete(t.Context(), types.CompletionRequest{
+		Model: "gpt",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "hi"}}},
+			},
+		},
+	})
+	autogold.Expect(true).Equal(t, errors.Is(err, errStreamInterrupted))
+	autogold.Expect(3).Equal(t, len(requests))
+	autogold.Expect("ab").Equal(t, *requests[2].Messages[len(requests[2].Messages)-1].Content.Text)
+}
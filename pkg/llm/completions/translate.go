@@ -4,15 +4,17 @@ import (
 	"encoding/base64"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
-func toResponse(resp *Response, created time.Time) (*types.CompletionResponse, error) {
+func toResponse(resp *Response, created time.Time, audio *AudioOutput) (*types.CompletionResponse, error) {
 	result := &types.CompletionResponse{
 		Model: resp.Model,
+		Usage: toUsage(resp.Usage),
 		Output: types.Message{
 			ID:      resp.ID,
 			Created: &created,
@@ -48,6 +50,33 @@ func toResponse(resp *Response, created time.Time) (*types.CompletionResponse, e
 				})
 			}
 
+			// Handle audio output, the transcript is kept as text so the audio does not need to be sent back
+			if choice.Message.Audio != nil {
+				if choice.Message.Audio.Transcript != "" {
+					result.Output.Items = append(result.Output.Items, types.CompletionItem{
+						ID: fmt.Sprintf("%s-transcript", resp.ID),
+						Content: &mcp.Content{
+							Type: "text",
+							Text: choice.Message.Audio.Transcript,
+						},
+					})
+				}
+				if choice.Message.Audio.Data != "" {
+					format := defaultAudioFormat
+					if audio != nil {
+						format = audio.Format
+					}
+					result.Output.Items = append(result.Output.Items, types.CompletionItem{
+						ID: fmt.Sprintf("%s-audio", resp.ID),
+						Content: &mcp.Content{
+							Type:     "audio",
+							Data:     choice.Message.Audio.Data,
+							MIMEType: audioMIMEType(format),
+						},
+					})
+				}
+			}
+
 			// Handle tool calls
 			for i, toolCall := range choice.Message.ToolCalls {
 				result.Output.Items = append(result.Output.Items, types.CompletionItem{
@@ -124,7 +153,11 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 	}
 
 	// Handle output schema
-	if req.OutputSchema != nil {
+	if req.OutputSchema != nil && req.OutputSchema.JSONMode() {
+		result.ResponseFormat = &ResponseFormat{
+			Type: "json_object",
+		}
+	} else if req.OutputSchema != nil {
 		result.ResponseFormat = &ResponseFormat{
 			Type: "json_schema",
 			JSONSchema: &JSONSchema{
@@ -139,6 +172,21 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 		}
 	}
 
+	// Handle audio output
+	if req.Audio != nil {
+		result.Modalities = []string{"text", "audio"}
+		result.Audio = &AudioOutput{
+			Voice:  req.Audio.Voice,
+			Format: req.Audio.Format,
+		}
+		if result.Audio.Voice == "" {
+			result.Audio.Voice = defaultAudioVoice
+		}
+		if result.Audio.Format == "" {
+			result.Audio.Format = defaultAudioFormat
+		}
+	}
+
 	// Convert messages
 	for _, msg := range req.Input {
 		openAIMsg := Message{
@@ -167,9 +215,14 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 							Type: "image_url",
 							ImageURL: &ImageURL{
 								URL:    item.Content.ToImageURL(),
-								Detail: "auto",
+								Detail: types.ImageDetail(*item.Content, "auto"),
 							},
 						})
+					case "audio":
+						// Audio output of previous turns is not sent back, its transcript is
+						if msg.Role != "assistant" {
+							parts = append(parts, audioPart(item.Content.Data, item.Content.MIMEType))
+						}
 					case "resource":
 						if item.Content.Resource != nil && item.Content.Resource.Annotations != nil && slices.Contains(item.Content.Resource.Annotations.Audience, "assistant") {
 							if _, ok := types.ImageMimeTypes[item.Content.Resource.MIMEType]; ok {
@@ -191,6 +244,8 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 									Type: "text",
 									Text: text,
 								})
+							} else if strings.HasPrefix(item.Content.Resource.MIMEType, "audio/") {
+								parts = append(parts, audioPart(item.Content.Resource.Blob, item.Content.Resource.MIMEType))
 							} else if _, ok := types.PDFMimeTypes[item.Content.Resource.MIMEType]; ok {
 								// For OpenAI completions API, PDFs are not directly supported like in anthropic
 								// Convert to text representation or skip
@@ -289,3 +344,64 @@ func toRequest(req *types.CompletionRequest) (Request, error) {
 
 	return result, nil
 }
+
+const (
+	defaultAudioVoice = "alloy"
+	// defaultAudioFormat is the only audio output format supported when streaming, which the client always does.
+	defaultAudioFormat = "pcm16"
+)
+
+// inputAudioFormat returns the input_audio format for a MIME type, or "" if it is not supported.
+func inputAudioFormat(mimeType string) string {
+	switch strings.Split(mimeType, ";")[0] {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	}
+	return ""
+}
+
+func audioMIMEType(format string) string {
+	switch format {
+	case "mp3":
+		return "audio/mpeg"
+	case "pcm16":
+		return "audio/pcm"
+	}
+	return "audio/" + format
+}
+
+func audioPart(data, mimeType string) ContentPart {
+	if format := inputAudioFormat(mimeType); format != "" {
+		return ContentPart{
+			Type: "input_audio",
+			InputAudio: &InputAudio{
+				Data:   data,
+				Format: format,
+			},
+		}
+	}
+	return ContentPart{
+		Type: "text",
+		Text: fmt.Sprintf("[Audio: %s is not supported]", mimeType),
+	}
+}
+
+func toUsage(usage *Usage) *types.Usage {
+	if usage == nil {
+		return nil
+	}
+	result := &types.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		result.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	if usage.CompletionTokensDetails != nil {
+		result.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return result
+}
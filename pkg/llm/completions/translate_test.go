@@ -0,0 +1,70 @@
+package completions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestToRequest_ImageDetail(t *testing.T) {
+	req, err := toRequest(&types.CompletionRequest{
+		Input: []types.Message{
+			{
+				Role: "user",
+				Items: []types.CompletionItem{
+					{Content: &mcp.Content{Type: "image", Data: "AA==", MIMEType: "image/png", Meta: map[string]any{types.ImageDetailMetaKey: "low"}}},
+					{Content: &mcp.Content{Type: "image", Data: "AQ==", MIMEType: "image/png"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var details []string
+	for _, part := range req.Messages[0].Content.ContentParts {
+		details = append(details, part.ImageURL.Detail)
+	}
+	autogold.Expect([]string{"low", "auto"}).Equal(t, details)
+}
+
+func TestToRequest_OutputMode(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output types.OutputSchema
+		format autogold.Value
+	}{
+		{"schema", types.OutputSchema{
+			Name:   "answer",
+			Schema: []byte(`{"type":"object"}`),
+			Strict: true,
+		}, autogold.Expect(`{"type":"json_schema","json_schema":{"name":"answer","schema":{"type":"object"},"strict":true}}`)},
+		{"oneOf", types.OutputSchema{
+			Name:   "answer",
+			OneOf:  []types.Field{{Description: "A name"}, {Fields: map[string]types.Field{"number(int)": {Description: "A number"}}}},
+			Strict: true,
+		}, autogold.Expect(`{"type":"json_schema","json_schema":{"name":"answer","schema":{"additionalProperties":false,"properties":{"value":{"anyOf":[{"description":"A name","type":"string"},{"additionalProperties":false,"properties":{"number":{"description":"A number","type":"integer"}},"required":["number"],"type":"object"}]}},"required":["value"],"title":"answer","type":"object"},"strict":true}}`)},
+		{"json", types.OutputSchema{
+			Name: "answer",
+			Mode: types.OutputModeJSON,
+		}, autogold.Expect(`{"type":"json_object"}`)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := toRequest(&types.CompletionRequest{
+				OutputSchema: &test.output,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(req.ResponseFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.format.Equal(t, string(data))
+		})
+	}
+}
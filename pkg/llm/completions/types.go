@@ -19,6 +19,13 @@ type Request struct {
 	User             string                `json:"user,omitempty"`
 	Metadata         map[string]any        `json:"metadata,omitempty"`
 	ResponseFormat   *ResponseFormat       `json:"response_format,omitempty"`
+	Modalities       []string              `json:"modalities,omitempty"`
+	Audio            *AudioOutput          `json:"audio,omitempty"`
+}
+
+type AudioOutput struct {
+	Voice  string `json:"voice"`
+	Format string `json:"format"`
 }
 
 type StreamOptions struct {
@@ -33,6 +40,14 @@ type Message struct {
 	ToolCalls    []ToolCall     `json:"tool_calls,omitempty"`
 	ToolCallID   string         `json:"tool_call_id,omitempty"`
 	Refusal      *string        `json:"refusal,omitempty"`
+	Audio        *MessageAudio  `json:"audio,omitempty"`
+}
+
+type MessageAudio struct {
+	ID         string `json:"id,omitempty"`
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
 }
 
 type MessageContent struct {
@@ -67,6 +82,12 @@ type ContentPart struct {
 	Type     string     `json:"type"`
 	Text     string     `json:"text,omitempty"`
 	ImageURL *ImageURL  `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+}
+
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
 }
 
 type ImageURL struct {
@@ -162,6 +183,7 @@ type ChoiceDelta struct {
 	Reasoning    *string       `json:"reasoning,omitempty"`
 	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`
 	Refusal      *string       `json:"refusal,omitempty"`
+	Audio        *MessageAudio `json:"audio,omitempty"`
 }
 
 type ToolCall struct {
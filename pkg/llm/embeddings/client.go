@@ -0,0 +1,116 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm/failover"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type Client struct {
+	Config
+}
+
+type Config struct {
+	APIKey  string
+	BaseURL string
+	Headers map[string]string
+	// FailoverBaseURLs are tried in order when BaseURL can not be reached or responds with a server error.
+	FailoverBaseURLs []string
+	// HTTPClient sends the requests to the API, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a new OpenAI compatible embeddings client with the provided API key and base URL.
+func NewClient(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.openai.com/v1"
+	}
+	cfg.BaseURL, cfg.FailoverBaseURLs = failover.Normalize(cfg.BaseURL, cfg.FailoverBaseURLs)
+	if cfg.Headers == nil {
+		cfg.Headers = map[string]string{}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if _, ok := cfg.Headers["Authorization"]; !ok && cfg.APIKey != "" {
+		cfg.Headers["Authorization"] = "Bearer " + cfg.APIKey
+	}
+	if _, ok := cfg.Headers["Content-Type"]; !ok {
+		cfg.Headers["Content-Type"] = "application/json"
+	}
+
+	return &Client{
+		Config: cfg,
+	}
+}
+
+type Request struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+}
+
+type Response struct {
+	Model string      `json:"model"`
+	Data  []Embedding `json:"data"`
+	Usage *Usage      `json:"usage,omitempty"`
+}
+
+type Embedding struct {
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+type Usage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+func (c *Client) Embed(ctx context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	data, err := json.Marshal(Request{
+		Model:          req.Model,
+		Input:          req.Input,
+		EncodingFormat: "float",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embeddings request: %w", err)
+	}
+
+	httpResp, err := failover.Post(ctx, c.HTTPClient, append([]string{c.BaseURL}, c.FailoverBaseURLs...), "/embeddings", c.Headers, data)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("failed to get response from embeddings API: %s %q", httpResp.Status, string(body))
+	}
+
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+	if len(resp.Data) != len(req.Input) {
+		return nil, fmt.Errorf("embeddings API returned %d embeddings for %d inputs", len(resp.Data), len(req.Input))
+	}
+
+	slices.SortFunc(resp.Data, func(a, b Embedding) int {
+		return a.Index - b.Index
+	})
+
+	result := &types.EmbeddingResponse{
+		Model:      resp.Model,
+		Embeddings: make([][]float64, 0, len(resp.Data)),
+	}
+	for _, embedding := range resp.Data {
+		result.Embeddings = append(result.Embeddings, embedding.Embedding)
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// newTestServer returns an embeddings endpoint that embeds each input as its length and index, returning
+// the embeddings in reverse order.
+func newTestServer(t *testing.T, requests *[]Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+
+		resp := Response{Model: req.Model}
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, Embedding{
+				Index:     i,
+				Embedding: []float64{float64(len(req.Input[i])), float64(i)},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_Embed(t *testing.T) {
+	var requests []Request
+	server := newTestServer(t, &requests)
+
+	client := NewClient(Config{APIKey: "key", BaseURL: server.URL + "/"})
+	resp, err := client.Embed(t.Context(), types.EmbeddingRequest{
+		Model: "text-embedding-3-small",
+		Input: []string{"a", "bb", "ccc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([]Request{{
+		Model:          "text-embedding-3-small",
+		Input:          []string{"a", "bb", "ccc"},
+		EncodingFormat: "float",
+	}}).Equal(t, requests)
+	autogold.Expect(&types.EmbeddingResponse{
+		Model: "text-embedding-3-small",
+		Embeddings: [][]float64{
+			{1, 0},
+			{2, 1},
+			{3, 2},
+		},
+	}).Equal(t, resp)
+}
+
+func TestClient_EmbedError(t *testing.T) {
+	var requests []Request
+	server := newTestServer(t, &requests)
+
+	client := NewClient(Config{APIKey: "wrong", BaseURL: server.URL})
+	_, err := client.Embed(t.Context(), types.EmbeddingRequest{Input: []string{"a"}})
+	autogold.Expect(`failed to get response from embeddings API: 400 Bad Request "unexpected request\n"`).Equal(t, err.Error())
+}
@@ -0,0 +1,62 @@
+// Package failover sends requests to the first of several equivalent API endpoints that is up.
+package failover
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+// Normalize returns the base URL and failover base URLs of a client without trailing slashes, so paths
+// can be appended to them.
+func Normalize(baseURL string, failoverBaseURLs []string) (string, []string) {
+	normalized := make([]string, 0, len(failoverBaseURLs))
+	for _, failoverBaseURL := range failoverBaseURLs {
+		normalized = append(normalized, strings.TrimSuffix(failoverBaseURL, "/"))
+	}
+	return strings.TrimSuffix(baseURL, "/"), normalized
+}
+
+// Post sends body to path on each of baseURLs in order until one of them responds without a server
+// error. A connection error or 5xx response moves on to the next base URL, the response or error of the
+// last one is returned. Other responses, including errors like 4xx, are returned as is because another
+// endpoint would reject the request the same way.
+func Post(ctx context.Context, client *http.Client, baseURLs []string, path string, headers map[string]string, body []byte) (*http.Response, error) {
+	if len(baseURLs) == 0 {
+		return nil, fmt.Errorf("no base URL configured")
+	}
+
+	send := func(baseURL string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return client.Do(req)
+	}
+
+	last := len(baseURLs) - 1
+	for i, baseURL := range baseURLs[:last] {
+		resp, err := send(baseURL)
+		switch {
+		case ctx.Err() != nil:
+			return resp, err
+		case err != nil:
+			log.Errorf(ctx, "failed to send request to %s, trying %s: %v", baseURL, baseURLs[i+1], err)
+		case resp.StatusCode >= http.StatusInternalServerError:
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			log.Errorf(ctx, "request to %s failed with %s, trying %s", baseURL, resp.Status, baseURLs[i+1])
+		default:
+			return resp, nil
+		}
+	}
+	return send(baseURLs[last])
+}
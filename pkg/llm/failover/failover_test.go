@@ -0,0 +1,54 @@
+package failover
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func newServer(t *testing.T, status int, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func post(t *testing.T, baseURLs ...string) string {
+	t.Helper()
+	resp, err := Post(t.Context(), http.DefaultClient, baseURLs, "/path", nil, []byte("{}"))
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.Status + " " + string(body)
+}
+
+func TestPost(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	var (
+		ok          = newServer(t, http.StatusOK, "secondary")
+		unavailable = newServer(t, http.StatusServiceUnavailable, "unavailable")
+		badRequest  = newServer(t, http.StatusBadRequest, "bad request")
+	)
+
+	autogold.Expect("200 OK secondary").Equal(t, post(t, down.URL, ok))
+	autogold.Expect("200 OK secondary").Equal(t, post(t, unavailable, ok))
+	autogold.Expect("400 Bad Request bad request").Equal(t, post(t, badRequest, ok))
+	// The response of the last base URL is returned, even if it is a server error.
+	autogold.Expect("503 Service Unavailable unavailable").Equal(t, post(t, down.URL, unavailable))
+}
+
+func TestNormalize(t *testing.T) {
+	baseURL, failoverBaseURLs := Normalize("https://primary/v1/", []string{"https://secondary/v1/", "https://tertiary/v1"})
+	autogold.Expect("https://primary/v1").Equal(t, baseURL)
+	autogold.Expect([]string{"https://secondary/v1", "https://tertiary/v1"}).Equal(t, failoverBaseURLs)
+}
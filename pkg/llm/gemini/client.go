@@ -0,0 +1,233 @@
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/failover"
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+type Client struct {
+	Config
+}
+
+type Config struct {
+	APIKey  string
+	BaseURL string
+	Headers map[string]string
+	// FailoverBaseURLs are tried in order when BaseURL can not be reached or responds with a server error.
+	FailoverBaseURLs []string
+	// StreamTimeouts abort responses that do not start or stall.
+	StreamTimeouts stream.Timeouts
+	// HTTPClient sends the requests to the API, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a new Gemini client with the provided API key and base URL.
+func NewClient(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	cfg.BaseURL, cfg.FailoverBaseURLs = failover.Normalize(cfg.BaseURL, cfg.FailoverBaseURLs)
+	if cfg.Headers == nil {
+		cfg.Headers = map[string]string{}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if _, ok := cfg.Headers["x-goog-api-key"]; !ok && cfg.APIKey != "" {
+		cfg.Headers["x-goog-api-key"] = cfg.APIKey
+	}
+	if _, ok := cfg.Headers["Content-Type"]; !ok {
+		cfg.Headers["Content-Type"] = "application/json"
+	}
+
+	return &Client{
+		Config: cfg,
+	}
+}
+
+func (c *Client) Complete(ctx context.Context, completionRequest types.CompletionRequest, opts ...types.CompletionOptions) (*types.CompletionResponse, error) {
+	req, err := toRequest(&completionRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := time.Now()
+	resp, err := c.complete(ctx, completionRequest.Agent, completionRequest.Model, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toResponse(resp, ts)
+}
+
+// complete streams the response to req and merges its chunks into one response.
+func (c *Client) complete(ctx context.Context, agentName, model string, req Request, opts ...types.CompletionOptions) (*Response, error) {
+	var (
+		opt = complete.Complete(opts...)
+	)
+
+	data, _ := json.Marshal(req)
+	log.Messages(ctx, "gemini-api", true, data)
+	reqCtx, watchdog := stream.Watch(ctx, c.StreamTimeouts)
+	defer watchdog.Stop()
+
+	path := "/models/" + url.PathEscape(model) + ":streamGenerateContent?alt=sse"
+	httpResp, err := failover.Post(reqCtx, c.HTTPClient, append([]string{c.BaseURL}, c.FailoverBaseURLs...), path, c.Headers, data)
+	if err != nil {
+		return nil, watchdog.Err(err)
+	}
+	defer httpResp.Body.Close()
+	httpResp.Body = watchdog.Body(httpResp.Body)
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("failed to get response from Gemini API: %s %q", httpResp.Status, string(body))
+	}
+
+	var (
+		lines        = bufio.NewScanner(httpResp.Body)
+		resp         Response
+		parts        []Part
+		finishReason string
+	)
+	lines.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for lines.Scan() {
+		line := lines.Text()
+
+		header, body, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(header) != "data" {
+			continue
+		}
+
+		var chunk Response
+		body = strings.TrimSpace(body)
+		if err := json.Unmarshal([]byte(body), &chunk); err != nil {
+			log.Errorf(ctx, "failed to decode event: %v: %s", err, body)
+			continue
+		}
+
+		if resp.ResponseID == "" {
+			resp.ResponseID = chunk.ResponseID
+			if resp.ResponseID == "" {
+				resp.ResponseID = uuid.String()
+			}
+		}
+		if chunk.ModelVersion != "" {
+			resp.ModelVersion = chunk.ModelVersion
+		}
+		if chunk.UsageMetadata != nil {
+			// The usage of each chunk is of the whole response so far
+			resp.UsageMetadata = chunk.UsageMetadata
+		}
+		if chunk.PromptFeedback != nil {
+			resp.PromptFeedback = chunk.PromptFeedback
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+
+		candidate := chunk.Candidates[0]
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+		for _, part := range candidate.Content.Parts {
+			var index int
+			parts, index = mergePart(parts, part)
+			sendProgress(ctx, resp, agentName, index, part, opt.ProgressToken)
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	resp.Candidates = []Candidate{
+		{
+			Content: Content{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: finishReason,
+		},
+	}
+
+	respData, err := json.Marshal(resp)
+	if err == nil {
+		log.Messages(ctx, "gemini-api", false, respData)
+	}
+
+	return &resp, nil
+}
+
+// mergePart adds a streamed part to the parts of the response. Text is streamed in many parts, which are joined
+// with the previous part if it is text of the same kind. It returns the index of the part the streamed part was
+// added to.
+func mergePart(parts []Part, part Part) ([]Part, int) {
+	last := len(parts) - 1
+	if last < 0 || part.FunctionCall != nil || part.InlineData != nil ||
+		parts[last].FunctionCall != nil || parts[last].InlineData != nil || parts[last].Thought != part.Thought {
+		return append(parts, part), last + 1
+	}
+
+	parts[last].Text += part.Text
+	if part.ThoughtSignature != "" {
+		parts[last].ThoughtSignature = part.ThoughtSignature
+	}
+	return parts, last
+}
+
+// sendProgress sends the streamed part as a partial item of the part at index of the response.
+func sendProgress(ctx context.Context, resp Response, agentName string, index int, part Part, progressToken any) {
+	item := types.CompletionItem{
+		ID:      fmt.Sprintf("%s-%d", resp.ResponseID, index),
+		Partial: true,
+		HasMore: true,
+	}
+
+	switch {
+	case part.FunctionCall != nil:
+		args, err := arguments(part.FunctionCall)
+		if err != nil {
+			return
+		}
+		item.ToolCall = &types.ToolCall{
+			CallID:    callID(resp.ResponseID, index, part.FunctionCall),
+			Name:      part.FunctionCall.Name,
+			Arguments: args,
+		}
+	case part.Text == "" || part.InlineData != nil:
+		return
+	case part.Thought:
+		item.Reasoning = &types.Reasoning{
+			Summary: []types.SummaryText{{Text: part.Text}},
+		}
+	default:
+		item.Content = &mcp.Content{
+			Type: "text",
+			Text: part.Text,
+		}
+	}
+
+	progress.Send(ctx, &types.CompletionProgress{
+		Model:     resp.ModelVersion,
+		Agent:     agentName,
+		MessageID: resp.ResponseID,
+		Item:      item,
+	}, progressToken)
+}
@@ -0,0 +1,193 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestClient_Request(t *testing.T) {
+	var (
+		path    string
+		request Request
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, `data: {"responseId":"resp_2","candidates":[{"content":{"role":"model","parts":[{"text":"done"}]}}]}`+"\n\n")
+	}))
+	defer server.Close()
+
+	_, err := NewClient(Config{BaseURL: server.URL}).Complete(t.Context(), types.CompletionRequest{
+		Model:        "gemini-2.5-pro",
+		SystemPrompt: "be helpful",
+		ToolChoice:   "search",
+		OutputSchema: &types.OutputSchema{
+			Name:   "result",
+			Schema: json.RawMessage(`{"type":"object"}`),
+		},
+		Tools: []types.ToolUseDefinition{
+			{
+				Name:        "search",
+				Description: "search the web",
+				Parameters:  json.RawMessage(`{"type":"object"}`),
+			},
+		},
+		Input: []types.Message{
+			{
+				Role: "user",
+				Items: []types.CompletionItem{
+					{Content: &mcp.Content{Type: "text", Text: "search for nanobot"}},
+					{Content: &mcp.Content{Type: "text"}},
+				},
+			},
+			{
+				Role: "assistant",
+				Items: []types.CompletionItem{
+					{Reasoning: &types.Reasoning{EncryptedContent: "signature"}},
+					{ToolCall: &types.ToolCall{CallID: "gemini-resp_1-0", Name: "search", Arguments: `{"query":"nanobot"}`}},
+				},
+			},
+			{
+				Role: "user",
+				Items: []types.CompletionItem{{ToolCallResult: &types.ToolCallResult{
+					CallID: "gemini-resp_1-0",
+					Output: types.CallResult{Content: []mcp.Content{{Type: "text", Text: "found it"}}},
+				}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect("/models/gemini-2.5-pro:streamGenerateContent").Equal(t, path)
+	data, err := json.MarshalIndent(request.Contents, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`[
+  {
+    "role": "user",
+    "parts": [
+      {
+        "text": "search for nanobot"
+      }
+    ]
+  },
+  {
+    "role": "model",
+    "parts": [
+      {
+        "functionCall": {
+          "name": "search",
+          "args": {
+            "query": "nanobot"
+          }
+        },
+        "thoughtSignature": "signature"
+      }
+    ]
+  },
+  {
+    "role": "user",
+    "parts": [
+      {
+        "functionResponse": {
+          "name": "search",
+          "response": {
+            "output": "found it"
+          }
+        }
+      }
+    ]
+  }
+]`).Equal(t, string(data))
+	autogold.Expect(&Content{Parts: []Part{{Text: "be helpful"}}}).Equal(t, request.SystemInstruction)
+	autogold.Expect(&ToolConfig{FunctionCallingConfig: FunctionCallingConfig{
+		Mode:                 "ANY",
+		AllowedFunctionNames: []string{"search"},
+	}}).Equal(t, request.ToolConfig)
+	autogold.Expect("application/json").Equal(t, request.GenerationConfig.ResponseMIMEType)
+	autogold.Expect(`{"type":"object"}`).Equal(t, string(request.Tools[0].FunctionDeclarations[0].ParametersJSONSchema))
+}
+
+func TestClient_Response(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"responseId":"resp_1","modelVersion":"gemini-2.5-pro","candidates":[{"content":{"role":"model","parts":[{"text":"Thinking ","thought":true}]}}]}`,
+			`{"responseId":"resp_1","candidates":[{"content":{"role":"model","parts":[{"text":"about it","thought":true}]}}]}`,
+			`{"responseId":"resp_1","candidates":[{"content":{"role":"model","parts":[{"text":"Let me "}]}}]}`,
+			`{"responseId":"resp_1","candidates":[{"content":{"role":"model","parts":[{"text":"search."}]}}]}`,
+			`{"responseId":"resp_1","candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"search","args":{"query":"nanobot"}},"thoughtSignature":"signature"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3,"totalTokenCount":18}}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(Config{BaseURL: server.URL}).Complete(t.Context(), types.CompletionRequest{
+		Model: "gemini-2.5-pro",
+		Input: []types.Message{
+			{
+				Role:  "user",
+				Items: []types.CompletionItem{{Content: &mcp.Content{Type: "text", Text: "search for nanobot"}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Output.Created = nil
+	autogold.Expect(&types.CompletionResponse{
+		Output: types.Message{
+			ID:   "resp_1",
+			Role: "assistant",
+			Items: []types.CompletionItem{
+				{
+					ID: "resp_1-0",
+					Reasoning: &types.Reasoning{Summary: []types.SummaryText{
+						{Text: "Thinking about it"},
+					}},
+				},
+				{
+					ID: "resp_1-1",
+					Content: &mcp.Content{
+						Type: "text",
+						Text: "Let me search.",
+					},
+				},
+				{
+					ID:        "resp_1-2-signature",
+					Reasoning: &types.Reasoning{EncryptedContent: "signature"},
+				},
+				{
+					ID: "resp_1-2",
+					ToolCall: &types.ToolCall{
+						Arguments: `{"query":"nanobot"}`,
+						CallID:    "gemini-resp_1-2",
+						Name:      "search",
+					},
+				},
+			},
+		},
+		Model: "gemini-2.5-pro",
+		Usage: &types.Usage{
+			PromptTokens:     10,
+			CompletionTokens: 8,
+			TotalTokens:      18,
+			ReasoningTokens:  3,
+		},
+	}).Equal(t, resp)
+}
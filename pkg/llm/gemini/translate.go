@@ -0,0 +1,346 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// generatedCallIDPrefix marks the IDs of function calls that the API returned without an ID. They are not sent
+// back, the API matches the responses to these calls by their order.
+const generatedCallIDPrefix = "gemini-"
+
+// thinkingBudgets are the tokens the model can think for per reasoning effort.
+var thinkingBudgets = map[string]int{
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+}
+
+func toResponse(resp *Response, created time.Time) (*types.CompletionResponse, error) {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("gemini blocked the prompt: %s", resp.PromptFeedback.BlockReason)
+	}
+
+	result := &types.CompletionResponse{
+		Model: resp.ModelVersion,
+		Usage: toUsage(resp.UsageMetadata),
+		Output: types.Message{
+			ID:      resp.ResponseID,
+			Created: &created,
+			Role:    "assistant",
+		},
+	}
+	if len(resp.Candidates) == 0 {
+		return result, nil
+	}
+
+	for partIndex, part := range resp.Candidates[0].Content.Parts {
+		id := fmt.Sprintf("%s-%d", resp.ResponseID, partIndex)
+		if part.Thought {
+			result.Output.Items = append(result.Output.Items, types.CompletionItem{
+				ID: id,
+				Reasoning: &types.Reasoning{
+					EncryptedContent: part.ThoughtSignature,
+					Summary:          []types.SummaryText{{Text: part.Text}},
+				},
+			})
+			continue
+		}
+
+		if part.ThoughtSignature != "" {
+			// The signature is sent back with the part that follows the reasoning
+			result.Output.Items = append(result.Output.Items, types.CompletionItem{
+				ID: id + "-signature",
+				Reasoning: &types.Reasoning{
+					EncryptedContent: part.ThoughtSignature,
+				},
+			})
+		}
+
+		switch {
+		case part.FunctionCall != nil:
+			args, err := arguments(part.FunctionCall)
+			if err != nil {
+				return nil, err
+			}
+			result.Output.Items = append(result.Output.Items, types.CompletionItem{
+				ID: id,
+				ToolCall: &types.ToolCall{
+					CallID:    callID(resp.ResponseID, partIndex, part.FunctionCall),
+					Name:      part.FunctionCall.Name,
+					Arguments: args,
+				},
+			})
+		case part.InlineData != nil:
+			contentType := "image"
+			if strings.HasPrefix(part.InlineData.MIMEType, "audio/") {
+				contentType = "audio"
+			}
+			result.Output.Items = append(result.Output.Items, types.CompletionItem{
+				ID: id,
+				Content: &mcp.Content{
+					Type:     contentType,
+					MIMEType: part.InlineData.MIMEType,
+					Data:     part.InlineData.Data,
+				},
+			})
+		case part.Text != "":
+			result.Output.Items = append(result.Output.Items, types.CompletionItem{
+				ID: id,
+				Content: &mcp.Content{
+					Type: "text",
+					Text: part.Text,
+				},
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// arguments returns the arguments of the function call as JSON, an empty object if it has none.
+func arguments(call *FunctionCall) (string, error) {
+	if call.Args == nil {
+		return "{}", nil
+	}
+	args, err := json.Marshal(call.Args)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal function call arguments: %w", err)
+	}
+	return string(args), nil
+}
+
+// callID returns the ID of the function call, or one made up from the response if the API did not return one.
+func callID(responseID string, partIndex int, call *FunctionCall) string {
+	if call.ID != "" {
+		return call.ID
+	}
+	return fmt.Sprintf("%s%s-%d", generatedCallIDPrefix, responseID, partIndex)
+}
+
+func toRequest(req *types.CompletionRequest) (Request, error) {
+	result := Request{
+		GenerationConfig: &GenerationConfig{
+			MaxOutputTokens: req.MaxTokens,
+			Temperature:     req.Temperature,
+			TopP:            req.TopP,
+		},
+	}
+
+	var system []Part
+	if prompt := strings.TrimSpace(req.SystemPrompt); prompt != "" {
+		system = append(system, Part{Text: prompt})
+	}
+
+	if len(req.Tools) > 0 {
+		var declarations []FunctionDeclaration
+		for _, tool := range req.Tools {
+			declarations = append(declarations, FunctionDeclaration{
+				Name:                 tool.Name,
+				Description:          tool.Description,
+				ParametersJSONSchema: tool.Parameters,
+			})
+		}
+		result.Tools = []Tool{{FunctionDeclarations: declarations}}
+	}
+
+	switch req.ToolChoice {
+	case "":
+	case "auto":
+		result.ToolConfig = &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "AUTO"}}
+	case "none":
+		result.ToolConfig = &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "NONE"}}
+	case "required":
+		result.ToolConfig = &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "ANY"}}
+	default:
+		result.ToolConfig = &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{req.ToolChoice},
+		}}
+	}
+
+	if req.OutputSchema != nil {
+		result.GenerationConfig.ResponseMIMEType = "application/json"
+		if !req.OutputSchema.JSONMode() {
+			result.GenerationConfig.ResponseJSONSchema = req.OutputSchema.ToSchema()
+		}
+	}
+
+	if req.Reasoning != nil {
+		result.GenerationConfig.ThinkingConfig = &ThinkingConfig{
+			IncludeThoughts: true,
+		}
+		if budget, ok := thinkingBudgets[req.Reasoning.Effort]; ok {
+			result.GenerationConfig.ThinkingConfig.ThinkingBudget = &budget
+		}
+	}
+
+	var (
+		// The function responses need the name of the function, which is only known from the call
+		callNames = map[string]string{}
+		signature string
+	)
+	for _, msg := range req.Input {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		for _, input := range msg.Items {
+			switch {
+			case input.Content != nil && msg.Role == "system":
+				system = append(system, contentToParts([]mcp.Content{*input.Content})...)
+			case input.Content != nil:
+				parts := contentToParts([]mcp.Content{*input.Content})
+				if len(parts) > 0 && role == "model" {
+					parts[0].ThoughtSignature, signature = signature, ""
+				}
+				result.Contents = appendParts(result.Contents, role, parts...)
+			case input.Reasoning != nil:
+				// The summaries of the thinking are not sent back, only its signature
+				signature = input.Reasoning.EncryptedContent
+			case input.ToolCall != nil:
+				args := map[string]any{}
+				if input.ToolCall.Arguments != "" {
+					if err := json.Unmarshal([]byte(input.ToolCall.Arguments), &args); err != nil {
+						return Request{}, fmt.Errorf("failed to unmarshal tool call arguments: %w", err)
+					}
+				}
+				callNames[input.ToolCall.CallID] = input.ToolCall.Name
+				result.Contents = appendParts(result.Contents, "model", Part{
+					FunctionCall: &FunctionCall{
+						ID:   sentCallID(input.ToolCall.CallID),
+						Name: input.ToolCall.Name,
+						Args: args,
+					},
+					ThoughtSignature: signature,
+				})
+				signature = ""
+			case input.ToolCallResult != nil:
+				response, parts := toolResponse(input.ToolCallResult.Output)
+				result.Contents = appendParts(result.Contents, "user", append([]Part{{
+					FunctionResponse: &FunctionResponse{
+						ID:       sentCallID(input.ToolCallResult.CallID),
+						Name:     callNames[input.ToolCallResult.CallID],
+						Response: response,
+					},
+				}}, parts...)...)
+			}
+		}
+	}
+
+	if len(system) > 0 {
+		result.SystemInstruction = &Content{Parts: system}
+	}
+
+	return result, nil
+}
+
+// sentCallID returns the ID of a function call as it is sent to the API, which is empty for IDs that were made
+// up by callID.
+func sentCallID(id string) string {
+	if strings.HasPrefix(id, generatedCallIDPrefix) {
+		return ""
+	}
+	return id
+}
+
+// appendParts adds the parts to the last content if it has the same role, because the API expects the roles to
+// alternate and the responses of parallel function calls to be in one content.
+func appendParts(contents []Content, role string, parts ...Part) []Content {
+	if len(parts) == 0 {
+		return contents
+	}
+	if last := len(contents) - 1; last >= 0 && contents[last].Role == role {
+		contents[last].Parts = append(contents[last].Parts, parts...)
+		return contents
+	}
+	return append(contents, Content{
+		Role:  role,
+		Parts: parts,
+	})
+}
+
+// toolResponse returns the text of the tool result as the response of the function, and the other content of
+// the result as parts to add after it.
+func toolResponse(output types.CallResult) (map[string]any, []Part) {
+	var (
+		texts []string
+		parts []Part
+	)
+	for _, part := range contentToParts(output.Content) {
+		if part.InlineData == nil {
+			texts = append(texts, part.Text)
+		} else {
+			parts = append(parts, part)
+		}
+	}
+
+	var value any = strings.Join(texts, "\n")
+	if output.StructuredContent != nil {
+		value = output.StructuredContent
+	}
+	if output.IsError {
+		return map[string]any{"error": value}, parts
+	}
+	return map[string]any{"output": value}, parts
+}
+
+func contentToParts(content []mcp.Content) (result []Part) {
+	for _, item := range content {
+		switch item.Type {
+		case "text", "":
+			// Gemini rejects parts without data
+			if item.Text != "" {
+				result = append(result, Part{Text: item.Text})
+			}
+		case "image", "audio":
+			result = append(result, Part{InlineData: &Blob{
+				MIMEType: item.MIMEType,
+				Data:     item.Data,
+			}})
+		case "resource":
+			if item.Resource == nil || item.Resource.Annotations == nil || !slices.Contains(item.Resource.Annotations.Audience, "assistant") {
+				continue
+			}
+			_, isText := types.TextMimeTypes[item.Resource.MIMEType]
+			_, isImage := types.ImageMimeTypes[item.Resource.MIMEType]
+			_, isPDF := types.PDFMimeTypes[item.Resource.MIMEType]
+			switch {
+			case isText && item.Resource.Blob != "":
+				text, _ := base64.StdEncoding.DecodeString(item.Resource.Blob)
+				result = append(result, Part{Text: string(text)})
+			case isText:
+				result = append(result, Part{Text: item.Resource.Text})
+			case (isImage || isPDF || strings.HasPrefix(item.Resource.MIMEType, "audio/")) && item.Resource.Blob != "":
+				result = append(result, Part{InlineData: &Blob{
+					MIMEType: item.Resource.MIMEType,
+					Data:     item.Resource.Blob,
+				}})
+			}
+		}
+	}
+	return
+}
+
+// toUsage converts the usage of a response. The candidates tokens of Gemini don't include the thoughts tokens, so
+// they are added to the completion tokens.
+func toUsage(usage *UsageMetadata) *types.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &types.Usage{
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+		TotalTokens:      usage.TotalTokenCount,
+		CachedTokens:     usage.CachedContentTokenCount,
+		ReasoningTokens:  usage.ThoughtsTokenCount,
+	}
+}
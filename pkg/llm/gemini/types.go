@@ -0,0 +1,107 @@
+package gemini
+
+import (
+	"encoding/json"
+)
+
+type Request struct {
+	Contents          []Content         `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type Content struct {
+	// Role is either "user" or "model"
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+	// Thought is true for the summaries of the thinking of the model.
+	Thought bool `json:"thought,omitempty"`
+	// ThoughtSignature is the encrypted thinking of the model, which has to be sent back with the part.
+	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+}
+
+type Blob struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type FunctionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+type FunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type ToolConfig struct {
+	FunctionCallingConfig FunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+type FunctionCallingConfig struct {
+	// Mode is either "AUTO", "ANY", or "NONE"
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GenerationConfig struct {
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	Temperature        *json.Number    `json:"temperature,omitempty"`
+	TopP               *json.Number    `json:"topP,omitempty"`
+	ResponseMIMEType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type ThinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+}
+
+// Response is a chunk of a streamed response, or the whole response once the chunks are merged.
+type Response struct {
+	ResponseID     string          `json:"responseId,omitempty"`
+	ModelVersion   string          `json:"modelVersion,omitempty"`
+	Candidates     []Candidate     `json:"candidates,omitempty"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+}
+
+type Candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+}
+
+type PromptFeedback struct {
+	BlockReason string `json:"blockReason,omitempty"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount,omitempty"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount,omitempty"`
+	TotalTokenCount         int `json:"totalTokenCount,omitempty"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+}
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"net/http"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+)
+
+// Interceptor wraps the transport of the requests to the LLM providers, to add authentication, headers or
+// logging. It returns a RoundTripper that can change the request before calling next, and observe or
+// replace the response of next. Like any RoundTripper it should clone a request before changing it.
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is a function that implements http.RoundTripper, to write interceptors.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// interceptedClient returns a copy of base, or http.DefaultClient if it is nil, that sends requests
+// through the interceptors. The first interceptor sees the request first and the response last. Without
+// interceptors base is returned as is.
+func interceptedClient(base *http.Client, interceptors []Interceptor) *http.Client {
+	if len(interceptors) == 0 {
+		return base
+	}
+
+	client := *complete.First(base, http.DefaultClient)
+	transport := complete.First(client.Transport, http.DefaultTransport)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		transport = interceptors[i](transport)
+	}
+	client.Transport = transport
+	return &client
+}
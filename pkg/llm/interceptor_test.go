@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestClient_Interceptors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Tenant", r.Header.Get("X-Tenant"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model": "embed",
+			"data":  []map[string]any{{"index": 0, "embedding": []float64{1}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	var calls []string
+	addHeader := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "request header")
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Tenant", "tenant")
+			resp, err := next.RoundTrip(req)
+			calls = append(calls, "response header")
+			return resp, err
+		})
+	}
+	observe := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "observe "+req.Header.Get("X-Tenant"))
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				calls = append(calls, "observe "+resp.Status+" "+resp.Header.Get("X-Request-Tenant"))
+			}
+			return resp, err
+		})
+	}
+
+	client := NewClient(Config{
+		Responses:    responses.Config{BaseURL: server.URL},
+		Interceptors: []Interceptor{addHeader, observe},
+	})
+	if _, err := client.Embed(t.Context(), types.EmbeddingRequest{Model: "embed", Input: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([]string{"request header", "observe tenant", "observe 200 OK tenant", "response header"}).Equal(t, calls)
+}
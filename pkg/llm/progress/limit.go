@@ -0,0 +1,244 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// truncatedSuffix marks the text of a complete item that was truncated to fit a progress notification.
+const truncatedSuffix = "... [truncated]"
+
+type maxSizeKey struct{}
+
+// WithMaxSize limits the progress sent with Send for the completion in ctx to size bytes of JSON each. A
+// partial item with a larger text, reasoning or tool call arguments delta is split into several partial items
+// with the same ID, which add up to the same delta. The text of a larger complete item is truncated, and the
+// arguments of a larger complete tool call are sent in partial items after it. The content of other larger
+// items is replaced by a notice that it was omitted. Zero is no limit.
+func WithMaxSize(ctx context.Context, size int) context.Context {
+	if size <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, maxSizeKey{}, size)
+}
+
+func progressSize(progress *types.CompletionProgress) int {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// delta returns the text of the item that can be split or truncated.
+func delta(progress *types.CompletionProgress) (*string, bool) {
+	switch {
+	case progress.Item.Content != nil && progress.Item.Content.Type == "text":
+		return &progress.Item.Content.Text, true
+	case progress.Item.ToolCall != nil && progress.Item.ToolCallResult == nil:
+		return &progress.Item.ToolCall.Arguments, true
+	case progress.Item.Reasoning != nil && len(progress.Item.Reasoning.Summary) == 1:
+		return &progress.Item.Reasoning.Summary[0].Text, true
+	}
+	return nil, false
+}
+
+// cloneItem copies the progress and the text content, tool call or reasoning of its item, so the copy's
+// delta can be changed.
+func cloneItem(progress *types.CompletionProgress) *types.CompletionProgress {
+	c := *progress
+	if c.Item.Content != nil {
+		content := *c.Item.Content
+		c.Item.Content = &content
+	}
+	if c.Item.ToolCall != nil {
+		toolCall := *c.Item.ToolCall
+		c.Item.ToolCall = &toolCall
+	}
+	if c.Item.Reasoning != nil {
+		reasoning := *c.Item.Reasoning
+		reasoning.Summary = slices.Clone(reasoning.Summary)
+		c.Item.Reasoning = &reasoning
+	}
+	return &c
+}
+
+// omitted returns a copy of the progress with the content of its item replaced by a notice that it was
+// omitted, so the client still learns about the item. The arguments of a tool call of a result are dropped
+// too if it doesn't fit otherwise.
+func omitted(progress *types.CompletionProgress, maxSize int) *types.CompletionProgress {
+	c := cloneItem(progress)
+	c.Structured = nil
+	notice := mcp.Content{
+		Type: "text",
+		Text: fmt.Sprintf("[omitted, %d bytes is larger than the progress limit of %d bytes]", progressSize(progress), maxSize),
+	}
+	if c.Item.ToolCallResult != nil {
+		result := *c.Item.ToolCallResult
+		result.Output = types.CallResult{
+			IsError: result.Output.IsError,
+			Content: []mcp.Content{notice},
+		}
+		c.Item.ToolCallResult = &result
+		if c.Item.ToolCall != nil && progressSize(c) > maxSize {
+			c.Item.ToolCall.Arguments = ""
+		}
+	} else {
+		c.Item.Content = &notice
+		c.Item.Reasoning = nil
+	}
+	return c
+}
+
+// fit returns the length of the longest prefix of text, cut at a rune boundary, so that the progress is at
+// most max bytes with the prefix and suffix as its delta. It returns 0 if not even one rune fits.
+func fit(progress *types.CompletionProgress, text, suffix string, max int) (n int) {
+	candidate := cloneItem(progress)
+	target, _ := delta(candidate)
+
+	// A prefix longer than max bytes is never small enough, so the search is bounded by max instead of by
+	// the length of text, which would make splitting a long text quadratic.
+	if len(text) > max {
+		end := max
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		text = text[:end]
+	}
+
+	// The prefixes that end at a rune boundary
+	var ends []int
+	for i := range text {
+		if i > 0 {
+			ends = append(ends, i)
+		}
+	}
+	ends = append(ends, len(text))
+
+	lo, hi := 0, len(ends)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		*target = text[:ends[mid]] + suffix
+		if progressSize(candidate) <= max {
+			n = ends[mid]
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	return n
+}
+
+// limit returns the progress to send in place of progress so none is larger than the max size of ctx.
+func limit(ctx context.Context, progress *types.CompletionProgress) []*types.CompletionProgress {
+	maxSize, _ := ctx.Value(maxSizeKey{}).(int)
+	if maxSize <= 0 || progressSize(progress) <= maxSize {
+		return []*types.CompletionProgress{progress}
+	}
+
+	text, ok := delta(progress)
+	if !ok {
+		notice := omitted(progress, maxSize)
+		if progressSize(notice) > maxSize {
+			log.Infof(ctx, "dropping progress of item %s, it is larger than the limit of %d bytes even without its content", progress.Item.ID, maxSize)
+			return nil
+		}
+		log.Infof(ctx, "omitting the content of the progress of item %s, %d bytes is larger than the limit of %d bytes", progress.Item.ID, progressSize(progress), maxSize)
+		return []*types.CompletionProgress{notice}
+	}
+
+	// The chunks are appended to the item by the client, so they must all have the same ID
+	base := cloneItem(progress)
+	if base.Item.ID == "" {
+		base.Item.ID = uuid.String()
+	}
+
+	if !progress.Item.Partial && progress.Item.ToolCall != nil {
+		// Truncated arguments are not valid JSON. The call is sent without them instead, replacing what was
+		// streamed of it so far, and then its arguments in partial chunks that are appended to it.
+		reset := cloneItem(base)
+		reset.Structured = nil
+		reset.Item.ToolCall.Arguments = ""
+		if progressSize(reset) > maxSize {
+			log.Infof(ctx, "dropping progress of item %s, it is larger than the limit of %d bytes even without its arguments", progress.Item.ID, maxSize)
+			return nil
+		}
+		base.Item.Partial = true
+		chunks := split(ctx, base, *text, maxSize)
+		if chunks == nil {
+			return nil
+		}
+		return append([]*types.CompletionProgress{reset}, chunks...)
+	}
+
+	if !progress.Item.Partial {
+		truncated := cloneItem(progress)
+		target, _ := delta(truncated)
+		n := fit(truncated, *text, truncatedSuffix, maxSize)
+		if n == 0 {
+			log.Infof(ctx, "dropping progress of item %s, it is larger than the limit of %d bytes even if truncated", progress.Item.ID, maxSize)
+			return nil
+		}
+		*target = (*text)[:n] + truncatedSuffix
+		return []*types.CompletionProgress{truncated}
+	}
+
+	return split(ctx, base, *text, maxSize)
+}
+
+// split returns partial items with the ID of base that add up to text, each at most maxSize bytes.
+func split(ctx context.Context, base *types.CompletionProgress, text string, maxSize int) []*types.CompletionProgress {
+	// The structured output is only sent with the last chunk, and only if it fits in one
+	structured := base.Structured
+	base = cloneItem(base)
+	base.Structured = nil
+
+	var (
+		result    []*types.CompletionProgress
+		remaining = text
+	)
+	for remaining != "" {
+		n := fit(base, remaining, "", maxSize)
+		if n == 0 {
+			log.Infof(ctx, "dropping progress of item %s, a single character is larger than the limit of %d bytes", base.Item.ID, maxSize)
+			return nil
+		}
+		chunk := cloneItem(base)
+		target, _ := delta(chunk)
+		*target = remaining[:n]
+		result = append(result, chunk)
+		remaining = remaining[n:]
+	}
+
+	if structured != nil && len(result) > 0 {
+		var (
+			last        = result[len(result)-1]
+			lastText, _ = delta(last)
+			_, size     = utf8.DecodeLastRuneInString(*lastText)
+			whole       = cloneItem(last)
+			tail        = cloneItem(last)
+			tailText, _ = delta(tail)
+		)
+		whole.Structured = structured
+		tail.Structured = structured
+		*tailText = (*lastText)[len(*lastText)-size:]
+
+		switch {
+		case progressSize(whole) <= maxSize:
+			result[len(result)-1] = whole
+		case progressSize(tail) <= maxSize:
+			// Split off the last character so it can carry the structured output
+			*lastText = (*lastText)[:len(*lastText)-size]
+			result = append(result, tail)
+		}
+	}
+	return result
+}
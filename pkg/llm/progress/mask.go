@@ -0,0 +1,84 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// ArgumentMasker returns the JSON arguments of a call of the tool, by the name the model calls it, with the
+// values of its sensitive arguments masked. It returns the arguments as is if nothing is masked.
+type ArgumentMasker func(ctx context.Context, tool, arguments string) string
+
+type argumentMaskKey struct{}
+
+type argumentMask struct {
+	mask ArgumentMasker
+	lock sync.Mutex
+	// calls are the partial tool calls streamed so far, by item ID
+	calls map[string]*maskedCall
+}
+
+type maskedCall struct {
+	name      string
+	arguments partialJSONText
+	withheld  bool
+}
+
+// WithArgumentMasker masks the arguments of the tool calls sent with Send for the completion in ctx. The
+// arguments of a complete call are replaced by the masked arguments. The masked arguments of a partial call
+// can't be appended to what was streamed, so its arguments are streamed as is until they have a value that
+// is masked, and the rest of them is withheld.
+func WithArgumentMasker(ctx context.Context, mask ArgumentMasker) context.Context {
+	if mask == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, argumentMaskKey{}, &argumentMask{
+		mask:  mask,
+		calls: map[string]*maskedCall{},
+	})
+}
+
+func (m *argumentMask) apply(ctx context.Context, progress *types.CompletionProgress) *types.CompletionProgress {
+	// The tool call of a result is masked by the call of the tool
+	toolCall := progress.Item.ToolCall
+	if toolCall == nil || toolCall.Arguments == "" || progress.Item.ToolCallResult != nil {
+		return progress
+	}
+
+	masked := cloneItem(progress)
+	if !progress.Item.Partial {
+		masked.Item.ToolCall.Arguments = m.mask(ctx, toolCall.Name, toolCall.Arguments)
+
+		m.lock.Lock()
+		delete(m.calls, progress.Item.ID)
+		m.lock.Unlock()
+		return masked
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	call := m.calls[progress.Item.ID]
+	if call == nil || progress.Item.ID == "" {
+		call = &maskedCall{}
+		if progress.Item.ID != "" {
+			m.calls[progress.Item.ID] = call
+		}
+	}
+	if toolCall.Name != "" {
+		call.name = toolCall.Name
+	}
+	if !call.withheld && call.arguments.add(toolCall.Arguments) {
+		if value, ok := call.arguments.parse(); ok {
+			data, err := json.Marshal(value)
+			call.withheld = err != nil || m.mask(ctx, call.name, string(data)) != string(data)
+		}
+	}
+	if call.withheld {
+		masked.Item.ToolCall.Arguments = ""
+	}
+	return masked
+}
@@ -0,0 +1,167 @@
+package progress
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ParsePartialJSON parses JSON that may have been cut off part way through, as it is while a model is
+// streaming structured output. The result is the value so far: open objects, arrays and string values are
+// closed, and trailing keys or literals that are not complete yet are dropped. ok is false if nothing
+// could be parsed.
+func ParsePartialJSON(data string) (value any, ok bool) {
+	if strings.TrimSpace(data) == "" {
+		return nil, false
+	}
+	if err := json.Unmarshal([]byte(data), &value); err == nil {
+		return value, true
+	}
+
+	var (
+		// stack holds the open containers, '{' or '['. expectKey is true for an object whose next string
+		// is a key.
+		stack     []byte
+		expectKey []bool
+		inString  bool
+		escape    bool
+		isKey     bool
+		inScalar  bool
+		// safe is the offset up to which data, once the containers in safeStack are closed, is valid
+		safe      int
+		safeStack []byte
+	)
+	markSafe := func(i int) {
+		safe = i
+		safeStack = slices.Clone(stack)
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch {
+			case escape:
+				escape = false
+			case c == '\\':
+				escape = true
+			case c == '"':
+				inString = false
+				if !isKey {
+					markSafe(i + 1)
+				}
+			}
+			continue
+		}
+
+		if inScalar {
+			switch c {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				inScalar = false
+				markSafe(i)
+			}
+		}
+
+		switch c {
+		case '{':
+			stack = append(stack, c)
+			expectKey = append(expectKey, true)
+			markSafe(i + 1)
+		case '[':
+			stack = append(stack, c)
+			expectKey = append(expectKey, false)
+			markSafe(i + 1)
+		case '}', ']':
+			if len(stack) == 0 {
+				return nil, false
+			}
+			stack = stack[:len(stack)-1]
+			expectKey = expectKey[:len(expectKey)-1]
+			markSafe(i + 1)
+		case '"':
+			inString = true
+			isKey = len(expectKey) > 0 && expectKey[len(expectKey)-1]
+		case ':':
+			if len(expectKey) > 0 {
+				expectKey[len(expectKey)-1] = false
+			}
+		case ',':
+			if len(stack) > 0 && stack[len(stack)-1] == '{' {
+				expectKey[len(expectKey)-1] = true
+			}
+		case ' ', '\t', '\r', '\n':
+		default:
+			inScalar = true
+		}
+	}
+
+	var candidates []string
+	if inString && !isKey {
+		candidates = append(candidates, trimPartialEscape(data, escape)+`"`+closeJSON(stack))
+	} else if inScalar {
+		candidates = append(candidates, data+closeJSON(stack))
+	}
+	candidates = append(candidates, data[:safe]+closeJSON(safeStack))
+
+	for _, candidate := range candidates {
+		if err := json.Unmarshal([]byte(candidate), &value); err == nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// trimPartialEscape drops an escape sequence that was cut off at the end of an open string.
+func trimPartialEscape(data string, escape bool) string {
+	if escape {
+		return data[:len(data)-1]
+	}
+	if i := strings.LastIndex(data, `\u`); i >= 0 && len(data)-i < 6 && (i == 0 || data[i-1] != '\\') {
+		return data[:i]
+	}
+	return data
+}
+
+func closeJSON(stack []byte) string {
+	var buf strings.Builder
+	for _, c := range slices.Backward(stack) {
+		if c == '{' {
+			buf.WriteByte('}')
+		} else {
+			buf.WriteByte(']')
+		}
+	}
+	return buf.String()
+}
+
+// reparseSize is how much JSON text is streamed, at most, before it is parsed again, so a long string value
+// is still updated while it has no structural character.
+const reparseSize = 256
+
+// partialJSONText is JSON text that is streamed in deltas. Parsing all of it for every delta is quadratic in
+// its length, so it is only parsed again when a delta can change its structure: the delta has a structural
+// character or starts the value of a key, or reparseSize bytes were streamed since it was parsed last.
+type partialJSONText struct {
+	text strings.Builder
+	// last is the last character of the text that is not whitespace
+	last   byte
+	parsed int
+}
+
+// add appends the delta to the text and returns true if the text should be parsed again.
+func (p *partialJSONText) add(delta string) bool {
+	startsValue := p.last == ':'
+	p.text.WriteString(delta)
+	if trimmed := strings.TrimRight(delta, " \t\r\n"); trimmed != "" {
+		p.last = trimmed[len(trimmed)-1]
+	}
+	if !startsValue && !strings.ContainsAny(delta, `{}[]:,"`) && p.text.Len()-p.parsed < reparseSize {
+		return false
+	}
+	p.parsed = p.text.Len()
+	return true
+}
+
+// parse returns the value of the text so far, like ParsePartialJSON.
+func (p *partialJSONText) parse() (any, bool) {
+	return ParsePartialJSON(p.text.String())
+}
@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestParsePartialJSON(t *testing.T) {
+	var got []string
+	for _, data := range []string{
+		``,
+		`{`,
+		`{"na`,
+		`{"name"`,
+		`{"name":`,
+		`{"name": "Al`,
+		`{"name": "Al\`,
+		`{"name": "Al\u00`,
+		`{"name": "Alice", "age": 3`,
+		`{"name": "Alice", "age": 30, "ok": tr`,
+		`{"name": "Alice", "tags": ["a", "b`,
+		`{"name": "Alice", "tags": ["a", "b"], "friends": [{"name": "Bob"}, {`,
+		`{"name": "Alice", "tags": ["a", "b"]}`,
+	} {
+		value, ok := ParsePartialJSON(data)
+		if !ok {
+			got = append(got, "-")
+			continue
+		}
+		out, _ := json.Marshal(value)
+		got = append(got, string(out))
+	}
+
+	autogold.Expect([]string{
+		"-",
+		"{}",
+		"{}",
+		"{}",
+		"{}",
+		`{"name":"Al"}`,
+		`{"name":"Al"}`,
+		`{"name":"Al"}`,
+		`{"age":3,"name":"Alice"}`,
+		`{"age":30,"name":"Alice"}`,
+		`{"name":"Alice","tags":["a","b"]}`,
+		`{"friends":[{"name":"Bob"},{}],"name":"Alice","tags":["a","b"]}`,
+		`{"name":"Alice","tags":["a","b"]}`,
+	}).Equal(t, got)
+}
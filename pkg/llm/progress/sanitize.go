@@ -0,0 +1,95 @@
+package progress
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// TextSanitizer returns text with the markup that must not reach a client removed.
+type TextSanitizer func(text string) string
+
+type textSanitizeKey struct{}
+
+type textSanitize struct {
+	sanitize TextSanitizer
+	lock     sync.Mutex
+	// texts are the partial texts streamed so far, by item ID
+	texts map[string]*sanitizedText
+}
+
+type sanitizedText struct {
+	// raw is the text that was streamed, sent is the sanitized text that was sent for it
+	raw, sent string
+}
+
+// WithTextSanitizer sanitizes the text sent with Send for the completion in ctx. A complete text is
+// replaced by the sanitized text. A partial text is accumulated and sanitized, and only what was added to
+// the sanitized text is sent. The end of the text that could still become markup, like an unclosed tag, is
+// held back until more of it is streamed, and nothing more is sent if the sanitized text changes what was
+// sent already, the complete text replaces it.
+func WithTextSanitizer(ctx context.Context, sanitize TextSanitizer) context.Context {
+	if sanitize == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, textSanitizeKey{}, &textSanitize{
+		sanitize: sanitize,
+		texts:    map[string]*sanitizedText{},
+	})
+}
+
+func (t *textSanitize) apply(progress *types.CompletionProgress) *types.CompletionProgress {
+	content := progress.Item.Content
+	if content == nil || content.Type != "text" {
+		return progress
+	}
+
+	sanitized := cloneItem(progress)
+	if !progress.Item.Partial || progress.Item.ID == "" {
+		sanitized.Item.Content.Text = t.sanitize(content.Text)
+
+		t.lock.Lock()
+		delete(t.texts, progress.Item.ID)
+		t.lock.Unlock()
+		return sanitized
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	text := t.texts[progress.Item.ID]
+	if text == nil {
+		text = &sanitizedText{}
+		t.texts[progress.Item.ID] = text
+	}
+	text.raw += content.Text
+
+	next := t.sanitize(text.raw[:stableEnd(text.raw)])
+	if !strings.HasPrefix(next, text.sent) {
+		sanitized.Item.Content.Text = ""
+		return sanitized
+	}
+	sanitized.Item.Content.Text = next[len(text.sent):]
+	text.sent = next
+	return sanitized
+}
+
+// stableEnd returns the end of the part of text that more text can not turn into markup: text up to an
+// unclosed tag or link destination.
+func stableEnd(text string) int {
+	end := len(text)
+	if i := strings.LastIndexByte(text, '<'); i >= 0 && !strings.Contains(text[i:], ">") && (i+1 == len(text) || startsTag(text[i+1])) {
+		end = i
+	}
+	if i := strings.LastIndex(text[:end], "]("); i >= 0 && !strings.Contains(text[i:end], ")") {
+		end = i
+	}
+	return end
+}
+
+// startsTag returns whether c can follow the '<' that starts a tag.
+func startsTag(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '!' || c == '?'
+}
@@ -2,12 +2,70 @@ package progress
 
 import (
 	"context"
+	"reflect"
+	"sync"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
+type structuredOutputKey struct{}
+
+type structuredOutput struct {
+	lock sync.Mutex
+	// outputs are the texts streamed so far, by item ID
+	outputs map[string]*partialOutput
+}
+
+type partialOutput struct {
+	partialJSONText
+	// value is the value that was sent last
+	value any
+}
+
+// WithStructuredOutput marks the completion in ctx as producing structured output. Text streamed with
+// Send is then also parsed as partial JSON and sent as CompletionProgress.Structured when its value changed.
+func WithStructuredOutput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, structuredOutputKey{}, &structuredOutput{
+		outputs: map[string]*partialOutput{},
+	})
+}
+
+func (s *structuredOutput) add(itemID, delta string) (any, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	output := s.outputs[itemID]
+	if output == nil {
+		output = &partialOutput{}
+		s.outputs[itemID] = output
+	}
+	if !output.add(delta) {
+		return nil, false
+	}
+	value, ok := output.parse()
+	if !ok || reflect.DeepEqual(value, output.value) {
+		return nil, false
+	}
+	output.value = value
+	return value, true
+}
+
+type firstProgressKey struct{}
+
+// WithFirstProgress calls started, once, when the completion in ctx sends its first progress with Send. It
+// is called whether or not the progress is sent to a client, so it marks the first byte of a response.
+func WithFirstProgress(ctx context.Context, started func()) context.Context {
+	var once sync.Once
+	return context.WithValue(ctx, firstProgressKey{}, func() {
+		once.Do(started)
+	})
+}
+
 func Send(ctx context.Context, progress *types.CompletionProgress, progressToken any) {
+	if started, ok := ctx.Value(firstProgressKey{}).(func()); ok {
+		started()
+	}
 	if progressToken == "" || progressToken == nil {
 		return
 	}
@@ -16,10 +74,29 @@ func Send(ctx context.Context, progress *types.CompletionProgress, progressToken
 		return
 	}
 
-	_ = session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
-		ProgressToken: progressToken,
-		Meta: map[string]any{
-			types.CompletionProgressMetaKey: progress,
-		},
-	})
+	if mask, ok := ctx.Value(argumentMaskKey{}).(*argumentMask); ok {
+		progress = mask.apply(ctx, progress)
+	}
+	if sanitize, ok := ctx.Value(textSanitizeKey{}).(*textSanitize); ok {
+		progress = sanitize.apply(progress)
+	}
+
+	if structured, ok := ctx.Value(structuredOutputKey{}).(*structuredOutput); ok &&
+		progress.Item.Partial && progress.Item.ID != "" &&
+		progress.Item.Content != nil && progress.Item.Content.Type == "text" {
+		if value, ok := structured.add(progress.Item.ID, progress.Item.Content.Text); ok {
+			withStructured := *progress
+			withStructured.Structured = value
+			progress = &withStructured
+		}
+	}
+
+	for _, progress := range limit(ctx, progress) {
+		_ = session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+			ProgressToken: progressToken,
+			Meta: map[string]any{
+				types.CompletionProgressMetaKey: progress,
+			},
+		})
+	}
 }
@@ -0,0 +1,287 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestSend_StructuredOutput(t *testing.T) {
+	var (
+		session    = mcp.NewEmptySession(t.Context())
+		ctx        = WithStructuredOutput(mcp.WithSession(t.Context(), session))
+		structured []string
+	)
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var req struct {
+			Meta map[string]types.CompletionProgress `json:"_meta"`
+		}
+		if err := json.Unmarshal(msg.Params, &req); err != nil {
+			return nil, err
+		}
+		data, _ := json.Marshal(req.Meta[types.CompletionProgressMetaKey].Structured)
+		structured = append(structured, string(data))
+		// The session has no wire, so don't send it any further
+		return nil, nil
+	})
+
+	for _, delta := range []string{`{"city": "Par`, `is", "days": [{"temp": 2`, `1}, {"temp": 19}]}`} {
+		Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{
+				ID:      "msg-1",
+				Partial: true,
+				Content: &mcp.Content{
+					Type: "text",
+					Text: delta,
+				},
+			},
+		}, "token")
+	}
+
+	autogold.Expect([]string{
+		`{"city":"Par"}`,
+		`{"city":"Paris","days":[{"temp":2}]}`,
+		`{"city":"Paris","days":[{"temp":21},{"temp":19}]}`,
+	}).Equal(t, structured)
+}
+
+func TestSend_StructuredOutputChanged(t *testing.T) {
+	ctx, progress := recordProgress(t, 10_000)
+	ctx = WithStructuredOutput(ctx)
+
+	long := strings.Repeat("a", reparseSize)
+	for _, delta := range []string{`{"city": "Par`, `is`, `"`, `, `, `"note": "`, "a", long, `"}`} {
+		Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{ID: "msg-1", Partial: true, Content: &mcp.Content{Type: "text", Text: delta}},
+		}, "token")
+	}
+
+	var structured []string
+	for _, p := range *progress {
+		data, _ := json.Marshal(p.Structured)
+		structured = append(structured, strings.ReplaceAll(string(data), long, "<long>"))
+	}
+	// The text is only parsed again for a structural character or a long delta, and the value is only sent
+	// when it changed
+	autogold.Expect([]string{
+		`{"city":"Par"}`,
+		"null",
+		`{"city":"Paris"}`,
+		"null",
+		`{"city":"Paris","note":""}`,
+		"null",
+		`{"city":"Paris","note":"<long>a"}`,
+		"null",
+	}).Equal(t, structured)
+}
+
+// recordProgress returns a context whose progress is recorded in the returned slice.
+func recordProgress(t *testing.T, maxSize int) (context.Context, *[]types.CompletionProgress) {
+	var (
+		session  = mcp.NewEmptySession(t.Context())
+		progress []types.CompletionProgress
+	)
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var req struct {
+			Meta map[string]json.RawMessage `json:"_meta"`
+		}
+		if err := json.Unmarshal(msg.Params, &req); err != nil {
+			return nil, err
+		}
+		data := req.Meta[types.CompletionProgressMetaKey]
+		if len(data) > maxSize {
+			t.Errorf("progress of %d bytes is larger than %d: %s", len(data), maxSize, data)
+		}
+		var p types.CompletionProgress
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+		return nil, nil
+	})
+	return WithMaxSize(mcp.WithSession(t.Context(), session), maxSize), &progress
+}
+
+func TestSend_MaxSizePartial(t *testing.T) {
+	ctx, progress := recordProgress(t, 120)
+
+	text := strings.Repeat("héllo wörld ", 20)
+	Send(ctx, &types.CompletionProgress{
+		MessageID: "msg-1",
+		Item: types.CompletionItem{
+			ID:      "item-1",
+			Partial: true,
+			Content: &mcp.Content{Type: "text", Text: text},
+		},
+	}, "token")
+
+	var (
+		ids    = map[string]struct{}{}
+		joined string
+	)
+	for _, p := range *progress {
+		ids[p.Item.ID] = struct{}{}
+		joined += p.Item.Content.Text
+		if !p.Item.Partial {
+			t.Errorf("chunk of item %s is not partial", p.Item.ID)
+		}
+	}
+	autogold.Expect(true).Equal(t, len(*progress) > 1)
+	autogold.Expect(map[string]struct{}{"item-1": {}}).Equal(t, ids)
+	autogold.Expect(text).Equal(t, joined)
+}
+
+func TestSend_MaxSizeToolCallArguments(t *testing.T) {
+	ctx, progress := recordProgress(t, 150)
+
+	args := `{"query": "` + strings.Repeat("x", 300) + `"}`
+	Send(ctx, &types.CompletionProgress{
+		Item: types.CompletionItem{
+			ID:       "item-1",
+			Partial:  true,
+			ToolCall: &types.ToolCall{CallID: "call-1", Name: "search", Arguments: args},
+		},
+	}, "token")
+
+	var joined string
+	for _, p := range *progress {
+		joined += p.Item.ToolCall.Arguments
+	}
+	autogold.Expect(true).Equal(t, len(*progress) > 1)
+	autogold.Expect(args).Equal(t, joined)
+}
+
+func TestSend_MaxSizeComplete(t *testing.T) {
+	ctx, progress := recordProgress(t, 150)
+
+	Send(ctx, &types.CompletionProgress{
+		Item: types.CompletionItem{
+			ID:      "item-1",
+			Content: &mcp.Content{Type: "text", Text: strings.Repeat("a", 200)},
+		},
+	}, "token")
+
+	autogold.Expect(1).Equal(t, len(*progress))
+	autogold.Expect("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa... [truncated]").Equal(t, (*progress)[0].Item.Content.Text)
+}
+
+func TestSend_MaxSizeStructuredOutput(t *testing.T) {
+	ctx, progress := recordProgress(t, 120)
+	ctx = WithStructuredOutput(ctx)
+
+	for _, delta := range []string{`{"city":` + strings.Repeat(" ", 60) + `"Paris"}`, strings.Repeat(" ", 30) + `{"` + strings.Repeat("P", 60) + `": 1}`} {
+		Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{
+				ID:      "item-1",
+				Partial: true,
+				Content: &mcp.Content{Type: "text", Text: delta},
+			},
+		}, "token")
+	}
+
+	var structured []string
+	for _, p := range *progress {
+		data, _ := json.Marshal(p.Structured)
+		structured = append(structured, string(data))
+	}
+	// The parsed output is only sent with the last chunk of the text it was parsed from, and only if it fits
+	autogold.Expect([]string{"null", "null", `{"city":"Paris"}`, "null", "null", "null"}).Equal(t, structured)
+}
+
+func TestSend_MaxSizeCompleteToolCall(t *testing.T) {
+	ctx, progress := recordProgress(t, 150)
+
+	args := `{"query": "` + strings.Repeat("x", 300) + `"}`
+	Send(ctx, &types.CompletionProgress{
+		Item: types.CompletionItem{
+			ID:       "item-1",
+			ToolCall: &types.ToolCall{CallID: "call-1", Name: "search", Arguments: args},
+		},
+	}, "token")
+
+	// The complete call replaces what was streamed of it, and its arguments are appended to it
+	first := (*progress)[0]
+	autogold.Expect(false).Equal(t, first.Item.Partial)
+	autogold.Expect("").Equal(t, first.Item.ToolCall.Arguments)
+
+	var joined string
+	for _, p := range (*progress)[1:] {
+		if !p.Item.Partial || p.Item.ID != "item-1" {
+			t.Errorf("chunk %+v is not a partial of item-1", p.Item)
+		}
+		joined += p.Item.ToolCall.Arguments
+	}
+	autogold.Expect(true).Equal(t, len(*progress) > 2)
+	autogold.Expect(args).Equal(t, joined)
+}
+
+func TestSend_MaxSizeOmitted(t *testing.T) {
+	ctx, progress := recordProgress(t, 150)
+
+	Send(ctx, &types.CompletionProgress{
+		Item: types.CompletionItem{
+			ID:      "item-1",
+			Content: &mcp.Content{Type: "image", MIMEType: "image/png", Data: strings.Repeat("A", 400)},
+		},
+	}, "token")
+
+	autogold.Expect([]types.CompletionProgress{{
+		Item: types.CompletionItem{
+			ID: "item-1",
+			Content: &mcp.Content{
+				Type: "text",
+				Text: "[omitted, 504 bytes is larger than the progress limit of 150 bytes]",
+				ID:   "item-1",
+			},
+		},
+	}}).Equal(t, *progress)
+}
+
+func TestSend_MaxSizeLongText(t *testing.T) {
+	ctx, progress := recordProgress(t, 1024)
+
+	// Splitting is linear in the length of the text, this would take minutes if it was quadratic
+	text := strings.Repeat("héllo wörld ", 100_000)
+	Send(ctx, &types.CompletionProgress{
+		Item: types.CompletionItem{
+			ID:      "item-1",
+			Partial: true,
+			Content: &mcp.Content{Type: "text", Text: text},
+		},
+	}, "token")
+
+	var joined strings.Builder
+	for _, p := range *progress {
+		joined.WriteString(p.Item.Content.Text)
+	}
+	autogold.Expect(len(text)).Equal(t, joined.Len())
+}
+
+func TestSend_TextSanitizer(t *testing.T) {
+	ctx, progress := recordProgress(t, 1000)
+	ctx = WithTextSanitizer(ctx, func(text string) string {
+		// Removes the <x> tags, and turns "ab" into "X", which changes text that was already sent
+		return strings.ReplaceAll(strings.ReplaceAll(text, "<x>", ""), "ab", "X")
+	})
+
+	send := func(partial bool, text string) {
+		Send(ctx, &types.CompletionProgress{
+			Item: types.CompletionItem{ID: "item-1", Partial: partial, Content: &mcp.Content{Type: "text", Text: text}},
+		}, "token")
+	}
+	for _, delta := range []string{"Hello <", "x>wor", "ld a", "b"} {
+		send(true, delta)
+	}
+	send(false, "Hello <x>world ab")
+
+	var texts []string
+	for _, p := range *progress {
+		texts = append(texts, p.Item.Content.Text)
+	}
+	autogold.Expect([]string{"Hello ", "wor", "ld a", "", "Hello world X"}).Equal(t, texts)
+}
@@ -1,15 +1,15 @@
 package responses
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/failover"
+	"github.com/nanobot-ai/nanobot/pkg/llm/stream"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
@@ -23,6 +23,12 @@ type Config struct {
 	APIKey            string
 	BaseURL           string
 	Headers           map[string]string
+	// FailoverBaseURLs are tried in order when BaseURL can not be reached or responds with a server error.
+	FailoverBaseURLs []string
+	// StreamTimeouts abort responses that do not start or stall.
+	StreamTimeouts stream.Timeouts
+	// HTTPClient sends the requests to the API, defaults to http.DefaultClient.
+	HTTPClient *http.Client
 }
 
 // NewClient creates a new OpenAI client with the provided API key and base URL.
@@ -30,11 +36,14 @@ func NewClient(cfg Config) *Client {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://api.openai.com/v1"
 	}
-	// Remove trailing slash from BaseURL to avoid double slashes in URL construction
-	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	// Remove trailing slashes from the base URLs to avoid double slashes in URL construction
+	cfg.BaseURL, cfg.FailoverBaseURLs = failover.Normalize(cfg.BaseURL, cfg.FailoverBaseURLs)
 	if cfg.Headers == nil {
 		cfg.Headers = map[string]string{}
 	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
 	if _, ok := cfg.Headers["Authorization"]; !ok && cfg.APIKey != "" {
 		cfg.Headers["Authorization"] = "Bearer " + cfg.APIKey
 	}
@@ -72,19 +81,15 @@ func (c *Client) complete(ctx context.Context, agentName string, req Request, op
 
 	data, _ := json.Marshal(req)
 	log.Messages(ctx, "responses-api", true, data)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/responses", bytes.NewBuffer(data))
-	if err != nil {
-		return nil, err
-	}
-	for key, value := range c.Headers {
-		httpReq.Header.Set(key, value)
-	}
+	reqCtx, watchdog := stream.Watch(ctx, c.StreamTimeouts)
+	defer watchdog.Stop()
 
-	httpResp, err := http.DefaultClient.Do(httpReq)
+	httpResp, err := failover.Post(reqCtx, c.HTTPClient, append([]string{c.BaseURL}, c.FailoverBaseURLs...), "/responses", c.Headers, data)
 	if err != nil {
-		return nil, err
+		return nil, watchdog.Err(err)
 	}
 	defer httpResp.Body.Close()
+	httpResp.Body = watchdog.Body(httpResp.Body)
 	if httpResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(httpResp.Body)
 		return nil, fmt.Errorf("failed to get response from OpenAI Responses API: %s %q", httpResp.Status, string(body))
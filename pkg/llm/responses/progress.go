@@ -54,6 +54,8 @@ func progressResponse(ctx context.Context, agentName, modelName string, resp *ht
 							Name:   event.Item.Name,
 						},
 					}
+					// The name is streamed before the arguments, so the call can be shown while they stream
+					llmProgress.Send(ctx, &progress, progressToken)
 				} else if event.Item.Type == "message" {
 					progress.Item = types.CompletionItem{
 						Partial: true,
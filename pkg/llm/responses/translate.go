@@ -26,6 +26,16 @@ func toResponse(req *types.CompletionRequest, resp *Response) (*types.Completion
 		},
 	}
 
+	if resp.Usage != (Usage{}) {
+		result.Usage = &types.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			CachedTokens:     resp.Usage.InputTokensDetails.CachedTokens,
+			ReasoningTokens:  resp.Usage.OutputTokensDetails.ReasoningTokens,
+		}
+	}
+
 	for _, output := range resp.Output {
 		if output.ComputerCall != nil {
 			for _, tool := range req.Tools {
@@ -160,7 +170,13 @@ func toRequest(completion *types.CompletionRequest) (req Request, _ error) {
 		}
 	}
 
-	if completion.OutputSchema != nil {
+	if completion.OutputSchema != nil && completion.OutputSchema.JSONMode() {
+		req.Text = &TextFormatting{
+			Format: Format{
+				JSONObject: &JSONObject{},
+			},
+		}
+	} else if completion.OutputSchema != nil {
 		req.Text = &TextFormatting{
 			Format: Format{
 				JSONSchema: &JSONSchema{
@@ -265,6 +281,7 @@ func contentToInputItem(content mcp.Content) (InputItemContent, bool) {
 		url := content.ToImageURL()
 		return InputItemContent{
 			InputImage: &InputImage{
+				Detail:   types.ImageDetail(content, ""),
 				ImageURL: &url,
 			},
 		}, true
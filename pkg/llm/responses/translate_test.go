@@ -0,0 +1,46 @@
+package responses
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestToRequest_OutputMode(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output types.OutputSchema
+		text   autogold.Value
+	}{
+		{"schema", types.OutputSchema{
+			Name:   "answer",
+			Schema: []byte(`{"type":"object"}`),
+			Strict: true,
+		}, autogold.Expect(`{"format":{"name":"answer","schema":{"type":"object"},"type":"json_schema","strict":true}}`)},
+		{"oneOf", types.OutputSchema{
+			Name:   "answer",
+			OneOf:  []types.Field{{Description: "A name"}, {Fields: map[string]types.Field{"number(int)": {Description: "A number"}}}},
+			Strict: true,
+		}, autogold.Expect(`{"format":{"name":"answer","schema":{"additionalProperties":false,"properties":{"value":{"anyOf":[{"description":"A name","type":"string"},{"additionalProperties":false,"properties":{"number":{"description":"A number","type":"integer"}},"required":["number"],"type":"object"}]}},"required":["value"],"title":"answer","type":"object"},"type":"json_schema","strict":true}}`)},
+		{"json", types.OutputSchema{
+			Name: "answer",
+			Mode: types.OutputModeJSON,
+		}, autogold.Expect(`{"format":{"type":"json_object"}}`)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := toRequest(&types.CompletionRequest{
+				OutputSchema: &test.output,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(req.Text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.text.Equal(t, string(data))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package stream aborts streamed responses that take too long to start or stall.
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Timeouts bounds the time a streamed response takes to start and between its data. Zero disables a
+// timeout.
+type Timeouts struct {
+	// FirstToken is how long to wait for the first data of the response after the request is sent.
+	FirstToken time.Duration
+	// Stall is how long to wait for more data once the response started.
+	Stall time.Duration
+}
+
+// TimeoutError is the cause of the request context when a timeout elapsed.
+type TimeoutError struct {
+	Stalled bool
+	Timeout time.Duration
+}
+
+func (t TimeoutError) Error() string {
+	if t.Stalled {
+		return fmt.Sprintf("the response stalled for %s", t.Timeout)
+	}
+	return fmt.Sprintf("no response within %s", t.Timeout)
+}
+
+// Watchdog cancels the context of a request when one of the timeouts elapsed.
+type Watchdog struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	timeouts Timeouts
+
+	lock    sync.Mutex
+	timer   *time.Timer
+	started bool
+}
+
+// Watch returns a context to send the request with, which is canceled with a TimeoutError if the response
+// does not arrive or stalls. The returned Watchdog must be stopped when the response was read.
+func Watch(ctx context.Context, timeouts Timeouts) (context.Context, *Watchdog) {
+	w := &Watchdog{timeouts: timeouts}
+	w.ctx, w.cancel = context.WithCancelCause(ctx)
+	if timeouts.FirstToken > 0 {
+		w.timer = time.AfterFunc(timeouts.FirstToken, func() {
+			w.cancel(TimeoutError{Timeout: timeouts.FirstToken})
+		})
+	}
+	return w.ctx, w
+}
+
+// Body wraps the body of the response so every read of data restarts the stall timeout. Reads fail with
+// the TimeoutError once a timeout elapsed.
+func (w *Watchdog) Body(body io.ReadCloser) io.ReadCloser {
+	return &watchedBody{ReadCloser: body, watchdog: w}
+}
+
+// Err returns the TimeoutError if a timeout elapsed, which caused err, otherwise err.
+func (w *Watchdog) Err(err error) error {
+	var timeout TimeoutError
+	if err != nil && errors.As(context.Cause(w.ctx), &timeout) {
+		return timeout
+	}
+	return err
+}
+
+// Stop stops the timeouts and releases the context of the request.
+func (w *Watchdog) Stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel(context.Canceled)
+}
+
+func (w *Watchdog) progressed() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.started && w.timer != nil {
+		w.timer.Reset(w.timeouts.Stall)
+		return
+	}
+	w.started = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.timeouts.Stall > 0 {
+		w.timer = time.AfterFunc(w.timeouts.Stall, func() {
+			w.cancel(TimeoutError{Stalled: true, Timeout: w.timeouts.Stall})
+		})
+	}
+}
+
+type watchedBody struct {
+	io.ReadCloser
+	watchdog *Watchdog
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watchdog.progressed()
+	}
+	if err == io.EOF {
+		return n, err
+	}
+	return n, b.watchdog.Err(err)
+}
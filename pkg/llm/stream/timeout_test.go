@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+)
+
+// newServer streams an event after each of the delays.
+func newServer(t *testing.T, delays ...time.Duration) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, delay := range delays {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			_, _ = io.WriteString(w, "data: {}\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func read(t *testing.T, url string, timeouts Timeouts) (string, error) {
+	t.Helper()
+	ctx, watchdog := Watch(t.Context(), timeouts)
+	defer watchdog.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", watchdog.Err(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(watchdog.Body(resp.Body))
+	return string(data), err
+}
+
+func TestWatch_Stalled(t *testing.T) {
+	url := newServer(t, 0, 0, time.Minute)
+
+	start := time.Now()
+	data, err := read(t, url, Timeouts{Stall: 50 * time.Millisecond})
+	autogold.Expect("data: {}\n\ndata: {}\n\n").Equal(t, data)
+	autogold.Expect("the response stalled for 50ms").Equal(t, err.Error())
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("stalled stream aborted after %s", d)
+	}
+}
+
+func TestWatch_SlowProgress(t *testing.T) {
+	// Every event arrives within the stall timeout, so the stream succeeds although it takes longer.
+	delay := 20 * time.Millisecond
+	url := newServer(t, delay, delay, delay, delay, delay)
+
+	data, err := read(t, url, Timeouts{FirstToken: 60 * time.Millisecond, Stall: 60 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(strings.Repeat("data: {}\n\n", 5)).Equal(t, data)
+}
+
+func TestWatch_FirstToken(t *testing.T) {
+	url := newServer(t, time.Minute)
+
+	_, err := read(t, url, Timeouts{FirstToken: 50 * time.Millisecond, Stall: time.Minute})
+	autogold.Expect("no response within 50ms").Equal(t, err.Error())
+}
@@ -22,7 +22,9 @@ var (
 	Base64Replacement = []byte(`$1..."`)
 )
 
-func Messages(_ context.Context, server string, out bool, data []byte) {
+func Messages(ctx context.Context, server string, out bool, data []byte) {
+	RecordMessage(ctx, server, out, data)
+
 	if !EnableUI && server == "nanobot.ui" {
 		return
 	}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionSend    = "send"
+	DirectionReceive = "receive"
+)
+
+// Record is a single recorded MCP message. Records are written one JSON object per line.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Server is the name of the MCP server the message was exchanged with, or the server role (such as
+	// "proxy" or "http-server") when nanobot is the server.
+	Server string `json:"server"`
+	// Direction is send or receive, from the point of view of this process.
+	Direction string          `json:"direction"`
+	ID        json.RawMessage `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	// RequestTime and DurationMs are set on responses that could be paired with their request.
+	RequestTime *time.Time      `json:"requestTime,omitempty"`
+	DurationMs  float64         `json:"durationMs,omitempty"`
+	Message     json.RawMessage `json:"message"`
+}
+
+// IsResponse returns true if the record is a JSON-RPC response (it has an ID but no method).
+func (r Record) IsResponse() bool {
+	return len(r.ID) > 0 && r.Method == ""
+}
+
+// Recorder writes every JSON-RPC message passed to Messages to a writer in a replayable format.
+type Recorder struct {
+	lock    sync.Mutex
+	out     io.Writer
+	now     func() time.Time
+	pending map[string]time.Time
+}
+
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{
+		out:     out,
+		now:     time.Now,
+		pending: map[string]time.Time{},
+	}
+}
+
+var (
+	recorderLock sync.RWMutex
+	recorder     *Recorder
+)
+
+// SetRecorder sets the recorder that all MCP messages are written to. A nil recorder disables recording.
+func SetRecorder(r *Recorder) {
+	recorderLock.Lock()
+	defer recorderLock.Unlock()
+	recorder = r
+}
+
+// StartRecording appends all MCP messages to the file at path.
+func StartRecording(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open recording file %s: %w", path, err)
+	}
+	SetRecorder(NewRecorder(f))
+	return nil
+}
+
+// RecordMessage records the message without printing it. This is used where nanobot is the server, which
+// is not otherwise logged.
+func RecordMessage(_ context.Context, server string, out bool, data []byte) {
+	recorderLock.RLock()
+	r := recorder
+	recorderLock.RUnlock()
+	if r != nil {
+		r.Record(server, out, data)
+	}
+}
+
+// Record writes data as a record if it is a JSON-RPC message. Anything else, such as LLM API traffic,
+// is ignored.
+func (r *Recorder) Record(server string, out bool, data []byte) {
+	var msg struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+	}
+	data = bytes.TrimSpace(data)
+	if err := json.Unmarshal(data, &msg); err != nil || msg.JSONRPC == "" {
+		return
+	}
+
+	record := Record{
+		Server:    server,
+		Direction: DirectionReceive,
+		Method:    msg.Method,
+		Message:   data,
+	}
+	if out {
+		record.Direction = DirectionSend
+	}
+	if len(msg.ID) > 0 && !bytes.Equal(msg.ID, []byte("null")) {
+		record.ID = msg.ID
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record.Time = r.now()
+	if len(record.ID) > 0 {
+		if record.Method != "" {
+			r.pending[pendingKey(server, record.Direction, record.ID)] = record.Time
+		} else {
+			requestDirection := DirectionReceive
+			if !out {
+				requestDirection = DirectionSend
+			}
+			key := pendingKey(server, requestDirection, record.ID)
+			if start, ok := r.pending[key]; ok {
+				delete(r.pending, key)
+				record.RequestTime = &start
+				record.DurationMs = float64(record.Time.Sub(start).Microseconds()) / 1000
+			}
+		}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	_, _ = r.out.Write(append(line, '\n'))
+}
+
+func pendingKey(server, direction string, id json.RawMessage) string {
+	return server + "\x00" + direction + "\x00" + string(id)
+}
+
+// ReadRecords reads records written by a Recorder.
+func ReadRecords(in io.Reader) (result []Record, _ error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to parse record %d: %w", len(result)+1, err)
+		}
+		result = append(result, record)
+	}
+	return result, scanner.Err()
+}
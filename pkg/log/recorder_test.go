@@ -0,0 +1,75 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestRecorder(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		r     = NewRecorder(&buf)
+		start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		now   = start
+	)
+	r.now = func() time.Time {
+		return now
+	}
+
+	r.Record("fs", true, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	now = now.Add(10 * time.Millisecond)
+	r.Record("fs", false, []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{}}`))
+	// LLM API traffic is not JSON-RPC and isn't recorded
+	r.Record("responses-api", true, []byte(`{"model":"gpt-4.1"}`))
+	now = now.Add(15 * time.Millisecond)
+	r.Record("fs", false, []byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`))
+	// A response with no matching request
+	r.Record("fs", false, []byte(`{"jsonrpc":"2.0","id":2,"result":{}}`))
+
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary []string
+	for _, record := range records {
+		line := record.Time.Sub(start).String() + " " + record.Server + " " + record.Direction + " " + string(record.ID) + " " + record.Method
+		if record.IsResponse() {
+			line += " response"
+		}
+		if record.RequestTime != nil {
+			line += " paired"
+		}
+		summary = append(summary, line)
+	}
+	autogold.Expect([]string{
+		"0s fs send 1 tools/list",
+		"10ms fs receive  notifications/progress",
+		"25ms fs receive 1  response paired",
+		"25ms fs receive 2  response",
+	}).Equal(t, summary)
+
+	autogold.Expect(25.0).Equal(t, records[2].DurationMs)
+	autogold.Expect(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`).Equal(t, string(records[2].Message))
+}
+
+func TestRecorder_ServerSide(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		r   = NewRecorder(&buf)
+	)
+
+	// When acting as the server the request is received and the response is sent
+	r.Record("http-server", false, []byte(`{"jsonrpc":"2.0","id":"a","method":"ping"}`))
+	r.Record("http-server", true, []byte(`{"jsonrpc":"2.0","id":"a","result":{}}`))
+
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(2).Equal(t, len(records))
+	autogold.Expect(true).Equal(t, records[1].RequestTime != nil)
+}
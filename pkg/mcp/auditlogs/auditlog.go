@@ -36,6 +36,10 @@ type MCPWebhookStatus struct {
 	Type    string `json:"type,omitempty"`
 	Method  string `json:"method,omitempty"`
 	Name    string `json:"name"`
+	Target  string `json:"target,omitempty"`
 	Status  string `json:"status"`
 	Message string `json:"message,omitempty"`
+	// Error is set when the hook failed to run, including failures that were ignored because the hook fails open.
+	Error            string `json:"error,omitempty"`
+	ProcessingTimeMs int64  `json:"processingTimeMs"`
 }
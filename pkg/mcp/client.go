@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,8 +17,11 @@ import (
 )
 
 type Client struct {
-	Session       *Session
-	toolOverrides ToolOverrides
+	Session            *Session
+	toolOverrides      ToolOverrides
+	defaultAnnotations *ToolAnnotations
+	serverName         string
+	maxTools           int
 }
 
 func (c *Client) Close(deleteSession bool) {
@@ -31,6 +35,7 @@ type SessionState struct {
 	InitializeResult  InitializeResult  `json:"initializeResult,omitzero"`
 	InitializeRequest InitializeRequest `json:"initializeRequest,omitzero"`
 	Attributes        map[string]any    `json:"attributes,omitempty"`
+	LastEventID       string            `json:"lastEventID,omitempty"`
 }
 
 type ClientOption struct {
@@ -50,7 +55,13 @@ type ClientOption struct {
 	ClientVersion string
 	Wire          Wire
 	HookRunner    HookRunner
-	ignoreEvents  bool
+	// MaxPendingRequests bounds the requests that can be waiting for a response at once. New requests past
+	// the bound fail, or wait for a slot if QueuePendingRequests is set.
+	MaxPendingRequests   int
+	QueuePendingRequests bool
+	// MaxTools caps the number of tools fetched from the pages of tools/list, defaults to 10000.
+	MaxTools     int
+	ignoreEvents bool
 }
 
 func (c ClientOption) Complete() ClientOption {
@@ -72,6 +83,9 @@ func (c ClientOption) Complete() ClientOption {
 	} else {
 		c.ClientName += fmt.Sprintf(" (via nanobot %s)", version.Get().String())
 	}
+	if c.MaxTools == 0 {
+		c.MaxTools = 10000
+	}
 	c.ignoreEvents = c.OnMessage == nil && c.OnNotify == nil && c.OnLogging == nil &&
 		c.OnRoots == nil && c.OnSampling == nil && c.OnElicit == nil
 	return c
@@ -116,12 +130,20 @@ func (c ClientOption) Merge(other ClientOption) (result ClientOption) {
 	result.TokenExchangeClientID = complete.Last(c.TokenExchangeClientID, other.TokenExchangeClientID)
 	result.TokenExchangeClientSecret = complete.Last(c.TokenExchangeClientSecret, other.TokenExchangeClientSecret)
 	result.OAuthClientName = complete.Last(c.OAuthClientName, other.OAuthClientName)
+	result.RequestTimeout = complete.Last(c.RequestTimeout, other.RequestTimeout)
+	result.RetryPolicy = complete.Last(c.RetryPolicy, other.RetryPolicy)
+	result.HTTPClient = complete.Last(c.HTTPClient, other.HTTPClient)
+	result.SSEReconnectBackoff = complete.Last(c.SSEReconnectBackoff, other.SSEReconnectBackoff)
+	result.SSEMaxReconnectAttempts = complete.Last(c.SSEMaxReconnectAttempts, other.SSEMaxReconnectAttempts)
 	result.Env = complete.MergeMap(c.Env, other.Env)
 	result.SessionState = complete.Last(c.SessionState, other.SessionState)
 	result.ParentSession = complete.Last(c.ParentSession, other.ParentSession)
 	result.Runner = complete.Last(c.Runner, other.Runner)
 	result.Wire = complete.Last(c.Wire, other.Wire)
 	result.HookRunner = complete.Last(c.HookRunner, other.HookRunner)
+	result.MaxPendingRequests = complete.Last(c.MaxPendingRequests, other.MaxPendingRequests)
+	result.QueuePendingRequests = complete.Last(c.QueuePendingRequests, other.QueuePendingRequests)
+	result.MaxTools = complete.Last(c.MaxTools, other.MaxTools)
 
 	return result
 }
@@ -149,7 +171,15 @@ type Server struct {
 	// If providing no tool overrides, all tools will be enabled.
 	ToolOverrides ToolOverrides `json:"toolOverrides,omitzero"`
 
+	// DefaultToolAnnotations are the annotations of the tools that the server lists without annotations, for
+	// example to declare the tools of a trusted server as read-only.
+	DefaultToolAnnotations *ToolAnnotations `json:"defaultToolAnnotations,omitempty"`
+
 	Hooks Hooks `json:"hooks,omitzero"`
+
+	// LogLevel is the minimum level of the log messages forwarded from this server. It is sent to the
+	// server with logging/setLevel when the client is created.
+	LogLevel string `json:"logLevel,omitempty"`
 }
 
 func (s Server) MarshalJSON() ([]byte, error) {
@@ -337,7 +367,12 @@ func NewSession(ctx context.Context, serverName string, config Server, opts ...C
 		}
 	}
 
-	return newSession(ctx, wire, toHandler(opt), opt.SessionState, opt.HookRunner, config.Hooks, opt.ParentSession)
+	session, err := newSession(ctx, wire, toHandler(opt), opt.SessionState, opt.HookRunner, config.Hooks, opt.ParentSession)
+	if err != nil {
+		return nil, err
+	}
+	session.pendingRequest.SetLimit(opt.MaxPendingRequests, opt.QueuePendingRequests)
+	return session, nil
 }
 
 func NewClient(ctx context.Context, serverName string, config Server, opts ...ClientOption) (*Client, error) {
@@ -364,8 +399,11 @@ func NewClient(ctx context.Context, serverName string, config Server, opts ...Cl
 	}()
 
 	c := &Client{
-		Session:       session,
-		toolOverrides: config.ToolOverrides,
+		Session:            session,
+		toolOverrides:      config.ToolOverrides,
+		defaultAnnotations: config.DefaultToolAnnotations,
+		serverName:         serverName,
+		maxTools:           opt.MaxTools,
 	}
 
 	var (
@@ -479,8 +517,7 @@ func (c *Client) ListTools(ctx context.Context) (*ListToolsResult, error) {
 		return &ListToolsResult{}, nil
 	}
 
-	var tools ListToolsResult
-	err := c.Session.Exchange(ctx, "tools/list", struct{}{}, &tools)
+	tools, err := c.listToolPages(ctx)
 	if err == nil && len(c.toolOverrides) > 0 {
 		filtered := tools.Tools[:0] // reuse the backing array
 		for _, tool := range tools.Tools {
@@ -501,23 +538,82 @@ func (c *Client) ListTools(ctx context.Context) (*ListToolsResult, error) {
 		tools.Tools = filtered
 	}
 
+	if err == nil && c.defaultAnnotations != nil {
+		for i, tool := range tools.Tools {
+			if tool.Annotations == nil {
+				annotations := *c.defaultAnnotations
+				tools.Tools[i].Annotations = &annotations
+			}
+		}
+	}
+
 	return &tools, err
 }
 
+// maxToolPages is the number of pages of tools/list after which the listing fails, so a server that keeps
+// returning new cursors, with or without tools, doesn't list forever.
+const maxToolPages = 1000
+
+// listToolPages follows the cursors of tools/list until the last page or until maxTools tools are fetched.
+func (c *Client) listToolPages(ctx context.Context) (result ListToolsResult, _ error) {
+	var (
+		cursor string
+		seen   = map[string]bool{}
+	)
+	for pages := 0; ; pages++ {
+		if pages == maxToolPages {
+			return result, fmt.Errorf("server %s listed more than %d pages of tools", c.serverName, maxToolPages)
+		}
+
+		var page ListToolsResult
+		if err := c.Session.Exchange(ctx, "tools/list", ListToolsRequest{Cursor: cursor}, &page); err != nil {
+			return result, err
+		}
+		result.Tools = append(result.Tools, page.Tools...)
+
+		if c.maxTools > 0 && len(result.Tools) >= c.maxTools {
+			if len(result.Tools) > c.maxTools || page.NextCursor != "" {
+				log.Errorf(ctx, "server %s has more than %d tools, ignoring the rest", c.serverName, c.maxTools)
+			}
+			result.Tools = result.Tools[:c.maxTools]
+			return result, nil
+		}
+		if page.NextCursor == "" || seen[page.NextCursor] {
+			return result, nil
+		}
+		seen[page.NextCursor] = true
+		cursor = page.NextCursor
+	}
+}
+
 func (c *Client) Ping(ctx context.Context) (*PingResult, error) {
 	var result PingResult
 	err := c.Session.Exchange(ctx, "ping", struct{}{}, &result)
 	return &result, err
 }
 
+// ResultType is the kind of content a tool call is expected to return.
+type ResultType string
+
+const (
+	// ResultTypeStructured requires the result to have structured content.
+	ResultTypeStructured ResultType = "structured"
+	// ResultTypeText requires the result to have text content.
+	ResultTypeText ResultType = "text"
+)
+
 type CallOption struct {
 	ProgressToken any
 	Meta          map[string]any
+	// Expect makes the call fail if a successful result does not have the content of this type. Error results
+	// are returned as is.
+	Expect ResultType
 }
 
 func (c CallOption) Merge(other CallOption) (result CallOption) {
 	result.ProgressToken = complete.Last(c.ProgressToken, other.ProgressToken)
 	result.Meta = complete.MergeMap(c.Meta, other.Meta)
+	result.Expect = complete.Last(c.Expect, other.Expect)
 	return
 }
 
@@ -543,6 +639,22 @@ func (c *Client) Call(ctx context.Context, tool string, args any, opts ...CallOp
 	}, result, ExchangeOption{
 		ProgressToken: opt.ProgressToken,
 	})
+	if err != nil || result.IsError {
+		return
+	}
+
+	switch opt.Expect {
+	case ResultTypeStructured:
+		if result.StructuredContent == nil {
+			return result, fmt.Errorf("tool %s returned no structured content", tool)
+		}
+	case ResultTypeText:
+		if !slices.ContainsFunc(result.Content, func(content Content) bool {
+			return content.Type == "text"
+		}) {
+			return result, fmt.Errorf("tool %s returned no text content", tool)
+		}
+	}
 
 	return
 }
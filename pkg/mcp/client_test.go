@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+// pagedToolsServer lists its tools in pages of two, where the cursor is the index of the next tool. If
+// loop is set, the last page points back to the second. The tools have the annotations of their name.
+func pagedToolsServer(tools int, loop bool, annotations map[string]*ToolAnnotations) testServer {
+	return testServer{
+		listTools: func(_ context.Context, req ListToolsRequest) (*ListToolsResult, error) {
+			start, _ := strconv.Atoi(req.Cursor)
+			var result ListToolsResult
+			for i := start; i < min(start+2, tools); i++ {
+				name := fmt.Sprintf("tool%d", i)
+				result.Tools = append(result.Tools, Tool{Name: name, Annotations: annotations[name]})
+			}
+			switch {
+			case start+2 < tools:
+				result.NextCursor = strconv.Itoa(start + 2)
+			case loop:
+				result.NextCursor = "2"
+			}
+			return &result, nil
+		},
+	}
+}
+
+func TestClient_ListToolsPages(t *testing.T) {
+	listTools := func(server testServer, maxTools int) (names []string) {
+		t.Helper()
+		serverSession, err := NewExistingServerSession(t.Context(), SessionState{}, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewClient(t.Context(), "paged", Server{}, ClientOption{
+			Wire:     serverSession,
+			MaxTools: maxTools,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close(false)
+
+		tools, err := c.ListTools(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tool := range tools.Tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+
+	autogold.Expect([]string{"tool0", "tool1", "tool2", "tool3", "tool4"}).Equal(t, listTools(pagedToolsServer(5, false, nil), 0))
+	autogold.Expect([]string{"tool0", "tool1", "tool2"}).Equal(t, listTools(pagedToolsServer(5, false, nil), 3))
+	// A cursor that was already followed ends the listing
+	autogold.Expect([]string{"tool0", "tool1", "tool2", "tool3"}).Equal(t, listTools(pagedToolsServer(4, true, nil), 0))
+}
+
+func TestClient_ListToolsEndlessPages(t *testing.T) {
+	// Every page is empty and has a new cursor
+	var pages int
+	serverSession, err := NewExistingServerSession(t.Context(), SessionState{}, testServer{
+		listTools: func(context.Context, ListToolsRequest) (*ListToolsResult, error) {
+			pages++
+			return &ListToolsResult{Tools: []Tool{}, NextCursor: strconv.Itoa(pages)}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(t.Context(), "endless", Server{}, ClientOption{
+		Wire: serverSession,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(false)
+
+	_, err = c.ListTools(t.Context())
+	autogold.Expect("server endless listed more than 1000 pages of tools").Equal(t, err.Error())
+	autogold.Expect(maxToolPages).Equal(t, pages)
+}
+
+func TestClient_DefaultToolAnnotations(t *testing.T) {
+	serverSession, err := NewExistingServerSession(t.Context(), SessionState{}, pagedToolsServer(2, false, map[string]*ToolAnnotations{
+		"tool1": {Title: "Delete", DestructiveHint: &[]bool{true}[0]},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(t.Context(), "paged", Server{
+		DefaultToolAnnotations: &ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: &[]bool{false}[0]},
+	}, ClientOption{
+		Wire: serverSession,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(false)
+
+	tools, err := c.ListTools(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, tool := range tools.Tools {
+		result = append(result, fmt.Sprintf("%s: readOnly=%v destructive=%v openWorld=%v", tool.Name,
+			tool.Annotations.ReadOnlyHint, tool.Annotations.IsDestructive(), tool.Annotations.IsOpenWorld()))
+	}
+	autogold.Expect([]string{
+		"tool0: readOnly=true destructive=true openWorld=false",
+		"tool1: readOnly=false destructive=true openWorld=true",
+	}).Equal(t, result)
+}
+
+// resultServer returns the result of the tool named by the call.
+func resultServer(results map[string]CallToolResult) testServer {
+	calls := map[string]toolHandler{}
+	for name, result := range results {
+		calls[name] = func(context.Context, CallToolRequest) (*CallToolResult, error) {
+			return &result, nil
+		}
+	}
+	return testServer{calls: calls}
+}
+
+func TestClient_CallExpect(t *testing.T) {
+	serverSession, err := NewExistingServerSession(t.Context(), SessionState{}, resultServer(map[string]CallToolResult{
+		"structured": {StructuredContent: map[string]any{"answer": 42}, Content: []Content{{Type: "text", Text: `{"answer":42}`}}},
+		"text":       {Content: []Content{{Type: "text", Text: "42"}}},
+		"image":      {Content: []Content{{Type: "image", MIMEType: "image/png", Data: "aW1hZ2U="}}},
+		"failed":     {IsError: true, Content: []Content{{Type: "text", Text: "no answer"}}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(t.Context(), "results", Server{}, ClientOption{
+		Wire: serverSession,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(false)
+
+	call := func(tool string, expect ResultType) string {
+		t.Helper()
+		result, err := c.Call(t.Context(), tool, nil, CallOption{Expect: expect})
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("structured=%v text=%s isError=%v", result.StructuredContent, result.Content[0].Text, result.IsError)
+	}
+
+	autogold.Expect("structured=map[answer:42] text={\"answer\":42} isError=false").Equal(t, call("structured", ResultTypeStructured))
+	autogold.Expect("structured=map[answer:42] text={\"answer\":42} isError=false").Equal(t, call("structured", ResultTypeText))
+	autogold.Expect("structured=<nil> text=42 isError=false").Equal(t, call("text", ResultTypeText))
+	autogold.Expect("tool text returned no structured content").Equal(t, call("text", ResultTypeStructured))
+	autogold.Expect("tool image returned no text content").Equal(t, call("image", ResultTypeText))
+	// Error results are returned as is
+	autogold.Expect("structured=<nil> text=no answer isError=true").Equal(t, call("failed", ResultTypeStructured))
+}
@@ -0,0 +1,35 @@
+package mcp
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FeatureHeaderPrefix is the prefix of HTTP headers that set a feature flag for the session, for
+	// example "X-Nanobot-Feature-Parallel-Tools: true".
+	FeatureHeaderPrefix = "X-Nanobot-Feature-"
+	// FeatureEnvPrefix is the prefix of environment variables that set a feature flag, for example
+	// NANOBOT_FEATURE_PARALLEL_TOOLS=true.
+	FeatureEnvPrefix = "NANOBOT_FEATURE_"
+
+	featureEnvKeyPrefix = "feature:"
+)
+
+// FeatureEnvKey is the session env key that holds the value of the named feature flag.
+func FeatureEnvKey(name string) string {
+	return featureEnvKeyPrefix + strings.ToLower(name)
+}
+
+// FeatureEnabled returns true if the named feature flag is turned on for the session in ctx. Flags set
+// on the session, typically from request headers, take precedence over NANOBOT_FEATURE_* env vars.
+func FeatureEnabled(ctx context.Context, name string) bool {
+	env := SessionFromContext(ctx).GetEnvMap()
+	v, ok := env[FeatureEnvKey(name)]
+	if !ok {
+		v = env[FeatureEnvPrefix+strings.ToUpper(strings.ReplaceAll(name, "-", "_"))]
+	}
+	enabled, _ := strconv.ParseBool(strings.TrimSpace(v))
+	return enabled
+}
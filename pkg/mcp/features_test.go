@@ -0,0 +1,37 @@
+package mcp
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestFeatureEnabled(t *testing.T) {
+	h := &HTTPServer{
+		env: map[string]string{
+			"NANOBOT_FEATURE_STRICT_SCHEMA": "true",
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/mcp", nil)
+	req.Header.Set("X-Nanobot-Feature-Parallel-Tools", "true")
+	flagged := NewEmptySession(t.Context())
+	flagged.AddEnv(h.getEnv(req))
+
+	plain := NewEmptySession(t.Context())
+	plain.AddEnv(h.getEnv(httptest.NewRequest("POST", "/mcp", nil)))
+
+	autogold.Expect(true).Equal(t, FeatureEnabled(flagged.Context(), "parallel-tools"))
+	autogold.Expect(false).Equal(t, FeatureEnabled(plain.Context(), "parallel-tools"))
+
+	// Env based flags apply to every session
+	autogold.Expect(true).Equal(t, FeatureEnabled(flagged.Context(), "strict-schema"))
+	autogold.Expect(true).Equal(t, FeatureEnabled(plain.Context(), "strict-schema"))
+
+	req = httptest.NewRequest("POST", "/mcp", nil)
+	req.Header.Set("X-Nanobot-Feature-Strict-Schema", "false")
+	optOut := NewEmptySession(t.Context())
+	optOut.AddEnv(h.getEnv(req))
+	autogold.Expect(false).Equal(t, FeatureEnabled(optOut.Context(), "strict-schema"))
+}
@@ -1,22 +1,44 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"maps"
 	"net/url"
+	"path"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
 )
 
 type HookRunner interface {
 	RunHook(ctx context.Context, in, out any, target string) (bool, error)
 }
 
+// Hooks are run in order of their "order" param (lowest first, default 0). Hooks with the same order run
+// in the order they are declared, which for hooks loaded from config is sorted by their definition. The
+// output of each hook is the input to the next.
 type Hooks []HookMapping
 
+// Ordered returns the hooks in the order they are run.
+func (h Hooks) Ordered() Hooks {
+	compare := func(a, b HookMapping) int {
+		return a.Order - b.Order
+	}
+	if slices.IsSortedFunc(h, compare) {
+		return h
+	}
+	ordered := slices.Clone(h)
+	slices.SortStableFunc(ordered, compare)
+	return ordered
+}
+
 func (h *Hooks) UnmarshalJSON(data []byte) error {
 	var m map[string]stringList
 	if err := json.Unmarshal(data, &m); err != nil {
@@ -30,14 +52,11 @@ func (h *Hooks) UnmarshalJSON(data []byte) error {
 			return fmt.Errorf("failed to parse hook definition %s: %w", key, err)
 		}
 
-		mappings = append(mappings, HookMapping{
-			Name:    def.Name,
-			Params:  def.Params,
-			Targets: m[key],
-		})
+		def.Targets = m[key]
+		mappings = append(mappings, def)
 	}
 
-	*h = mappings
+	*h = Hooks(mappings).Ordered()
 	return nil
 }
 
@@ -77,7 +96,16 @@ func (s *stringList) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-type HookResponseCallback[T any] = func(hook HookMapping, resp T, err error) T
+// HookInvocation describes a single run of a hook target.
+type HookInvocation struct {
+	HookMapping
+	Target   string
+	Duration time.Duration
+}
+
+// HookResponseCallback is called after a hook target runs if it returned output or failed. Failures of
+// hooks that fail open are passed to callbacks but are not returned by InvokeHooks.
+type HookResponseCallback[T any] = func(hook HookInvocation, resp T, err error) T
 
 func InvokeHooks[T any](ctx context.Context, r HookRunner, hooks Hooks, in *T, name string, params map[string]string, callbacks ...HookResponseCallback[T]) (T, error) {
 	var (
@@ -86,16 +114,32 @@ func InvokeHooks[T any](ctx context.Context, r HookRunner, hooks Hooks, in *T, n
 		matched bool
 		errs    []error
 	)
-	for _, mapping := range hooks {
+	for _, mapping := range hooks.Ordered() {
+		if mapping.Async {
+			if mapping.Matches(name, params) {
+				runAsyncHook(ctx, r, mapping, current)
+			}
+			continue
+		}
 		if mapping.Matches(name, params) {
 			for _, target := range mapping.Targets {
 				matched = true
-				hasOutput, err := r.RunHook(ctx, current, &out, target)
+				start := time.Now()
+				hasOutput, err := runHook(ctx, r, mapping, current, &out, target)
 				if hasOutput || err != nil {
+					invocation := HookInvocation{
+						HookMapping: mapping,
+						Target:      target,
+						Duration:    time.Since(start),
+					}
 					for _, cb := range callbacks {
-						out = cb(mapping, out, err)
+						out = cb(invocation, out, err)
 					}
 				}
+				if err != nil && mapping.FailOpen {
+					log.Errorf(ctx, "ignoring failed hook %s: %v", mapping.String(), err)
+					continue
+				}
 				if err != nil {
 					errs = append(errs, fmt.Errorf("failed to run hook %s: %w", mapping.String(), err))
 					continue
@@ -112,10 +156,111 @@ func InvokeHooks[T any](ctx context.Context, r HookRunner, hooks Hooks, in *T, n
 	return *current, errors.Join(errs...)
 }
 
+// runHook runs a single hook target, applying the timeout and retries of the mapping.
+func runHook(ctx context.Context, r HookRunner, mapping HookMapping, in, out any, target string) (hasOutput bool, err error) {
+	for attempt := 0; attempt <= mapping.Retries; attempt++ {
+		if attempt > 0 {
+			log.Debugf(ctx, "retrying hook %s (attempt %d): %v", target, attempt+1, err)
+		}
+
+		hookCtx, cancel := ctx, context.CancelFunc(func() {})
+		if mapping.Timeout > 0 {
+			hookCtx, cancel = context.WithTimeoutCause(ctx, mapping.Timeout,
+				fmt.Errorf("hook %s timed out after %s", target, mapping.Timeout))
+		}
+		hasOutput, err = r.RunHook(hookCtx, in, out, target)
+		if err != nil && hookCtx.Err() != nil {
+			err = context.Cause(hookCtx)
+		}
+		cancel()
+
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return hasOutput, err
+}
+
+// runAsyncHook runs the targets of an async hook in the background. The input is marshaled before
+// returning so later hooks can't change what the async hook sees, and the output is discarded.
+func runAsyncHook(ctx context.Context, r HookRunner, mapping HookMapping, in any) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		log.Errorf(ctx, "failed to marshal input for async hook %s: %v", mapping.String(), err)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, target := range mapping.Targets {
+		go func() {
+			var out json.RawMessage
+			if _, err := runHook(ctx, r, mapping, json.RawMessage(data), &out, target); err != nil {
+				log.Errorf(ctx, "failed to run async hook %s: %v", mapping.String(), err)
+			}
+		}()
+	}
+}
+
 type HookMapping struct {
-	Name    string
-	Params  map[string]string
-	Targets []string
+	Name   string
+	Params map[string]string
+	// Order is set with the "order" param in the hook definition, such as "tools/call?order=10". It is
+	// not matched against the message params.
+	Order int
+	// Async is set with the "async=true" param. Async hooks run in the background for side effects only,
+	// they can't modify or reject the message and don't delay it.
+	Async bool
+	// Timeout is set with the "timeout" param, such as "timeout=5s". Each attempt to run a target is
+	// limited to the timeout, zero means no limit.
+	Timeout time.Duration
+	// Retries is set with the "retries" param and is how many more times a failing target is run.
+	Retries int
+	// FailOpen is set with the "failOpen=true" param. A target that still fails after its retries is
+	// logged and skipped instead of failing the message.
+	FailOpen bool
+	Targets  []string
+}
+
+// hookOptions are the params of a hook definition that configure the hook rather than being matched.
+var hookOptions = []string{"order", "async", "timeout", "retries", "failOpen"}
+
+func (h *HookMapping) setOption(key, value string) (err error) {
+	switch key {
+	case "order":
+		h.Order, err = strconv.Atoi(value)
+	case "async":
+		h.Async, err = strconv.ParseBool(value)
+	case "timeout":
+		h.Timeout, err = time.ParseDuration(value)
+	case "retries":
+		h.Retries, err = strconv.Atoi(value)
+		if err == nil && h.Retries < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+	case "failOpen":
+		h.FailOpen, err = strconv.ParseBool(value)
+	}
+	return err
+}
+
+func (h HookMapping) options() url.Values {
+	q := url.Values{}
+	if h.Order != 0 {
+		q.Set("order", strconv.Itoa(h.Order))
+	}
+	if h.Async {
+		q.Set("async", "true")
+	}
+	if h.Timeout != 0 {
+		q.Set("timeout", h.Timeout.String())
+	}
+	if h.Retries != 0 {
+		q.Set("retries", strconv.Itoa(h.Retries))
+	}
+	if h.FailOpen {
+		q.Set("failOpen", "true")
+	}
+	return q
 }
 
 func parseHookDefinition(data string) (result HookMapping, _ error) {
@@ -125,6 +270,16 @@ func parseHookDefinition(data string) (result HookMapping, _ error) {
 		if err != nil {
 			return result, fmt.Errorf("failed to parse hook parameters: %w", err)
 		}
+		for _, key := range hookOptions {
+			value := query.Get(key)
+			if value == "" {
+				continue
+			}
+			query.Del(key)
+			if err := result.setOption(key, value); err != nil {
+				return result, fmt.Errorf("invalid hook %s %q: %w", key, value, err)
+			}
+		}
 		result.Params = make(map[string]string, len(query))
 		for k, v := range query {
 			if len(v) == 0 {
@@ -143,23 +298,38 @@ func parseHookDefinition(data string) (result HookMapping, _ error) {
 
 // Matches will indicate if the hook definition applies to the given hook definition.
 // It is assumed that the `other` hook definition is fully defined.
+//
+// The name and param values are patterns in path.Match syntax, such as "tools/*" or "read_*", and a
+// param value can list several comma separated patterns, such as "direction=request,response". A
+// pattern of "*" matches anything.
 func (h HookMapping) Matches(name string, params map[string]string) bool {
-	if h.Name != name && h.Name != "*" {
+	if !matchesPattern(h.Name, name) {
 		return false
 	}
 	for k, v := range h.Params {
-		if params[k] != v {
+		if !matchesPattern(v, params[k]) {
 			return false
 		}
 	}
 	return true
 }
 
+func matchesPattern(pattern, value string) bool {
+	for _, p := range strings.Split(pattern, ",") {
+		if p == "*" || p == value {
+			return true
+		}
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (h HookMapping) String() string {
 	s := strings.Builder{}
 	s.WriteString(h.Name)
-	if len(h.Params) > 0 {
-		q := make(url.Values, len(h.Params))
+	if q := h.options(); len(h.Params) > 0 || len(q) > 0 {
 		for k, v := range h.Params {
 			q.Add(k, v)
 		}
@@ -169,3 +339,38 @@ func (h HookMapping) String() string {
 
 	return s.String()
 }
+
+// HookDecision is the outcome of running message hooks with RunMessageHooks.
+type HookDecision struct {
+	Accept bool `json:"accept"`
+	// Reason holds the rejection reasons and hook errors when the message is not accepted.
+	Reason   string          `json:"reason,omitempty"`
+	Modified bool            `json:"modified"`
+	Message  *Message        `json:"message,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// RunMessageHooks runs the hooks that match msg as if it were sent in direction ("request" or "response")
+// on a session, but without sending it anywhere. This is used to test hooks in isolation.
+func RunMessageHooks(ctx context.Context, r HookRunner, hooks Hooks, msg Message, direction string) HookDecision {
+	original, _ := json.Marshal(msg)
+
+	s := &Session{
+		HookRunner: r,
+		hooks:      hooks,
+	}
+	newMsg, result, err := s.callAllHooks(ctx, &msg, direction)
+
+	decision := HookDecision{
+		Accept:  err == nil,
+		Message: newMsg,
+		Result:  result,
+	}
+	if err != nil {
+		decision.Reason = err.Error()
+	}
+	if modified, err := json.Marshal(newMsg); err == nil {
+		decision.Modified = !bytes.Equal(original, modified)
+	}
+	return decision
+}
@@ -0,0 +1,417 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+)
+
+type hookRunnerFunc func(ctx context.Context, in, out any, target string) (bool, error)
+
+func (f hookRunnerFunc) RunHook(ctx context.Context, in, out any, target string) (bool, error) {
+	return f(ctx, in, out, target)
+}
+
+func TestSession_HookSyntheticResult(t *testing.T) {
+	var (
+		wire  = &testWire{sent: make(chan Message, 1)}
+		hooks = Hooks{{
+			Name:    "tools/call",
+			Params:  map[string]string{"name": "cached", "direction": "request"},
+			Targets: []string{"cache/lookup"},
+		}}
+		runner = hookRunnerFunc(func(_ context.Context, _, out any, _ string) (bool, error) {
+			*out.(*SessionMessageHook) = SessionMessageHook{
+				Accept: true,
+				Result: json.RawMessage(`{"content":[{"type":"text","text":"from cache"}]}`),
+			}
+			return true, nil
+		})
+	)
+
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	var result CallToolResult
+	if err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "cached"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("from cache").Equal(t, result.Content[0].Text)
+
+	select {
+	case msg := <-wire.sent:
+		t.Fatalf("expected the hook to short-circuit the call, but %s was sent", msg.Method)
+	default:
+	}
+	autogold.Expect(0).Equal(t, s.pendingRequest.Len())
+
+	// Tools the hook doesn't match still go to the server
+	go func() {
+		req := <-wire.sent
+		wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[{"type":"text","text":"from server"}]}`)})
+	}()
+	if err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "other"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("from server").Equal(t, result.Content[0].Text)
+}
+
+func TestSession_HookRejectIgnoresResult(t *testing.T) {
+	var (
+		wire  = &testWire{sent: make(chan Message, 1)}
+		hooks = Hooks{{
+			Name:    "tools/call",
+			Params:  map[string]string{"direction": "request"},
+			Targets: []string{"policy/check"},
+		}}
+		runner = hookRunnerFunc(func(_ context.Context, _, out any, _ string) (bool, error) {
+			*out.(*SessionMessageHook) = SessionMessageHook{
+				Reason: "not allowed",
+				Result: json.RawMessage(`{"content":[]}`),
+			}
+			return true, nil
+		})
+	)
+
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	var result CallToolResult
+	err = s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "blocked"}, &result)
+	autogold.Expect(`failed to send request: failed to call "request" hooks: hook tools/call rejected message: not allowed`).Equal(t, err.Error())
+	autogold.Expect(0).Equal(t, len(wire.sent))
+}
+
+func TestInvokeHooks_Order(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{
+		"tools/call?order=10": ["last"],
+		"tools/call?name=add&order=-1": ["first"],
+		"*": ["second", "third"]
+	}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []string
+	runner := hookRunnerFunc(func(_ context.Context, in, out any, target string) (bool, error) {
+		// Each hook appends its name to the tool name, so later hooks see the earlier modifications
+		msg := *in.(*SessionMessageHook).Message
+		var call CallToolRequest
+		if err := json.Unmarshal(msg.Params, &call); err != nil {
+			return false, err
+		}
+		ran = append(ran, target+" saw "+call.Name)
+		call.Name += "," + target
+		msg.Params, _ = json.Marshal(call)
+		*out.(*SessionMessageHook) = SessionMessageHook{Accept: true, Message: &msg}
+		return true, nil
+	})
+
+	params, _ := json.Marshal(CallToolRequest{Name: "add"})
+	result, err := InvokeHooks(t.Context(), runner, hooks, &SessionMessageHook{
+		Accept:  true,
+		Message: &Message{Method: "tools/call", Params: params},
+	}, "tools/call", map[string]string{"name": "add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect([]string{
+		"first saw add", "second saw add,first", "third saw add,first,second",
+		"last saw add,first,second,third",
+	}).Equal(t, ran)
+	autogold.Expect(`{"name":"add,first,second,third,last"}`).Equal(t, string(result.Message.Params))
+
+	// The order survives a round trip and isn't matched as a message param
+	data, err := json.Marshal(hooks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`{"*":["second","third"],"tools/call?name=add\u0026order=-1":["first"],"tools/call?order=10":["last"]}`).Equal(t, string(data))
+}
+
+func TestHooks_InvalidOrder(t *testing.T) {
+	var hooks Hooks
+	err := json.Unmarshal([]byte(`{"tools/call?order=soon": ["hook"]}`), &hooks)
+	autogold.Expect(`failed to parse hook definition tools/call?order=soon: invalid hook order "soon": strconv.Atoi: parsing "soon": invalid syntax`).Equal(t, err.Error())
+}
+
+func TestHookMapping_Matches(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{
+		"tools/call?name=read_*&direction=request": ["reads"],
+		"notifications/*?direction=request,response": ["notifications"]
+	}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	var fired []string
+	runner := hookRunnerFunc(func(_ context.Context, _, _ any, target string) (bool, error) {
+		fired = append(fired, target)
+		return false, nil
+	})
+
+	for _, msg := range []struct {
+		method, name, direction string
+	}{
+		{"tools/call", "read_file", "request"},
+		{"tools/call", "read_file", "response"},
+		{"tools/call", "write_file", "request"},
+		{"tools/list", "", "request"},
+		{"notifications/progress", "", "response"},
+		{"notifications/message", "", "request"},
+	} {
+		fired = append(fired, msg.method+" "+msg.name+" "+msg.direction+":")
+		_, err := InvokeHooks(t.Context(), runner, hooks, &SessionMessageHook{}, msg.method, map[string]string{
+			"name":      msg.name,
+			"direction": msg.direction,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	autogold.Expect([]string{
+		"tools/call read_file request:",
+		"reads",
+		"tools/call read_file response:",
+		"tools/call write_file request:",
+		"tools/list  request:",
+		"notifications/progress  response:",
+		"notifications",
+		"notifications/message  request:",
+		"notifications",
+	}).Equal(t, fired)
+}
+
+func TestSession_AsyncHook(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{
+		"tools/call?direction=request&async=true": ["audit/notify"]
+	}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wire     = &testWire{sent: make(chan Message, 1)}
+		started  = make(chan string, 1)
+		release  = make(chan struct{})
+		finished = make(chan struct{})
+		runner   = hookRunnerFunc(func(_ context.Context, in, out any, _ string) (bool, error) {
+			defer close(finished)
+			var hook SessionMessageHook
+			_ = json.Unmarshal(in.(json.RawMessage), &hook)
+			started <- hook.Message.Method
+			<-release
+			// Rejecting from an async hook has no effect
+			*out.(*json.RawMessage) = json.RawMessage(`{"accept":false,"reason":"too slow"}`)
+			return true, nil
+		})
+	)
+
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	go func() {
+		req := <-wire.sent
+		wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[{"type":"text","text":"done"}]}`)})
+	}()
+
+	// The hook is blocked until after the exchange completes, so the exchange can't be waiting on it
+	var result CallToolResult
+	if err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "slow"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("done").Equal(t, result.Content[0].Text)
+	autogold.Expect("tools/call").Equal(t, <-started)
+
+	close(release)
+	<-finished
+}
+
+func TestSession_HookTimeout(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		var hooks Hooks
+		def := `{"tools/call?direction=request&timeout=10ms&retries=1": ["slow/hook"]}`
+		if failOpen {
+			def = `{"tools/call?direction=request&timeout=10ms&retries=1&failOpen=true": ["slow/hook"]}`
+		}
+		if err := json.Unmarshal([]byte(def), &hooks); err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			wire     = &testWire{sent: make(chan Message, 1)}
+			attempts int
+			runner   = hookRunnerFunc(func(ctx context.Context, _, _ any, _ string) (bool, error) {
+				attempts++
+				<-ctx.Done()
+				return false, ctx.Err()
+			})
+		)
+		s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			req := <-wire.sent
+			wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[]}`)})
+		}()
+
+		var result CallToolResult
+		err = s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "read"}, &result)
+		autogold.Expect(2).Equal(t, attempts)
+		if failOpen {
+			// The broken hook is skipped and the call reaches the server
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			autogold.Expect(`failed to send request: failed to call "request" hooks: failed to run hook tools/call: hook slow/hook timed out after 10ms`).Equal(t, err.Error())
+		}
+		s.Close(false)
+	}
+}
+
+func TestHooks_Options(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{"tools/call?name=read&timeout=5s&retries=2&failOpen=true": ["hook"]}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(HookMapping{
+		Name:     "tools/call",
+		Params:   map[string]string{"name": "read"},
+		Timeout:  5 * time.Second,
+		Retries:  2,
+		FailOpen: true,
+		Targets:  []string{"hook"},
+	}).Equal(t, hooks[0])
+	autogold.Expect("tools/call?failOpen=true&name=read&retries=2&timeout=5s").Equal(t, hooks[0].String())
+
+	err := json.Unmarshal([]byte(`{"tools/call?retries=-1": ["hook"]}`), &hooks)
+	autogold.Expect(`failed to parse hook definition tools/call?retries=-1: invalid hook retries "-1": must not be negative`).Equal(t, err.Error())
+}
+
+func TestSession_HookAuditLog(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{
+		"tools/call?direction=request": ["slow/hook"],
+		"tools/call?direction=request&failOpen=true&order=1": ["broken/hook"]
+	}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wire   = &testWire{sent: make(chan Message, 1)}
+		runner = hookRunnerFunc(func(_ context.Context, _, out any, target string) (bool, error) {
+			if target == "broken/hook" {
+				return false, errors.New("connection refused")
+			}
+			time.Sleep(20 * time.Millisecond)
+			*out.(*SessionMessageHook) = SessionMessageHook{Accept: true, Reason: "looks fine"}
+			return true, nil
+		})
+		auditLog = &auditlogs.MCPAuditLog{}
+		ctx      = WithAuditLog(t.Context(), auditLog)
+	)
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	go func() {
+		req := <-wire.sent
+		wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[]}`)})
+	}()
+	var result CallToolResult
+	if err := s.Exchange(ctx, "tools/call", CallToolRequest{Name: "read"}, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := auditLog.WebhookStatuses
+	autogold.Expect(2).Equal(t, len(statuses))
+	autogold.Expect(true).Equal(t, statuses[0].ProcessingTimeMs >= 20)
+
+	statuses[0].ProcessingTimeMs = 0
+	statuses[1].ProcessingTimeMs = 0
+	autogold.Expect([]auditlogs.MCPWebhookStatus{
+		{
+			Type:    "request",
+			Method:  "tools/call",
+			Name:    "tools/call",
+			Target:  "slow/hook",
+			Status:  "ok",
+			Message: "looks fine",
+		},
+		{
+			Type:   "request",
+			Method: "tools/call",
+			Name:   "tools/call",
+			Target: "broken/hook",
+			Status: "error",
+			Error:  "connection refused",
+		},
+	}).Equal(t, statuses)
+}
+
+func TestRunMessageHooks(t *testing.T) {
+	var hooks Hooks
+	if err := json.Unmarshal([]byte(`{"tools/call?direction=request": ["policy/check"]}`), &hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := hookRunnerFunc(func(_ context.Context, in, out any, _ string) (bool, error) {
+		msg := *in.(*SessionMessageHook).Message
+		var call CallToolRequest
+		if err := json.Unmarshal(msg.Params, &call); err != nil {
+			return false, err
+		}
+		switch call.Name {
+		case "delete":
+			*out.(*SessionMessageHook) = SessionMessageHook{Reason: "deletes are not allowed"}
+		case "read":
+			msg.Params = json.RawMessage(`{"name":"read","arguments":{"path":"/safe"}}`)
+			*out.(*SessionMessageHook) = SessionMessageHook{Accept: true, Message: &msg}
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+
+	run := func(params string) HookDecision {
+		return RunMessageHooks(t.Context(), runner, hooks, Message{
+			JSONRPC: "2.0",
+			Method:  "tools/call",
+			Params:  json.RawMessage(params),
+		}, "request")
+	}
+
+	decision := run(`{"name": "list"}`)
+	autogold.Expect(true).Equal(t, decision.Accept)
+	autogold.Expect(false).Equal(t, decision.Modified)
+
+	decision = run(`{"name":"read","arguments":{"path":"/etc"}}`)
+	autogold.Expect(true).Equal(t, decision.Accept)
+	autogold.Expect(true).Equal(t, decision.Modified)
+	autogold.Expect(`{"name":"read","arguments":{"path":"/safe"}}`).Equal(t, string(decision.Message.Params))
+
+	decision = run(`{"name":"delete"}`)
+	autogold.Expect(false).Equal(t, decision.Accept)
+	autogold.Expect("hook tools/call rejected message: deletes are not allowed").Equal(t, decision.Reason)
+}
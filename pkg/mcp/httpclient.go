@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -19,13 +24,30 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/log"
 )
 
-const SessionIDHeader = "Mcp-Session-Id"
+const (
+	SessionIDHeader = "Mcp-Session-Id"
+
+	// exchangedTokenExpiryMargin is how long before it expires an exchanged token is exchanged again.
+	exchangedTokenExpiryMargin = 30 * time.Second
+	// maxExchangedTokens is the number of exchanged tokens that are cached, the tokens that expire first are
+	// evicted to cache another.
+	maxExchangedTokens = 1000
+)
+
+// exchangedToken is an access token from the token exchange endpoint, when it expires, and the refresh
+// token to get a new one.
+type exchangedToken struct {
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
 
 type HTTPClient struct {
 	ctx          context.Context
 	cancel       context.CancelCauseFunc
 	clientLock   sync.RWMutex
 	httpClient   *http.Client
+	baseClient   *http.Client
 	handler      WireHandler
 	oauthHandler *oauth
 	baseURL      string
@@ -36,9 +58,16 @@ type HTTPClient struct {
 	waiter       *waiter
 	sse          bool
 
+	requestTimeout          time.Duration
+	retryPolicy             RetryPolicy
+	sseReconnectBackoff     Backoff
+	sseMaxReconnectAttempts int
+
 	tokenExchangeEndpoint     string
 	tokenExchangeClientID     string
 	tokenExchangeClientSecret string
+	exchangedTokensLock       sync.Mutex
+	exchangedTokens           map[string]exchangedToken
 
 	initializeLock    sync.RWMutex
 	initializeRequest *Message
@@ -46,6 +75,9 @@ type HTTPClient struct {
 
 	sseLock       sync.RWMutex
 	needReconnect bool
+
+	lastEventIDLock sync.Mutex
+	lastEventID     string
 }
 
 type HTTPClientOptions struct {
@@ -57,6 +89,115 @@ type HTTPClientOptions struct {
 	TokenExchangeEndpoint     string
 	TokenExchangeClientID     string
 	TokenExchangeClientSecret string
+	// RequestTimeout bounds each POST to the server, including reading its response. Zero means no timeout.
+	RequestTimeout time.Duration
+	// RetryPolicy controls how idempotent requests are retried, defaults to DefaultRetryPolicy.
+	RetryPolicy *RetryPolicy
+	// HTTPClient sends the requests to the server and the authorization server, defaults to
+	// http.DefaultClient. After OAuth its transport is the base of the authenticated client.
+	HTTPClient *http.Client
+	// SSEReconnectBackoff is the time to wait before reconnecting a dropped SSE stream, defaults to
+	// DefaultSSEReconnectBackoff.
+	SSEReconnectBackoff *Backoff
+	// SSEMaxReconnectAttempts is the number of failed reconnects after which the client is closed. Zero
+	// means the client keeps reconnecting.
+	SSEMaxReconnectAttempts int
+}
+
+// Backoff is a delay that grows by Multiplier with every attempt, from Base up to Max.
+type Backoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultSSEReconnectBackoff waits half a second before reconnecting, doubling up to 30 seconds.
+var DefaultSSEReconnectBackoff = Backoff{
+	Base:       500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+}
+
+func (b *Backoff) complete() Backoff {
+	if b == nil {
+		return DefaultSSEReconnectBackoff
+	}
+	result := *b
+	if result.Base == 0 {
+		result.Base = DefaultSSEReconnectBackoff.Base
+	}
+	if result.Max == 0 {
+		result.Max = max(DefaultSSEReconnectBackoff.Max, result.Base)
+	}
+	if result.Multiplier == 0 {
+		result.Multiplier = DefaultSSEReconnectBackoff.Multiplier
+	}
+	return result
+}
+
+// delay returns the time to wait before the attempt, starting at 1.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := float64(b.Base) * math.Pow(b.Multiplier, float64(attempt-1))
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// RetryPolicy controls how requests that fail with a retryable status code are sent again. Only requests
+// that are safe to repeat, like listing tools, are retried, and the SSE stream never is.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent, including the first. One disables retries.
+	MaxAttempts int
+	// BaseBackoff is the time to wait before the first retry. It doubles with every retry, with jitter.
+	BaseBackoff time.Duration
+	// RetryableStatusCodes are the status codes of responses that are retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy retries requests that failed at a gateway twice.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	BaseBackoff:          500 * time.Millisecond,
+	RetryableStatusCodes: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+func (r *RetryPolicy) complete() RetryPolicy {
+	if r == nil {
+		return DefaultRetryPolicy
+	}
+	result := *r
+	if result.MaxAttempts == 0 {
+		result.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if result.BaseBackoff == 0 {
+		result.BaseBackoff = DefaultRetryPolicy.BaseBackoff
+	}
+	if result.RetryableStatusCodes == nil {
+		result.RetryableStatusCodes = DefaultRetryPolicy.RetryableStatusCodes
+	}
+	return result
+}
+
+// backoff returns the time to wait before the retry after the given attempt, between half and all of
+// the exponential backoff.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	d := r.BaseBackoff << (attempt - 1)
+	return d/2 + rand.N(d/2+1)
+}
+
+// idempotentMethods are the requests that can be sent again without side effects if the server did not
+// respond to them.
+var idempotentMethods = []string{
+	"initialize",
+	"ping",
+	"tools/list",
+	"prompts/list",
+	"prompts/get",
+	"resources/list",
+	"resources/templates/list",
+	"resources/read",
+	"completion/complete",
 }
 
 func newHTTPClient(serverName string, config Server, opts HTTPClientOptions, sessionState *SessionState, headers map[string]string, watchesEvents bool) (*HTTPClient, error) {
@@ -67,8 +208,12 @@ func newHTTPClient(serverName string, config Server, opts HTTPClientOptions, ses
 		sessionID = &sessionState.ID
 	}
 
-	var initializeRequest *Message
+	var (
+		initializeRequest *Message
+		lastEventID       string
+	)
 	if sessionState != nil {
+		lastEventID = sessionState.LastEventID
 		var err error
 		initializeRequest, err = NewMessage("initialize", sessionState.InitializeRequest)
 		if err != nil {
@@ -76,9 +221,12 @@ func newHTTPClient(serverName string, config Server, opts HTTPClientOptions, ses
 		}
 	}
 
+	baseClient := complete.First(opts.HTTPClient, http.DefaultClient)
+
 	return &HTTPClient{
-		httpClient:        http.DefaultClient,
-		oauthHandler:      newOAuth(opts.CallbackHandler, opts.ClientCredLookup, opts.TokenStorage, opts.OAuthClientName, opts.OAuthRedirectURL),
+		httpClient:        baseClient,
+		baseClient:        baseClient,
+		oauthHandler:      newOAuth(baseClient, opts.CallbackHandler, opts.ClientCredLookup, opts.TokenStorage, opts.OAuthClientName, opts.OAuthRedirectURL),
 		baseURL:           config.BaseURL,
 		messageURL:        config.BaseURL,
 		serverName:        serverName,
@@ -88,6 +236,12 @@ func newHTTPClient(serverName string, config Server, opts HTTPClientOptions, ses
 		needReconnect:     watchesEvents,
 		sessionID:         sessionID,
 		initializeRequest: initializeRequest,
+		lastEventID:       lastEventID,
+		requestTimeout:    opts.RequestTimeout,
+		retryPolicy:       opts.RetryPolicy.complete(),
+
+		sseReconnectBackoff:     opts.SSEReconnectBackoff.complete(),
+		sseMaxReconnectAttempts: opts.SSEMaxReconnectAttempts,
 
 		tokenExchangeClientID:     opts.TokenExchangeClientID,
 		tokenExchangeClientSecret: opts.TokenExchangeClientSecret,
@@ -109,6 +263,20 @@ func (s *HTTPClient) SessionID() string {
 	return *s.sessionID
 }
 
+// LastEventID returns the ID of the last SSE event received from the server, so a restarted client can
+// resume the stream after it.
+func (s *HTTPClient) LastEventID() string {
+	s.lastEventIDLock.Lock()
+	defer s.lastEventIDLock.Unlock()
+	return s.lastEventID
+}
+
+func (s *HTTPClient) setLastEventID(id string) {
+	s.lastEventIDLock.Lock()
+	defer s.lastEventIDLock.Unlock()
+	s.lastEventID = id
+}
+
 func (s *HTTPClient) Close(deleteSession bool) {
 	if deleteSession {
 		s.initializeLock.RLock()
@@ -215,6 +383,58 @@ func (s *HTTPClient) newRequest(ctx context.Context, method string, in any) (*ht
 	return req, nil
 }
 
+// requestContext returns the context for a POST to the server, with the request timeout if one is set.
+func (s *HTTPClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.requestTimeout > 0 {
+		return context.WithTimeout(ctx, s.requestTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// post sends msg to the server. Idempotent requests are sent again with exponential backoff if the
+// response has a retryable status code. Any other response is returned, so a request that was accepted
+// is never sent twice. prepare, if set, is called with every request before it is sent.
+func (s *HTTPClient) post(ctx context.Context, msg Message, prepare func(*http.Request)) (*http.Response, error) {
+	retryable := msg.ID != nil && slices.Contains(idempotentMethods, msg.Method)
+
+	for attempt := 1; ; attempt++ {
+		req, err := s.newRequest(ctx, http.MethodPost, msg)
+		if err != nil {
+			return nil, err
+		}
+		if prepare != nil {
+			prepare(req)
+		}
+
+		s.clientLock.RLock()
+		httpClient := s.httpClient
+		s.clientLock.RUnlock()
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !retryable || attempt >= s.retryPolicy.MaxAttempts || !slices.Contains(s.retryPolicy.RetryableStatusCodes, resp.StatusCode) {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		backoff := s.retryPolicy.backoff(attempt)
+		log.Debugf(ctx, "retrying %s request to %s in %s after %s", msg.Method, s.serverName, backoff, resp.Status)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
 func (s *HTTPClient) ensureSSE(ctx context.Context, msg *Message, lastEventID string) error {
 	s.sseLock.RLock()
 	if !s.needReconnect {
@@ -366,6 +586,7 @@ func (s *HTTPClient) ensureSSE(ctx context.Context, msg *Message, lastEventID st
 			seenID, message, ok := messages.readNextMessage("message")
 			if seenID != "" {
 				lastEventID = seenID
+				s.setLastEventID(seenID)
 			}
 			if !ok {
 				if err := messages.err(); err != nil {
@@ -393,7 +614,7 @@ func (s *HTTPClient) ensureSSE(ctx context.Context, msg *Message, lastEventID st
 					s.sseLock.Unlock()
 				}
 
-				if err := s.ensureSSE(ctx, msg, lastEventID); err != nil {
+				if err := s.reconnectSSE(ctx, msg, lastEventID); err != nil {
 					return fmt.Errorf("failed to reconnect to SSE server: %v", err), false
 				}
 
@@ -413,6 +634,48 @@ func (s *HTTPClient) ensureSSE(ctx context.Context, msg *Message, lastEventID st
 	return <-gotResponse
 }
 
+// reconnectSSE reconnects the dropped SSE stream, resuming after lastEventID. It waits with backoff before
+// every attempt, and closes the client when the maximum number of attempts failed. Errors that another
+// attempt would not fix are returned right away, the next message sent reconnects.
+func (s *HTTPClient) reconnectSSE(ctx context.Context, msg *Message, lastEventID string) error {
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(s.sseReconnectBackoff.delay(attempt))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return s.ctx.Err()
+		case <-timer.C:
+		}
+
+		err := s.ensureSSE(ctx, msg, lastEventID)
+		if err == nil {
+			return nil
+		}
+
+		var (
+			authErr            AuthRequiredErr
+			sessionNotFoundErr SessionNotFoundErr
+		)
+		if errors.As(err, &authErr) || errors.As(err, &sessionNotFoundErr) {
+			return err
+		}
+
+		if s.sseMaxReconnectAttempts > 0 && attempt >= s.sseMaxReconnectAttempts {
+			err = fmt.Errorf("failed to reconnect to SSE server %s after %d attempts: %w", s.serverName, attempt, err)
+			log.Errorf(ctx, "%v", err)
+			s.waiter.CloseWithError(err)
+			return err
+		}
+
+		log.Debugf(ctx, "failed to reconnect to SSE server %s, attempt %d: %v", s.serverName, attempt, err)
+	}
+}
+
+// Err returns the error the client was closed with, if any.
+func (s *HTTPClient) Err() error {
+	return s.waiter.Err()
+}
+
 func (s *HTTPClient) Start(ctx context.Context, handler WireHandler) error {
 	s.ctx, s.cancel = context.WithCancelCause(ctx)
 	s.handler = handler
@@ -423,7 +686,7 @@ func (s *HTTPClient) Start(ctx context.Context, handler WireHandler) error {
 
 	if s.sessionID != nil {
 		go func() {
-			err := s.ensureSSE(ctx, nil, "")
+			err := s.ensureSSE(ctx, nil, s.LastEventID())
 			if err != nil {
 				log.Errorf(ctx, "failed to re-initialize SSE: %v", err)
 			}
@@ -434,19 +697,13 @@ func (s *HTTPClient) Start(ctx context.Context, handler WireHandler) error {
 }
 
 func (s *HTTPClient) initialize(ctx context.Context, msg Message) error {
-	req, err := s.newRequest(ctx, http.MethodPost, msg)
-	if err != nil {
-		return err
-	}
-
-	// Remove the session ID header if it exists because we are initializing.
-	delete(req.Header, SessionIDHeader)
-
-	s.clientLock.RLock()
-	httpClient := s.httpClient
-	s.clientLock.RUnlock()
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
 
-	resp, err := httpClient.Do(req)
+	resp, err := s.post(reqCtx, msg, func(req *http.Request) {
+		// Remove the session ID header if it exists because we are initializing.
+		delete(req.Header, SessionIDHeader)
+	})
 	if err != nil {
 		return err
 	}
@@ -484,7 +741,7 @@ func (s *HTTPClient) initialize(ctx context.Context, msg Message) error {
 	s.initializeLock.Unlock()
 
 	go func() {
-		if err = s.ensureSSE(ctx, nil, ""); err != nil {
+		if err := s.ensureSSE(ctx, nil, ""); err != nil {
 			log.Errorf(context.Background(), "failed to initialize SSE: %v", err)
 		}
 	}()
@@ -510,6 +767,11 @@ func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
 	// Check for an authentication-required error and put the user through the OAuth process.
 	var oauthErr AuthRequiredErr
 	if errors.As(err, &oauthErr) {
+		// The exchanged tokens were not accepted, so exchange them again.
+		s.exchangedTokensLock.Lock()
+		s.exchangedTokens = nil
+		s.exchangedTokensLock.Unlock()
+
 		httpClient, err := s.oauthHandler.oauthClient(s.ctx, s, s.baseURL, oauthErr.ProtectedResourceValue)
 		if err != nil || httpClient == nil {
 			streamError := fmt.Errorf("failed to initialize HTTP Streaming client: %w", oauthErr)
@@ -542,11 +804,11 @@ func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
 		// Continually unwrap the errors until we find one that starts with oauth2:
 		if strings.HasPrefix(unwrappedErr.Error(), "oauth2:") {
 			// If we do find an error that starts with "oauth2:" then there was an issue with the oauth2 HTTP client.
-			// Reset the HTTP client to the default and try again. Using the default client will give us the unauthenticated
+			// Reset the HTTP client to the base client and try again. Using the base client will give us the unauthenticated
 			// error that we need to continue the process.
 
 			s.clientLock.Lock()
-			s.httpClient = http.DefaultClient
+			s.httpClient = s.baseClient
 			s.clientLock.Unlock()
 
 			// Use the exported Send method here so that we catch the AuthRequiredErr above on the recursed call.
@@ -613,16 +875,10 @@ func (s *HTTPClient) send(ctx context.Context, msg Message) error {
 		}()
 	}
 
-	req, err := s.newRequest(ctx, http.MethodPost, msg)
-	if err != nil {
-		return err
-	}
-
-	s.clientLock.RLock()
-	httpClient := s.httpClient
-	s.clientLock.RUnlock()
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
 
-	resp, err := httpClient.Do(req)
+	resp, err := s.post(reqCtx, msg, nil)
 	if err != nil {
 		return err
 	}
@@ -639,7 +895,7 @@ func (s *HTTPClient) send(ctx context.Context, msg Message) error {
 	if resp.StatusCode == http.StatusNotFound {
 		streamingErrorMessage, _ := io.ReadAll(resp.Body)
 		return SessionNotFoundErr{
-			SessionID: req.Header.Get(SessionIDHeader),
+			SessionID: resp.Request.Header.Get(SessionIDHeader),
 			Err:       fmt.Errorf("failed to send message: %s: %s", resp.Status, streamingErrorMessage),
 		}
 	}
@@ -766,7 +1022,9 @@ func cutSSELine(line string) (string, string, bool) {
 }
 
 // exchangeToken performs OAuth 2.0 Token Exchange (RFC 8693) with the authorization server.
-// It exchanges the subject token for an access token.
+// It exchanges the subject token for an access token, which is cached until shortly before it expires.
+// An expired access token is refreshed with the refresh token from the exchange, if there is one, and
+// the subject token is exchanged again if that fails.
 // Returns the exchanged access token or an error. If the endpoint returns 404, returns (empty string, nil).
 func (s *HTTPClient) exchangeToken(ctx context.Context, subjectToken string) (string, error) {
 	if s.tokenExchangeEndpoint == "" {
@@ -774,6 +1032,34 @@ func (s *HTTPClient) exchangeToken(ctx context.Context, subjectToken string) (st
 		return "", nil
 	}
 
+	hash := sha256.Sum256([]byte(subjectToken))
+	key := hex.EncodeToString(hash[:])
+
+	s.exchangedTokensLock.Lock()
+	cached, ok := s.exchangedTokens[key]
+	s.exchangedTokensLock.Unlock()
+	if ok && time.Now().Add(exchangedTokenExpiryMargin).Before(cached.expiresAt) {
+		return cached.accessToken, nil
+	}
+
+	if ok && cached.refreshToken != "" {
+		data := url.Values{}
+		data.Set("grant_type", "refresh_token")
+		data.Set("refresh_token", cached.refreshToken)
+
+		tokenResp, err := s.requestToken(ctx, data)
+		if err != nil {
+			log.Debugf(ctx, "Failed to refresh exchanged token, exchanging it again: %v", err)
+		} else if tokenResp != nil {
+			if tokenResp.RefreshToken == "" {
+				// The refresh token can be used again if no new one was issued.
+				tokenResp.RefreshToken = cached.refreshToken
+			}
+			s.cacheExchangedToken(key, tokenResp)
+			return tokenResp.AccessToken, nil
+		}
+	}
+
 	// Build the token exchange request according to RFC 8693
 	data := url.Values{}
 	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
@@ -782,10 +1068,33 @@ func (s *HTTPClient) exchangeToken(ctx context.Context, subjectToken string) (st
 	data.Set("requested_token_type", "urn:ietf:params:oauth:token-type:access_token")
 	data.Set("resource", s.baseURL)
 
+	tokenResp, err := s.requestToken(ctx, data)
+	if err != nil {
+		return "", err
+	} else if tokenResp == nil {
+		return "", nil
+	}
+
+	s.cacheExchangedToken(key, tokenResp)
+	return tokenResp.AccessToken, nil
+}
+
+type tokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	Scope           string `json:"scope"`
+	RefreshToken    string `json:"refresh_token"`
+}
+
+// requestToken sends a token request with the grant in data to the token exchange endpoint. If the
+// endpoint does not respond with 200 OK, it returns (nil, nil).
+func (s *HTTPClient) requestToken(ctx context.Context, data url.Values) (*tokenResponse, error) {
 	// Create the HTTP request
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenExchangeEndpoint, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("failed to create token exchange request: %w", err)
+		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -796,35 +1105,68 @@ func (s *HTTPClient) exchangeToken(ctx context.Context, subjectToken string) (st
 	}
 
 	// Make the request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.baseClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to call token exchange endpoint: %w", err)
+		return nil, fmt.Errorf("failed to call token exchange endpoint: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// If the response status code is not OK, then continue without a token.
 	// Maybe OAuth will work.
 	if resp.StatusCode != http.StatusOK {
-		log.Debugf(ctx, "Token exchange endpoint: %s returned %d", s.tokenExchangeEndpoint, resp.StatusCode)
-		return "", nil
+		log.Debugf(ctx, "Token exchange endpoint: %s returned %d for %s grant", s.tokenExchangeEndpoint, resp.StatusCode, data.Get("grant_type"))
+		return nil, nil
 	}
 
 	// Parse successful response
-	var tokenResp struct {
-		AccessToken     string `json:"access_token"`
-		IssuedTokenType string `json:"issued_token_type"`
-		TokenType       string `json:"token_type"`
-		ExpiresIn       int    `json:"expires_in"`
-		Scope           string `json:"scope"`
-		RefreshToken    string `json:"refresh_token"`
-	}
+	var tokenResp tokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to parse token exchange response: %w", err)
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
 	if tokenResp.AccessToken == "" {
-		return "", fmt.Errorf("token exchange response missing access_token")
+		return nil, fmt.Errorf("token response missing access_token")
 	}
 
-	return tokenResp.AccessToken, nil
+	return &tokenResp, nil
+}
+
+// cacheExchangedToken stores the access and refresh token until the access token expires. Tokens without
+// an expiry are not cached. The tokens that expired are evicted, and if the cache is full, the tokens that
+// expire first, so a client that is used with many subject tokens doesn't keep all of them.
+func (s *HTTPClient) cacheExchangedToken(key string, tokenResp *tokenResponse) {
+	if tokenResp.ExpiresIn <= 0 {
+		return
+	}
+
+	s.exchangedTokensLock.Lock()
+	defer s.exchangedTokensLock.Unlock()
+
+	if s.exchangedTokens == nil {
+		s.exchangedTokens = map[string]exchangedToken{}
+	}
+	delete(s.exchangedTokens, key)
+
+	now := time.Now()
+	maps.DeleteFunc(s.exchangedTokens, func(_ string, token exchangedToken) bool {
+		return now.After(token.expiresAt)
+	})
+	for len(s.exchangedTokens) >= maxExchangedTokens {
+		var (
+			first    string
+			earliest time.Time
+		)
+		for key, token := range s.exchangedTokens {
+			if first == "" || token.expiresAt.Before(earliest) {
+				first, earliest = key, token.expiresAt
+			}
+		}
+		delete(s.exchangedTokens, first)
+	}
+
+	s.exchangedTokens[key] = exchangedToken{
+		accessToken:  tokenResp.AccessToken,
+		refreshToken: tokenResp.RefreshToken,
+		expiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
 }
@@ -0,0 +1,456 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"golang.org/x/oauth2"
+)
+
+// retryServer is a streamable HTTP MCP server that responds to the first requests of a method with the
+// queued status codes, and successfully after that.
+type retryServer struct {
+	lock     sync.Mutex
+	statuses map[string][]int
+	attempts map[string]int
+	delay    time.Duration
+	// authorization is the Authorization header of the last request.
+	authorization string
+}
+
+func (r *retryServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.lock.Lock()
+	r.attempts[msg.Method]++
+	r.authorization = req.Header.Get("Authorization")
+	var status int
+	if statuses := r.statuses[msg.Method]; len(statuses) > 0 {
+		status, r.statuses[msg.Method] = statuses[0], statuses[1:]
+	}
+	r.lock.Unlock()
+
+	switch {
+	case status != 0:
+		w.WriteHeader(status)
+	case msg.ID == nil:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		if msg.Method != "initialize" {
+			select {
+			case <-time.After(r.delay):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		w.Header().Set(SessionIDHeader, "session")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+	}
+}
+
+func (r *retryServer) attemptsOf(method string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.attempts[method]
+}
+
+func newRetryClient(t *testing.T, server *retryServer, opts HTTPClientOptions) (*HTTPClient, chan Message) {
+	t.Helper()
+	server.attempts = map[string]int{}
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = &RetryPolicy{BaseBackoff: time.Millisecond}
+	}
+	c, err := newHTTPClient("test", Server{BaseURL: srv.URL}, opts, nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(chan Message, 10)
+	if err := c.Start(t.Context(), func(_ context.Context, msg Message) {
+		responses <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close(false)
+	})
+
+	if err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 1, Method: "initialize", Params: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	<-responses
+	return c, responses
+}
+
+func TestHTTPClient_RetryIdempotent(t *testing.T) {
+	server := &retryServer{statuses: map[string][]int{
+		"initialize": {http.StatusServiceUnavailable},
+		"tools/list": {http.StatusBadGateway, http.StatusGatewayTimeout},
+	}}
+	c, responses := newRetryClient(t, server, HTTPClientOptions{})
+
+	if err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 2, Method: "tools/list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(float64(2)).Equal(t, (<-responses).ID)
+	autogold.Expect(2).Equal(t, server.attemptsOf("initialize"))
+	autogold.Expect(3).Equal(t, server.attemptsOf("tools/list"))
+}
+
+func TestHTTPClient_RetryLimit(t *testing.T) {
+	server := &retryServer{statuses: map[string][]int{
+		"tools/list": {http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond},
+	})
+
+	err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 2, Method: "tools/list"})
+	autogold.Expect("failed to send message: 503 Service Unavailable: ").Equal(t, err.Error())
+	autogold.Expect(2).Equal(t, server.attemptsOf("tools/list"))
+}
+
+func TestHTTPClient_NoRetry(t *testing.T) {
+	server := &retryServer{statuses: map[string][]int{
+		"tools/call": {http.StatusServiceUnavailable},
+		"ping":       {http.StatusAccepted, http.StatusServiceUnavailable},
+	}}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{})
+
+	// Tools can have side effects, so calls are not sent again.
+	err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"a"}`)})
+	autogold.Expect("failed to send message: 503 Service Unavailable: ").Equal(t, err.Error())
+	autogold.Expect(1).Equal(t, server.attemptsOf("tools/call"))
+
+	// A request the server accepted is not sent again.
+	if err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 3, Method: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1).Equal(t, server.attemptsOf("ping"))
+}
+
+func TestHTTPClient_RequestTimeout(t *testing.T) {
+	server := &retryServer{delay: time.Minute}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{RequestTimeout: 50 * time.Millisecond})
+
+	err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 2, Method: "tools/list"})
+	autogold.Expect(true).Equal(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestHTTPClient_ExchangeTokenCache(t *testing.T) {
+	var (
+		lock      sync.Mutex
+		exchanges int
+		expiresIn = 3600
+	)
+	exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		exchanges++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("exchanged-%s-%d", r.FormValue("subject_token"), exchanges),
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(exchange.Close)
+
+	server := &retryServer{}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{TokenExchangeEndpoint: exchange.URL})
+
+	send := func(subjectToken string) string {
+		if err := c.Send(WithToken(t.Context(), subjectToken), Message{JSONRPC: "2.0", Method: "notifications/cancelled"}); err != nil {
+			t.Fatal(err)
+		}
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		return server.authorization
+	}
+
+	autogold.Expect("Bearer exchanged-a-1").Equal(t, send("a"))
+	autogold.Expect("Bearer exchanged-a-1").Equal(t, send("a"))
+	autogold.Expect("Bearer exchanged-b-2").Equal(t, send("b"))
+	autogold.Expect(2).Equal(t, exchanges)
+
+	// Tokens that expire within the margin are exchanged again.
+	lock.Lock()
+	expiresIn = 10
+	lock.Unlock()
+	autogold.Expect("Bearer exchanged-c-3").Equal(t, send("c"))
+	autogold.Expect("Bearer exchanged-c-4").Equal(t, send("c"))
+}
+
+func TestHTTPClient_RefreshExchangedToken(t *testing.T) {
+	var (
+		lock          sync.Mutex
+		grants        []string
+		refreshFails  bool
+		refreshTokens = map[string]bool{}
+	)
+	exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		grant := r.FormValue("grant_type")
+		grants = append(grants, grant)
+		if grant == "refresh_token" && (refreshFails || !refreshTokens[r.FormValue("refresh_token")]) {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+
+		refreshToken := fmt.Sprintf("refresh-%d", len(grants))
+		refreshTokens[refreshToken] = true
+		// The tokens expire within the margin, so they are refreshed on the next request.
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", len(grants)),
+			"refresh_token": refreshToken,
+			"expires_in":    10,
+		})
+	}))
+	t.Cleanup(exchange.Close)
+
+	server := &retryServer{}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{TokenExchangeEndpoint: exchange.URL})
+
+	send := func() string {
+		if err := c.Send(WithToken(t.Context(), "subject"), Message{JSONRPC: "2.0", Method: "notifications/cancelled"}); err != nil {
+			t.Fatal(err)
+		}
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		return server.authorization
+	}
+
+	autogold.Expect("Bearer access-1").Equal(t, send())
+	autogold.Expect("Bearer access-2").Equal(t, send())
+	autogold.Expect("Bearer access-3").Equal(t, send())
+
+	lock.Lock()
+	refreshFails = true
+	lock.Unlock()
+	autogold.Expect("Bearer access-5").Equal(t, send())
+
+	autogold.Expect([]string{
+		"urn:ietf:params:oauth:grant-type:token-exchange",
+		"refresh_token",
+		"refresh_token",
+		"refresh_token",
+		"urn:ietf:params:oauth:grant-type:token-exchange",
+	}).Equal(t, grants)
+}
+
+func TestHTTPClient_ExchangedTokensEvicted(t *testing.T) {
+	c := &HTTPClient{exchangedTokens: map[string]exchangedToken{
+		"expired": {accessToken: "expired", expiresAt: time.Now().Add(-time.Minute)},
+	}}
+
+	// Expired tokens are evicted when another token is cached
+	c.cacheExchangedToken("a", &tokenResponse{AccessToken: "a", ExpiresIn: 60})
+	autogold.Expect([]string{"a"}).Equal(t, slices.Sorted(maps.Keys(c.exchangedTokens)))
+
+	// A full cache evicts the token that expires first
+	for i := range maxExchangedTokens - 1 {
+		c.cacheExchangedToken(fmt.Sprintf("token-%d", i), &tokenResponse{AccessToken: "token", ExpiresIn: 3600})
+	}
+	c.cacheExchangedToken("b", &tokenResponse{AccessToken: "b", ExpiresIn: 3600})
+	_, ok := c.exchangedTokens["a"]
+	autogold.Expect([]any{maxExchangedTokens, false}).Equal(t, []any{len(c.exchangedTokens), ok})
+}
+
+// countingTransport counts the POST requests it sends.
+type countingTransport struct {
+	lock  sync.Mutex
+	posts int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		c.lock.Lock()
+		c.posts++
+		c.lock.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// staticTokenStorage returns the same valid token for every URL.
+type staticTokenStorage struct{}
+
+func (staticTokenStorage) GetTokenConfig(context.Context, string) (*oauth2.Config, *oauth2.Token, error) {
+	return &oauth2.Config{}, &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func (staticTokenStorage) SetTokenConfig(context.Context, string, *oauth2.Config, *oauth2.Token) error {
+	return nil
+}
+
+func TestHTTPClient_CustomHTTPClient(t *testing.T) {
+	transport := &countingTransport{}
+	server := &retryServer{}
+	c, _ := newRetryClient(t, server, HTTPClientOptions{
+		HTTPClient:   &http.Client{Transport: transport},
+		TokenStorage: staticTokenStorage{},
+	})
+
+	if err := c.Send(t.Context(), Message{JSONRPC: "2.0", Method: "notifications/cancelled"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The client from the token storage authenticates with the custom transport.
+	transport.lock.Lock()
+	defer transport.lock.Unlock()
+	autogold.Expect(2).Equal(t, transport.posts)
+	autogold.Expect("Bearer token").Equal(t, server.authorization)
+
+	oauthTransport, ok := c.httpClient.Transport.(*oauth2.Transport)
+	autogold.Expect(true).Equal(t, ok)
+	autogold.Expect(true).Equal(t, oauthTransport.Base == transport)
+}
+
+func TestHTTPClient_SSEReconnect(t *testing.T) {
+	var (
+		lock         sync.Mutex
+		lastEventIDs []string
+		connects     []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var msg Message
+			_ = json.NewDecoder(r.Body).Decode(&msg)
+			if msg.ID == nil {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			w.Header().Set(SessionIDHeader, "session")
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+			return
+		}
+
+		lock.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connects = append(connects, time.Now())
+		first := len(connects) == 1
+		lock.Unlock()
+
+		if !first {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		// Send one event and drop the stream.
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "id: 7\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := newHTTPClient("test", Server{BaseURL: srv.URL}, HTTPClientOptions{
+		SSEReconnectBackoff:     &Backoff{Base: 10 * time.Millisecond, Max: 20 * time.Millisecond, Multiplier: 2},
+		SSEMaxReconnectAttempts: 3,
+	}, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(t.Context(), func(context.Context, Message) {}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close(false)
+	})
+
+	if err := c.Send(t.Context(), Message{JSONRPC: "2.0", ID: 1, Method: "initialize", Params: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not closed after the reconnect attempts")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	autogold.Expect([]string{"", "7", "7", "7"}).Equal(t, lastEventIDs)
+	for i, wait := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond} {
+		if d := connects[i+1].Sub(connects[i]); d < wait {
+			t.Errorf("reconnect %d after %s, expected at least %s", i+1, d, wait)
+		}
+	}
+	autogold.Expect(true).Equal(t, strings.HasPrefix(c.Err().Error(), "failed to reconnect to SSE server test after 3 attempts: "))
+}
+
+func TestHTTPClient_ResumeLastEventID(t *testing.T) {
+	lastEventIDs := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		select {
+		case lastEventIDs <- r.Header.Get("Last-Event-ID"):
+		default:
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "id: 42\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := newHTTPClient("test", Server{BaseURL: srv.URL}, HTTPClientOptions{}, &SessionState{
+		ID:          "session",
+		LastEventID: "41",
+	}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan Message, 1)
+	if err := c.Start(t.Context(), func(_ context.Context, msg Message) {
+		messages <- msg
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close(false)
+	})
+
+	select {
+	case id := <-lastEventIDs:
+		autogold.Expect("41").Equal(t, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SSE stream was not resumed")
+	}
+	select {
+	case <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not received")
+	}
+	autogold.Expect("42").Equal(t, c.LastEventID())
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"github.com/tidwall/gjson"
 )
@@ -241,6 +242,7 @@ func (h *HTTPServer) streamEvents(rw http.ResponseWriter, req *http.Request, aud
 		return
 	}
 	defer h.sessions.Release(session)
+	session.countActive()
 
 	auditLog.ResponseStatus = http.StatusOK
 	auditLog.ClientName = session.session.InitializeRequest.ClientInfo.Name
@@ -265,6 +267,7 @@ func (h *HTTPServer) streamEvents(rw http.ResponseWriter, req *http.Request, aud
 		}
 
 		data, _ := json.Marshal(msg)
+		log.RecordMessage(req.Context(), "http-server", true, data)
 		_, err := rw.Write([]byte("data: " + string(data) + "\n\n"))
 		if err != nil {
 			http.Error(rw, "Failed to write message: "+err.Error(), http.StatusInternalServerError)
@@ -325,6 +328,13 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	auditLog := buildAuditLog(req, auditMethod, sessionID)
 
+	if req.Method != http.MethodGet {
+		// Event streams are left out, they last as long as the client is connected
+		defer func() {
+			metrics.ObserveMCPRequest(auditLog.CallType, time.Since(auditLog.CreatedAt))
+		}()
+	}
+
 	// Wrap response writer for DELETE and POST to capture response
 	var recorder *responseRecorder
 	if req.Method == http.MethodDelete || req.Method == http.MethodPost {
@@ -411,6 +421,8 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	log.RecordMessage(ctx, "http-server", false, auditLog.RequestBody)
+
 	auditLog.CallType = msg.Method
 	if msg.ID != nil {
 		auditLog.RequestID = fmt.Sprintf("%v", msg.ID)
@@ -430,6 +442,7 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 			auditLog.ResponseStatus = recorder.statusCode
 		}
 		auditLog.ResponseBody = recorder.body.Bytes()
+		log.RecordMessage(ctx, "http-server", true, auditLog.ResponseBody)
 		responseHeaders, _ := json.Marshal(recorder.Header())
 		auditLog.ResponseHeaders = responseHeaders
 		auditLog.ProcessingTimeMs = time.Since(auditLog.CreatedAt).Milliseconds()
@@ -447,6 +460,7 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 			return
 		}
 		defer h.sessions.Release(streamingSession)
+		streamingSession.countActive()
 
 		streamingSession.session.sessionManager = h.sessions
 
@@ -500,20 +514,22 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	defer h.sessions.Release(session)
+	session.countActive()
 
 	session.session.sessionManager = h.sessions
 	session.session.AddEnv(h.getEnv(req))
 
 	resp, err := session.Exchange(ctx, msg)
 	if err != nil {
+		session.Close(true)
 		if errors.As(err, &AuthRequiredErr{}) {
 			respondWithUnauthorized(rw, req)
 			return
 		}
-		session.Close(true)
 		http.Error(rw, fmt.Sprintf(`{"http_error": "Failed to handle message: %v"}`, err), http.StatusInternalServerError)
 		return
 	} else if resp.Error != nil && errors.As(resp.Error, &AuthRequiredErr{}) {
+		session.Close(true)
 		respondWithUnauthorized(rw, req)
 		return
 	}
@@ -714,6 +730,8 @@ func (h *HTTPServer) getEnv(req *http.Request) map[string]string {
 	for k, v := range req.Header {
 		if key, ok := strings.CutPrefix(k, "X-Nanobot-Env-"); ok {
 			env[key] = strings.Join(v, ", ")
+		} else if name, ok := strings.CutPrefix(k, FeatureHeaderPrefix); ok {
+			env[FeatureEnvKey(name)] = strings.Join(v, ", ")
 		}
 	}
 	return env
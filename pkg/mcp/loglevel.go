@@ -0,0 +1,22 @@
+package mcp
+
+import "slices"
+
+// LogLevels are the MCP log levels, from least to most severe.
+var LogLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// IsLogLevel reports whether level is one of LogLevels.
+func IsLogLevel(level string) bool {
+	return slices.Contains(LogLevels, level)
+}
+
+// LogLevelEnabled reports whether a message of the given level should be forwarded when the minimum level
+// is threshold. An empty threshold, or a level that is not known, is always enabled.
+func LogLevelEnabled(level, threshold string) bool {
+	minimum := slices.Index(LogLevels, threshold)
+	if minimum < 0 {
+		return true
+	}
+	severity := slices.Index(LogLevels, level)
+	return severity < 0 || severity >= minimum
+}
@@ -55,6 +55,11 @@ func nextMessageID() float64 {
 	return float64(x)
 }
 
+// IsResponse returns true if the message is a response to a request, having an ID but no method.
+func (r *Message) IsResponse() bool {
+	return r.ID != nil && r.Method == ""
+}
+
 func (r *Message) IsRequest() bool {
 	return len(r.Params) > 0 && !bytes.Equal(r.Params, []byte("null"))
 }
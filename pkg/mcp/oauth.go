@@ -24,29 +24,42 @@ type oauth struct {
 	redirectURL, clientName string
 	currentToken            oauth2.Token
 	metadataClient          *http.Client
+	baseClient              *http.Client
 	callbackHandler         CallbackHandler
 	clientLookup            ClientCredLookup
 	tokenStorage            TokenStorage
 }
 
-func newOAuth(callbackHandler CallbackHandler, clientLookup ClientCredLookup, tokenStorage TokenStorage, clientName, redirectURL string) *oauth {
+// newOAuth returns an OAuth handler. The clients it creates, and its requests to the authorization server,
+// use the transport of baseClient.
+func newOAuth(baseClient *http.Client, callbackHandler CallbackHandler, clientLookup ClientCredLookup, tokenStorage TokenStorage, clientName, redirectURL string) *oauth {
 	return &oauth{
 		clientName:      clientName,
 		redirectURL:     redirectURL,
 		callbackHandler: callbackHandler,
 		metadataClient: &http.Client{
-			Timeout: 5 * time.Second,
+			Transport: baseClient.Transport,
+			Timeout:   5 * time.Second,
 		},
+		baseClient:   baseClient,
 		clientLookup: clientLookup,
 		tokenStorage: tokenStorage,
 	}
 }
 
+// withBaseClient returns a context that makes the oauth2 package send its requests with the base client,
+// and use its transport for the clients it creates.
+func (o *oauth) withBaseClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, o.baseClient)
+}
+
 func (o *oauth) loadFromStorage(ctx context.Context, connectURL string) *http.Client {
 	if o.tokenStorage == nil {
 		return nil
 	}
 
+	ctx = o.withBaseClient(ctx)
+
 	// Read the token config from storage to see if we have valid auth
 	conf, tok, err := o.tokenStorage.GetTokenConfig(ctx, connectURL)
 	if err != nil {
@@ -67,6 +80,8 @@ func (o *oauth) loadFromStorage(ctx context.Context, connectURL string) *http.Cl
 }
 
 func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, authenticateHeader string) (*http.Client, error) {
+	ctx = o.withBaseClient(ctx)
+
 	if httpClient := o.loadFromStorage(ctx, connectURL); httpClient != nil {
 		return httpClient, nil
 	}
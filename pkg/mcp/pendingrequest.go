@@ -1,15 +1,70 @@
 package mcp
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrTooManyPendingRequests = errors.New("too many pending requests")
 
 type PendingRequests struct {
-	lock sync.Mutex
-	ids  map[any]chan Message
+	lock  sync.Mutex
+	ids   map[any]chan Message
+	limit int
+	queue bool
+	// freed is closed, and then cleared, whenever a pending request is removed, waking queued waiters.
+	freed chan struct{}
+}
+
+// SetLimit bounds the number of pending requests. Past the limit, Wait either fails with
+// ErrTooManyPendingRequests or, if queue is true, blocks until a request completes. A limit of zero or
+// less is unbounded.
+func (p *PendingRequests) SetLimit(limit int, queue bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.limit = limit
+	p.queue = queue
+	p.release()
 }
 
+// WaitFor registers id regardless of the limit and returns the channel its response is delivered to.
 func (p *PendingRequests) WaitFor(id any) chan Message {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+
+	return p.waitFor(id)
+}
+
+// Wait is like WaitFor but honors the limit set with SetLimit.
+func (p *PendingRequests) Wait(ctx context.Context, id any) (chan Message, error) {
+	for {
+		p.lock.Lock()
+		if p.limit <= 0 || len(p.ids) < p.limit {
+			defer p.lock.Unlock()
+			return p.waitFor(id), nil
+		}
+		if !p.queue {
+			p.lock.Unlock()
+			return nil, fmt.Errorf("%w: limit of %d reached", ErrTooManyPendingRequests, p.limit)
+		}
+		if p.freed == nil {
+			p.freed = make(chan struct{})
+		}
+		freed := p.freed
+		p.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-freed:
+		}
+	}
+}
+
+func (p *PendingRequests) waitFor(id any) chan Message {
 	if p.ids == nil {
 		p.ids = make(map[any]chan Message)
 	}
@@ -18,19 +73,36 @@ func (p *PendingRequests) WaitFor(id any) chan Message {
 	return ch
 }
 
+func (p *PendingRequests) release() {
+	if p.freed != nil {
+		close(p.freed)
+		p.freed = nil
+	}
+}
+
+// Notify delivers msg to the waiter for its ID if msg is a response. Each waiter receives at most one
+// response, so a repeated response for the same ID is not delivered. Requests are never delivered, even if
+// their ID collides with a pending request.
 func (p *PendingRequests) Notify(msg Message) bool {
+	if !msg.IsResponse() {
+		return false
+	}
+
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
 	ch, ok := p.ids[msg.ID]
-	if ok {
-		select {
-		case ch <- msg:
-			return true
-			// don't let it block, we are holding the lock
-		default:
-		}
-		delete(p.ids, msg.ID)
+	if !ok {
+		return false
+	}
+	delete(p.ids, msg.ID)
+	p.release()
+
+	select {
+	case ch <- msg:
+		return true
+		// don't let it block, we are holding the lock
+	default:
 	}
 	return false
 }
@@ -40,6 +112,15 @@ func (p *PendingRequests) Done(id any) {
 	defer p.lock.Unlock()
 
 	delete(p.ids, id)
+	p.release()
+}
+
+// Len returns the number of requests waiting for a response.
+func (p *PendingRequests) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.ids)
 }
 
 func (p *PendingRequests) Close() {
@@ -50,4 +131,5 @@ func (p *PendingRequests) Close() {
 		close(ch)
 	}
 	p.ids = nil
+	p.release()
 }
@@ -0,0 +1,213 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestPendingRequests_Notify(t *testing.T) {
+	var p PendingRequests
+	ch := p.WaitFor(1.0)
+
+	// Unknown IDs and requests reusing a pending ID are not delivered
+	autogold.Expect(false).Equal(t, p.Notify(Message{ID: 2.0, Result: json.RawMessage(`"unknown"`)}))
+	autogold.Expect(false).Equal(t, p.Notify(Message{ID: 1.0, Method: "ping"}))
+
+	autogold.Expect(true).Equal(t, p.Notify(Message{ID: 1.0, Result: json.RawMessage(`"first"`)}))
+	autogold.Expect(false).Equal(t, p.Notify(Message{ID: 1.0, Result: json.RawMessage(`"duplicate"`)}))
+
+	autogold.Expect(`"first"`).Equal(t, string((<-ch).Result))
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected second response: %s", msg.Result)
+	default:
+	}
+}
+
+type testWire struct {
+	handler WireHandler
+	sent    chan Message
+	ctx     context.Context
+}
+
+func (w *testWire) Close(bool) {}
+
+func (w *testWire) Wait() {
+	<-w.ctx.Done()
+}
+
+func (w *testWire) Start(ctx context.Context, handler WireHandler) error {
+	w.ctx = ctx
+	w.handler = handler
+	return nil
+}
+
+func (w *testWire) Send(_ context.Context, req Message) error {
+	w.sent <- req
+	return nil
+}
+
+func (w *testWire) SessionID() string {
+	return ""
+}
+
+func TestSession_UnknownAndDuplicateResponses(t *testing.T) {
+	var (
+		wire     = &testWire{sent: make(chan Message, 1)}
+		received = make(chan Message, 4)
+	)
+
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(_ context.Context, msg Message) {
+		received <- msg
+	}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	go func() {
+		req := <-wire.sent
+		ctx := t.Context()
+		wire.handler(ctx, Message{JSONRPC: "2.0", ID: "unknown", Result: json.RawMessage(`"wrong"`)})
+		wire.handler(ctx, Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`"right"`)})
+		wire.handler(ctx, Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`"duplicate"`)})
+		wire.handler(ctx, Message{JSONRPC: "2.0", Method: "notifications/message"})
+	}()
+
+	var result string
+	if err := s.Exchange(t.Context(), "test", struct{}{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("right").Equal(t, result)
+
+	// The notification is sent last, so it is only the first message handled if the unknown and
+	// duplicate responses were dropped
+	autogold.Expect("notifications/message").Equal(t, (<-received).Method)
+}
+
+func TestSession_ExchangeTimeout(t *testing.T) {
+	wire := &testWire{sent: make(chan Message, 2)}
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	var result string
+	err = s.Exchange(t.Context(), "test", struct{}{}, &result, ExchangeOption{
+		Timeout: 10 * time.Millisecond,
+	})
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrExchangeTimeout))
+	autogold.Expect("timed out waiting for response to test after 10ms").Equal(t, err.Error())
+	autogold.Expect(0).Equal(t, s.pendingRequest.Len())
+
+	// A late response for the abandoned request is dropped, not delivered to the next waiter
+	late := <-wire.sent
+	wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: late.ID, Result: json.RawMessage(`"late"`)})
+	autogold.Expect(0).Equal(t, s.pendingRequest.Len())
+}
+
+func TestSession_DefaultExchangeTimeout(t *testing.T) {
+	defer func(d time.Duration) {
+		DefaultExchangeTimeout = d
+	}(DefaultExchangeTimeout)
+	DefaultExchangeTimeout = 10 * time.Millisecond
+
+	wire := &testWire{sent: make(chan Message, 1)}
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+
+	var result string
+	err = s.Exchange(t.Context(), "test", struct{}{}, &result)
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrExchangeTimeout))
+	autogold.Expect(0).Equal(t, s.pendingRequest.Len())
+}
+
+func TestPendingRequests_Limit(t *testing.T) {
+	var p PendingRequests
+	p.SetLimit(1, false)
+
+	if _, err := p.Wait(t.Context(), 1.0); err != nil {
+		t.Fatal(err)
+	}
+	_, err := p.Wait(t.Context(), 2.0)
+	autogold.Expect("too many pending requests: limit of 1 reached").Equal(t, err.Error())
+
+	p.Done(1.0)
+	if _, err := p.Wait(t.Context(), 2.0); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1).Equal(t, p.Len())
+}
+
+func TestPendingRequests_LimitQueue(t *testing.T) {
+	var p PendingRequests
+	p.SetLimit(1, true)
+
+	first, err := p.Wait(t.Context(), 1.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := p.Wait(t.Context(), 2.0)
+		queued <- err
+	}()
+
+	select {
+	case err := <-queued:
+		t.Fatalf("expected the second request to be queued, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Answering the first request frees its slot
+	autogold.Expect(true).Equal(t, p.Notify(Message{ID: 1.0, Result: json.RawMessage(`{}`)}))
+	<-first
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1).Equal(t, p.Len())
+
+	// A queued request gives up when its context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Wait(ctx, 3.0)
+	autogold.Expect(true).Equal(t, errors.Is(err, context.DeadlineExceeded))
+	autogold.Expect(1).Equal(t, p.Len())
+}
+
+func TestSession_MaxPendingRequests(t *testing.T) {
+	wire := &testWire{sent: make(chan Message, 2)}
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(false)
+	s.pendingRequest.SetLimit(1, false)
+
+	done := make(chan error, 1)
+	go func() {
+		var result string
+		done <- s.Exchange(t.Context(), "first", struct{}{}, &result)
+	}()
+	first := <-wire.sent
+
+	var result string
+	err = s.Exchange(t.Context(), "second", struct{}{}, &result)
+	autogold.Expect("failed to send second: too many pending requests: limit of 1 reached").Equal(t, err.Error())
+
+	wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: first.ID, Result: json.RawMessage(`"ok"`)})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(0).Equal(t, s.pendingRequest.Len())
+}
@@ -0,0 +1,209 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+// ReplayWire is a Wire that answers requests from MCP traffic captured by log.Recorder instead of a live
+// server. Requests are matched to recorded requests by method and params (ignoring _meta), and any
+// notifications the server sent while the recorded request was in flight are replayed before the response.
+type ReplayWire struct {
+	lock      sync.Mutex
+	exchanges []*replayExchange
+	handler   WireHandler
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+type replayExchange struct {
+	method        string
+	params        string
+	notifications []log.Record
+	response      *log.Record
+	used          bool
+}
+
+// NewReplayWire creates a wire that replays the records for serverName. Records are expected to be from
+// the client's point of view, so requests are sent and responses received.
+func NewReplayWire(serverName string, records []log.Record) *ReplayWire {
+	var (
+		exchanges []*replayExchange
+		inFlight  = map[string]*replayExchange{}
+	)
+
+	for _, record := range records {
+		if record.Server != serverName {
+			continue
+		}
+		switch {
+		case record.Direction == log.DirectionSend && record.Method != "" && len(record.ID) > 0:
+			var msg Message
+			if err := json.Unmarshal(record.Message, &msg); err != nil {
+				continue
+			}
+			exchange := &replayExchange{
+				method: record.Method,
+				params: replayParams(record.Method, msg.Params),
+			}
+			exchanges = append(exchanges, exchange)
+			inFlight[string(record.ID)] = exchange
+		case record.Direction == log.DirectionReceive && record.IsResponse():
+			if exchange, ok := inFlight[string(record.ID)]; ok {
+				exchange.response = &record
+				delete(inFlight, string(record.ID))
+			}
+		case record.Direction == log.DirectionReceive && record.Method != "" && len(record.ID) == 0:
+			for _, exchange := range inFlight {
+				exchange.notifications = append(exchange.notifications, record)
+			}
+		}
+	}
+
+	return &ReplayWire{
+		exchanges: exchanges,
+	}
+}
+
+// NewReplayWireFromFile reads a recording written with the --record flag and replays it for serverName.
+func NewReplayWireFromFile(serverName, path string) (*ReplayWire, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording %s: %w", path, err)
+	}
+	defer f.Close()
+
+	records, err := log.ReadRecords(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording %s: %w", path, err)
+	}
+
+	return NewReplayWire(serverName, records), nil
+}
+
+// replayParams normalizes params for matching, dropping _meta which holds values such as progress
+// tokens that change between runs. Initialize params describe the client, which may differ from the
+// recording, so initialize only matches on the method.
+func replayParams(method string, params json.RawMessage) string {
+	if method == "initialize" || len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return "{}"
+	}
+	var obj any
+	if err := json.Unmarshal(params, &obj); err != nil {
+		return string(params)
+	}
+	if m, ok := obj.(map[string]any); ok {
+		delete(m, "_meta")
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return string(params)
+	}
+	return string(data)
+}
+
+func (r *ReplayWire) Start(ctx context.Context, handler WireHandler) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.handler = handler
+	return nil
+}
+
+// match returns the first unused exchange for the method and params. Once every matching exchange has been
+// used the last one is replayed again, so repeated idempotent calls such as tools/list keep working.
+func (r *ReplayWire) match(method, params string) *replayExchange {
+	var last *replayExchange
+	for _, exchange := range r.exchanges {
+		if exchange.method != method || exchange.params != params || exchange.response == nil {
+			continue
+		}
+		if !exchange.used {
+			exchange.used = true
+			return exchange
+		}
+		last = exchange
+	}
+	return last
+}
+
+func (r *ReplayWire) Send(_ context.Context, req Message) error {
+	if req.Method == "" || req.ID == nil {
+		// Notifications and responses to server requests are accepted and dropped
+		return nil
+	}
+
+	r.lock.Lock()
+	if r.handler == nil {
+		r.lock.Unlock()
+		return fmt.Errorf("replay wire is not started")
+	}
+	var (
+		params   = replayParams(req.Method, req.Params)
+		exchange = r.match(req.Method, params)
+		ctx      = r.ctx
+		handler  = r.handler
+	)
+	r.lock.Unlock()
+
+	if exchange == nil {
+		return fmt.Errorf("no recorded response for %s with params %s", req.Method, params)
+	}
+
+	var (
+		messages      []Message
+		progressToken = req.ProgressToken()
+	)
+	for _, record := range slices.Concat(exchange.notifications, []log.Record{*exchange.response}) {
+		var msg Message
+		if err := json.Unmarshal(record.Message, &msg); err != nil {
+			return fmt.Errorf("failed to parse recorded message for %s: %w", req.Method, err)
+		}
+		if msg.Method == "notifications/progress" && progressToken != nil {
+			// Progress has to be reported against the token of this request, not the recorded one
+			var progress NotificationProgressRequest
+			if err := json.Unmarshal(msg.Params, &progress); err == nil {
+				progress.ProgressToken = progressToken
+				msg.Params, _ = json.Marshal(progress)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	messages[len(messages)-1].ID = req.ID
+
+	go func() {
+		for _, msg := range messages {
+			handler(ctx, msg)
+		}
+	}()
+
+	return nil
+}
+
+func (r *ReplayWire) Close(bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func (r *ReplayWire) Wait() {
+	r.lock.Lock()
+	ctx := r.ctx
+	r.lock.Unlock()
+	if ctx != nil {
+		<-ctx.Done()
+	}
+}
+
+func (r *ReplayWire) SessionID() string {
+	return ""
+}
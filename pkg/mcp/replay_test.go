@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+const testRecording = `
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"send","id":"1","method":"initialize","message":{"jsonrpc":"2.0","id":"1","method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"nanobot","version":"v0.0.1"}}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"receive","id":"1","durationMs":1,"message":{"jsonrpc":"2.0","id":"1","result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"fs","version":"1.0.0"}}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"send","method":"notifications/initialized","message":{"jsonrpc":"2.0","method":"notifications/initialized"}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"send","id":"2","method":"tools/list","message":{"jsonrpc":"2.0","id":"2","method":"tools/list","params":{}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"receive","id":"2","durationMs":1,"message":{"jsonrpc":"2.0","id":"2","result":{"tools":[{"name":"read","inputSchema":{"type":"object"}}]}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"send","id":"3","method":"tools/call","message":{"jsonrpc":"2.0","id":"3","method":"tools/call","params":{"name":"read","arguments":{"path":"a.txt"},"_meta":{"progressToken":"recorded"}}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"receive","method":"notifications/progress","message":{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"recorded","progress":1,"message":"reading"}}}
+{"time":"2025-01-01T00:00:00Z","server":"fs","direction":"receive","id":"3","durationMs":5,"message":{"jsonrpc":"2.0","id":"3","result":{"content":[{"type":"text","text":"contents of a.txt"}]}}}
+{"time":"2025-01-01T00:00:00Z","server":"other","direction":"send","id":"4","method":"tools/list","message":{"jsonrpc":"2.0","id":"4","method":"tools/list","params":{}}}
+`
+
+func newReplayClient(t *testing.T, onNotify func(context.Context, Message) error) *Client {
+	t.Helper()
+
+	records, err := log.ReadRecords(strings.NewReader(testRecording))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(t.Context(), "fs", Server{}, ClientOption{
+		Wire:     NewReplayWire("fs", records),
+		OnNotify: onNotify,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Session.Close(false)
+	})
+	return c
+}
+
+func TestReplayWire(t *testing.T) {
+	for range 2 {
+		var (
+			lock     sync.Mutex
+			progress []string
+		)
+		c := newReplayClient(t, func(_ context.Context, msg Message) error {
+			var p NotificationProgressRequest
+			if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &p) == nil {
+				lock.Lock()
+				progress = append(progress, fmt.Sprintf("%v: %s", p.ProgressToken, p.Message))
+				lock.Unlock()
+			}
+			return nil
+		})
+
+		tools, err := c.ListTools(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		autogold.Expect("read").Equal(t, tools.Tools[0].Name)
+
+		result, err := c.Call(t.Context(), "read", map[string]any{"path": "a.txt"}, CallOption{
+			ProgressToken: "replayed",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		autogold.Expect("contents of a.txt").Equal(t, result.Content[0].Text)
+
+		lock.Lock()
+		autogold.Expect([]string{"replayed: reading"}).Equal(t, progress)
+		lock.Unlock()
+	}
+}
+
+func TestReplayWire_Unmatched(t *testing.T) {
+	c := newReplayClient(t, nil)
+
+	_, err := c.Call(t.Context(), "read", map[string]any{"path": "b.txt"})
+	autogold.Expect(`failed to send request: no recorded response for tools/call with params {"arguments":{"path":"b.txt"},"name":"read"}`).Equal(t, err.Error())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
@@ -36,15 +37,32 @@ func NewExistingServerSession(ctx context.Context, state SessionState, handler M
 	for k, v := range state.Attributes {
 		session.Set(k, v)
 	}
-	return &ServerSession{
+	serverSession := &ServerSession{
 		session: session,
 		wire:    s,
-	}, nil
+	}
+	session.serverSession = serverSession
+	return serverSession, nil
 }
 
 type ServerSession struct {
 	session *Session
 	wire    *serverWire
+	// countLock guards counted and closed, counted is true if the session is counted as active
+	countLock sync.Mutex
+	counted   bool
+	closed    bool
+}
+
+// countActive counts the session as an active session in the metrics, once, until it is closed. The HTTP
+// server counts the sessions it serves, so the in-process sessions of clients are not counted.
+func (s *ServerSession) countActive() {
+	s.countLock.Lock()
+	defer s.countLock.Unlock()
+	if !s.counted && !s.closed {
+		s.counted = true
+		metrics.SessionStarted()
+	}
 }
 
 func (s *ServerSession) Wait() {
@@ -96,6 +114,11 @@ func (s *ServerSession) GetSession() *Session {
 	return s.session
 }
 
+// SetSessionStore sets the store the session is persisted in by Session.Persist.
+func (s *ServerSession) SetSessionStore(store SessionStore) {
+	s.session.sessionManager = store
+}
+
 func (s *ServerSession) Exchange(ctx context.Context, msg Message) (Message, error) {
 	isInit, err := s.session.preInit(&msg)
 	if err != nil {
@@ -144,6 +167,13 @@ func (s *ServerSession) Close(deleteSession bool) {
 		return
 	}
 
+	s.countLock.Lock()
+	if s.counted && !s.closed {
+		metrics.SessionEnded()
+	}
+	s.closed = true
+	s.countLock.Unlock()
+
 	if s.session != nil {
 		s.session.Close(deleteSession)
 	}
@@ -212,12 +242,17 @@ func (s *serverWire) Send(ctx context.Context, req Message) error {
 	if s.pending.Notify(req) {
 		return nil
 	}
+
+	s.readerLock.RLock()
+	noReader := s.noReader
+	s.readerLock.RUnlock()
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-s.ctx.Done():
 		return s.ctx.Err()
-	case <-s.noReader:
+	case <-noReader:
 		return ErrNoReader
 	case s.read <- req:
 		return nil
@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+)
+
+func activeSessions(t *testing.T) string {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rw.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, "nanobot_active_sessions "); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestServerSession_ActiveSessions(t *testing.T) {
+	before := activeSessions(t)
+
+	// The in-process sessions of clients are not counted
+	inProcess, err := NewServerSession(t.Context(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := activeSessions(t); after != before {
+		t.Errorf("active sessions are %s with an in-process session, want %s", after, before)
+	}
+	inProcess.Close(false)
+	if after := activeSessions(t); after != before {
+		t.Errorf("active sessions are %s after closing an in-process session, want %s", after, before)
+	}
+
+	handler, err := NewHTTPServer(t.Context(), nil, testServer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// A session of the HTTP server is counted until it is deleted
+	c, err := NewClient(t.Context(), "http", Server{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTools(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if activeSessions(t) == before {
+		t.Errorf("the session of the HTTP server is not counted")
+	}
+	c.Close(true)
+	if after := activeSessions(t); after != before {
+		t.Errorf("active sessions are %s after deleting the session, want %s", after, before)
+	}
+}
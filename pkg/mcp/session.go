@@ -9,8 +9,10 @@ import (
 	"reflect"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
 )
 
@@ -53,6 +55,7 @@ type Session struct {
 	filters           []filterRegistration
 	filterID          int
 	sessionManager    SessionStore
+	serverSession     *ServerSession
 	hooks             Hooks
 }
 
@@ -100,6 +103,16 @@ func (s *Session) Go(ctx context.Context, f func(ctx context.Context)) {
 	f(ctx)
 }
 
+// Persist stores the state of the root session in its session store right away, instead of when the
+// request that changed it is done. It does nothing if the session is not a stored server session.
+func (s *Session) Persist(ctx context.Context) error {
+	root := s.Root()
+	if root == nil || root.sessionManager == nil || root.serverSession == nil || root.ID() == "" {
+		return nil
+	}
+	return root.sessionManager.Store(ctx, root.ID(), root.serverSession)
+}
+
 func (s *Session) ID() string {
 	if s == nil || s.wire == nil {
 		return ""
@@ -133,11 +146,17 @@ func (s *Session) State() (*SessionState, error) {
 		}
 	}
 
+	var lastEventID string
+	if wire, ok := s.wire.(interface{ LastEventID() string }); ok {
+		lastEventID = wire.LastEventID()
+	}
+
 	return &SessionState{
 		ID:                s.wire.SessionID(),
 		InitializeResult:  s.InitializeResult,
 		InitializeRequest: s.InitializeRequest,
 		Attributes:        attr,
+		LastEventID:       lastEventID,
 	}, nil
 }
 
@@ -396,10 +415,6 @@ func (s *Session) SendPayload(ctx context.Context, method string, payload any) e
 }
 
 func (s *Session) Send(ctx context.Context, req Message) error {
-	if s.wire == nil {
-		return fmt.Errorf("empty session: wire is not initialized")
-	}
-
 	s.lock.Lock()
 	f := slices.Clone(s.filters)
 	s.lock.Unlock()
@@ -412,25 +427,49 @@ func (s *Session) Send(ctx context.Context, req Message) error {
 		req = *newReq
 	}
 
-	newReq, err := s.callAllHooks(ctx, &req, "request")
+	// Filters run first so that an empty session can still have its messages observed.
+	if s.wire == nil {
+		return fmt.Errorf("empty session: wire is not initialized")
+	}
+
+	newReq, result, err := s.callAllHooks(ctx, &req, "request")
 	if err != nil {
 		return fmt.Errorf("failed to call \"request\" hooks: %w", err)
 	}
 
 	req = *newReq
 	req.JSONRPC = "2.0"
+	if result != nil && req.ID != nil {
+		// A hook answered the request, deliver its result as if it came from the server
+		s.pendingRequest.Notify(Message{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  result,
+			Session: s,
+		})
+		return nil
+	}
 	if err := s.wire.Send(ctx, req); err != nil {
 		return err
 	}
 	return nil
 }
 
+// DefaultExchangeTimeout is how long Exchange waits for a response when ExchangeOption.Timeout is not set.
+// Zero waits until the context is done.
+var DefaultExchangeTimeout time.Duration
+
+var ErrExchangeTimeout = errors.New("timed out waiting for response")
+
 type ExchangeOption struct {
 	ProgressToken any
+	// Timeout overrides DefaultExchangeTimeout for this exchange. A negative value disables the timeout.
+	Timeout time.Duration
 }
 
 func (e ExchangeOption) Merge(other ExchangeOption) (result ExchangeOption) {
 	result.ProgressToken = complete.Last(e.ProgressToken, other.ProgressToken)
+	result.Timeout = complete.Last(e.Timeout, other.Timeout)
 	return
 }
 
@@ -459,13 +498,28 @@ func (s *Session) postInit(msg *Message) error {
 	return nil
 }
 
+// serverError is an error response from the other side of the session. It unwraps to the RPCError so
+// that handlers relaying the call, such as an agent calling another agent, pass the code and message on
+// instead of replacing them with an internal error.
+type serverError struct {
+	rpcError *RPCError
+}
+
+func (e *serverError) Error() string {
+	return "error from server: " + e.rpcError.Message
+}
+
+func (e *serverError) Unwrap() error {
+	return e.rpcError
+}
+
 func (s *Session) marshalResponse(m Message, out any) error {
 	if mOut, ok := out.(*Message); ok {
 		*mOut = m
 		return nil
 	}
 	if m.Error != nil {
-		return fmt.Errorf("error from server: %s", m.Error.Message)
+		return &serverError{rpcError: m.Error}
 	}
 	if m.Result == nil {
 		return ErrNoResult
@@ -521,11 +575,14 @@ func getMessageName(req *Message) string {
 	return name
 }
 
-func (s *Session) callAllHooks(ctx context.Context, req *Message, direction string) (*Message, error) {
+// callAllHooks runs the hooks matching req. It returns the message as modified by the hooks and, for the
+// "request" direction, any synthetic result a hook supplied in place of sending the request.
+func (s *Session) callAllHooks(ctx context.Context, req *Message, direction string) (*Message, json.RawMessage, error) {
 	var (
 		hooks    = s.hooks
 		name     = getMessageName(req)
 		auditLog = AuditLogFromContext(ctx)
+		result   json.RawMessage
 		errs     []error
 	)
 	if len(hooks) == 0 {
@@ -547,28 +604,43 @@ func (s *Session) callAllHooks(ctx context.Context, req *Message, direction stri
 	hookResponse, _ := InvokeHooks(ctx, s.HookRunner, hooks, &SessionMessageHook{
 		Accept:  true,
 		Message: req,
-	}, req.Method, params, func(hook HookMapping, hookResponse SessionMessageHook, err error) SessionMessageHook {
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to run hook %s: %w", hook.Name, err))
-			return hookResponse
-		}
-
+	}, req.Method, params, func(hook HookInvocation, hookResponse SessionMessageHook, err error) SessionMessageHook {
 		if auditLog != nil {
 			status := "ok"
-			if !hookResponse.Accept {
+			if err != nil {
+				status = "error"
+			} else if !hookResponse.Accept {
 				status = "rejected"
+			} else if direction == "request" && hookResponse.Result != nil {
+				status = "synthetic"
+			}
+			webhookStatus := auditlogs.MCPWebhookStatus{
+				Type:             direction,
+				Method:           req.Method,
+				Name:             hook.Name,
+				Target:           hook.Target,
+				Status:           status,
+				ProcessingTimeMs: hook.Duration.Milliseconds(),
+			}
+			if err != nil {
+				webhookStatus.Error = err.Error()
+			} else {
+				webhookStatus.Message = hookResponse.Reason
+			}
+			auditLog.WebhookStatuses = append(auditLog.WebhookStatuses, webhookStatus)
+		}
+
+		if err != nil {
+			if !hook.FailOpen {
+				errs = append(errs, fmt.Errorf("failed to run hook %s: %w", hook.Name, err))
 			}
-			auditLog.WebhookStatuses = append(auditLog.WebhookStatuses, auditlogs.MCPWebhookStatus{
-				Type:    direction,
-				Method:  req.Method,
-				Name:    hook.Name,
-				Status:  status,
-				Message: hookResponse.Reason,
-			})
+			return hookResponse
 		}
 
 		if !hookResponse.Accept {
 			errs = append(errs, fmt.Errorf("hook %s rejected message: %s", hook.Name, hookResponse.Reason))
+		} else if direction == "request" && hookResponse.Result != nil {
+			result = hookResponse.Result
 		}
 
 		// Use the hook response message if set, otherwise use the last value we have
@@ -580,7 +652,7 @@ func (s *Session) callAllHooks(ctx context.Context, req *Message, direction stri
 		return hookResponse
 	})
 
-	return hookResponse.Message, errors.Join(errs...)
+	return hookResponse.Message, result, errors.Join(errs...)
 }
 
 func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts ...ExchangeOption) (err error) {
@@ -595,19 +667,32 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 		return err
 	}
 
+	timeout := opt.Timeout
+	if timeout == 0 {
+		timeout = DefaultExchangeTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w to %s after %s", ErrExchangeTimeout, method, timeout))
+		defer cancel()
+	}
+
 	defer func() {
 		tempReq := *req
 		tempReq.Result = respResult
 		tempReq.Error = respError
 		if err != nil && respError == nil {
-			tempReq.Error = ErrRPCUnknown.WithMessage(fmt.Sprintf("failed to call %s [%s]: %s", req.Method, getMessageName(req), err))
+			tempReq.Error = ErrRPCUnknown.WithMessage("failed to call %s [%s]: %s", req.Method, getMessageName(req), err)
 		}
-		if _, hooksErr := s.callAllHooks(ctx, &tempReq, "response"); hooksErr != nil && err == nil {
+		if _, _, hooksErr := s.callAllHooks(ctx, &tempReq, "response"); hooksErr != nil && err == nil {
 			err = fmt.Errorf("failed to call \"response\" hooks: %w", hooksErr)
 		}
 	}()
 
-	ch := s.pendingRequest.WaitFor(req.ID)
+	ch, err := s.pendingRequest.Wait(ctx, req.ID)
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
 	defer s.pendingRequest.Done(req.ID)
 
 	isInit, err := s.preInit(req)
@@ -628,7 +713,7 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return context.Cause(ctx)
 		case err = <-errChan:
 			if err != nil {
 				return err
@@ -654,6 +739,12 @@ func (s *Session) onWire(ctx context.Context, message Message) {
 	if s.pendingRequest.Notify(message) {
 		return
 	}
+	if message.IsResponse() {
+		// Nothing is waiting on this ID, it was already answered or never sent. Handing it to the handler
+		// would treat it as a request.
+		log.Errorf(ctx, "dropping response with unknown or duplicate id %v", message.ID)
+		return
+	}
 	s.handler.OnMessage(WithSession(ctx, s), message)
 }
 
@@ -17,6 +17,7 @@ import (
 type waiter struct {
 	running chan struct{}
 	closed  bool
+	err     error
 	lock    sync.Mutex
 }
 
@@ -31,14 +32,26 @@ func (w *waiter) Wait() {
 }
 
 func (w *waiter) Close() {
+	w.CloseWithError(nil)
+}
+
+// CloseWithError closes the waiter, recording err as the reason if it was not closed yet.
+func (w *waiter) CloseWithError(err error) {
 	w.lock.Lock()
 	if !w.closed {
 		w.closed = true
+		w.err = err
 		close(w.running)
 	}
 	w.lock.Unlock()
 }
 
+func (w *waiter) Err() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
+
 type Stdio struct {
 	stdout         io.Reader
 	stdin          io.Writer
@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+)
+
+// toolHandler handles the calls of a tool of a testServer.
+type toolHandler func(ctx context.Context, req CallToolRequest) (*CallToolResult, error)
+
+// testServer is an MCP server for tests. It lists its tools with listTools and calls them with the handler
+// of the tool name.
+type testServer struct {
+	listTools func(ctx context.Context, req ListToolsRequest) (*ListToolsResult, error)
+	calls     map[string]toolHandler
+}
+
+func (s testServer) OnMessage(ctx context.Context, msg Message) {
+	switch msg.Method {
+	case "initialize":
+		Invoke(ctx, msg, func(_ context.Context, _ Message, params InitializeRequest) (*InitializeResult, error) {
+			return &InitializeResult{
+				ProtocolVersion: params.ProtocolVersion,
+				Capabilities: ServerCapabilities{
+					Tools: &ToolsServerCapability{},
+				},
+			}, nil
+		})
+	case "notifications/initialized":
+	case "tools/list":
+		Invoke(ctx, msg, func(ctx context.Context, _ Message, req ListToolsRequest) (*ListToolsResult, error) {
+			if s.listTools == nil {
+				return &ListToolsResult{}, nil
+			}
+			return s.listTools(ctx, req)
+		})
+	case "tools/call":
+		Invoke(ctx, msg, func(ctx context.Context, _ Message, req CallToolRequest) (*CallToolResult, error) {
+			handler, ok := s.calls[req.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tool %s", req.Name)
+			}
+			return handler(ctx, req)
+		})
+	default:
+		msg.SendError(ctx, ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
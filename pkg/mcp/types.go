@@ -340,10 +340,12 @@ type CallToolRequest struct {
 var EmptyObjectSchema = json.RawMessage(`{"type": "object", "properties": {}, "additionalProperties": false, "required": []}`)
 
 type ListToolsRequest struct {
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type ListToolsResult struct {
-	Tools []Tool `json:"tools"`
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type GetPromptRequest struct {
@@ -487,4 +489,7 @@ type SessionMessageHook struct {
 	Accept  bool
 	Message *Message
 	Reason  string
+	// Result, when set by a "request" hook, is returned as the result of the request (for example a
+	// CallToolResult for tools/call) and the request is not sent to the server.
+	Result json.RawMessage `json:",omitempty"`
 }
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader holds the HMAC-SHA256 of the request body, formatted as "sha256=<hex>".
+	WebhookSignatureHeader = "X-Nanobot-Signature"
+
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// Webhook is a hook target that POSTs the hook input as JSON to URL and reads the hook output from the
+// JSON response. A response with no body leaves the input unchanged.
+type Webhook struct {
+	URL     string
+	Secret  string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// IsWebhookTarget returns true if the hook target is an http or https URL rather than a server/tool.
+func IsWebhookTarget(target string) bool {
+	return strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://")
+}
+
+// SignWebhookPayload returns the signature header value for body.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns true if signature is the signature of body. Webhook receivers can use
+// this to check requests came from nanobot.
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, body)), []byte(signature))
+}
+
+// Run sends in to the webhook and unmarshals the response into out. It has the same contract as
+// HookRunner.RunHook.
+func (w Webhook) Run(ctx context.Context, in, out any) (hasOutput bool, _ error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(w.Secret, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call webhook %s: %w", w.URL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read webhook response from %s: %w", w.URL, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("webhook %s returned %d: %s", w.URL, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("failed to unmarshal webhook response from %s: %w", w.URL, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+const testWebhookSecret = "s3cret"
+
+func newTestWebhook(t *testing.T, respond func(hook SessionMessageHook) (int, string)) Webhook {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature(testWebhookSecret, body, r.Header.Get(WebhookSignatureHeader)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var hook SessionMessageHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		code, resp := respond(hook)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+	return Webhook{
+		URL:    srv.URL,
+		Secret: testWebhookSecret,
+	}
+}
+
+func webhookSession(t *testing.T, webhook Webhook) (*Session, *testWire) {
+	t.Helper()
+	var (
+		wire  = &testWire{sent: make(chan Message, 1)}
+		hooks = Hooks{{
+			Name:    "tools/call",
+			Params:  map[string]string{"direction": "request"},
+			Targets: []string{webhook.URL},
+		}}
+		runner = hookRunnerFunc(func(ctx context.Context, in, out any, _ string) (bool, error) {
+			return webhook.Run(ctx, in, out)
+		})
+	)
+	s, err := newSession(t.Context(), wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, runner, hooks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close(false)
+	})
+	return s, wire
+}
+
+func TestWebhook_Accept(t *testing.T) {
+	s, wire := webhookSession(t, newTestWebhook(t, func(SessionMessageHook) (int, string) {
+		return http.StatusNoContent, ""
+	}))
+
+	go func() {
+		req := <-wire.sent
+		wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[]}`)})
+	}()
+	var result CallToolResult
+	if err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "read"}, &result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhook_Reject(t *testing.T) {
+	s, wire := webhookSession(t, newTestWebhook(t, func(SessionMessageHook) (int, string) {
+		return http.StatusOK, `{"accept":false,"reason":"read is not allowed"}`
+	}))
+
+	var result CallToolResult
+	err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "read"}, &result)
+	autogold.Expect(`failed to send request: failed to call "request" hooks: hook tools/call rejected message: read is not allowed`).Equal(t, err.Error())
+	autogold.Expect(0).Equal(t, len(wire.sent))
+}
+
+func TestWebhook_Modify(t *testing.T) {
+	s, wire := webhookSession(t, newTestWebhook(t, func(hook SessionMessageHook) (int, string) {
+		hook.Message.Params = json.RawMessage(`{"name":"read","arguments":{"path":"/safe"}}`)
+		data, _ := json.Marshal(hook)
+		return http.StatusOK, string(data)
+	}))
+
+	sent := make(chan string, 1)
+	go func() {
+		req := <-wire.sent
+		sent <- string(req.Params)
+		wire.handler(t.Context(), Message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"content":[]}`)})
+	}()
+	var result CallToolResult
+	if err := s.Exchange(t.Context(), "tools/call", CallToolRequest{Name: "read", Arguments: map[string]any{"path": "/etc"}}, &result); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`{"name":"read","arguments":{"path":"/safe"}}`).Equal(t, <-sent)
+}
+
+func TestWebhook_Signature(t *testing.T) {
+	webhook := newTestWebhook(t, func(SessionMessageHook) (int, string) {
+		return http.StatusOK, `{"accept":true}`
+	})
+
+	var out SessionMessageHook
+	hasOutput, err := webhook.Run(t.Context(), SessionMessageHook{Accept: true}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(true).Equal(t, hasOutput)
+
+	webhook.Secret = "wrong"
+	_, err = webhook.Run(t.Context(), SessionMessageHook{Accept: true}, &out)
+	autogold.Expect(true).Equal(t, strings.HasSuffix(err.Error(), " returned 401: bad signature"))
+}
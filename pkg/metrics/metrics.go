@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "nanobot"
+
+var (
+	// Registry holds the nanobot metrics and the Go and process collectors.
+	Registry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Number of tool calls.",
+	}, []string{"server", "tool"})
+	toolCallErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_call_errors_total",
+		Help:      "Number of tool calls that failed or returned an error result.",
+	}, []string{"server", "tool"})
+	toolCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_call_duration_seconds",
+		Help:      "Duration of tool calls.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"server", "tool"})
+	activeSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Number of MCP sessions loaded in this process and not yet closed.",
+	})
+	mcpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mcp_request_duration_seconds",
+		Help:      "Duration of MCP requests to the HTTP server.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls,
+		toolCallErrors,
+		toolCallDuration,
+		activeSessions,
+		mcpRequestDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveToolCall records a tool call of the server that took duration.
+func ObserveToolCall(server, tool string, duration time.Duration, failed bool) {
+	toolCalls.WithLabelValues(server, tool).Inc()
+	toolCallDuration.WithLabelValues(server, tool).Observe(duration.Seconds())
+	if failed {
+		toolCallErrors.WithLabelValues(server, tool).Inc()
+	}
+}
+
+// SessionStarted records an MCP session that is created or loaded from the session store.
+func SessionStarted() {
+	activeSessions.Inc()
+}
+
+// SessionEnded records an MCP session that is closed, because it was deleted or unloaded.
+func SessionEnded() {
+	activeSessions.Dec()
+}
+
+// mcpMethods are the methods of MCP and of the HTTP server that are recorded as is. Any other method, which
+// is up to the client, is recorded as "other" so clients can't create an unbounded number of series.
+var mcpMethods = map[string]bool{
+	"initialize":                           true,
+	"ping":                                 true,
+	"tools/list":                           true,
+	"tools/call":                           true,
+	"resources/list":                       true,
+	"resources/read":                       true,
+	"resources/templates/list":             true,
+	"resources/subscribe":                  true,
+	"resources/unsubscribe":                true,
+	"prompts/list":                         true,
+	"prompts/get":                          true,
+	"completion/complete":                  true,
+	"logging/setLevel":                     true,
+	"sampling/createMessage":               true,
+	"elicitation/create":                   true,
+	"roots/list":                           true,
+	"notifications/initialized":            true,
+	"notifications/cancelled":              true,
+	"notifications/progress":               true,
+	"notifications/message":                true,
+	"notifications/roots/list_changed":     true,
+	"notifications/resources/updated":      true,
+	"notifications/resources/list_changed": true,
+	"notifications/tools/list_changed":     true,
+	"notifications/prompts/list_changed":   true,
+	"session/delete":                       true,
+}
+
+// ObserveMCPRequest records an MCP request of the method that took duration.
+func ObserveMCPRequest(method string, duration time.Duration) {
+	if !mcpMethods[method] {
+		method = "other"
+	}
+	mcpRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveToolCall(t *testing.T) {
+	ObserveToolCall("server", "ok", time.Second, false)
+	ObserveToolCall("server", "failing", time.Second, true)
+	ObserveToolCall("server", "failing", time.Second, true)
+
+	autogold.Expect(1.0).Equal(t, testutil.ToFloat64(toolCalls.WithLabelValues("server", "ok")))
+	autogold.Expect(0.0).Equal(t, testutil.ToFloat64(toolCallErrors.WithLabelValues("server", "ok")))
+	autogold.Expect(2.0).Equal(t, testutil.ToFloat64(toolCalls.WithLabelValues("server", "failing")))
+	autogold.Expect(2.0).Equal(t, testutil.ToFloat64(toolCallErrors.WithLabelValues("server", "failing")))
+	autogold.Expect(2).Equal(t, testutil.CollectAndCount(toolCallDuration))
+}
+
+func TestHandler(t *testing.T) {
+	SessionStarted()
+	SessionStarted()
+	SessionEnded()
+	ObserveMCPRequest("tools/call", time.Millisecond)
+	ObserveMCPRequest("made/up", time.Millisecond)
+	ObserveMCPRequest("also/made/up", time.Millisecond)
+
+	rw := httptest.NewRecorder()
+	Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+
+	var lines []string
+	for _, line := range strings.Split(rw.Body.String(), "\n") {
+		if strings.HasPrefix(line, "nanobot_active_sessions ") ||
+			strings.HasPrefix(line, `nanobot_mcp_request_duration_seconds_count{method="tools/call"}`) ||
+			strings.HasPrefix(line, `nanobot_mcp_request_duration_seconds_count{method="other"}`) {
+			lines = append(lines, line)
+		}
+	}
+	autogold.Expect([]string{
+		"nanobot_active_sessions 1",
+		`nanobot_mcp_request_duration_seconds_count{method="other"} 2`,
+		`nanobot_mcp_request_duration_seconds_count{method="tools/call"} 1`,
+	}).Equal(t, lines)
+}
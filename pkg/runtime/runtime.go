@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/agents"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
@@ -16,13 +17,16 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/sampling"
 	"github.com/nanobot-ai/nanobot/pkg/servers/agent"
 	"github.com/nanobot-ai/nanobot/pkg/servers/capabilities"
+	"github.com/nanobot-ai/nanobot/pkg/servers/debug"
 	"github.com/nanobot-ai/nanobot/pkg/servers/meta"
 	"github.com/nanobot-ai/nanobot/pkg/servers/resources"
+	"github.com/nanobot-ai/nanobot/pkg/servers/vectorstore"
 	"github.com/nanobot-ai/nanobot/pkg/servers/workspace"
 	"github.com/nanobot-ai/nanobot/pkg/session"
 	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
 	"github.com/nanobot-ai/nanobot/pkg/types"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Runtime struct {
@@ -43,6 +47,32 @@ type Options struct {
 	TokenExchangeClientID     string
 	TokenExchangeClientSecret string
 	AuditLogCollector         *auditlogs.Collector
+	// DebugServer registers the nanobot.debug server, which echoes input and simulates delays, errors
+	// and progress for testing clients, and diffs the execution of chat turns.
+	DebugServer bool
+	// MaxAgentDepth limits how deep agents can call other agents.
+	MaxAgentDepth int
+	// MaxImageDimension downscales image attachments so neither side is larger than this many pixels.
+	MaxImageDimension int
+	// ImageQuality is the JPEG quality of downscaled images.
+	ImageQuality int
+	// MaxAttachmentSize is the largest http(s) attachment in bytes that is fetched.
+	MaxAttachmentSize int64
+	// AttachmentFetchTimeout bounds fetching an http(s) attachment.
+	AttachmentFetchTimeout time.Duration
+	// FetchAttachments enables http(s) attachment URLs, which are fetched from public addresses only.
+	FetchAttachments bool
+	// ResultCache stores the results of cached read-only tool calls, defaults to an in-memory cache.
+	ResultCache tools.ResultCache
+	// TracerProvider records spans of tool calls and samples. Nothing is recorded if it is nil.
+	TracerProvider trace.TracerProvider
+	// SensitiveArguments are glob patterns of tool argument names whose values are masked in progress
+	// notifications.
+	SensitiveArguments []string
+	// WebhookSecret signs the requests of webhook hooks.
+	WebhookSecret string
+	// WebhookTimeout bounds a webhook hook.
+	WebhookTimeout time.Duration
 }
 
 func (o Options) Merge(other Options) (result Options) {
@@ -57,6 +87,18 @@ func (o Options) Merge(other Options) (result Options) {
 	result.TokenExchangeClientID = complete.Last(o.TokenExchangeClientID, other.TokenExchangeClientID)
 	result.TokenExchangeClientSecret = complete.Last(o.TokenExchangeClientSecret, other.TokenExchangeClientSecret)
 	result.AuditLogCollector = complete.Last(o.AuditLogCollector, other.AuditLogCollector)
+	result.DebugServer = complete.Last(o.DebugServer, other.DebugServer)
+	result.MaxAgentDepth = complete.Last(o.MaxAgentDepth, other.MaxAgentDepth)
+	result.MaxImageDimension = complete.Last(o.MaxImageDimension, other.MaxImageDimension)
+	result.ImageQuality = complete.Last(o.ImageQuality, other.ImageQuality)
+	result.MaxAttachmentSize = complete.Last(o.MaxAttachmentSize, other.MaxAttachmentSize)
+	result.AttachmentFetchTimeout = complete.Last(o.AttachmentFetchTimeout, other.AttachmentFetchTimeout)
+	result.FetchAttachments = complete.Last(o.FetchAttachments, other.FetchAttachments)
+	result.ResultCache = complete.Last(o.ResultCache, other.ResultCache)
+	result.TracerProvider = complete.Last(o.TracerProvider, other.TracerProvider)
+	result.SensitiveArguments = append(o.SensitiveArguments, other.SensitiveArguments...)
+	result.WebhookSecret = complete.Last(o.WebhookSecret, other.WebhookSecret)
+	result.WebhookTimeout = complete.Last(o.WebhookTimeout, other.WebhookTimeout)
 	return
 }
 
@@ -82,9 +124,23 @@ func NewRuntime(cfg llm.Config, opts ...Options) (*Runtime, error) {
 		TokenExchangeClientID:     opt.TokenExchangeClientID,
 		TokenExchangeClientSecret: opt.TokenExchangeClientSecret,
 		AuditLogCollector:         opt.AuditLogCollector,
+		MaxAgentDepth:             opt.MaxAgentDepth,
+		MaxImageDimension:         opt.MaxImageDimension,
+		ImageQuality:              opt.ImageQuality,
+		MaxAttachmentSize:         opt.MaxAttachmentSize,
+		AttachmentFetchTimeout:    opt.AttachmentFetchTimeout,
+		FetchAttachments:          opt.FetchAttachments,
+		ResultCache:               opt.ResultCache,
+		TracerProvider:            opt.TracerProvider,
+		SensitiveArguments:        opt.SensitiveArguments,
+		MaxProgressSize:           cfg.MaxProgressSize,
+		WebhookSecret:             opt.WebhookSecret,
+		WebhookTimeout:            opt.WebhookTimeout,
 	})
 	agentsService := agents.New(completer, registry)
-	sampler := sampling.NewSampler(agentsService)
+	sampler := sampling.NewSampler(agentsService, sampling.Options{
+		TracerProvider: opt.TracerProvider,
+	})
 
 	// This is a circular dependency. Oh well, so much for good design.
 	registry.SetSampler(sampler)
@@ -95,29 +151,46 @@ func NewRuntime(cfg llm.Config, opts ...Options) (*Runtime, error) {
 		opt:       opt,
 	}
 
+	dataOptions := sessiondata.Options{
+		Concurrency: opt.MaxConcurrency,
+	}
+
 	registry.AddServer("nanobot.meta", func(string) mcp.MessageHandler {
-		return meta.NewServer(sessiondata.NewData(r))
+		return meta.NewServer(sessiondata.NewData(r, dataOptions), completer)
 	})
 
 	registry.AddServer("nanobot.agent", func(name string) mcp.MessageHandler {
-		return agent.NewServer(sessiondata.NewData(r), r, agentsService, name)
+		return agent.NewServer(sessiondata.NewData(r, dataOptions), r, agentsService, name)
 	})
 
+	if opt.DebugServer {
+		agentsService.SetRecordTurns(true)
+		registry.AddServer("nanobot.debug", func(string) mcp.MessageHandler {
+			return debug.NewServer()
+		})
+	}
+
 	if opt.DSN != "" {
 		var (
-			once  = &sync.Once{}
-			store *resources.Store
+			once     = &sync.Once{}
+			store    *resources.Store
+			getStore = func() *resources.Store {
+				once.Do(func() {
+					var err error
+					store, err = resources.NewStoreFromDSN(opt.DSN)
+					if err != nil {
+						panic(fmt.Errorf("failed to create resources store: %w", err))
+					}
+				})
+				return store
+			}
 		)
 		registry.AddServer("nanobot.resources", func(string) mcp.MessageHandler {
-			once.Do(func() {
-				var err error
-				store, err = resources.NewStoreFromDSN(opt.DSN)
-				if err != nil {
-					panic(fmt.Errorf("failed to create resources store: %w", err))
-				}
-			})
-			return resources.NewServer(store)
+			return resources.NewServer(getStore())
 		})
+		agentsService.SetResourceCreator(lazyResourceCreator(func() *resources.Server {
+			return resources.NewServer(getStore())
+		}))
 	}
 
 	if opt.DSN != "" {
@@ -129,19 +202,41 @@ func NewRuntime(cfg llm.Config, opts ...Options) (*Runtime, error) {
 			return workspace.NewServer(store)
 		})
 		registry.AddServer("nanobot.capabilities", func(string) mcp.MessageHandler {
-			return capabilities.NewServer(store)
+			return capabilities.NewServer(store, sessiondata.NewData(r, dataOptions))
+		})
+	}
+
+	if opt.DSN != "" {
+		store, err := vectorstore.NewStoreFromDSN(opt.DSN)
+		if err != nil {
+			panic(fmt.Errorf("failed to create vector store: %w", err))
+		}
+		registry.AddServer("nanobot.vectorstore", func(string) mcp.MessageHandler {
+			return vectorstore.NewServer(store, completer)
 		})
 	}
 
 	return r, nil
 }
 
+// lazyResourceCreator creates the resources server, and with it the store, when a resource is first created.
+type lazyResourceCreator func() *resources.Server
+
+func (l lazyResourceCreator) CreateResource(ctx context.Context, params resources.CreateArtifactParams) (*mcp.Resource, error) {
+	return l().CreateResource(ctx, params)
+}
+
 func (r *Runtime) WithTempSession(ctx context.Context, config *types.Config) context.Context {
 	session := mcp.NewEmptySession(ctx)
 	session.Set(types.ConfigSessionKey, config)
 	return mcp.WithSession(types.WithConfig(ctx, *config), session)
 }
 
+// GetTarget resolves a "server/tool" or agent reference to the single tool it refers to.
+func (r *Runtime) GetTarget(ctx context.Context, serverRef string) (*tools.ListToolsResult, error) {
+	return r.getToolFromRef(ctx, types.ConfigFromContext(ctx), serverRef)
+}
+
 func (r *Runtime) getToolFromRef(ctx context.Context, config types.Config, serverRef string) (*tools.ListToolsResult, error) {
 	var (
 		server, tool string
@@ -179,7 +274,7 @@ func (r *Runtime) getToolFromRef(ctx context.Context, config types.Config, serve
 	}, nil
 }
 
-func (r *Runtime) CallFromCLI(ctx context.Context, serverRef string, args ...string) (*mcp.CallToolResult, error) {
+func (r *Runtime) CallFromCLI(ctx context.Context, serverRef string, args []string, opts ...tools.CallOptions) (*mcp.CallToolResult, error) {
 	var (
 		argValue any
 		argMap   = map[string]string{}
@@ -226,7 +321,7 @@ func (r *Runtime) CallFromCLI(ctx context.Context, serverRef string, args ...str
 		argValue = map[string]any{}
 	}
 
-	callResult, err := r.Call(ctx, tools.Server, tools.Tools[0].Name, argValue)
+	callResult, err := r.Call(ctx, tools.Server, tools.Tools[0].Name, argValue, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -13,19 +13,35 @@ import (
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrNoMatchingModel = fmt.Errorf("no matching model found")
 
 type Sampler struct {
 	completer types.Completer
+	tracer    trace.Tracer
 }
 
-func NewSampler(completer types.Completer) *Sampler {
+type Options struct {
+	// TracerProvider records a span of each sample. Nothing is recorded if it is nil.
+	TracerProvider trace.TracerProvider
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.TracerProvider = complete.Last(o.TracerProvider, other.TracerProvider)
+	return
+}
+
+func NewSampler(completer types.Completer, opts ...Options) *Sampler {
+	opt := complete.Complete(opts...)
 	return &Sampler{
 		completer: completer,
+		tracer:    tracing.Tracer(opt.TracerProvider, "github.com/nanobot-ai/nanobot/pkg/sampling"),
 	}
 }
 
@@ -94,10 +110,12 @@ func (s *Sampler) getMatchingModel(config types.Config, req *mcp.CreateMessageRe
 }
 
 type SamplerOptions struct {
-	ProgressToken      any
-	Continue           bool
-	Chat               *bool
-	NewThread          *bool
+	ProgressToken any
+	Continue      bool
+	Chat          *bool
+	NewThread     *bool
+	// Resume continues the interrupted turn of the chat instead of starting a new one.
+	Resume             bool
 	ToolChoice         *mcp.ToolChoice
 	Tools              []mcp.Tool
 	ToolIncludeContext string
@@ -108,6 +126,7 @@ func (s SamplerOptions) Merge(other SamplerOptions) (result SamplerOptions) {
 	result.ProgressToken = complete.Last(s.ProgressToken, other.ProgressToken)
 	result.Continue = complete.Last(s.Continue, other.Continue)
 	result.Chat = complete.Last(s.Chat, other.Chat)
+	result.Resume = s.Resume || other.Resume
 	result.ToolChoice = complete.Last(s.ToolChoice, other.ToolChoice)
 	result.Tools = append(s.Tools, other.Tools...)
 	result.ToolIncludeContext = complete.Last(s.ToolIncludeContext, other.ToolIncludeContext)
@@ -115,17 +134,24 @@ func (s SamplerOptions) Merge(other SamplerOptions) (result SamplerOptions) {
 	return
 }
 
-func (s *Sampler) Sample(ctx context.Context, req mcp.CreateMessageRequest, opts ...SamplerOptions) (result *types.CallResult, _ error) {
+func (s *Sampler) Sample(ctx context.Context, req mcp.CreateMessageRequest, opts ...SamplerOptions) (result *types.CallResult, err error) {
 	opt := complete.Complete(opts...)
 	config := types.ConfigFromContext(ctx)
 
+	ctx, span := s.tracer.Start(ctx, "sample")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	model, ok := s.getMatchingModel(config, &req)
 	if !ok {
 		return nil, ErrNoMatchingModel
 	}
+	span.SetAttributes(attribute.String("nanobot.model", model))
 
 	request := types.CompletionRequest{
-		Model: model,
+		Model:  model,
+		Resume: opt.Resume,
 	}
 
 	if req.MaxTokens != 0 {
@@ -243,6 +269,7 @@ func CompletionResponseToCallResult(resp *types.CompletionResponse, includeMessa
 		Model:        resp.Model,
 		ChatResponse: resp.ChatResponse,
 		IsError:      resp.Error != "",
+		StopReason:   resp.StopReason,
 	}
 
 	for _, output := range resp.Output.Items {
@@ -291,6 +318,18 @@ func CompletionResponseToCallResult(resp *types.CompletionResponse, includeMessa
 		})
 	}
 
+	if len(resp.Citations) > 0 {
+		// Pass the sources on with the same convention the tools use, so they reach the caller's UI. The
+		// content may be shared with a tool result, so it is copied.
+		result.Content = slices.Clone(result.Content)
+		last := &result.Content[len(result.Content)-1]
+		last.Meta = maps.Clone(last.Meta)
+		if last.Meta == nil {
+			last.Meta = map[string]any{}
+		}
+		last.Meta[types.CitationsMetaKey] = resp.Citations
+	}
+
 	if includeMessages {
 		outputMessages := append(resp.InternalMessages, resp.Output)
 		if resp.Error != "" {
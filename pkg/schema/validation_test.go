@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestValidateAndFixToolSchema_OneOf(t *testing.T) {
+	fields := map[string]types.Field{
+		"id": {
+			Description: "A name or a number",
+			OneOf: []types.Field{
+				{Description: "A name"},
+				{Fields: map[string]types.Field{"number(int)": {Description: "The number"}}},
+			},
+		},
+	}
+	data, err := json.Marshal(types.BuildSimpleSchema("", "", fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixed := ValidateAndFixToolSchema(data)
+	var schema struct {
+		Properties map[string]struct {
+			AnyOf []map[string]any `json:"anyOf"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(fixed, &schema); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(2).Equal(t, len(schema.Properties["id"].AnyOf))
+
+	// The alternatives of an output schema are kept
+	output := types.OutputSchema{
+		OneOf: []types.Field{{Description: "yes"}, {Description: "no"}},
+	}.ToSchema()
+	if fixed := ValidateAndFixToolSchema(output); string(fixed) != string(output) {
+		t.Fatalf("expected the schema to be kept, got %s", fixed)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"slices"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
 	"github.com/nanobot-ai/nanobot/pkg/expr"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
@@ -253,37 +254,52 @@ func (s *Server) handlePing(ctx context.Context, msg mcp.Message, _ struct{}) er
 	return msg.Reply(ctx, mcp.PingResult{})
 }
 
-func getEnvVal(envMap map[string]string, envKey string, envDef types.EnvDef) string {
+// getEnvVal returns the value of the env var and whether it is the default of the config. The other values are
+// from the client.
+func getEnvVal(envMap map[string]string, envKey string, envDef types.EnvDef) (string, bool) {
 	val, ok := expr.Lookup(envMap, envKey)
 	if ok {
-		return val
+		return val, false
 	}
 
 	if envDef.UseBearerToken {
 		bearer, ok := envMap["http:bearer-token"]
 		if ok && bearer != "" {
-			return bearer
+			return bearer, false
 		}
 	}
 
 	if !envDef.Optional {
-		return ""
+		return "", false
 	}
 
-	return envDef.Default
+	return envDef.Default, true
 }
 
-func reconcileEnv(session *mcp.Session, c types.Config) error {
-	envMap := session.GetEnvMap()
-	var missing []string
+func reconcileEnv(ctx context.Context, session *mcp.Session, c types.Config) error {
+	var (
+		envMap     = session.GetEnvMap()
+		reconciled = map[string]string{}
+		missing    []string
+	)
 	for envKey, envDef := range c.Env {
-		envVal := getEnvVal(envMap, envKey, envDef)
+		envVal, isDefault := getEnvVal(envMap, envKey, envDef)
+		// Only the config of the operator can reference secrets, the values of the client are used as is so
+		// that a client can not read files or env vars of the host.
+		if isDefault && envvar.IsSecretRef(envVal) {
+			secret, err := envvar.ResolveSecret(ctx, envVal)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secret for %s: %w", envKey, err)
+			}
+			envVal = secret
+		}
 		if envVal == "" && !envDef.Optional {
 			missing = append(missing, envKey)
 			continue
 		}
-		envMap[envKey] = envVal
+		reconciled[envKey] = envVal
 	}
+	session.AddEnv(reconciled)
 
 	if len(missing) == 0 {
 		return nil
@@ -366,7 +382,7 @@ func (s *Server) handleInitialize(ctx context.Context, msg mcp.Message, payload
 	session := mcp.SessionFromContext(ctx)
 	c := types.ConfigFromContext(ctx)
 
-	if err := reconcileEnv(session, c); err != nil {
+	if err := reconcileEnv(ctx, session, c); err != nil {
 		return err
 	}
 
@@ -423,13 +439,8 @@ func (s *Server) handleSetLogLevel(ctx context.Context, msg mcp.Message, payload
 
 	// Iterate through all MCP servers and set their log level
 	for serverName := range config.MCPServers {
-		c, err := s.runtime.GetClient(ctx, serverName)
-		if err != nil {
-			return fmt.Errorf("failed to get client for %s: %w", serverName, err)
-		}
-
-		if err := c.SetLogLevel(ctx, payload.Level); err != nil {
-			return fmt.Errorf("failed to set log level for %s: %w", serverName, err)
+		if err := s.runtime.SetLogLevel(ctx, serverName, payload.Level); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestReconcileEnv_SecretRefs(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("from config"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(mcp.SessionEnvMapKey, map[string]string{
+		"HEADER":            "file:///etc/passwd",
+		"http:bearer-token": "env://HOME",
+	})
+
+	err := reconcileEnv(t.Context(), session, types.Config{
+		Env: map[string]types.EnvDef{
+			"HEADER":  {},
+			"BEARER":  {UseBearerToken: true},
+			"DEFAULT": {Optional: true, Default: "file://" + secret},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := session.GetEnvMap()
+	// The values of the client are literals, only the config can reference secrets
+	autogold.Expect("file:///etc/passwd").Equal(t, env["HEADER"])
+	autogold.Expect("env://HOME").Equal(t, env["BEARER"])
+	autogold.Expect("from config").Equal(t, env["DEFAULT"])
+}
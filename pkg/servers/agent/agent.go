@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"slices"
 
 	"github.com/nanobot-ai/nanobot/pkg/agents"
@@ -104,6 +105,26 @@ func (s *Server) readHistory(ctx context.Context) (ret []mcp.ResourceContent, _
 	return messagesToResourceContents(messages)
 }
 
+func (s *Server) readLogs(ctx context.Context) ([]mcp.ResourceContent, error) {
+	logs := tools.SessionLogs(ctx)
+	if logs == nil {
+		logs = []mcp.LoggingMessage{}
+	}
+
+	data, err := json.Marshal(logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal logs: %w", err)
+	}
+
+	return []mcp.ResourceContent{
+		{
+			URI:      types.LogsURI,
+			MIMEType: types.LogsMimeType,
+			Text:     string(data),
+		},
+	}, nil
+}
+
 func (s *Server) readProgress(ctx context.Context) (ret []mcp.ResourceContent, _ error) {
 	var (
 		progress types.CompletionResponse
@@ -208,6 +229,14 @@ func (s *Server) resourcesRead(ctx context.Context, _ mcp.Message, request mcp.R
 		return &mcp.ReadResourceResult{
 			Contents: contents,
 		}, nil
+	case types.LogsURI:
+		contents, err = s.readLogs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: contents,
+		}, nil
 	}
 
 	c := types.ConfigFromContext(ctx)
@@ -236,8 +265,8 @@ func (s *Server) resourcesTemplatesList(ctx context.Context, _ mcp.Message, _ mc
 		return nil, err
 	}
 
-	for _, resource := range resources {
-		result.ResourceTemplates = append(result.ResourceTemplates, resource.Target.ResourceTemplate)
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		result.ResourceTemplates = append(result.ResourceTemplates, resources[name].Target.ResourceTemplate)
 	}
 
 	return result, nil
@@ -253,8 +282,8 @@ func (s *Server) resourcesList(ctx context.Context, _ mcp.Message, _ mcp.ListRes
 		return nil, err
 	}
 
-	for _, resource := range resources {
-		result.Resources = append(result.Resources, resource.Target)
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		result.Resources = append(result.Resources, resources[name].Target)
 	}
 
 	result.Resources = append(result.Resources, mcp.Resource{
@@ -269,6 +298,12 @@ func (s *Server) resourcesList(ctx context.Context, _ mcp.Message, _ mcp.ListRes
 		Title:       "Chat Streaming Progress",
 		Description: "The streaming content of the current or last chat exchange.",
 		MimeType:    types.ToolResultMimeType,
+	}, mcp.Resource{
+		URI:         types.LogsURI,
+		Name:        "logs",
+		Title:       "Logs",
+		Description: "The log messages sent by the MCP servers of the current session.",
+		MimeType:    types.LogsMimeType,
 	})
 	return result, nil
 }
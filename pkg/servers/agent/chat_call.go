@@ -4,15 +4,75 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/sampling"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
 const progressSessionKey = "progress"
 
+// progressRuns tracks the runs of progress tokens that are running in this process. A stored progress that
+// has more but is not running here was interrupted, by a restart for example.
+var progressRuns = runRegistry{sessions: map[string]*sessionRuns{}}
+
+type runRegistry struct {
+	lock     sync.Mutex
+	sessions map[string]*sessionRuns
+}
+
+// sessionRuns is the run of the progress token of a session.
+type sessionRuns struct {
+	// lock guards checking for and starting a run of a progress token, so two calls with the same token
+	// can't both start one.
+	lock    sync.Mutex
+	users   int
+	token   any
+	running bool
+}
+
+// acquire returns the runs of the session, which must be released when done.
+func (r *runRegistry) acquire(session *mcp.Session) (*sessionRuns, func()) {
+	key := session.ID()
+	if key == "" {
+		key = fmt.Sprintf("%p", session)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	runs, ok := r.sessions[key]
+	if !ok {
+		runs = &sessionRuns{}
+		r.sessions[key] = runs
+	}
+	runs.users++
+
+	return runs, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		runs.users--
+		if runs.users == 0 && !runs.running {
+			delete(r.sessions, key)
+		}
+	}
+}
+
+// finishRun records that the run of the progress token in the session is no longer running.
+func finishRun(session *mcp.Session, progressToken any) {
+	runs, release := progressRuns.acquire(session)
+	runs.lock.Lock()
+	if runs.running && reflect.DeepEqual(runs.token, progressToken) {
+		runs.running = false
+		runs.token = nil
+	}
+	runs.lock.Unlock()
+	release()
+}
+
 type chatCall struct {
 	s *Server
 }
@@ -28,6 +88,7 @@ func (c chatCall) Definition() mcp.Tool {
 func closeProgress(ctx context.Context, session *mcp.Session, err error) {
 	var response types.CompletionResponse
 	session.Get(progressSessionKey, &response)
+	defer finishRun(session, response.ProgressToken)
 	response.HasMore = false
 	if err != nil {
 		response.Error = err.Error()
@@ -38,7 +99,7 @@ func closeProgress(ctx context.Context, session *mcp.Session, err error) {
 		// Sort items to ensure consistent display order: reasoning, content, tools
 		sortCompletionItems(&response.Output)
 	}
-	response.ProgressToken = nil
+	// The progress token is kept, so a retried call with the same token returns this run, see startProgress
 	session.Set(progressSessionKey, &response)
 
 	_ = session.SendPayload(ctx, "notifications/resources/updated", map[string]any{
@@ -174,6 +235,13 @@ func appendProgress(ctx context.Context, session *mcp.Session, progressMessage *
 	} else if progressItem.ToolCall != nil && currentItem.ToolCall == nil {
 		currentItem.ToolCall = progressItem.ToolCall
 	} else if progressItem.ToolCall != nil {
+		// The first partial of a call can have only its name, or only its ID, as streamed by the LLM
+		if currentItem.ToolCall.Name == "" {
+			currentItem.ToolCall.Name = progressItem.ToolCall.Name
+		}
+		if currentItem.ToolCall.CallID == "" {
+			currentItem.ToolCall.CallID = progressItem.ToolCall.CallID
+		}
 		currentItem.ToolCall.Arguments += progressItem.ToolCall.Arguments
 	} else if progressItem.Reasoning != nil && len(progressItem.Reasoning.Summary) > 0 {
 		if len(currentItem.Reasoning.Summary) == 0 {
@@ -186,7 +254,76 @@ func appendProgress(ctx context.Context, session *mcp.Session, progressMessage *
 	return nil, nil
 }
 
+// startProgress resets the progress of the session for a new run of the progress token. If the last run
+// of the session was started with the same token, which happens if a client retries a call, it is left as
+// is and returned with true instead. A run that has more but is not running in this process was interrupted
+// and is started again.
+func startProgress(session *mcp.Session, progressToken any) (types.CompletionResponse, bool) {
+	runs, release := progressRuns.acquire(session)
+	defer release()
+	runs.lock.Lock()
+	defer runs.lock.Unlock()
+
+	var previous types.CompletionResponse
+	if progressToken != nil && session.Get(progressSessionKey, &previous) &&
+		reflect.DeepEqual(previous.ProgressToken, progressToken) &&
+		(!previous.HasMore || runs.running && reflect.DeepEqual(runs.token, progressToken)) {
+		return previous, true
+	}
+
+	session.Set(progressSessionKey, &types.CompletionResponse{
+		ProgressToken: progressToken,
+		HasMore:       true,
+	})
+	runs.token = progressToken
+	runs.running = true
+	return types.CompletionResponse{}, false
+}
+
+// asyncResult is the result of an async chat call, pointing the client to the progress of the run.
+func asyncResult() *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				Text: fmt.Sprintf("Chat request has been sent to the agent. You can track the progress of the response in the resource %s",
+					types.ProgressURI),
+			},
+			{
+				Type:     "resource_link",
+				URI:      types.ProgressURI,
+				MIMEType: types.ToolResultMimeType,
+			},
+		},
+	}
+}
+
+// replayResult is the result of a retried chat call for the run it already started. The progress is
+// returned while the run is in progress or if the call is async, and the response of the run otherwise.
+func replayResult(previous *types.CompletionResponse, async bool) (*mcp.CallToolResult, error) {
+	if async || previous.HasMore {
+		return asyncResult(), nil
+	}
+
+	result, err := sampling.CompletionResponseToCallResult(previous, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.CallToolResult{
+		StructuredContent: result.StructuredContent,
+		IsError:           result.IsError,
+		Content:           result.Content,
+	}, nil
+}
+
 func (c chatCall) Invoke(ctx context.Context, msg mcp.Message, payload mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	asyncMeta := msg.Meta()[types.AsyncMetaKey]
+	async := (asyncMeta == "true" || asyncMeta == true) && msg.ProgressToken() != nil
+
+	session := mcp.SessionFromContext(ctx)
+	if previous, ok := startProgress(session.Parent, msg.ProgressToken()); ok {
+		return replayResult(&previous, async)
+	}
+
 	description := c.s.describeSession(ctx, payload.Arguments)
 	if description != nil {
 		defer func() {
@@ -198,30 +335,21 @@ func (c chatCall) Invoke(ctx context.Context, msg mcp.Message, payload mcp.CallT
 		var err error
 		payload.Arguments["attachments"], err = c.inlineAttachments(ctx, attachments)
 		if err != nil {
+			closeProgress(ctx, session.Parent, err)
 			return nil, err
 		}
 	}
 
-	async := msg.Meta()[types.AsyncMetaKey]
-	if (async == "true" || async == true) && msg.ProgressToken() != nil {
+	depth := types.AgentDepthFromMeta(ctx, msg.Meta())
+	ctx = types.WithAgentDepth(ctx, depth)
+
+	if async {
 		nctx := types.NanobotContext(ctx)
-		session := mcp.SessionFromContext(ctx)
-		mcp.SessionFromContext(ctx).Go(types.WithNanobotContext(session.Context(), nctx), func(ctx context.Context) {
+		asyncCtx := types.WithAgentDepth(types.WithNanobotContext(session.Context(), nctx), depth)
+		session.Go(asyncCtx, func(ctx context.Context) {
 			_, _ = c.chatInvoke(ctx, msg, payload)
 		})
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				{
-					Text: fmt.Sprintf("Chat request has been sent to the agent. You can track the progress of the response in the resource %s",
-						types.ProgressURI),
-				},
-				{
-					Type:     "resource_link",
-					URI:      types.ProgressURI,
-					MIMEType: types.ToolResultMimeType,
-				},
-			},
-		}, nil
+		return asyncResult(), nil
 	}
 
 	return c.chatInvoke(ctx, msg, payload)
@@ -237,10 +365,6 @@ func (c chatCall) chatInvoke(ctx context.Context, msg mcp.Message, payload mcp.C
 		return appendProgress(ctx, session, msg)
 	})()
 
-	session.Set(progressSessionKey, &types.CompletionResponse{
-		ProgressToken: msg.ProgressToken(),
-	})
-
 	result, err := c.s.runtime.Call(ctx, c.s.agentName, c.s.agentName, payload.Arguments, tools.CallOptions{
 		ProgressToken: msg.ProgressToken(),
 		LogData: map[string]any{
@@ -0,0 +1,265 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// countingCaller counts the runs of the agent, which respond once they are released.
+type countingCaller struct {
+	lock        sync.Mutex
+	runs        int
+	started     chan struct{}
+	release     chan struct{}
+	releaseOnce sync.Once
+}
+
+// Release lets the runs of the agent respond.
+func (c *countingCaller) Release() {
+	c.releaseOnce.Do(func() {
+		close(c.release)
+	})
+}
+
+func (c *countingCaller) Call(_ context.Context, server, _ string, _ any, _ ...tools.CallOptions) (*types.CallResult, error) {
+	if server != "agent" {
+		// The session description is generated with nanobot.summary
+		return &types.CallResult{}, nil
+	}
+
+	c.lock.Lock()
+	c.runs++
+	c.lock.Unlock()
+	c.started <- struct{}{}
+	<-c.release
+
+	return &types.CallResult{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: "hello",
+			},
+		},
+	}, nil
+}
+
+func (c *countingCaller) Runs() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.runs
+}
+
+func (c *countingCaller) GetClient(context.Context, string) (*mcp.Client, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *countingCaller) GetPrompt(context.Context, string, string, map[string]string) (*mcp.GetPromptResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newChatSession(t *testing.T) (*tools.Service, *countingCaller, context.Context) {
+	t.Helper()
+
+	caller := &countingCaller{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	s := &Server{
+		agentName: "agent",
+		runtime:   caller,
+	}
+	s.tools = mcp.NewServerTools(chatCall{s: s})
+
+	// The test waits for the messages being handled when it finishes, so the server is not replying to a
+	// session that is gone.
+	var (
+		handlingLock sync.Mutex
+		handling     sync.WaitGroup
+		finished     bool
+	)
+	t.Cleanup(func() {
+		caller.Release()
+		handlingLock.Lock()
+		finished = true
+		handlingLock.Unlock()
+		handling.Wait()
+	})
+
+	svc := tools.NewToolsService()
+	svc.AddServer("agent", func(string) mcp.MessageHandler {
+		return mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+			handlingLock.Lock()
+			if finished {
+				handlingLock.Unlock()
+				return
+			}
+			handling.Add(1)
+			handlingLock.Unlock()
+			defer handling.Done()
+
+			switch msg.Method {
+			case "initialize":
+				mcp.Invoke(ctx, msg, s.initialize)
+			case "tools/list":
+				mcp.Invoke(ctx, msg, s.tools.List)
+			case "tools/call":
+				mcp.Invoke(ctx, msg, s.tools.Call)
+			}
+		})
+	})
+
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{
+			"agent": {},
+		},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	session.Set(types.DescriptionSessionKey, "chat")
+	return svc, caller, mcp.WithSession(types.WithConfig(t.Context(), config), session)
+}
+
+func callChat(ctx context.Context, svc *tools.Service, async bool, progressToken string) (*types.CallResult, error) {
+	opts := tools.CallOptions{
+		ProgressToken: progressToken,
+	}
+	if async {
+		opts.Meta = map[string]any{
+			types.AsyncMetaKey: true,
+		}
+	}
+	return svc.Call(ctx, "agent", types.AgentTool, map[string]any{
+		"prompt": "hi",
+	}, opts)
+}
+
+func TestChat_RetriedAsyncCallDoesNotStartRun(t *testing.T) {
+	svc, caller, ctx := newChatSession(t)
+
+	// Without a session manager the run isn't started in the background, so the first call waits for it
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := callChat(ctx, svc, true, "token")
+		firstErr <- err
+	}()
+	<-caller.started
+
+	// The client retries while the run is in progress
+	retried, err := callChat(ctx, svc, true, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(types.ProgressURI).Equal(t, retried.Content[1].URI)
+
+	caller.Release()
+	if err := <-firstErr; err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1).Equal(t, caller.Runs())
+}
+
+func TestChat_RetriedCallReturnsCompletedRun(t *testing.T) {
+	svc, caller, ctx := newChatSession(t)
+	caller.Release()
+
+	first, err := callChat(ctx, svc, false, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("hello").Equal(t, first.Content[0].Text)
+
+	if _, err := callChat(ctx, svc, false, "token"); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1).Equal(t, caller.Runs())
+
+	// A new token starts a new run
+	if _, err := callChat(ctx, svc, false, "other"); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(2).Equal(t, caller.Runs())
+}
+
+func TestAppendProgress_PartialToolCall(t *testing.T) {
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(t.Context(), session)
+
+	for _, toolCall := range []types.ToolCall{
+		// The first partial has the name but no arguments yet
+		{CallID: "call_1", Name: "search"},
+		{Arguments: `{"query":`},
+		{Arguments: ` "nanobot"}`},
+	} {
+		params, err := json.Marshal(mcp.NotificationProgressRequest{
+			ProgressToken: "token",
+			Meta: map[string]any{
+				types.CompletionProgressMetaKey: types.CompletionProgress{
+					MessageID: "msg_1",
+					Item: types.CompletionItem{
+						ID:       "item_1",
+						Partial:  true,
+						HasMore:  true,
+						ToolCall: &toolCall,
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := appendProgress(ctx, session, &mcp.Message{Method: "notifications/progress", Params: params}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var response types.CompletionResponse
+	session.Get(progressSessionKey, &response)
+	autogold.Expect(&types.ToolCall{
+		Arguments: `{"query": "nanobot"}`,
+		CallID:    "call_1",
+		Name:      "search",
+	}).Equal(t, response.InternalMessages[0].Items[0].ToolCall)
+}
+
+func TestChat_RetriedCallRestartsInterruptedRun(t *testing.T) {
+	svc, caller, ctx := newChatSession(t)
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := callChat(ctx, svc, true, "token")
+		firstErr <- err
+	}()
+	<-caller.started
+
+	// The server restarts, so the stored progress has more but the run is not running anymore
+	progressRuns = runRegistry{sessions: map[string]*sessionRuns{}}
+
+	retriedErr := make(chan error, 1)
+	go func() {
+		_, err := callChat(ctx, svc, true, "token")
+		retriedErr <- err
+	}()
+	select {
+	case <-caller.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the interrupted run was not started again")
+	}
+	autogold.Expect(2).Equal(t, caller.Runs())
+
+	caller.Release()
+	if err := <-firstErr; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-retriedErr; err != nil {
+		t.Fatal(err)
+	}
+}
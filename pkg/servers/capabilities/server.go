@@ -8,6 +8,7 @@ import (
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/servers/workspace"
+	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"github.com/nanobot-ai/nanobot/pkg/version"
@@ -16,16 +17,20 @@ import (
 
 type Server struct {
 	store *workspace.Store
+	data  *sessiondata.Data
 	tools mcp.ServerTools
 }
 
-func NewServer(store *workspace.Store) *Server {
+func NewServer(store *workspace.Store, data *sessiondata.Data) *Server {
 	s := &Server{
 		store: store,
+		data:  data,
 	}
 
 	s.tools = mcp.NewServerTools(
 		mcp.NewServerTool("init_session", "Initializes the session capabilities", s.initSession),
+		mcp.NewServerTool("set_enabled", "Enable or disable an MCP server, or a single tool of a server, for the current session", s.setEnabled),
+		mcp.NewServerTool("list_disabled", "List the MCP servers and tools disabled for the current session", s.listDisabled),
 	)
 
 	return s
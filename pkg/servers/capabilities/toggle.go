@@ -0,0 +1,45 @@
+package capabilities
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const serverName = "nanobot.capabilities"
+
+type SetEnabledRequest struct {
+	Server  string `json:"server"`
+	Tool    string `json:"tool,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (s *Server) setEnabled(ctx context.Context, req SetEnabledRequest) (*types.DisabledTargets, error) {
+	if !types.IsUISession(ctx) {
+		return nil, mcp.ErrRPCInvalidRequest.WithMessage("only UI sessions can change enabled servers and tools")
+	}
+
+	c := types.ConfigFromContext(ctx)
+	if _, ok := c.MCPServers[req.Server]; !ok {
+		if _, ok := c.Agents[req.Server]; !ok {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("unknown server %s", req.Server)
+		}
+	}
+	if req.Server == serverName && !req.Enabled {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("%s can not be disabled", serverName)
+	}
+
+	session := mcp.SessionFromContext(ctx).Root()
+	disabled := types.DisabledTargetsFromContext(ctx)
+	disabled.SetEnabled(req.Server, req.Tool, req.Enabled)
+	session.Set(types.DisabledTargetsSessionKey, &disabled)
+
+	s.data.RefreshToolMapping(ctx)
+	return &disabled, nil
+}
+
+func (s *Server) listDisabled(ctx context.Context, _ struct{}) (*types.DisabledTargets, error) {
+	disabled := types.DisabledTargetsFromContext(ctx)
+	return &disabled, nil
+}
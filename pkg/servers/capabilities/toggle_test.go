@@ -0,0 +1,135 @@
+package capabilities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type fakeServer struct {
+	tools mcp.ServerTools
+}
+
+func newFakeServer(names ...string) *fakeServer {
+	var serverTools []mcp.ServerTool
+	for _, name := range names {
+		serverTools = append(serverTools, mcp.NewServerTool(name, name, func(context.Context, struct{}) (string, error) {
+			return name, nil
+		}))
+	}
+	return &fakeServer{
+		tools: mcp.NewServerTools(serverTools...),
+	}
+}
+
+func (f *fakeServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, func(context.Context, mcp.Message, mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+			return &mcp.InitializeResult{
+				Capabilities: mcp.ServerCapabilities{
+					Tools: &mcp.ToolsServerCapability{},
+				},
+			}, nil
+		})
+	case "notifications/initialized":
+	case "tools/list":
+		mcp.Invoke(ctx, msg, f.tools.List)
+	case "tools/call":
+		mcp.Invoke(ctx, msg, f.tools.Call)
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func newTestSession(t *testing.T, ui bool) (*tools.Service, context.Context) {
+	t.Helper()
+
+	svc := tools.NewToolsService()
+	svc.AddServer("fs", func(string) mcp.MessageHandler {
+		return newFakeServer("read", "write")
+	})
+	svc.AddServer("search", func(string) mcp.MessageHandler {
+		return newFakeServer("query")
+	})
+	svc.AddServer(serverName, func(string) mcp.MessageHandler {
+		return NewServer(nil, sessiondata.NewData(svc))
+	})
+
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{
+			"fs":       {},
+			"search":   {},
+			serverName: {},
+		},
+	}
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	session.Set(types.SessionInitSessionKey, &types.SessionInitHook{
+		Meta: map[string]any{"ui": ui},
+	})
+	return svc, mcp.WithSession(types.WithConfig(t.Context(), config), session)
+}
+
+func listedTools(t *testing.T, svc *tools.Service, ctx context.Context) (result []string) {
+	t.Helper()
+	list, err := svc.ListTools(ctx, tools.ListToolsOptions{
+		Servers: []string{"fs", "search"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range list {
+		for _, tool := range l.Tools {
+			result = append(result, l.Server+"/"+tool.Name)
+		}
+	}
+	return
+}
+
+func setEnabled(t *testing.T, svc *tools.Service, ctx context.Context, req SetEnabledRequest) {
+	t.Helper()
+	if _, err := svc.Call(ctx, serverName, "set_enabled", req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	svc, ctx := newTestSession(t, true)
+
+	autogold.Expect([]string{"fs/read", "fs/write", "search/query"}).Equal(t, listedTools(t, svc, ctx))
+
+	setEnabled(t, svc, ctx, SetEnabledRequest{Server: "fs"})
+	autogold.Expect([]string{"search/query"}).Equal(t, listedTools(t, svc, ctx))
+
+	if _, err := svc.Call(ctx, "fs", "read", nil); err == nil {
+		t.Fatal("expected calling a disabled server to fail")
+	}
+
+	setEnabled(t, svc, ctx, SetEnabledRequest{Server: "fs", Enabled: true})
+	setEnabled(t, svc, ctx, SetEnabledRequest{Server: "fs", Tool: "write"})
+	autogold.Expect([]string{"fs/read", "search/query"}).Equal(t, listedTools(t, svc, ctx))
+
+	autogold.Expect(types.DisabledTargets{Tools: []string{"fs/write"}}).Equal(t, types.DisabledTargetsFromContext(ctx))
+}
+
+func TestSetEnabled_Errors(t *testing.T) {
+	svc, ctx := newTestSession(t, true)
+
+	_, err := svc.Call(ctx, serverName, "set_enabled", SetEnabledRequest{Server: "missing"})
+	autogold.Expect("error from server: JSON RPC invalid params: unknown server missing").Equal(t, err.Error())
+
+	_, err = svc.Call(ctx, serverName, "set_enabled", SetEnabledRequest{Server: serverName})
+	autogold.Expect("error from server: JSON RPC invalid params: nanobot.capabilities can not be disabled").Equal(t, err.Error())
+
+	svc, ctx = newTestSession(t, false)
+	_, err = svc.Call(ctx, serverName, "set_enabled", SetEnabledRequest{Server: "fs"})
+	autogold.Expect("error from server: JSON RPC invalid request: only UI sessions can change enabled servers and tools").Equal(t, err.Error())
+	autogold.Expect([]string{"fs/read", "fs/write", "search/query"}).Equal(t, listedTools(t, svc, ctx))
+}
@@ -0,0 +1,228 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+// Server is a built-in MCP server for testing client integrations. It echoes back what it receives and
+// can be told to simulate delays, errors and progress notifications.
+type Server struct {
+	tools mcp.ServerTools
+}
+
+func NewServer() *Server {
+	s := &Server{}
+
+	s.tools = mcp.NewServerTools(
+		mcp.NewServerTool("echo", "Returns the arguments and _meta it was called with", s.echo),
+		mcp.NewServerTool("sleep", "Waits for the given duration before returning", s.sleep),
+		mcp.NewServerTool("error", "Fails with the given JSON-RPC error code and message, or returns it as a tool error", s.error),
+		progressTool{},
+		mcp.NewServerTool("execution_diff", "Returns the changes to the execution of the last chat turn of the session, such as the tool outputs and response it added", s.executionDiff),
+	)
+
+	return s
+}
+
+func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, s.initialize)
+	case "notifications/initialized":
+		// nothing to do
+	case "tools/list":
+		mcp.Invoke(ctx, msg, s.tools.List)
+	case "tools/call":
+		mcp.Invoke(ctx, msg, s.tools.Call)
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func (s *Server) initialize(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	return &mcp.InitializeResult{
+		ProtocolVersion: params.ProtocolVersion,
+		Capabilities: mcp.ServerCapabilities{
+			Tools: &mcp.ToolsServerCapability{},
+		},
+		ServerInfo: mcp.ServerInfo{
+			Name:    version.Name,
+			Version: version.Get().String(),
+		},
+	}, nil
+}
+
+func (s *Server) echo(_ context.Context, args map[string]any) (map[string]any, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	return args, nil
+}
+
+type SleepRequest struct {
+	Duration string `json:"duration" jsonschema:"How long to sleep, as a Go duration such as 500ms or 2s"`
+}
+
+func (s *Server) sleep(ctx context.Context, req SleepRequest) (string, error) {
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		return "", mcp.ErrRPCInvalidParams.WithMessage("invalid duration %q: %v", req.Duration, err)
+	}
+
+	select {
+	case <-time.After(d):
+		return fmt.Sprintf("slept for %s", d), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+type ErrorRequest struct {
+	Code      int    `json:"code,omitempty" jsonschema:"The JSON-RPC error code, defaults to -32603 (internal error)"`
+	Message   string `json:"message,omitempty" jsonschema:"The error message"`
+	ToolError bool   `json:"toolError,omitempty" jsonschema:"Return the error as a tool result with isError set instead of a JSON-RPC error"`
+}
+
+func (s *Server) error(_ context.Context, req ErrorRequest) (*mcp.CallToolResult, error) {
+	if req.Code == 0 {
+		req.Code = mcp.ErrRPCInternal.Code
+	}
+	if req.Message == "" {
+		req.Message = "induced error"
+	}
+
+	if req.ToolError {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("%d: %s", req.Code, req.Message),
+				},
+			},
+		}, nil
+	}
+
+	return nil, mcp.NewRPCError(req.Code, req.Message)
+}
+
+type ExecutionDiffRequest struct {
+	Thread string `json:"thread,omitempty" jsonschema:"The name of the thread, the default thread if empty"`
+}
+
+type ExecutionDiffResult struct {
+	Changes []types.ExecutionChange `json:"changes"`
+}
+
+func (s *Server) executionDiff(ctx context.Context, req ExecutionDiffRequest) (*ExecutionDiffResult, error) {
+	var (
+		session      = mcp.SessionFromContext(ctx).Root()
+		executionKey = types.PreviousExecutionKey
+		turnStartKey = types.TurnStartExecutionKey
+		before       types.Execution
+		after        types.Execution
+	)
+	if req.Thread != "" {
+		executionKey += "/" + req.Thread
+		turnStartKey += "/" + req.Thread
+	}
+
+	if !session.Get(turnStartKey, &before) {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("no chat turn has been recorded for the thread %q", req.Thread)
+	}
+	session.Get(executionKey, &after)
+
+	changes, err := types.DiffExecutions(&before, &after)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecutionDiffResult{
+		Changes: changes,
+	}, nil
+}
+
+// progressTool needs the request message to find the progress token, so it implements mcp.ServerTool
+// directly.
+type progressTool struct{}
+
+type ProgressRequest struct {
+	Count    int    `json:"count,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+func (p progressTool) Definition() mcp.Tool {
+	return mcp.Tool{
+		Name:        "progress",
+		Description: "Emits count progress notifications to the caller's progress token before returning",
+		InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "count": {"type": "integer", "description": "The number of notifications to send, defaults to 3"},
+    "message": {"type": "string", "description": "The message to include in each notification"},
+    "interval": {"type": "string", "description": "How long to wait between notifications, as a Go duration"}
+  }
+}`),
+	}
+}
+
+func (p progressTool) Invoke(ctx context.Context, msg mcp.Message, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req ProgressRequest
+	if len(call.Arguments) > 0 {
+		if err := mcp.JSONCoerce(call.Arguments, &req); err != nil {
+			return nil, err
+		}
+	}
+	if req.Count <= 0 {
+		req.Count = 3
+	}
+
+	var interval time.Duration
+	if req.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(req.Interval)
+		if err != nil {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("invalid interval %q: %v", req.Interval, err)
+		}
+	}
+
+	var (
+		token = msg.ProgressToken()
+		total = json.Number(fmt.Sprint(req.Count))
+		sent  = 0
+	)
+	for i := 1; token != nil && i <= req.Count; i++ {
+		if i > 1 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err := msg.Session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+			ProgressToken: token,
+			Progress:      json.Number(fmt.Sprint(i)),
+			Total:         &total,
+			Message:       req.Message,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to send progress notification: %w", err)
+		}
+		sent++
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("sent %d progress notifications", sent),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,157 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func newTestSession(t *testing.T) (*tools.Service, context.Context) {
+	t.Helper()
+
+	svc := tools.NewToolsService()
+	svc.AddServer("nanobot.debug", func(string) mcp.MessageHandler {
+		return NewServer()
+	})
+
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{
+			"nanobot.debug": {},
+		},
+	}
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	return svc, mcp.WithSession(types.WithConfig(t.Context(), config), session)
+}
+
+func TestEcho(t *testing.T) {
+	svc, ctx := newTestSession(t)
+
+	result, err := svc.Call(ctx, "nanobot.debug", "echo", map[string]any{
+		"greeting": "hello",
+		"count":    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(map[string]any{"count": 2.0, "greeting": "hello"}).Equal(t, result.StructuredContent)
+}
+
+func TestError(t *testing.T) {
+	svc, ctx := newTestSession(t)
+
+	_, err := svc.Call(ctx, "nanobot.debug", "error", ErrorRequest{
+		Code:    -32001,
+		Message: "boom",
+	})
+	autogold.Expect("error from server: boom").Equal(t, err.Error())
+
+	result, err := svc.Call(ctx, "nanobot.debug", "error", ErrorRequest{
+		Message:   "boom",
+		ToolError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(true).Equal(t, result.IsError)
+	autogold.Expect("-32603: boom").Equal(t, result.Content[0].Text)
+}
+
+func TestProgress(t *testing.T) {
+	svc, ctx := newTestSession(t)
+
+	var (
+		lock     sync.Mutex
+		progress []mcp.NotificationProgressRequest
+	)
+	defer mcp.SessionFromContext(ctx).AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		if msg.Method != "notifications/progress" {
+			return msg, nil
+		}
+		// Progress is normalized per token by the session, so only the payload is checked
+		var payload mcp.NotificationProgressRequest
+		if err := json.Unmarshal(msg.Params, &payload); err == nil && payload.Meta == nil {
+			lock.Lock()
+			progress = append(progress, payload)
+			lock.Unlock()
+		}
+		return nil, nil
+	})()
+
+	result, err := svc.Call(ctx, "nanobot.debug", "progress", ProgressRequest{
+		Count:   2,
+		Message: "working",
+	}, tools.CallOptions{
+		ProgressToken: "token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("sent 2 progress notifications").Equal(t, result.Content[0].Text)
+
+	lock.Lock()
+	defer lock.Unlock()
+	var messages []string
+	for _, p := range progress {
+		messages = append(messages, p.Message+" of "+string(*p.Total))
+	}
+	autogold.Expect([]string{"working of 2", "working of 2"}).Equal(t, messages)
+}
+
+func TestExecutionDiff(t *testing.T) {
+	svc, ctx := newTestSession(t)
+
+	_, err := svc.Call(ctx, "nanobot.debug", "execution_diff", ExecutionDiffRequest{})
+	autogold.Expect(`error from server: JSON RPC invalid params: no chat turn has been recorded for the thread ""`).Equal(t, err.Error())
+
+	session := mcp.SessionFromContext(ctx)
+	session.Set(types.TurnStartExecutionKey, &types.Execution{})
+	session.Set(types.PreviousExecutionKey, &types.Execution{
+		Done: true,
+		ToolOutputs: map[string]types.ToolOutput{
+			"call1": {Done: true},
+		},
+		Response: &types.CompletionResponse{
+			Model: "gpt",
+		},
+	})
+
+	result, err := svc.Call(ctx, "nanobot.debug", "execution_diff", ExecutionDiffRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diff ExecutionDiffResult
+	if err := mcp.JSONCoerce(result.StructuredContent, &diff); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]types.ExecutionChange{
+		{
+			Op:    "add",
+			Path:  "/done",
+			After: true,
+		},
+		{
+			Op:   "add",
+			Path: "/response",
+			After: map[string]interface{}{
+				"model":  "gpt",
+				"output": map[string]interface{}{},
+			},
+		},
+		{
+			Op:   "add",
+			Path: "/toolOutputs",
+			After: map[string]interface{}{"call1": map[string]interface{}{
+				"done":   true,
+				"output": map[string]interface{}{},
+			}},
+		},
+	}).Equal(t, diff.Changes)
+}
@@ -0,0 +1,17 @@
+package meta
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type cancelAllResult struct {
+	Canceled int `json:"canceled"`
+}
+
+func (s *Server) cancelAll(ctx context.Context, _ struct{}) (*cancelAllResult, error) {
+	return &cancelAllResult{
+		Canceled: types.CancelAll(ctx),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestCancelAll(t *testing.T) {
+	svc := tools.NewToolsService()
+	svc.AddServer("nanobot.meta", func(string) mcp.MessageHandler {
+		return NewServer(nil, nil)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"nanobot.meta": {}}}
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.SessionInitSessionKey, &types.SessionInitHook{Meta: map[string]any{"ui": true}})
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	var runs []context.Context
+	for range 2 {
+		runCtx, done := types.WithCancel(ctx)
+		defer done()
+		runs = append(runs, runCtx)
+	}
+
+	// A tool call of a run, in a child session, is canceled with the run and not counted again.
+	child := mcp.NewEmptySession(runs[0])
+	child.Parent = session
+	callCtx, done := types.WithCancel(mcp.WithSession(runs[0], child))
+	defer done()
+
+	finished, finish := types.WithCancel(ctx)
+	finish()
+
+	cancelAll := func() any {
+		t.Helper()
+		result, err := svc.Call(ctx, "nanobot.meta", "cancel_all", struct{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.StructuredContent
+	}
+
+	// The call of cancel_all is not canceled, so it can respond.
+	autogold.Expect(map[string]any{"canceled": 2.0}).Equal(t, cancelAll())
+
+	for _, runCtx := range append(runs, callCtx) {
+		select {
+		case <-runCtx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("expected the run to be canceled")
+		}
+		if cause := context.Cause(runCtx); !errors.Is(cause, types.ErrCanceledAll) {
+			t.Fatalf("expected cause %v, got %v", types.ErrCanceledAll, cause)
+		}
+	}
+	if !errors.Is(context.Cause(finished), context.Canceled) {
+		t.Fatalf("expected the finished run to keep its cause, got %v", context.Cause(finished))
+	}
+
+	autogold.Expect(map[string]any{"canceled": 0.0}).Equal(t, cancelAll())
+}
@@ -0,0 +1,39 @@
+package meta
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/chunk"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+type chunkParams struct {
+	Text       string `json:"text" jsonschema:"The text to split into chunks"`
+	DocumentID string `json:"documentID,omitempty" jsonschema:"The ID of the document the chunk IDs are derived from, defaults to a hash of the text"`
+	Size       int    `json:"size,omitempty" jsonschema:"The maximum size of a chunk, defaults to 1000"`
+	Overlap    int    `json:"overlap,omitempty" jsonschema:"The maximum size of the text repeated from the end of the previous chunk, defaults to 0"`
+	Unit       string `json:"unit,omitempty" jsonschema:"The unit of size and overlap, characters (the default) or tokens"`
+}
+
+type chunkResult struct {
+	Chunks []chunk.Chunk `json:"chunks"`
+}
+
+func (s *Server) chunk(_ context.Context, params chunkParams) (*chunkResult, error) {
+	if params.Text == "" {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("text must not be empty")
+	}
+
+	chunks, err := chunk.Split(params.DocumentID, params.Text, chunk.Options{
+		Size:    params.Size,
+		Overlap: params.Overlap,
+		Unit:    params.Unit,
+	})
+	if err != nil {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("%v", err)
+	}
+
+	return &chunkResult{
+		Chunks: chunks,
+	}, nil
+}
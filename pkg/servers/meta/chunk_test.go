@@ -0,0 +1,38 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/chunk"
+)
+
+func TestChunk(t *testing.T) {
+	s := NewServer(nil, nil)
+
+	result, err := s.chunk(t.Context(), chunkParams{
+		Text:       "One sentence. Another sentence.",
+		DocumentID: "doc",
+		Size:       20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&chunkResult{Chunks: []chunk.Chunk{
+		{
+			ID:   "doc#0",
+			End:  13,
+			Text: "One sentence.",
+		},
+		{
+			ID:    "doc#1",
+			Index: 1,
+			Start: 14,
+			End:   31,
+			Text:  "Another sentence.",
+		},
+	}}).Equal(t, result)
+
+	_, err = s.chunk(t.Context(), chunkParams{Text: "text", Size: 10, Overlap: 20})
+	autogold.Expect("-32602: JSON RPC invalid params: chunk overlap must be between 0 and the chunk size 10, got 20").Equal(t, err.Error())
+}
@@ -0,0 +1,27 @@
+package meta
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type embedParams struct {
+	Texts []string `json:"texts" jsonschema:"The texts to embed"`
+	Model string   `json:"model,omitempty" jsonschema:"The embeddings model to use, defaults to the configured embeddings model"`
+}
+
+func (s *Server) embed(ctx context.Context, params embedParams) (*types.EmbeddingResponse, error) {
+	if len(params.Texts) == 0 {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("texts must not be empty")
+	}
+	if s.embedder == nil {
+		return nil, mcp.ErrRPCInvalidRequest.WithMessage("embeddings are not configured")
+	}
+
+	return s.embedder.Embed(ctx, types.EmbeddingRequest{
+		Model: params.Model,
+		Input: params.Texts,
+	})
+}
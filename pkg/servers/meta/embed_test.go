@@ -0,0 +1,37 @@
+package meta
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type testEmbedder struct {
+	requests []types.EmbeddingRequest
+}
+
+func (t *testEmbedder) Embed(_ context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	t.requests = append(t.requests, req)
+	resp := &types.EmbeddingResponse{Model: "test"}
+	for _, text := range req.Input {
+		resp.Embeddings = append(resp.Embeddings, []float64{float64(len(text))})
+	}
+	return resp, nil
+}
+
+func TestEmbed(t *testing.T) {
+	embedder := &testEmbedder{}
+	s := NewServer(nil, embedder)
+
+	resp, err := s.embed(t.Context(), embedParams{Texts: []string{"hello", "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&types.EmbeddingResponse{Model: "test", Embeddings: [][]float64{{5}, {2}}}).Equal(t, resp)
+	autogold.Expect([]types.EmbeddingRequest{{Input: []string{"hello", "hi"}}}).Equal(t, embedder.requests)
+
+	_, err = s.embed(t.Context(), embedParams{})
+	autogold.Expect("-32602: JSON RPC invalid params: texts must not be empty").Equal(t, err.Error())
+}
@@ -10,25 +10,44 @@ import (
 )
 
 type Server struct {
-	tools mcp.ServerTools
-	data  *sessiondata.Data
+	tools    mcp.ServerTools
+	data     *sessiondata.Data
+	embedder types.Embedder
 }
 
-func NewServer(data *sessiondata.Data) *Server {
+func NewServer(data *sessiondata.Data, embedder types.Embedder) *Server {
 	s := &Server{
-		data: data,
+		data:     data,
+		embedder: embedder,
 	}
 
 	s.tools = mcp.NewServerTools(
 		mcp.NewServerTool("list_chats", "Returns all previous chat threads", s.listChats),
 		mcp.NewServerTool("update_chat", "Update fields of a give chat thread", s.updateChat),
 		mcp.NewServerTool("list_agents", "List available agents and their meta data", s.listAgents),
+		mcp.NewServerTool("server_status", "Probes the configured MCP servers and reports if they are reachable and authenticated, their tool count and last error", s.serverStatus),
+		mcp.NewServerTool("cancel_all", "Cancels all active runs and tool calls of the session and returns how many were canceled", s.cancelAll),
+		s.embedTool(),
+		s.chunkTool(),
+		s.countTokensTool(),
 		//mcp.NewServerTool("clone", "Clone the current session and return a new session ID", s.clone),
 	)
 
 	return s
 }
 
+func (s *Server) embedTool() mcp.ServerTool {
+	return mcp.NewServerTool("embed", "Returns an embedding vector for each of the given texts, for similarity search", s.embed)
+}
+
+func (s *Server) chunkTool() mcp.ServerTool {
+	return mcp.NewServerTool("chunk", "Splits a document into overlapping chunks at sentence and paragraph boundaries, for embedding or retrieval", s.chunk)
+}
+
+func (s *Server) countTokensTool() mcp.ServerTool {
+	return mcp.NewServerTool("count_tokens", "Estimates the number of tokens of a text for the tokenizer of a model", s.countTokens)
+}
+
 func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
 	switch msg.Method {
 	case "initialize":
@@ -46,9 +65,13 @@ func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
 
 func (s *Server) initialize(ctx context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
 	if !types.IsUISession(ctx) {
-		s.tools = mcp.NewServerTools()
+		// Only embeddings, chunking and token counts are useful to agents, the chat tools are for the UI.
+		s.tools = mcp.NewServerTools(s.embedTool(), s.chunkTool(), s.countTokensTool())
 		return &mcp.InitializeResult{
 			ProtocolVersion: params.ProtocolVersion,
+			Capabilities: mcp.ServerCapabilities{
+				Tools: &mcp.ToolsServerCapability{},
+			},
 			ServerInfo: mcp.ServerInfo{
 				Name:    version.Name,
 				Version: version.Get().String(),
@@ -0,0 +1,115 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	// statusConcurrency is the number of servers that are probed at once.
+	statusConcurrency = 5
+	// statusTimeout bounds probing a single server.
+	statusTimeout = 10 * time.Second
+)
+
+type serverStatusParams struct {
+	Servers []string `json:"servers,omitempty" jsonschema:"The servers to probe, all configured servers if empty"`
+}
+
+type serverStatus struct {
+	Name string `json:"name"`
+	// Reachable is true if the server responded, even if it requires authentication.
+	Reachable     bool   `json:"reachable"`
+	Authenticated bool   `json:"authenticated"`
+	Tools         int    `json:"tools"`
+	LatencyMS     int64  `json:"latencyMS"`
+	Error         string `json:"error,omitempty"`
+}
+
+type serverStatusResult struct {
+	Servers []serverStatus `json:"servers"`
+	Healthy int            `json:"healthy"`
+}
+
+func (s *Server) serverStatus(ctx context.Context, params serverStatusParams) (*serverStatusResult, error) {
+	config := types.ConfigFromContext(ctx)
+
+	names := params.Servers
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(config.MCPServers))
+	}
+	for _, name := range names {
+		if _, ok := config.MCPServers[name]; !ok {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("server %s is not configured", name)
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, statusConcurrency)
+		result    = &serverStatusResult{
+			Servers: make([]serverStatus, len(names)),
+		}
+	)
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			result.Servers[i] = s.probe(ctx, name)
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range result.Servers {
+		if status.Error == "" {
+			result.Healthy++
+		}
+	}
+	return result, nil
+}
+
+// probe initializes a client for the server and lists its tools.
+func (s *Server) probe(ctx context.Context, name string) (status serverStatus) {
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	status.Name = name
+	start := time.Now()
+	defer func() {
+		status.LatencyMS = time.Since(start).Milliseconds()
+	}()
+
+	c, err := s.data.InitializedClient(ctx, name)
+	if err == nil {
+		var tools *mcp.ListToolsResult
+		if tools, err = c.ListTools(ctx); err == nil {
+			status.Tools = len(tools.Tools)
+		}
+	}
+
+	var authErr mcp.AuthRequiredErr
+	switch {
+	case err == nil:
+		status.Reachable = true
+		status.Authenticated = true
+	case errors.As(err, &authErr):
+		status.Reachable = true
+		status.Error = err.Error()
+	default:
+		// An error response still means the server could be reached and the client is authorized
+		var rpcErr *mcp.RPCError
+		status.Reachable = errors.As(err, &rpcErr)
+		status.Authenticated = status.Reachable
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,109 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// statusTestServer lists a single tool, or fails to list tools if it is broken.
+type statusTestServer struct {
+	broken bool
+}
+
+func (s statusTestServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+			return &mcp.InitializeResult{
+				ProtocolVersion: params.ProtocolVersion,
+				Capabilities: mcp.ServerCapabilities{
+					Tools: &mcp.ToolsServerCapability{},
+				},
+			}, nil
+		})
+	case "notifications/initialized":
+	case "tools/list":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, _ mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+			if s.broken {
+				return nil, fmt.Errorf("server is broken")
+			}
+			return &mcp.ListToolsResult{Tools: []mcp.Tool{{Name: "tool"}}}, nil
+		})
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+// authRuntime requires authentication for the server named "auth".
+type authRuntime struct {
+	*tools.Service
+}
+
+func (a authRuntime) GetClient(ctx context.Context, name string) (*mcp.Client, error) {
+	if name == "auth" {
+		return nil, mcp.AuthRequiredErr{Err: fmt.Errorf("401 Unauthorized")}
+	}
+	return a.Service.GetClient(ctx, name)
+}
+
+func TestServerStatus(t *testing.T) {
+	svc := tools.NewToolsService()
+	svc.AddServer("healthy", func(string) mcp.MessageHandler {
+		return statusTestServer{}
+	})
+	svc.AddServer("broken", func(string) mcp.MessageHandler {
+		return statusTestServer{broken: true}
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{
+		"healthy": {},
+		"broken":  {},
+		"auth":    {},
+		"offline": {BaseURL: "http://127.0.0.1:1/mcp"},
+	}}
+	s := NewServer(sessiondata.NewData(authRuntime{Service: svc}), nil)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	result, err := s.serverStatus(ctx, serverStatusParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range result.Servers {
+		result.Servers[i].LatencyMS = 0
+	}
+	autogold.Expect(&serverStatusResult{
+		Servers: []serverStatus{
+			{
+				Name:      "auth",
+				Reachable: true,
+				Error:     "authentication required: 401 Unauthorized",
+			},
+			{
+				Name:          "broken",
+				Reachable:     true,
+				Authenticated: true,
+				Error:         "error from server: JSON RPC internal error",
+			},
+			{
+				Name:          "healthy",
+				Reachable:     true,
+				Authenticated: true,
+				Tools:         1,
+			},
+			{
+				Name:  "offline",
+				Error: `failed to send request: failed to initialize client: Post "http://127.0.0.1:1/mcp": dial tcp 127.0.0.1:1: connect: connection refused`,
+			},
+		},
+		Healthy: 1,
+	}).Equal(t, result)
+
+	_, err = s.serverStatus(ctx, serverStatusParams{Servers: []string{"missing"}})
+	autogold.Expect("-32602: JSON RPC invalid params: server missing is not configured").Equal(t, err.Error())
+}
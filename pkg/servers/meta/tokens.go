@@ -0,0 +1,26 @@
+package meta
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/tokens"
+)
+
+type countTokensParams struct {
+	Text  string `json:"text" jsonschema:"The text to estimate the tokens of"`
+	Model string `json:"model,omitempty" jsonschema:"The model whose tokenizer is used, for example gpt-4o or claude-sonnet-4"`
+}
+
+type countTokensResult struct {
+	Model  string `json:"model,omitempty"`
+	Family string `json:"family"`
+	Tokens int    `json:"tokens"`
+}
+
+func (s *Server) countTokens(_ context.Context, params countTokensParams) (*countTokensResult, error) {
+	return &countTokensResult{
+		Model:  params.Model,
+		Family: tokens.Family(params.Model),
+		Tokens: tokens.Estimate(params.Model, params.Text),
+	}, nil
+}
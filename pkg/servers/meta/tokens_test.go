@@ -0,0 +1,17 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestCountTokens(t *testing.T) {
+	s := NewServer(nil, nil)
+
+	result, err := s.countTokens(t.Context(), countTokensParams{Text: "Hello, world!", Model: "gpt-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&countTokensResult{Model: "gpt-4", Family: "cl100k", Tokens: 4}).Equal(t, result)
+}
@@ -2,8 +2,10 @@ package resources
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
@@ -13,6 +15,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// defaultReadLength is the number of bytes read_resource returns by default.
+const defaultReadLength = 10000
+
 type Server struct {
 	tools mcp.ServerTools
 	store *Store
@@ -25,11 +30,16 @@ func NewServer(store *Store) *Server {
 
 	s.tools = mcp.NewServerTools(
 		mcp.NewServerTool("create_resource", "Create a resource", s.createResource),
+		s.readResourceTool(),
 	)
 
 	return s
 }
 
+func (s *Server) readResourceTool() mcp.ServerTool {
+	return mcp.NewServerTool("read_resource", "Read part of the text of a resource, like a tool result that was too large to include", s.readResourceText)
+}
+
 type GetArtifactParams struct {
 	ArtifactID string `json:"artifactID"`
 }
@@ -41,6 +51,12 @@ type CreateArtifactParams struct {
 	MimeType    string `json:"mimeType"`
 }
 
+// CreateResource stores a resource for the session in ctx, reusing a resource of the session with the same
+// content.
+func (s *Server) CreateResource(ctx context.Context, params CreateArtifactParams) (*mcp.Resource, error) {
+	return s.createResource(ctx, params)
+}
+
 func (s *Server) createResource(ctx context.Context, params CreateArtifactParams) (*mcp.Resource, error) {
 	sessionID, accountID := types.GetSessionAndAccountID(ctx)
 
@@ -49,12 +65,29 @@ func (s *Server) createResource(ctx context.Context, params CreateArtifactParams
 		return nil, mcp.ErrRPCInvalidParams.WithMessage("invalid base64 data: %v", err)
 	}
 
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	// Attachments are often sent again in later turns, reference the content already stored for this session
+	existing, err := s.store.FindBySessionIDAndHash(ctx, sessionID, accountID, hash, params.MimeType)
+	if err == nil {
+		return &mcp.Resource{
+			URI:         "nanobot://resource/" + existing.UUID,
+			Name:        existing.Name,
+			Description: existing.Description,
+			MimeType:    existing.MimeType,
+			Size:        int64(len(data)),
+		}, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
 	uuid := uuid.String()
 	err = s.store.Create(ctx, &Resource{
 		UUID:        uuid,
 		SessionID:   sessionID,
 		AccountID:   accountID,
 		Blob:        params.Blob,
+		Hash:        hash,
 		MimeType:    params.MimeType,
 		Name:        params.Name,
 		Description: params.Description,
@@ -96,6 +129,46 @@ func (s *Server) readResource(ctx context.Context, _ mcp.Message, body mcp.ReadR
 	}, nil
 }
 
+type ReadResourceTextParams struct {
+	URI    string `json:"uri" jsonschema:"The URI of the resource, nanobot://resource/{uuid}"`
+	Offset int    `json:"offset,omitempty" jsonschema:"The byte offset to start reading at, defaults to 0"`
+	Length int    `json:"length,omitempty" jsonschema:"The number of bytes to read, defaults to 10000"`
+}
+
+func (s *Server) readResourceText(ctx context.Context, params ReadResourceTextParams) (string, error) {
+	_, accountID := types.GetSessionAndAccountID(ctx)
+
+	id, ok := strings.CutPrefix(params.URI, "nanobot://resource/")
+	if !ok {
+		return "", mcp.ErrRPCInvalidParams.WithMessage("invalid uri format, expected nanobot://resource/{uuid}")
+	}
+
+	artifact, err := s.store.GetByUUIDAndAccountID(ctx, id, accountID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", mcp.ErrRPCInvalidParams.WithMessage("artifact not found")
+	} else if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(artifact.Blob)
+	if err != nil {
+		return "", err
+	}
+
+	length := params.Length
+	if length <= 0 {
+		length = defaultReadLength
+	}
+	start := min(max(params.Offset, 0), len(data))
+	end := min(start+length, len(data))
+
+	text := string(data[start:end])
+	if end < len(data) {
+		text += fmt.Sprintf("\n\n[bytes %d to %d of %d, read from offset %d for more]", start, end, len(data), end)
+	}
+	return text, nil
+}
+
 func (s *Server) listResourcesTemplates(_ context.Context, _ mcp.Message, _ mcp.ListResourceTemplatesRequest) (*mcp.ListResourceTemplatesResult, error) {
 	return &mcp.ListResourceTemplatesResult{
 		ResourceTemplates: make([]mcp.ResourceTemplate, 0),
@@ -150,9 +223,13 @@ func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
 
 func (s *Server) initialize(ctx context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
 	if !types.IsUISession(ctx) {
-		s.tools = mcp.NewServerTools()
+		// Agents can only read the text of resources, like the large tool results stored for them.
+		s.tools = mcp.NewServerTools(s.readResourceTool())
 		return &mcp.InitializeResult{
 			ProtocolVersion: params.ProtocolVersion,
+			Capabilities: mcp.ServerCapabilities{
+				Tools: &mcp.ToolsServerCapability{},
+			},
 			ServerInfo: mcp.ServerInfo{
 				Name:    version.Name,
 				Version: version.Get().String(),
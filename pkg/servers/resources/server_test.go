@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestCreateResource_Dedup(t *testing.T) {
+	store, err := NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store)
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.AccountIDSessionKey, "account")
+	ctx := mcp.WithSession(t.Context(), session)
+
+	image := base64.StdEncoding.EncodeToString([]byte("image"))
+
+	first, err := s.createResource(ctx, CreateArtifactParams{Name: "cat.png", Blob: image, MimeType: "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.createResource(ctx, CreateArtifactParams{Name: "cat-again.png", Blob: image, MimeType: "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(first).Equal(t, second)
+
+	other, err := s.createResource(ctx, CreateArtifactParams{Name: "dog.png", Blob: base64.StdEncoding.EncodeToString([]byte("other")), MimeType: "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(false).Equal(t, other.URI == first.URI)
+
+	resources, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(2).Equal(t, len(resources))
+
+	read, err := s.readResource(ctx, mcp.Message{}, mcp.ReadResourceRequest{URI: second.URI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(image).Equal(t, read.Contents[0].Blob)
+}
+
+func TestStore_FindBySessionIDAndHash(t *testing.T) {
+	store, err := NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(t.Context(), &Resource{UUID: "1", SessionID: "a", AccountID: "account", Hash: "abc", MimeType: "image/png"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := store.FindBySessionIDAndHash(t.Context(), "a", "account", "abc", "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("1").Equal(t, resource.UUID)
+
+	// Content is only shared within a session
+	_, err = store.FindBySessionIDAndHash(t.Context(), "b", "account", "abc", "image/png")
+	autogold.Expect("record not found").Equal(t, err.Error())
+}
+
+func TestReadResourceText(t *testing.T) {
+	store, err := NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store)
+
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.AccountIDSessionKey, "account")
+	ctx := mcp.WithSession(t.Context(), session)
+
+	resource, err := s.createResource(ctx, CreateArtifactParams{
+		Name:     "result",
+		Blob:     base64.StdEncoding.EncodeToString([]byte("0123456789")),
+		MimeType: "text/plain",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := s.readResourceText(ctx, ReadResourceTextParams{URI: resource.URI, Offset: 2, Length: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("2345\n\n[bytes 2 to 6 of 10, read from offset 6 for more]").Equal(t, text)
+
+	text, err = s.readResourceText(ctx, ReadResourceTextParams{URI: resource.URI, Offset: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("6789").Equal(t, text)
+
+	_, err = s.readResourceText(ctx, ReadResourceTextParams{URI: "https://example.com"})
+	autogold.Expect("-32602: JSON RPC invalid params: invalid uri format, expected nanobot://resource/{uuid}").Equal(t, err.Error())
+}
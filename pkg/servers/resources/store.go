@@ -55,6 +55,16 @@ func (s *Store) GetByUUIDAndAccountID(ctx context.Context, uuid, accountID strin
 	return &artifact, nil
 }
 
+// FindBySessionIDAndHash retrieves the artifact of a session with the given content hash and mime type
+func (s *Store) FindBySessionIDAndHash(ctx context.Context, sessionID, accountID, hash, mimeType string) (*Resource, error) {
+	var artifact Resource
+	err := s.db.WithContext(ctx).Where("session_id = ? and account_id = ? and hash = ? and mime_type = ?", sessionID, accountID, hash, mimeType).First(&artifact).Error
+	if err != nil {
+		return nil, err
+	}
+	return &artifact, nil
+}
+
 // Delete deletes an artifact by its ID
 func (s *Store) Delete(ctx context.Context, id uint) error {
 	return s.db.WithContext(ctx).Delete(&Resource{}, id).Error
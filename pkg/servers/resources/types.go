@@ -15,6 +15,8 @@ type Resource struct {
 	AccountID string `json:"accountID" gorm:"index;not null"`
 	// Blob is the binary content of the artifact
 	Blob string `json:"blob"`
+	// Hash is the hex encoded SHA-256 of the content, identical content is only stored once per session
+	Hash string `json:"hash,omitempty" gorm:"index"`
 	// MimeType the mime type of the content
 	MimeType    string `json:"mimeType,omitempty"`
 	Name        string `json:"name,omitempty"`
@@ -0,0 +1,256 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+const defaultSearchLimit = 5
+
+type Server struct {
+	store    *Store
+	embedder types.Embedder
+	tools    mcp.ServerTools
+}
+
+func NewServer(store *Store, embedder types.Embedder) *Server {
+	s := &Server{
+		store:    store,
+		embedder: embedder,
+	}
+
+	s.tools = mcp.NewServerTools(
+		mcp.NewServerTool("upsert", "Store documents in the vector store so they can be found with search. Documents with an existing ID are replaced.", s.upsert),
+		mcp.NewServerTool("search", "Search the vector store for the documents most similar in meaning to the query", s.search),
+	)
+
+	return s
+}
+
+type Document struct {
+	ID       string         `json:"id" jsonschema:"The unique ID of the document"`
+	Text     string         `json:"text" jsonschema:"The text of the document"`
+	Metadata map[string]any `json:"metadata,omitempty" jsonschema:"Metadata returned with the document in search results"`
+}
+
+type UpsertParams struct {
+	Documents []Document `json:"documents" jsonschema:"The documents to store"`
+}
+
+type UpsertResult struct {
+	IDs []string `json:"ids"`
+}
+
+func (s *Server) upsert(ctx context.Context, params UpsertParams) (*UpsertResult, error) {
+	sessionID, accountID := types.GetSessionAndAccountID(ctx)
+
+	if len(params.Documents) == 0 {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("documents must not be empty")
+	}
+
+	texts := make([]string, 0, len(params.Documents))
+	for _, doc := range params.Documents {
+		if doc.ID == "" {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("document id is required")
+		}
+		if doc.Text == "" {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("text of document %q is required", doc.ID)
+		}
+		texts = append(texts, doc.Text)
+	}
+
+	embeddings, err := s.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chunks = make([]Chunk, 0, len(params.Documents))
+		result = &UpsertResult{}
+	)
+	for i, doc := range params.Documents {
+		embedding, err := json.Marshal(embeddings[i])
+		if err != nil {
+			return nil, err
+		}
+
+		var metadata []byte
+		if doc.Metadata != nil {
+			metadata, err = json.Marshal(doc.Metadata)
+			if err != nil {
+				return nil, mcp.ErrRPCInvalidParams.WithMessage("invalid metadata: %v", err)
+			}
+		}
+
+		chunks = append(chunks, Chunk{
+			DocumentID: doc.ID,
+			AccountID:  accountID,
+			SessionID:  sessionID,
+			Text:       doc.Text,
+			Metadata:   metadata,
+			Embedding:  embedding,
+		})
+		result.IDs = append(result.IDs, doc.ID)
+	}
+
+	if err := s.store.Upsert(ctx, chunks); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type SearchParams struct {
+	Query string `json:"query" jsonschema:"The text to search for"`
+	Limit int    `json:"limit,omitempty" jsonschema:"The maximum number of documents to return, defaults to 5"`
+	Scope string `json:"scope,omitempty" jsonschema:"Search the documents of this session (session) or of all sessions of the account (account), defaults to session"`
+}
+
+type SearchResult struct {
+	Documents []ScoredDocument `json:"documents"`
+}
+
+type ScoredDocument struct {
+	Document
+	Score float64 `json:"score"`
+}
+
+func (s *Server) search(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	sessionID, accountID := types.GetSessionAndAccountID(ctx)
+
+	if params.Query == "" {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("query is required")
+	}
+
+	if params.Scope != "" && params.Scope != "session" && params.Scope != "account" {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("invalid scope %q, must be session or account", params.Scope)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	embeddings, err := s.embed(ctx, []string{params.Query})
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []Chunk
+	if params.Scope == "account" {
+		chunks, err = s.store.FindByAccountID(ctx, accountID)
+	} else {
+		chunks, err = s.store.FindBySessionID(ctx, accountID, sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Documents: make([]ScoredDocument, 0, len(chunks)),
+	}
+	for _, chunk := range chunks {
+		var embedding []float64
+		if err := json.Unmarshal(chunk.Embedding, &embedding); err != nil {
+			continue
+		}
+
+		doc := ScoredDocument{
+			Document: Document{
+				ID:   chunk.DocumentID,
+				Text: chunk.Text,
+			},
+			Score: cosineSimilarity(embeddings[0], embedding),
+		}
+		if len(chunk.Metadata) > 0 {
+			_ = json.Unmarshal(chunk.Metadata, &doc.Metadata)
+		}
+		result.Documents = append(result.Documents, doc)
+	}
+
+	slices.SortStableFunc(result.Documents, func(a, b ScoredDocument) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return 0
+		}
+	})
+	if len(result.Documents) > limit {
+		result.Documents = result.Documents[:limit]
+	}
+
+	return result, nil
+}
+
+func (s *Server) embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if s.embedder == nil {
+		return nil, mcp.ErrRPCInvalidRequest.WithMessage("embeddings are not configured")
+	}
+
+	resp, err := s.embedder.Embed(ctx, types.EmbeddingRequest{
+		Input: texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, mcp.ErrRPCInternal.WithMessage("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, 0 if either is empty or their
+// lengths differ.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, s.initialize)
+	case "notifications/initialized":
+		// nothing to do
+	case "tools/list":
+		mcp.Invoke(ctx, msg, s.tools.List)
+	case "tools/call":
+		mcp.Invoke(ctx, msg, s.tools.Call)
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func (s *Server) initialize(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	return &mcp.InitializeResult{
+		ProtocolVersion: params.ProtocolVersion,
+		Capabilities: mcp.ServerCapabilities{
+			Tools: &mcp.ToolsServerCapability{},
+		},
+		ServerInfo: mcp.ServerInfo{
+			Name:    version.Name,
+			Version: version.Get().String(),
+		},
+	}, nil
+}
@@ -0,0 +1,150 @@
+package vectorstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// topicEmbedder embeds text by counting the words of each topic, so texts about the same topic are
+// similar even when they share no words.
+type topicEmbedder struct{}
+
+var topics = [][]string{
+	{"cat", "kitten", "dog", "puppy", "pet", "pets"},
+	{"rain", "sunny", "weather", "forecast", "storm"},
+	{"pizza", "pasta", "dinner", "recipe", "cook"},
+}
+
+func (topicEmbedder) Embed(_ context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	resp := &types.EmbeddingResponse{}
+	for _, text := range req.Input {
+		embedding := make([]float64, len(topics))
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			for i, topic := range topics {
+				for _, topicWord := range topic {
+					if strings.Trim(word, ".,?!") == topicWord {
+						embedding[i]++
+					}
+				}
+			}
+		}
+		resp.Embeddings = append(resp.Embeddings, embedding)
+	}
+	return resp, nil
+}
+
+func newTestContext(t *testing.T, accountID string) context.Context {
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.AccountIDSessionKey, accountID)
+	return mcp.WithSession(t.Context(), session)
+}
+
+func searchIDs(result *SearchResult) (ids []string) {
+	for _, doc := range result.Documents {
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	store, err := NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store, topicEmbedder{})
+	ctx := newTestContext(t, "account")
+
+	_, err = s.upsert(ctx, UpsertParams{
+		Documents: []Document{
+			{ID: "pets", Text: "A kitten and a puppy are good pets", Metadata: map[string]any{"source": "pets.md"}},
+			{ID: "weather", Text: "The forecast says rain and a storm"},
+			{ID: "food", Text: "A pasta recipe to cook for dinner"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.search(ctx, SearchParams{Query: "What should I feed my cat?", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(&SearchResult{Documents: []ScoredDocument{{
+		Document: Document{
+			ID:       "pets",
+			Text:     "A kitten and a puppy are good pets",
+			Metadata: map[string]any{"source": "pets.md"},
+		},
+		Score: 1,
+	}}}).Equal(t, result)
+
+	result, err = s.search(ctx, SearchParams{Query: "Will it be sunny?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"weather", "pets", "food"}).Equal(t, searchIDs(result))
+
+	// Upserting an existing ID replaces the document
+	_, err = s.upsert(ctx, UpsertParams{
+		Documents: []Document{{ID: "weather", Text: "Pizza for dinner in the rain"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = s.search(ctx, SearchParams{Query: "What is for dinner?", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"food", "weather"}).Equal(t, searchIDs(result))
+	autogold.Expect("Pizza for dinner in the rain").Equal(t, result.Documents[1].Text)
+
+	// Other accounts do not see the documents
+	result, err = s.search(newTestContext(t, "other"), SearchParams{Query: "dinner", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string(nil)).Equal(t, searchIDs(result))
+
+	_, err = s.search(ctx, SearchParams{Query: "dinner", Scope: "everything"})
+	autogold.Expect(`-32602: JSON RPC invalid params: invalid scope "everything", must be session or account`).Equal(t, err.Error())
+}
+
+func TestSearch_Scope(t *testing.T) {
+	store, err := NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store, topicEmbedder{})
+	ctx := newTestContext(t, "account")
+
+	err = store.Upsert(ctx, []Chunk{{
+		DocumentID: "other-session",
+		AccountID:  "account",
+		SessionID:  "other",
+		Text:       "A cat",
+		Embedding:  []byte("[1, 0, 0]"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.upsert(ctx, UpsertParams{Documents: []Document{{ID: "this-session", Text: "A dog"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.search(ctx, SearchParams{Query: "pets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"this-session"}).Equal(t, searchIDs(result))
+
+	result, err = s.search(ctx, SearchParams{Query: "pets", Scope: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"other-session", "this-session"}).Equal(t, searchIDs(result))
+}
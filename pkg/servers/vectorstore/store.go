@@ -0,0 +1,55 @@
+package vectorstore
+
+import (
+	"context"
+
+	"github.com/nanobot-ai/nanobot/pkg/gormdsn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Store struct {
+	// db is the database connection
+	db *gorm.DB
+}
+
+// NewStore creates a new vector store with the given database connection
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func NewStoreFromDSN(dsn string) (*Store, error) {
+	db, err := gormdsn.NewDBFromDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := NewStore(db)
+	return s, s.Init()
+}
+
+// Init initializes the vector store by migrating the schema
+func (s *Store) Init() error {
+	return s.db.AutoMigrate(&Chunk{})
+}
+
+// Upsert creates the chunks, replacing the text, metadata and embedding of chunks that already exist
+func (s *Store) Upsert(ctx context.Context, chunks []Chunk) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "document_id"}, {Name: "account_id"}, {Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"text", "metadata", "embedding", "updated_at"}),
+	}).Create(&chunks).Error
+}
+
+// FindByAccountID retrieves the chunks of all sessions of an account
+func (s *Store) FindByAccountID(ctx context.Context, accountID string) ([]Chunk, error) {
+	var chunks []Chunk
+	err := s.db.WithContext(ctx).Where("account_id = ?", accountID).Find(&chunks).Error
+	return chunks, err
+}
+
+// FindBySessionID retrieves the chunks of a session of an account
+func (s *Store) FindBySessionID(ctx context.Context, accountID, sessionID string) ([]Chunk, error) {
+	var chunks []Chunk
+	err := s.db.WithContext(ctx).Where("account_id = ? and session_id = ?", accountID, sessionID).Find(&chunks).Error
+	return chunks, err
+}
@@ -0,0 +1,28 @@
+package vectorstore
+
+import (
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Chunk is a piece of text stored with its embedding
+type Chunk struct {
+	gorm.Model
+	// DocumentID is the caller provided ID of the chunk, upserting the same ID replaces the chunk
+	DocumentID string `json:"documentID" gorm:"uniqueIndex:idx_vector_chunk;not null"`
+	// AccountID is the ID of the account that owns this chunk
+	AccountID string `json:"accountID" gorm:"uniqueIndex:idx_vector_chunk;not null"`
+	// SessionID is the ID of the session that created this chunk
+	SessionID string `json:"sessionID" gorm:"uniqueIndex:idx_vector_chunk;index"`
+	// Text is the content of the chunk
+	Text string `json:"text"`
+	// Metadata is a JSON object stored with the chunk and returned with search results
+	Metadata datatypes.JSON `json:"metadata"`
+	// Embedding is the JSON encoded embedding vector of Text
+	Embedding datatypes.JSON `json:"embedding"`
+}
+
+// TableName overrides the default table name to be "vector_chunks"
+func (Chunk) TableName() string {
+	return "vector_chunks"
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/log"
@@ -29,12 +30,32 @@ const (
 )
 
 type Data struct {
-	runtime RuntimeMeta
+	runtime     RuntimeMeta
+	concurrency int
 }
 
-func NewData(runtime RuntimeMeta) *Data {
+type Options struct {
+	// Concurrency is the number of servers whose resources are listed at once, 10 by default.
+	Concurrency int
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.Concurrency = complete.Last(o.Concurrency, other.Concurrency)
+	return
+}
+
+func (o Options) Complete() Options {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 10
+	}
+	return o
+}
+
+func NewData(runtime RuntimeMeta, opts ...Options) *Data {
+	opt := complete.Complete(opts...)
 	return &Data{
-		runtime: runtime,
+		runtime:     runtime,
+		concurrency: opt.Concurrency,
 	}
 }
 
@@ -368,6 +389,12 @@ func (d *Data) Refresh(ctx context.Context) {
 	session.Delete(currentAgentTargetSessionKey)
 }
 
+// RefreshToolMapping clears only the cached tool mapping so that the next lookup rebuilds it, leaving the
+// current agent and other mappings in place.
+func (d *Data) RefreshToolMapping(ctx context.Context) {
+	mcp.SessionFromContext(ctx).Delete(toolMappingKey)
+}
+
 func (d *Data) getPublishedMCPServers(ctx context.Context) (result []string) {
 	var (
 		c       types.Config
@@ -675,25 +702,58 @@ func (d *Data) BuildPromptMappings(ctx context.Context, refs []string) (types.Pr
 	return result, nil
 }
 
-func (d *Data) BuildResourceMappings(ctx context.Context, refs []string) (types.ResourceMappings, error) {
-	resourceMappings := types.ResourceMappings{}
-	for _, ref := range refs {
-		toolRef := types.ParseToolRef(ref)
-		if toolRef.Server == "" {
+// listServers calls list with the client of the server of each ref, for d.concurrency servers at once, and returns
+// the results in the order of the refs. The refs of servers that fail are logged as what and skipped, with a nil
+// result.
+func listServers[T any](ctx context.Context, d *Data, refs []string, what string, list func(*mcp.Client) (*T, error)) ([]types.ToolRef, []*T) {
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, d.concurrency)
+		toolRefs  = make([]types.ToolRef, len(refs))
+		results   = make([]*T, len(refs))
+	)
+	for i, ref := range refs {
+		toolRefs[i] = types.ParseToolRef(ref)
+		if toolRefs[i].Server == "" {
 			continue
 		}
 
-		c, err := d.runtime.GetClient(ctx, toolRef.Server)
-		if err != nil {
-			log.Errorf(ctx, "failed to get client for server %s while building resource mappings, skipping: %v", toolRef, err)
-			continue
-		}
-		resources, err := c.ListResources(ctx)
-		if err != nil {
-			log.Errorf(ctx, "failed to get resources for server %s while building resource mappings, skipping: %v", toolRef, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			toolRef := toolRefs[i]
+			c, err := d.runtime.GetClient(ctx, toolRef.Server)
+			if err != nil {
+				log.Errorf(ctx, "failed to get client for server %s while building %s mappings, skipping: %v", toolRef, what, err)
+				return
+			}
+			result, err := list(c)
+			if err != nil {
+				log.Errorf(ctx, "failed to get %ss for server %s while building %s mappings, skipping: %v", what, toolRef, what, err)
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return toolRefs, results
+}
+
+func (d *Data) BuildResourceMappings(ctx context.Context, refs []string) (types.ResourceMappings, error) {
+	resourceMappings := types.ResourceMappings{}
+	toolRefs, listed := listServers(ctx, d, refs, "resource", func(c *mcp.Client) (*mcp.ListResourcesResult, error) {
+		return c.ListResources(ctx)
+	})
+
+	for i, resources := range listed {
+		if resources == nil {
 			continue
 		}
-
+		toolRef := toolRefs[i]
 		for _, resource := range resources.Resources {
 			resourceMappings[toolRef.PublishedName(resource.URI)] = types.TargetMapping[mcp.Resource]{
 				MCPServer:  toolRef.Server,
@@ -708,22 +768,15 @@ func (d *Data) BuildResourceMappings(ctx context.Context, refs []string) (types.
 
 func (d *Data) BuildResourceTemplateMappings(ctx context.Context, refs []string) (types.ResourceTemplateMappings, error) {
 	resourceTemplateMappings := types.ResourceTemplateMappings{}
-	for _, ref := range refs {
-		toolRef := types.ParseToolRef(ref)
-		if toolRef.Server == "" {
-			continue
-		}
+	toolRefs, listed := listServers(ctx, d, refs, "resource template", func(c *mcp.Client) (*mcp.ListResourceTemplatesResult, error) {
+		return c.ListResourceTemplates(ctx)
+	})
 
-		c, err := d.runtime.GetClient(ctx, toolRef.Server)
-		if err != nil {
-			log.Errorf(ctx, "failed to get client for server %s while building resource template mappings, skipping: %v", toolRef, err)
+	for i, resources := range listed {
+		if resources == nil {
 			continue
 		}
-		resources, err := c.ListResourceTemplates(ctx)
-		if err != nil {
-			log.Errorf(ctx, "failed to get resource templates for server %s while building resource template mappings, skipping: %v", toolRef, err)
-		}
-
+		toolRef := toolRefs[i]
 		for _, resource := range resources.ResourceTemplates {
 			re, err := uriToRegexp(resource.URITemplate)
 			if err != nil {
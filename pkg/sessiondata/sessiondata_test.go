@@ -0,0 +1,95 @@
+package sessiondata
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// resourceServer lists its resources after delay, recording how many lists are in flight at once.
+type resourceServer struct {
+	resources []mcp.Resource
+	delay     time.Duration
+	inFlight  *atomic.Int32
+	maxFlight *atomic.Int32
+}
+
+func (r resourceServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+			return &mcp.InitializeResult{
+				ProtocolVersion: params.ProtocolVersion,
+				Capabilities: mcp.ServerCapabilities{
+					Resources: &mcp.ResourcesServerCapability{},
+				},
+			}, nil
+		})
+	case "notifications/initialized":
+	case "resources/list":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, _ mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+			n := r.inFlight.Add(1)
+			defer r.inFlight.Add(-1)
+			for {
+				current := r.maxFlight.Load()
+				if n <= current || r.maxFlight.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(r.delay)
+			return &mcp.ListResourcesResult{Resources: r.resources}, nil
+		})
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func TestBuildResourceMappings_Concurrency(t *testing.T) {
+	var inFlight, maxFlight atomic.Int32
+
+	svc := tools.NewToolsService()
+	config := types.Config{MCPServers: map[string]mcp.Server{}}
+	for name, delay := range map[string]time.Duration{
+		"first":  100 * time.Millisecond,
+		"second": 50 * time.Millisecond,
+		"third":  10 * time.Millisecond,
+	} {
+		svc.AddServer(name, func(string) mcp.MessageHandler {
+			return resourceServer{
+				resources: []mcp.Resource{{URI: "file:///" + name}, {URI: "file:///shared", Name: name}},
+				delay:     delay,
+				inFlight:  &inFlight,
+				maxFlight: &maxFlight,
+			}
+		})
+		config.MCPServers[name] = mcp.Server{}
+	}
+
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	mappings, err := NewData(svc, Options{Concurrency: 2}).BuildResourceMappings(ctx, []string{"first", "second", "third"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	autogold.Expect(int32(2)).Equal(t, maxFlight.Load())
+
+	// The resource of the last ref wins, not of the server that responded last
+	result := map[string]string{}
+	for name, mapping := range mappings {
+		result[name] = mapping.MCPServer
+	}
+	autogold.Expect(map[string]string{
+		"file:///first":  "first",
+		"file:///second": "second",
+		"file:///shared": "third",
+		"file:///third":  "third",
+	}).Equal(t, result)
+}
@@ -0,0 +1,210 @@
+package tokens
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// FamilyO200K is the tokenizer of the GPT-4o, GPT-4.1, GPT-5 and o-series models.
+	FamilyO200K = "o200k"
+	// FamilyCL100K is the tokenizer of the GPT-4, GPT-3.5 and embeddings models.
+	FamilyCL100K = "cl100k"
+	// FamilyClaude is the tokenizer of the Claude models.
+	FamilyClaude = "claude"
+
+	// lettersPerToken is the average number of letters per token of words that are too long to be a
+	// single token.
+	lettersPerToken = 8
+	// maxWordLetters is the length up to which words are counted as a single token.
+	maxWordLetters = 12
+)
+
+// Family returns the tokenizer family of the model, FamilyO200K for unknown models.
+func Family(model string) string {
+	model = strings.ToLower(model)
+	switch {
+	case strings.HasPrefix(model, "claude"):
+		return FamilyClaude
+	case strings.HasPrefix(model, "gpt-4o"), strings.HasPrefix(model, "gpt-4.1"), strings.HasPrefix(model, "gpt-4.5"):
+		return FamilyO200K
+	case strings.HasPrefix(model, "gpt-4"), strings.HasPrefix(model, "gpt-3.5"), strings.HasPrefix(model, "text-embedding"):
+		return FamilyCL100K
+	default:
+		return FamilyO200K
+	}
+}
+
+// Estimate estimates the number of tokens of text for the tokenizer of the model. It is not an exact count:
+// the text is split the way the tokenizer does before merging, and each piece is estimated from its length,
+// because the merges of the tokenizer are not known. The estimate can differ from the count of the
+// provider, most for uncommon words.
+func Estimate(model, text string) int {
+	var (
+		family = Family(model)
+		count  int
+	)
+	for _, piece := range split(family, text) {
+		count += pieceTokens(piece)
+	}
+	return count
+}
+
+// pieceTokens estimates the number of tokens of a piece of pre-tokenized text.
+func pieceTokens(piece string) int {
+	var letters, wide int
+	for _, r := range piece {
+		switch {
+		case r >= 0x2e80:
+			// CJK characters and symbols are mostly a single token each
+			wide++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	if wide > 0 {
+		return wide + (letters+lettersPerToken-1)/lettersPerToken
+	}
+	if letters <= maxWordLetters {
+		return 1
+	}
+	return (letters + lettersPerToken - 1) / lettersPerToken
+}
+
+// split pre-tokenizes text the way the tokenizers of the OpenAI models do. The Claude tokenizer is not
+// public, it is split like cl100k which gives similar counts.
+func split(family, text string) (result []string) {
+	for i := 0; i < len(text); {
+		n := nextPiece(family, text[i:])
+		result = append(result, text[i:i+n])
+		i += n
+	}
+	return result
+}
+
+// nextPiece returns the length of the first piece of text, following the alternatives of the
+// pre-tokenization pattern in order.
+func nextPiece(family, text string) int {
+	if family != FamilyO200K {
+		if n := contraction(text); n > 0 {
+			return n
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(text)
+
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	start := 0
+	if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\r' && r != '\n' {
+		if next, _ := utf8.DecodeRuneInString(text[size:]); unicode.IsLetter(next) {
+			start = size
+		}
+	}
+	if first, _ := utf8.DecodeRuneInString(text[start:]); unicode.IsLetter(first) {
+		n := start + word(family, text[start:])
+		if family == FamilyO200K {
+			n += contraction(text[n:])
+		}
+		return n
+	}
+
+	// \p{N}{1,3}
+	if unicode.IsNumber(r) {
+		n := 0
+		for digits := 0; digits < 3 && n < len(text); digits++ {
+			r, size := utf8.DecodeRuneInString(text[n:])
+			if !unicode.IsNumber(r) {
+				break
+			}
+			n += size
+		}
+		return n
+	}
+
+	// ' ?[^\s\p{L}\p{N}]+[\r\n]*', o200k also includes / in the trailing characters
+	start = 0
+	if r == ' ' {
+		start = 1
+	}
+	if n := punctuation(text[start:]); n > 0 {
+		n += start
+		for n < len(text) && (text[n] == '\r' || text[n] == '\n' || (family == FamilyO200K && text[n] == '/')) {
+			n++
+		}
+		return n
+	}
+
+	// \s*[\r\n]+
+	spaces := 0
+	for spaces < len(text) {
+		r, size := utf8.DecodeRuneInString(text[spaces:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		spaces += size
+	}
+	if newline := strings.LastIndexAny(text[:spaces], "\r\n"); newline >= 0 {
+		return newline + 1
+	}
+
+	// \s+(?!\S)|\s+, the last space before a word is the start of the word
+	if spaces > size && spaces < len(text) {
+		_, last := utf8.DecodeLastRuneInString(text[:spaces])
+		return spaces - last
+	}
+	if spaces > 0 {
+		return spaces
+	}
+
+	return size
+}
+
+// contraction returns the length of a contraction like 's and 're at the start of text, 0 if there is none.
+func contraction(text string) int {
+	if !strings.HasPrefix(text, "'") {
+		return 0
+	}
+	lower := strings.ToLower(text[1:min(len(text), 3)])
+	for _, suffix := range []string{"re", "ve", "ll", "s", "t", "m", "d"} {
+		if strings.HasPrefix(lower, suffix) {
+			return 1 + len(suffix)
+		}
+	}
+	return 0
+}
+
+// word returns the length of the letters at the start of text. o200k splits words where lower case
+// letters are followed by upper case letters, like in camel case.
+func word(family, text string) int {
+	n := 0
+	lower := false
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.M, r) {
+			break
+		}
+		if family == FamilyO200K {
+			if unicode.IsUpper(r) && lower {
+				break
+			}
+			lower = lower || unicode.IsLower(r)
+		}
+		n += size
+	}
+	return n
+}
+
+// punctuation returns the length of the characters at the start of text that are not whitespace, letters
+// or numbers.
+func punctuation(text string) int {
+	n := 0
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsNumber(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
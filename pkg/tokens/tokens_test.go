@@ -0,0 +1,54 @@
+package tokens
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestFamily(t *testing.T) {
+	autogold.Expect([]string{"o200k", "o200k", "cl100k", "cl100k", "claude", "o200k"}).Equal(t, []string{
+		Family("gpt-4o-mini"),
+		Family("gpt-4.1"),
+		Family("gpt-4-turbo"),
+		Family("text-embedding-3-small"),
+		Family("claude-sonnet-4"),
+		Family("gpt-5"),
+	})
+}
+
+func TestEstimate(t *testing.T) {
+	// The estimates match the counts of the cl100k and o200k tokenizers for these strings
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{model: "gpt-4", text: "Hello, world!", want: 4},
+		{model: "gpt-4o", text: "Hello, world!", want: 4},
+		{model: "gpt-4", text: "The quick brown fox jumps over the lazy dog.", want: 10},
+		{model: "gpt-4o", text: "The quick brown fox jumps over the lazy dog.", want: 10},
+		{model: "gpt-4", text: "I'm 12345 years old", want: 7},
+		{model: "gpt-4", text: `print("hi")` + "\n", want: 4},
+		{model: "gpt-4", text: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.model, tt.text); got != tt.want {
+			t.Errorf("Estimate(%q, %q) = %d, want %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	autogold.Expect([]string{"I", "'m", " ", "123", "45", " years", " old"}).Equal(t, split(FamilyCL100K, "I'm 12345 years old"))
+	autogold.Expect([]string{"I'm", " ", "123", "45", " years", " old"}).Equal(t, split(FamilyO200K, "I'm 12345 years old"))
+	autogold.Expect([]string{"HelloWorld"}).Equal(t, split(FamilyCL100K, "HelloWorld"))
+	autogold.Expect([]string{"Hello", "World"}).Equal(t, split(FamilyO200K, "HelloWorld"))
+	autogold.Expect([]string{"one", "\n\n", " ", " two", "  "}).Equal(t, split(FamilyCL100K, "one\n\n  two  "))
+}
+
+func TestEstimate_LongWords(t *testing.T) {
+	autogold.Expect(1).Equal(t, Estimate("gpt-4o", "programming"))
+	autogold.Expect(3).Equal(t, Estimate("gpt-4o", "internationalization"))
+	autogold.Expect(4).Equal(t, Estimate("gpt-4o", "你好世界"))
+}
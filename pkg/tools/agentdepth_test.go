@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/sampling"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// testAgentServer stands in for the nanobot.agent server, running the agent with the depth from _meta
+// like the real chat tool does.
+func testAgentServer(svc *Service, agent string) testServer {
+	return testServer{
+		calls: map[string]toolHandler{
+			types.AgentTool: func(ctx context.Context, msg mcp.Message, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				ctx = types.WithAgentDepth(ctx, types.AgentDepthFromMeta(ctx, msg.Meta()))
+				result, err := svc.Call(ctx, agent, agent, call.Arguments)
+				if err != nil {
+					return nil, err
+				}
+				return &mcp.CallToolResult{Content: result.Content}, nil
+			},
+		},
+	}
+}
+
+// loopingSampler runs every agent by having it call the agent next, forming a cycle.
+type loopingSampler struct {
+	svc      *Service
+	next     map[string]string
+	maxDepth atomic.Int64
+}
+
+func (l *loopingSampler) Sample(ctx context.Context, req mcp.CreateMessageRequest, _ ...sampling.SamplerOptions) (*types.CallResult, error) {
+	l.maxDepth.Store(max(l.maxDepth.Load(), int64(types.AgentDepth(ctx))))
+	return l.svc.Call(ctx, l.next[req.ModelPreferences.Hints[0].Name], types.AgentTool, map[string]any{"prompt": "hi"})
+}
+
+func TestCall_MaxAgentDepth(t *testing.T) {
+	svc := NewToolsService(Options{
+		MaxAgentDepth: 3,
+	})
+	svc.AddServer("nanobot.agent", func(name string) mcp.MessageHandler {
+		return testAgentServer(svc, name)
+	})
+	sampler := &loopingSampler{
+		svc:  svc,
+		next: map[string]string{"a": "b", "b": "a"},
+	}
+	svc.SetSampler(sampler)
+
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {},
+			"b": {},
+		},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	_, err := svc.Call(ctx, "a", "a", map[string]any{"prompt": "hi"})
+	autogold.Expect("error from server: JSON RPC invalid request: maximum agent call depth exceeded: calling agent b would exceed the limit of 3").Equal(t, err.Error())
+	autogold.Expect(int64(3)).Equal(t, sampler.maxDepth.Load())
+}
+
+func TestCall_MaxAgentDepthLocal(t *testing.T) {
+	svc := NewToolsService(Options{
+		MaxAgentDepth: 1,
+	})
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {},
+		},
+	}
+	ctx := types.WithAgentDepth(types.WithConfig(t.Context(), config), 1)
+
+	_, err := svc.Call(ctx, "a", "a", map[string]any{"prompt": "hi"})
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrMaxAgentDepth))
+}
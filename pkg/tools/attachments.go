@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not public but not in netip's private ranges.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// attachmentData returns the base64 encoded data and MIME type of an attachment URL. Data URIs are
+// decoded, http and https URLs are fetched if the service fetches attachments. The MIME type is empty if
+// the URL does not declare one.
+func (s *Service) attachmentData(ctx context.Context, url string) (string, string, error) {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		if !s.fetchAttachments {
+			return "", "", fmt.Errorf("invalid attachment URL: %s, fetching http(s) URLs is not enabled", url)
+		}
+		return s.fetchAttachment(ctx, url)
+	}
+	if !strings.HasPrefix(url, "data:") {
+		return "", "", fmt.Errorf("invalid attachment URL: %s, only data URI and http(s) URLs are supported", url)
+	}
+	parts := strings.Split(strings.TrimPrefix(url, "data:"), "base64,")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid attachment URL: %s, only base64 data URI are supported", url)
+	}
+	return parts[1], strings.Split(parts[0], ";")[0], nil
+}
+
+// fetchAttachment downloads the attachment at url, limited to the maximum attachment size and the fetch
+// timeout of the service. The MIME type is the Content-Type of the response, or detected from the content
+// if the server did not send a specific one.
+func (s *Service) fetchAttachment(ctx context.Context, url string) (string, string, error) {
+	if s.attachmentFetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.attachmentFetchTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid attachment URL %s: %w", url, err)
+	}
+	resp, err := s.attachmentClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch attachment %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to fetch attachment %s: %s", url, resp.Status)
+	}
+	if s.maxAttachmentSize > 0 && resp.ContentLength > s.maxAttachmentSize {
+		return "", "", fmt.Errorf("attachment %s is %d bytes, larger than the maximum of %d bytes", url, resp.ContentLength, s.maxAttachmentSize)
+	}
+
+	body := io.Reader(resp.Body)
+	if s.maxAttachmentSize > 0 {
+		body = io.LimitReader(resp.Body, s.maxAttachmentSize+1)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read attachment %s: %w", url, err)
+	}
+	if s.maxAttachmentSize > 0 && int64(len(raw)) > s.maxAttachmentSize {
+		return "", "", fmt.Errorf("attachment %s is larger than the maximum of %d bytes", url, s.maxAttachmentSize)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(raw))
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), mimeType, nil
+}
+
+// publicAddress returns true for the addresses attachments can be fetched from, which excludes loopback,
+// link-local, private and other internal addresses such as the cloud metadata services.
+func publicAddress(addr netip.AddrPort) bool {
+	ip := addr.Addr().Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// newAttachmentClient returns the client that fetches attachments. The addresses are checked when connecting,
+// after the host is resolved, so a host can not resolve to an internal address. Every redirect connects again
+// and is checked the same way.
+func newAttachmentClient(allowed func(netip.AddrPort) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(addr) {
+				return fmt.Errorf("%w: %s", ErrAttachmentAddressNotAllowed, addr.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect to the attachment for us, bypassing the check of the address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported URL %s", req.URL)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// ResultCache stores the results of read-only tool calls, see CallOptions.CacheTTL. It must be safe for
+// concurrent use, so it can be shared, for example by a Redis-backed implementation.
+type ResultCache interface {
+	// Get returns the result stored under key, false if there is none or it expired.
+	Get(ctx context.Context, key string) (*types.CallResult, bool, error)
+	// Set stores the result under key until ttl has passed.
+	Set(ctx context.Context, key string, result *types.CallResult, ttl time.Duration) error
+}
+
+type cachedResult struct {
+	result  *types.CallResult
+	expires time.Time
+}
+
+// MemoryResultCache is the in-memory ResultCache used by default.
+type MemoryResultCache struct {
+	lock    sync.Mutex
+	results map[string]cachedResult
+}
+
+func NewMemoryResultCache() *MemoryResultCache {
+	return &MemoryResultCache{
+		results: map[string]cachedResult{},
+	}
+}
+
+func (m *MemoryResultCache) Get(_ context.Context, key string) (*types.CallResult, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	cached, ok := m.results[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !time.Now().Before(cached.expires) {
+		delete(m.results, key)
+		return nil, false, nil
+	}
+	return cached.result, true, nil
+}
+
+func (m *MemoryResultCache) Set(_ context.Context, key string, result *types.CallResult, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	for k, cached := range m.results {
+		if !now.Before(cached.expires) {
+			delete(m.results, k)
+		}
+	}
+	m.results[key] = cachedResult{
+		result:  result,
+		expires: now.Add(ttl),
+	}
+	return nil
+}
+
+// resultCacheKey hashes the server, tool and arguments of a call, and who makes it: the account, or the session
+// if there is no account, and the config and env the server is started with. Results are so never shared
+// between users or with a server configured differently. Maps are marshaled with sorted keys, so equal
+// arguments have the same key.
+func resultCacheKey(ctx context.Context, config types.Config, server, tool string, args any) (string, error) {
+	sessionID, accountID := types.GetSessionAndAccountID(ctx)
+	if accountID != "" {
+		sessionID = ""
+	}
+	data, err := json.Marshal([]any{
+		accountID,
+		sessionID,
+		config.MCPServers[server],
+		mcp.SessionFromContext(ctx).GetEnvMap(),
+		server,
+		tool,
+		args,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal arguments of %s/%s: %w", server, tool, err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// readOnlyTool returns true if the tool is annotated as read-only. The target of the call options is used
+// if it is the tool, otherwise the tool is looked up.
+func (s *Service) readOnlyTool(ctx context.Context, config types.Config, server, tool string, target any) bool {
+	var annotations *mcp.ToolAnnotations
+	switch t := target.(type) {
+	case mcp.Tool:
+		annotations = t.Annotations
+	case types.TargetTool:
+		annotations = t.Annotations
+	default:
+		found, err := s.getTarget(ctx, config, server, tool)
+		if err != nil {
+			return false
+		}
+		mcpTool, ok := found.(mcp.Tool)
+		if !ok {
+			return false
+		}
+		annotations = mcpTool.Annotations
+	}
+	return annotations != nil && annotations.ReadOnlyHint
+}
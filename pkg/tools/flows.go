@@ -79,7 +79,9 @@ func (s *Service) newGlobals(ctx context.Context, vars map[string]any, opt ...Ca
 
 func (s *Service) callFromScript(ctx context.Context, target string, args any, opt CallOptions) (map[string]any, error) {
 	server, tool, _ := strings.Cut(target, "/")
-	ret, err := s.Call(ctx, server, tool, args, opt)
+	ret, err := s.Call(ctx, server, tool, args, opt, CallOptions{
+		ParseJSONContent: true,
+	})
 	if err != nil {
 		return nil, err
 	}
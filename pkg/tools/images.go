@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	// Register the GIF decoder so GIF attachments can be downscaled too.
+	_ "image/gif"
+)
+
+// maxImagePixels is the largest number of pixels of an image that is decoded to be downscaled. The
+// dimensions of an image are declared in its header, so a small file can claim dimensions that take
+// gigabytes to decode.
+const maxImagePixels = 50_000_000
+
+// downscaleImage scales the base64 encoded image down so neither side is larger than maxDimension,
+// keeping the aspect ratio. PNG images stay PNG, everything else is encoded as JPEG with the given
+// quality. Images that are small enough, or formats that can not be decoded, are returned unchanged.
+func downscaleImage(data, mimeType string, maxDimension, quality int) (string, string, error) {
+	if maxDimension <= 0 {
+		return data, mimeType, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || (config.Width <= maxDimension && config.Height <= maxDimension) {
+		return data, mimeType, nil
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return "", "", fmt.Errorf("%s image of %dx%d pixels is larger than the limit of %d pixels", format,
+			config.Width, config.Height, maxImagePixels)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode %s image: %w", format, err)
+	}
+
+	width, height := config.Width, config.Height
+	if width >= height {
+		height = max(1, height*maxDimension/width)
+		width = maxDimension
+	} else {
+		width = max(1, width*maxDimension/height)
+		height = maxDimension
+	}
+
+	var (
+		dst = resizeImage(src, width, height)
+		buf bytes.Buffer
+	)
+	if format == "png" {
+		mimeType = "image/png"
+		err = png.Encode(&buf, dst)
+	} else {
+		mimeType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode downscaled image: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), mimeType, nil
+}
+
+// resizeImage scales src down to width x height, averaging the source pixels that cover each
+// destination pixel.
+func resizeImage(src image.Image, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(in, in.Bounds(), src, bounds.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*bounds.Dy()/height, max((y+1)*bounds.Dy()/height, y*bounds.Dy()/height+1)
+		for x := range width {
+			x0, x1 := x*bounds.Dx()/width, max((x+1)*bounds.Dx()/width, x*bounds.Dx()/width+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := in.NRGBAAt(sx, sy)
+					r += int(c.R)
+					g += int(c.G)
+					b += int(c.B)
+					a += int(c.A)
+					n++
+				}
+			}
+			out.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(b / n),
+				A: uint8(a / n),
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func testImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func imageAttachment(t *testing.T, s *Service, mimeType, data string) (string, image.Config) {
+	t.Helper()
+	req, err := s.convertToSampleRequest(t.Context(), types.Config{}, "a", map[string]any{
+		"attachments": []any{
+			map[string]any{"url": "data:" + mimeType + ";base64," + data},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := req.Messages[0].Content[0]
+	raw, err := base64.StdEncoding.DecodeString(content.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content.MIMEType, config
+}
+
+func TestConvertToSampleRequest_DownscaleImage(t *testing.T) {
+	s := NewToolsService(Options{MaxImageDimension: 100})
+
+	mimeType, config := imageAttachment(t, s, "image/jpeg", testImage(t, 400, 200, func(buf *bytes.Buffer, img image.Image) error {
+		return jpeg.Encode(buf, img, nil)
+	}))
+	autogold.Expect("image/jpeg").Equal(t, mimeType)
+	autogold.Expect([]int{100, 50}).Equal(t, []int{config.Width, config.Height})
+
+	mimeType, config = imageAttachment(t, s, "image/png", testImage(t, 150, 300, func(buf *bytes.Buffer, img image.Image) error {
+		return png.Encode(buf, img)
+	}))
+	autogold.Expect("image/png").Equal(t, mimeType)
+	autogold.Expect([]int{50, 100}).Equal(t, []int{config.Width, config.Height})
+}
+
+func TestConvertToSampleRequest_SmallImageUntouched(t *testing.T) {
+	data := testImage(t, 80, 60, func(buf *bytes.Buffer, img image.Image) error {
+		return png.Encode(buf, img)
+	})
+
+	for _, s := range []*Service{
+		NewToolsService(Options{MaxImageDimension: 100}),
+		NewToolsService(),
+	} {
+		req, err := s.convertToSampleRequest(t.Context(), types.Config{}, "a", map[string]any{
+			"attachments": []any{
+				map[string]any{"url": "data:image/png;base64," + data},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		autogold.Expect(true).Equal(t, req.Messages[0].Content[0].Data == data)
+	}
+}
+
+func TestConvertToSampleRequest_DownscaleDisabled(t *testing.T) {
+	data := testImage(t, 400, 200, func(buf *bytes.Buffer, img image.Image) error {
+		return png.Encode(buf, img)
+	})
+	_, config := imageAttachment(t, NewToolsService(), "image/png", data)
+	autogold.Expect([]int{400, 200}).Equal(t, []int{config.Width, config.Height})
+}
+
+func TestConvertToSampleRequest_ImageTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Declare 100000x100000 pixels in the IHDR chunk of the small PNG
+	raw := buf.Bytes()
+	binary.BigEndian.PutUint32(raw[16:], 100000)
+	binary.BigEndian.PutUint32(raw[20:], 100000)
+	binary.BigEndian.PutUint32(raw[29:], crc32.ChecksumIEEE(raw[12:29]))
+
+	_, err := NewToolsService(Options{MaxImageDimension: 100}).convertToSampleRequest(t.Context(), types.Config{}, "a", map[string]any{
+		"attachments": []any{
+			map[string]any{"name": "huge.png", "url": "data:image/png;base64," + base64.StdEncoding.EncodeToString(raw)},
+		},
+	})
+	autogold.Expect("invalid image attachment huge.png: png image of 100000x100000 pixels is larger than the limit of 50000000 pixels").Equal(t, err.Error())
+}
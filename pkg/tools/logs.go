@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	logsSessionKey     = "logs"
+	logLevelSessionKey = "logLevel/"
+	// maxSessionLogs is the number of log messages kept per session, older messages are dropped first.
+	maxSessionLogs = 1000
+)
+
+var logsLock sync.Mutex
+
+type sessionLogs struct {
+	lock    sync.Mutex
+	entries []mcp.LoggingMessage
+}
+
+// recordLog adds a log message to the logs of the root session and notifies subscribers of types.LogsURI.
+func recordLog(ctx context.Context, session *mcp.Session, msg mcp.LoggingMessage) {
+	session = session.Root()
+	if session == nil {
+		return
+	}
+
+	logsLock.Lock()
+	var logs *sessionLogs
+	if !session.Get(logsSessionKey, &logs) {
+		logs = &sessionLogs{}
+		session.Set(logsSessionKey, logs)
+	}
+	logsLock.Unlock()
+
+	logs.lock.Lock()
+	logs.entries = append(logs.entries, msg)
+	if len(logs.entries) > maxSessionLogs {
+		logs.entries = slices.Delete(logs.entries, 0, len(logs.entries)-maxSessionLogs)
+	}
+	logs.lock.Unlock()
+
+	_ = session.SendPayload(ctx, "notifications/resources/updated", map[string]any{
+		"uri": types.LogsURI,
+	})
+}
+
+// SessionLogs returns the log messages the MCP servers of the session in ctx have sent, oldest first.
+func SessionLogs(ctx context.Context) []mcp.LoggingMessage {
+	var logs *sessionLogs
+	if !mcp.SessionFromContext(ctx).Root().Get(logsSessionKey, &logs) {
+		return nil
+	}
+
+	logs.lock.Lock()
+	defer logs.lock.Unlock()
+	return slices.Clone(logs.entries)
+}
+
+// SetLogLevel sets the minimum level of the log messages forwarded from server for the session in ctx and
+// sends it to the server with logging/setLevel. It takes precedence over the logLevel in the config.
+func (s *Service) SetLogLevel(ctx context.Context, server, level string) error {
+	if !mcp.IsLogLevel(level) {
+		return fmt.Errorf("invalid log level %q, must be one of %s", level, strings.Join(mcp.LogLevels, ", "))
+	}
+
+	mcp.SessionFromContext(ctx).Root().Set(logLevelSessionKey+server, level)
+
+	c, err := s.GetClient(ctx, server)
+	if err != nil {
+		return fmt.Errorf("failed to get client for %s: %w", server, err)
+	}
+
+	if err := c.SetLogLevel(ctx, level); err != nil {
+		return fmt.Errorf("failed to set log level for %s: %w", server, err)
+	}
+	return nil
+}
+
+// logLevel returns the minimum level of the log messages forwarded from server, an empty string if all
+// messages are forwarded.
+func logLevel(session *mcp.Session, server string, mcpConfig mcp.Server) string {
+	var level string
+	if session.Root().Get(logLevelSessionKey+server, &level) {
+		return level
+	}
+	return mcpConfig.LogLevel
+}
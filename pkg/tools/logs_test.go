@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// loggingServer sends a log message for calls of its "a" and "b" tools, or one message per level for the
+// "levels" tool. The levels the client sets are sent to levels.
+func loggingServer(levels chan string) testServer {
+	logs := func(ctx context.Context, _ mcp.Message, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		levels := []string{"info"}
+		if call.Name == "levels" {
+			levels = []string{"debug", "info", "warning", "error"}
+		}
+		for _, level := range levels {
+			err := mcp.SessionFromContext(ctx).SendPayload(ctx, "notifications/message", mcp.LoggingMessage{
+				Level:  level,
+				Logger: "test",
+				Data:   "called " + call.Name,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "ok"}}}, nil
+	}
+	return testServer{
+		calls: map[string]toolHandler{
+			"a":      logs,
+			"b":      logs,
+			"levels": logs,
+		},
+		setLogLevel: func(level string) {
+			levels <- level
+		},
+	}
+}
+
+func TestSessionLogs(t *testing.T) {
+	svc := NewToolsService(Options{})
+	svc.AddServer("logger", func(string) mcp.MessageHandler {
+		return loggingServer(make(chan string, 10))
+	})
+
+	config := types.Config{}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	updated := make(chan string, 10)
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		if msg.Method == "notifications/resources/updated" {
+			var params struct {
+				URI string `json:"uri"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			updated <- params.URI
+		}
+		return msg, nil
+	})
+
+	autogold.Expect([]mcp.LoggingMessage(nil)).Equal(t, SessionLogs(ctx))
+
+	for _, tool := range []string{"a", "b"} {
+		if _, err := svc.Call(ctx, "logger", tool, nil); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case uri := <-updated:
+			autogold.Expect("nanobot://logs").Equal(t, uri)
+		case <-t.Context().Done():
+			t.Fatal("no resource update")
+		}
+	}
+
+	autogold.Expect([]mcp.LoggingMessage{
+		{
+			Level:  "info",
+			Logger: "test",
+			Data: map[string]any{
+				"data":   "called a",
+				"server": "logger",
+			},
+		},
+		{
+			Level:  "info",
+			Logger: "test",
+			Data: map[string]any{
+				"data":   "called b",
+				"server": "logger",
+			},
+		},
+	}).Equal(t, SessionLogs(ctx))
+}
+
+func TestSessionLogs_Limit(t *testing.T) {
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(t.Context(), session)
+
+	for i := range maxSessionLogs + 5 {
+		recordLog(ctx, session, mcp.LoggingMessage{Level: "info", Data: float64(i)})
+	}
+
+	logs := SessionLogs(ctx)
+	autogold.Expect(maxSessionLogs).Equal(t, len(logs))
+	autogold.Expect(float64(5)).Equal(t, logs[0].Data)
+}
+
+func TestSessionLogs_LogLevel(t *testing.T) {
+	levels := make(chan string, 10)
+	svc := NewToolsService(Options{})
+	svc.AddServer("logger", func(string) mcp.MessageHandler {
+		return loggingServer(levels)
+	})
+
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{
+			"logger": {
+				LogLevel: "warning",
+			},
+		},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	session.Set(types.ConfigSessionKey, config)
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	logLevels := func() (result []string) {
+		for _, log := range SessionLogs(ctx) {
+			result = append(result, log.Level)
+		}
+		return result
+	}
+
+	if _, err := svc.Call(ctx, "logger", "levels", nil); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("warning").Equal(t, <-levels)
+	autogold.Expect([]string{"warning", "error"}).Equal(t, logLevels())
+
+	if err := svc.SetLogLevel(ctx, "logger", "error"); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("error").Equal(t, <-levels)
+
+	if _, err := svc.Call(ctx, "logger", "levels", nil); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"warning", "error", "error"}).Equal(t, logLevels())
+
+	err := svc.SetLogLevel(ctx, "logger", "verbose")
+	autogold.Expect(`invalid log level "verbose", must be one of debug, info, notice, warning, error, critical, alert, emergency`).Equal(t, err.Error())
+}
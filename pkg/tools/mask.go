@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// maskedArgument replaces the value of a sensitive argument in progress notifications.
+const maskedArgument = "********"
+
+// maskArguments returns the JSON arguments of a call with the values of sensitive fields replaced, so they
+// aren't broadcast in progress notifications. A field is sensitive if its name matches one of the
+// Options.SensitiveArguments patterns, or the input schema of the tool marks it with "writeOnly": true,
+// "format": "password" or "x-sensitive": true. The arguments are returned as is if nothing is masked.
+func (s *Service) maskArguments(ctx context.Context, config types.Config, server, tool string, target any, arguments string) string {
+	if !strings.HasPrefix(strings.TrimSpace(arguments), "{") {
+		return arguments
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal([]byte(arguments), &data); err != nil {
+		return arguments
+	}
+
+	var schema map[string]any
+	if inputSchema := s.inputSchema(ctx, config, server, tool, target); len(inputSchema) > 0 {
+		// An invalid schema marks nothing, the patterns still apply
+		_ = json.Unmarshal(inputSchema, &schema)
+	}
+
+	if !s.maskValue(data, schema) {
+		return arguments
+	}
+
+	masked, err := json.Marshal(data)
+	if err != nil {
+		return arguments
+	}
+	return string(masked)
+}
+
+// ArgumentMasker returns a progress.ArgumentMasker for the calls of the tools of the mappings that a model
+// streams, which masks their arguments like the arguments of the progress of the calls.
+func (s *Service) ArgumentMasker(config types.Config, mappings types.ToolMappings) progress.ArgumentMasker {
+	return func(ctx context.Context, name, arguments string) string {
+		mapping, ok := mappings[name]
+		if !ok {
+			// Only the patterns apply to a tool that is not known
+			return s.maskArguments(ctx, config, "", name, mcp.Tool{}, arguments)
+		}
+		return s.maskArguments(ctx, config, mapping.MCPServer, mapping.TargetName, mapping.Target, arguments)
+	}
+}
+
+// maskValue masks the sensitive fields of the value in place and returns true if any were masked.
+func (s *Service) maskValue(value any, schema map[string]any) (masked bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for name, field := range v {
+			fieldSchema, _ := properties[name].(map[string]any)
+			if s.sensitiveArgument(name) || sensitiveSchema(fieldSchema) {
+				v[name] = maskedArgument
+				masked = true
+				continue
+			}
+			masked = s.maskValue(field, fieldSchema) || masked
+		}
+	case []any:
+		items, _ := schema["items"].(map[string]any)
+		for _, item := range v {
+			masked = s.maskValue(item, items) || masked
+		}
+	}
+	return masked
+}
+
+// sensitiveArgument returns true if the name matches one of the sensitive argument patterns, ignoring case.
+func (s *Service) sensitiveArgument(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range s.sensitiveArguments {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sensitiveSchema(schema map[string]any) bool {
+	writeOnly, _ := schema["writeOnly"].(bool)
+	sensitive, _ := schema["x-sensitive"].(bool)
+	return writeOnly || sensitive || schema["format"] == "password"
+}
+
+// inputSchema returns the input schema of the tool. The target of the call options is used if it is the
+// tool, otherwise the tool is looked up.
+func (s *Service) inputSchema(ctx context.Context, config types.Config, server, tool string, target any) json.RawMessage {
+	switch t := target.(type) {
+	case mcp.Tool:
+		return t.InputSchema
+	case types.TargetTool:
+		return t.InputSchema
+	}
+	if _, ok := config.Agents[server]; ok {
+		return nil
+	}
+	found, err := s.getTarget(ctx, config, server, tool)
+	if err != nil {
+		return nil
+	}
+	mcpTool, _ := found.(mcp.Tool)
+	return mcpTool.InputSchema
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	// ResultFormatText returns results as text content only, structured content is converted to indented
+	// JSON text.
+	ResultFormatText = "text"
+	// ResultFormatStructured returns results with structured content, parsed from JSON text content if
+	// the tool did not return any.
+	ResultFormatStructured = "structured"
+)
+
+func validResultFormat(format string) error {
+	switch format {
+	case "", ResultFormatText, ResultFormatStructured:
+		return nil
+	}
+	return fmt.Errorf("invalid result format %q, must be %s or %s", format, ResultFormatText, ResultFormatStructured)
+}
+
+// formatResult shapes the result for the preferred format, see CallOptions.ResultFormat. Other content, like
+// images and resource links, is kept as is.
+func formatResult(ret *types.CallResult, format string) {
+	switch format {
+	case ResultFormatText:
+		if ret.StructuredContent == nil {
+			return
+		}
+		hasText := false
+		for _, c := range ret.Content {
+			hasText = hasText || c.Type == "text"
+		}
+		if !hasText {
+			data, err := json.MarshalIndent(ret.StructuredContent, "", "  ")
+			if err != nil {
+				return
+			}
+			ret.Content = append([]mcp.Content{{Type: "text", Text: string(data)}}, ret.Content...)
+		}
+		ret.StructuredContent = nil
+	case ResultFormatStructured:
+		parseJSONContent(ret)
+	}
+}
+
+// parseJSONContent sets the structured content of a result without one to its text content, if the result
+// has a single text content that is JSON.
+func parseJSONContent(ret *types.CallResult) {
+	if ret.StructuredContent == nil && len(ret.Content) == 1 && ret.Content[0].Text != "" {
+		var obj any
+		if err := json.Unmarshal([]byte(ret.Content[0].Text), &obj); err == nil {
+			ret.StructuredContent = obj
+		}
+	}
+}
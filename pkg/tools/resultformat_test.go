@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestFormatResult(t *testing.T) {
+	format := func(result types.CallResult, format string) types.CallResult {
+		formatResult(&result, format)
+		return result
+	}
+
+	structured := types.CallResult{
+		StructuredContent: map[string]any{"answer": 42},
+		Content:           []mcp.Content{{Type: "image", Data: "aW1hZ2U=", MIMEType: "image/png"}},
+	}
+	autogold.Expect(structured).Equal(t, format(structured, ""))
+	autogold.Expect(types.CallResult{Content: []mcp.Content{
+		{
+			Type: "text",
+			Text: `{
+  "answer": 42
+}`,
+		},
+		{
+			Type:     "image",
+			Data:     "aW1hZ2U=",
+			MIMEType: "image/png",
+		},
+	}}).Equal(t, format(structured, ResultFormatText))
+	autogold.Expect(structured).Equal(t, format(structured, ResultFormatStructured))
+
+	// Text content that is already there is not duplicated
+	both := types.CallResult{
+		StructuredContent: map[string]any{"answer": 42},
+		Content:           []mcp.Content{{Type: "text", Text: `{"answer":42}`}},
+	}
+	autogold.Expect(types.CallResult{Content: []mcp.Content{{Type: "text", Text: `{"answer":42}`}}}).Equal(t, format(both, ResultFormatText))
+
+	text := types.CallResult{Content: []mcp.Content{{Type: "text", Text: `{"answer": 42}`}}}
+	autogold.Expect(text).Equal(t, format(text, ResultFormatText))
+	autogold.Expect(types.CallResult{
+		StructuredContent: map[string]any{"answer": 42.0},
+		Content:           []mcp.Content{{Type: "text", Text: `{"answer": 42}`}},
+	}).Equal(t, format(text, ResultFormatStructured))
+}
+
+func TestCall_ResultFormat(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	result, err := svc.Call(ctx, "counting", "echo", map[string]any{"text": "[1, 2]"}, CallOptions{ResultFormat: ResultFormatStructured})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]any{1.0, 2.0}).Equal(t, result.StructuredContent)
+
+	_, err = svc.Call(ctx, "counting", "echo", map[string]any{"text": "[1, 2]"}, CallOptions{ResultFormat: "xml"})
+	autogold.Expect(`invalid result format "xml", must be text or structured`).Equal(t, err.Error())
+}
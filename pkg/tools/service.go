@@ -16,11 +16,18 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/envvar"
 	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/sampling"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Service struct {
@@ -36,8 +43,33 @@ type Service struct {
 	tokenExchangeClientID     string
 	tokenExchangeClientSecret string
 	auditLogCollector         *auditlogs.Collector
+	maxAgentDepth             int
+	maxImageDimension         int
+	imageQuality              int
+	maxAttachmentSize         int64
+	attachmentFetchTimeout    time.Duration
+	fetchAttachments          bool
+	attachmentClient          *http.Client
+	resultCache               ResultCache
+	tracer                    trace.Tracer
+	sensitiveArguments        []string
+	maxProgressSize           int
+	webhookSecret             string
+	webhookTimeout            time.Duration
+	hookRunnerLock            sync.Mutex
 }
 
+var (
+	// ErrMaxAgentDepth is returned when an agent call would go deeper than Options.MaxAgentDepth.
+	ErrMaxAgentDepth = errors.New("maximum agent call depth exceeded")
+	// ErrMimeTypeNotAllowed is returned for an attachment whose type is not in the agent's mimeTypes.
+	ErrMimeTypeNotAllowed = errors.New("attachment type not allowed")
+	// ErrAttachmentAddressNotAllowed is returned for an attachment URL that is not on a public address.
+	ErrAttachmentAddressNotAllowed = errors.New("attachment address not allowed")
+	// ErrToolCallTimeout is returned for a tool call that did not finish within CallOptions.Timeout.
+	ErrToolCallTimeout = errors.New("tool call timed out")
+)
+
 type Sampler interface {
 	Sample(ctx context.Context, sampling mcp.CreateMessageRequest, opts ...sampling.SamplerOptions) (*types.CallResult, error)
 }
@@ -52,6 +84,34 @@ type Options struct {
 	TokenExchangeClientID     string
 	TokenExchangeClientSecret string
 	AuditLogCollector         *auditlogs.Collector
+	// MaxAgentDepth limits how deep agents can call other agents, defaults to 10.
+	MaxAgentDepth int
+	// MaxImageDimension downscales image attachments so neither side is larger than this many pixels.
+	// Zero, the default, sends images as they are.
+	MaxImageDimension int
+	// ImageQuality is the JPEG quality of downscaled images, defaults to 85.
+	ImageQuality int
+	// MaxAttachmentSize is the largest http(s) attachment in bytes that is fetched, defaults to 20 MiB.
+	MaxAttachmentSize int64
+	// AttachmentFetchTimeout bounds fetching an http(s) attachment, defaults to 30 seconds.
+	AttachmentFetchTimeout time.Duration
+	// FetchAttachments enables http(s) attachment URLs, which are fetched from public addresses only. Only
+	// data URIs are accepted otherwise.
+	FetchAttachments bool
+	// ResultCache stores the results of calls with CallOptions.CacheTTL, defaults to an in-memory cache.
+	ResultCache ResultCache
+	// TracerProvider records spans of tool calls and client creation. Nothing is recorded if it is nil.
+	TracerProvider trace.TracerProvider
+	// SensitiveArguments are glob patterns of argument names, matched ignoring case, whose values are
+	// masked in progress notifications. Arguments marked sensitive by the tool's schema are always masked.
+	SensitiveArguments []string
+	// MaxProgressSize limits the size in bytes of each progress notification of a tool call, see
+	// progress.WithMaxSize. Zero is no limit.
+	MaxProgressSize int
+	// WebhookSecret signs the requests of webhook hooks. They are not signed if it is empty.
+	WebhookSecret string
+	// WebhookTimeout bounds a webhook hook, defaults to mcp.DefaultWebhookTimeout.
+	WebhookTimeout time.Duration
 }
 
 func (r Options) Merge(other Options) (result Options) {
@@ -64,6 +124,18 @@ func (r Options) Merge(other Options) (result Options) {
 	result.TokenExchangeClientID = complete.Last(r.TokenExchangeClientID, other.TokenExchangeClientID)
 	result.TokenExchangeClientSecret = complete.Last(r.TokenExchangeClientSecret, other.TokenExchangeClientSecret)
 	result.AuditLogCollector = complete.Last(r.AuditLogCollector, other.AuditLogCollector)
+	result.MaxAgentDepth = complete.Last(r.MaxAgentDepth, other.MaxAgentDepth)
+	result.MaxImageDimension = complete.Last(r.MaxImageDimension, other.MaxImageDimension)
+	result.ImageQuality = complete.Last(r.ImageQuality, other.ImageQuality)
+	result.MaxAttachmentSize = complete.Last(r.MaxAttachmentSize, other.MaxAttachmentSize)
+	result.AttachmentFetchTimeout = complete.Last(r.AttachmentFetchTimeout, other.AttachmentFetchTimeout)
+	result.FetchAttachments = complete.Last(r.FetchAttachments, other.FetchAttachments)
+	result.ResultCache = complete.Last(r.ResultCache, other.ResultCache)
+	result.TracerProvider = complete.Last(r.TracerProvider, other.TracerProvider)
+	result.SensitiveArguments = append(r.SensitiveArguments, other.SensitiveArguments...)
+	result.MaxProgressSize = complete.Last(r.MaxProgressSize, other.MaxProgressSize)
+	result.WebhookSecret = complete.Last(r.WebhookSecret, other.WebhookSecret)
+	result.WebhookTimeout = complete.Last(r.WebhookTimeout, other.WebhookTimeout)
 	return result
 }
 
@@ -71,6 +143,21 @@ func (r Options) Complete() Options {
 	if r.Concurrency == 0 {
 		r.Concurrency = 10
 	}
+	if r.MaxAgentDepth == 0 {
+		r.MaxAgentDepth = 10
+	}
+	if r.ImageQuality == 0 {
+		r.ImageQuality = 85
+	}
+	if r.MaxAttachmentSize == 0 {
+		r.MaxAttachmentSize = 20 << 20
+	}
+	if r.AttachmentFetchTimeout == 0 {
+		r.AttachmentFetchTimeout = 30 * time.Second
+	}
+	if r.ResultCache == nil {
+		r.ResultCache = NewMemoryResultCache()
+	}
 	return r
 }
 
@@ -86,6 +173,19 @@ func NewToolsService(opts ...Options) *Service {
 		tokenExchangeClientID:     opt.TokenExchangeClientID,
 		tokenExchangeClientSecret: opt.TokenExchangeClientSecret,
 		auditLogCollector:         opt.AuditLogCollector,
+		maxAgentDepth:             opt.MaxAgentDepth,
+		maxImageDimension:         opt.MaxImageDimension,
+		imageQuality:              opt.ImageQuality,
+		maxAttachmentSize:         opt.MaxAttachmentSize,
+		attachmentFetchTimeout:    opt.AttachmentFetchTimeout,
+		fetchAttachments:          opt.FetchAttachments,
+		attachmentClient:          newAttachmentClient(publicAddress),
+		resultCache:               opt.ResultCache,
+		tracer:                    tracing.Tracer(opt.TracerProvider, "github.com/nanobot-ai/nanobot/pkg/tools"),
+		sensitiveArguments:        opt.SensitiveArguments,
+		maxProgressSize:           opt.MaxProgressSize,
+		webhookSecret:             opt.WebhookSecret,
+		webhookTimeout:            opt.WebhookTimeout,
 	}
 }
 
@@ -228,7 +328,14 @@ func (c *clientFactory) get() (*mcp.Client, error) {
 }
 
 func (c *clientFactory) Serialize() (any, error) {
-	if c.client == nil || c.client.Session.ID() == "" {
+	if c.client == nil {
+		// Keep the state of a resumed session that was not used yet, so the last event ID is not lost.
+		if c.oldState != nil && c.oldState.ID != "" {
+			return c.oldState, nil
+		}
+		return nil, nil
+	}
+	if c.client.Session.ID() == "" {
 		return nil, nil
 	}
 	return c.client.Session.State()
@@ -256,14 +363,25 @@ func (c *clientFactory) Deserialize(data any) (_ any, err error) {
 	}, nil
 }
 
-func (s *Service) GetClient(ctx context.Context, name string) (*mcp.Client, error) {
-	session := mcp.SessionFromContext(ctx)
+// startSpan starts a child span of the span in ctx, see Options.TracerProvider.
+func (s *Service) startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := s.tracer
+	if tracer == nil {
+		tracer = tracing.Tracer(nil, "")
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+func (s *Service) GetClient(ctx context.Context, name string) (_ *mcp.Client, err error) {
+	ctx, span := s.startSpan(ctx, "get client "+name, attribute.String("mcp.server", name))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	session := mcp.SessionFromContext(ctx).Root()
 	if session == nil {
 		return nil, fmt.Errorf("session not found in context")
 	}
-	for session.Parent != nil {
-		session = session.Parent
-	}
 
 	sessionKey := "clients/" + name
 	factory := newClientFactory(func(state *mcp.SessionState) (*mcp.Client, error) {
@@ -279,18 +397,23 @@ func (s *Service) GetClient(ctx context.Context, name string) (*mcp.Client, erro
 	return factory.get()
 }
 
+// setHookRunner makes the service the hook runner of the session, if it has none yet. Clients of different
+// servers can be created concurrently, like when their resources are listed.
+func (s *Service) setHookRunner(session *mcp.Session) {
+	s.hookRunnerLock.Lock()
+	defer s.hookRunnerLock.Unlock()
+	if session.HookRunner == nil {
+		session.HookRunner = s
+	}
+}
+
 func (s *Service) newClient(ctx context.Context, name string, state *mcp.SessionState) (*mcp.Client, error) {
-	session := mcp.SessionFromContext(ctx)
+	session := mcp.SessionFromContext(ctx).Root()
 	if session == nil {
 		return nil, fmt.Errorf("session not found in context")
 	}
-	for session.Parent != nil {
-		session = session.Parent
-	}
 
-	if session.HookRunner == nil {
-		session.HookRunner = s
-	}
+	s.setHookRunner(session)
 
 	config := types.ConfigFromContext(ctx)
 
@@ -394,14 +517,21 @@ func (s *Service) newClient(ctx context.Context, name string, state *mcp.Session
 			return session.Send(mcp.WithMCPServerConfig(mcp.WithAuditLog(ctx, auditLog), mcpConfig), msg)
 		},
 		OnLogging: func(ctx context.Context, logMsg mcp.LoggingMessage) (err error) {
-			data, err := json.Marshal(mcp.LoggingMessage{
+			if !mcp.LogLevelEnabled(logMsg.Level, logLevel(session, name, config.MCPServers[name])) {
+				return nil
+			}
+
+			logMsg = mcp.LoggingMessage{
 				Level:  logMsg.Level,
 				Logger: logMsg.Logger,
 				Data: map[string]any{
 					"server": name,
 					"data":   logMsg.Data,
 				},
-			})
+			}
+			recordLog(ctx, session, logMsg)
+
+			data, err := json.Marshal(logMsg)
 			if err != nil {
 				return fmt.Errorf("failed to marshal logging message: %w", err)
 			}
@@ -533,12 +663,28 @@ func (s *Service) newClient(ctx context.Context, name string, state *mcp.Session
 	}
 	sessionCtx = mcp.WithAuditLog(sessionCtx, mcp.AuditLogFromContext(ctx))
 
-	return mcp.NewClient(sessionCtx, name, mcpConfig, clientOpts)
+	c, err := mcp.NewClient(sessionCtx, name, mcpConfig, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if level := logLevel(session, name, config.MCPServers[name]); level != "" {
+		if err := c.SetLogLevel(sessionCtx, level); err != nil {
+			return nil, fmt.Errorf("failed to set log level for %s: %w", name, err)
+		}
+	}
+
+	return c, nil
 }
 
 func (s *Service) sampleCall(ctx context.Context, agent string, args any, opts ...SampleCallOptions) (*types.CallResult, error) {
 	config := types.ConfigFromContext(ctx)
-	createMessageRequest, err := s.convertToSampleRequest(config, agent, args)
+	createMessageRequest, err := s.convertToSampleRequest(ctx, config, agent, args)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleArgs, err := sampleCallArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -547,6 +693,7 @@ func (s *Service) sampleCall(ctx context.Context, agent string, args any, opts .
 
 	return s.sampler.Sample(ctx, *createMessageRequest, sampling.SamplerOptions{
 		ProgressToken: opt.ProgressToken,
+		Resume:        sampleArgs.Resume || opt.Resume,
 	})
 }
 
@@ -558,6 +705,20 @@ type CallOptions struct {
 	Target             any
 	ToolCallInvocation *ToolCallInvocation
 	Meta               map[string]any
+	// Timeout bounds how long to wait for the result of an MCP tool call, zero waits until ctx is done.
+	// A call that times out fails with an error wrapping ErrToolCallTimeout.
+	Timeout time.Duration
+	// CacheTTL returns the result of a previous call of a read-only MCP tool with the same arguments if it
+	// is younger than this. Zero, the default, always calls the tool.
+	CacheTTL time.Duration
+	// ParseJSONContent sets the structured content of a result without one to its text content, if the
+	// result has a single text content that is JSON.
+	ParseJSONContent bool
+	// ResultFormat is the preferred representation of the result, ResultFormatText or
+	// ResultFormatStructured. Empty, the default, returns the result as the tool did.
+	ResultFormat string
+	// Resume continues the interrupted turn of the chat when calling an agent, instead of starting a new one.
+	Resume bool
 }
 
 type ToolCallInvocation struct {
@@ -574,6 +735,11 @@ func (o CallOptions) Merge(other CallOptions) (result CallOptions) {
 	result.Target = complete.Last(o.Target, other.Target)
 	result.ToolCallInvocation = complete.Last(o.ToolCallInvocation, other.ToolCallInvocation)
 	result.Meta = complete.MergeMap(o.Meta, other.Meta)
+	result.Timeout = complete.Last(o.Timeout, other.Timeout)
+	result.CacheTTL = complete.Last(o.CacheTTL, other.CacheTTL)
+	result.ParseJSONContent = o.ParseJSONContent || other.ParseJSONContent
+	result.ResultFormat = complete.Last(o.ResultFormat, other.ResultFormat)
+	result.Resume = o.Resume || other.Resume
 	return
 }
 
@@ -595,8 +761,19 @@ func (s *Service) getTarget(ctx context.Context, config types.Config, server, to
 }
 
 func (s *Service) RunHook(ctx context.Context, in, out any, target string) (hasOutput bool, _ error) {
+	if mcp.IsWebhookTarget(target) {
+		// The secret and timeout are server config, a session must not be able to change them
+		return mcp.Webhook{
+			URL:     target,
+			Secret:  s.webhookSecret,
+			Timeout: s.webhookTimeout,
+		}.Run(ctx, in, out)
+	}
+
 	server, tool, _ := strings.Cut(target, "/")
-	result, err := s.Call(ctx, server, tool, in)
+	result, err := s.Call(ctx, server, tool, in, CallOptions{
+		ParseJSONContent: true,
+	})
 	if err != nil {
 		return false, fmt.Errorf("failed to call hook %s: %w", target, err)
 	}
@@ -628,18 +805,6 @@ func (s *Service) RunHook(ctx context.Context, in, out any, target string) (hasO
 }
 
 func (s *Service) Call(ctx context.Context, server, tool string, args any, opts ...CallOptions) (ret *types.CallResult, err error) {
-	defer func() {
-		if ret == nil {
-			return
-		}
-		if ret.StructuredContent == nil && len(ret.Content) == 1 && ret.Content[0].Text != "" {
-			var obj any
-			if err := json.Unmarshal([]byte(ret.Content[0].Text), &obj); err == nil {
-				ret.StructuredContent = obj
-			}
-		}
-	}()
-
 	var (
 		opt              = complete.Complete(opts...)
 		session          = mcp.SessionFromContext(ctx)
@@ -648,17 +813,53 @@ func (s *Service) Call(ctx context.Context, server, tool string, args any, opts
 		logProgressDone  = true
 	)
 
+	if err := validResultFormat(opt.ResultFormat); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if ret == nil {
+			return
+		}
+		if opt.ParseJSONContent {
+			parseJSONContent(ret)
+		}
+		formatResult(ret, opt.ResultFormat)
+	}()
+
 	target := server
 	if tool != "" {
 		target = server + "/" + tool
 	}
 
+	if types.DisabledTargetsFromContext(ctx).IsDisabled(server, tool) {
+		return nil, fmt.Errorf("%s is disabled for this session", target)
+	}
+
+	ctx, done := types.WithCancel(ctx)
+	defer done()
+
 	targetType := "tool"
 	if _, ok := config.Agents[server]; ok {
 		targetType = "agent"
 	}
 
+	ctx, span := s.startSpan(ctx, "call "+target,
+		attribute.String("mcp.server", server),
+		attribute.String("mcp.tool", tool),
+		attribute.String("nanobot.target_type", targetType),
+	)
+	start := time.Now()
+	defer func() {
+		if err == nil && ret != nil && ret.IsError {
+			span.SetStatus(codes.Error, "tool returned an error result")
+		}
+		tracing.End(span, err)
+		metrics.ObserveToolCall(server, tool, time.Since(start), err != nil || ret == nil || ret.IsError)
+	}()
+
 	if session != nil && opt.ProgressToken != nil {
+		ctx := progress.WithMaxSize(ctx, s.maxProgressSize)
 		var (
 			tc        types.ToolCall
 			messageID string
@@ -683,21 +884,17 @@ func (s *Service) Call(ctx context.Context, server, tool string, args any, opts
 		}
 		tc.Target = target
 		tc.TargetType = targetType
+		tc.Arguments = s.maskArguments(ctx, config, server, tool, opt.Target, tc.Arguments)
 
 		if logProgressStart {
-			_ = session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
-				ProgressToken: opt.ProgressToken,
-				Meta: map[string]any{
-					types.CompletionProgressMetaKey: types.CompletionProgress{
-						MessageID: messageID,
-						Item: types.CompletionItem{
-							HasMore:  true,
-							ID:       itemID,
-							ToolCall: &tc,
-						},
-					},
+			progress.Send(ctx, &types.CompletionProgress{
+				MessageID: messageID,
+				Item: types.CompletionItem{
+					HasMore:  true,
+					ID:       itemID,
+					ToolCall: &tc,
 				},
-			})
+			}, opt.ProgressToken)
 		}
 
 		if logProgressDone {
@@ -719,51 +916,103 @@ func (s *Service) Call(ctx context.Context, server, tool string, args any, opts
 						},
 					}
 				}
-				_ = session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
-					ProgressToken: opt.ProgressToken,
-					Meta: map[string]any{
-						types.CompletionProgressMetaKey: types.CompletionProgress{
-							MessageID: messageID,
-							Item: types.CompletionItem{
-								ID:             itemID,
-								ToolCall:       &tc,
-								ToolCallResult: &tcResult,
-							},
-						},
+				progress.Send(ctx, &types.CompletionProgress{
+					MessageID: messageID,
+					Item: types.CompletionItem{
+						ID:             itemID,
+						ToolCall:       &tc,
+						ToolCallResult: &tcResult,
 					},
-				})
+				}, opt.ProgressToken)
 			}()
 		}
 	}
 
 	if _, ok := config.Agents[server]; ok && tool != types.AgentTool {
-		return s.sampleCall(ctx, server, args, SampleCallOptions{
+		depth := types.AgentDepth(ctx) + 1
+		if depth > s.maxAgentDepth {
+			// Returned as an RPC error so the reason isn't hidden when the caller is an agent over MCP
+			err := fmt.Errorf("%w: calling agent %s would exceed the limit of %d", ErrMaxAgentDepth, server, s.maxAgentDepth)
+			return nil, mcp.ErrRPCInvalidRequest.WithMessage("%v", err).WithError(err)
+		}
+		return s.sampleCall(types.WithAgentDepth(ctx, depth), server, args, SampleCallOptions{
 			ProgressToken: opt.ProgressToken,
+			Resume:        opt.Resume,
 		})
 	}
 
+	var cacheKey string
+	if opt.CacheTTL > 0 && s.resultCache != nil && s.readOnlyTool(ctx, config, server, tool, opt.Target) {
+		cacheKey, err = resultCacheKey(ctx, config, server, tool, args)
+		if err != nil {
+			return nil, err
+		}
+		cached, ok, err := s.resultCache.Get(ctx, cacheKey)
+		if err != nil {
+			log.Errorf(ctx, "failed to get cached result of %s: %v", target, err)
+		} else if ok {
+			result := *cached
+			return &result, nil
+		}
+	}
+
 	c, err := s.GetClient(ctx, server)
 	if err != nil {
 		return nil, err
 	}
 
-	mcpCallResult, err := c.Call(ctx, tool, args, mcp.CallOption{
+	meta := opt.Meta
+	if depth := types.AgentDepth(ctx); targetType == "agent" && depth > 0 {
+		// The agent runs in its own session, so the depth is passed along in _meta
+		meta = maps.Clone(meta)
+		if meta == nil {
+			meta = map[string]any{}
+		}
+		meta[types.AgentDepthMetaKey] = depth
+	}
+	meta = tracing.InjectMeta(ctx, meta)
+
+	callCtx := ctx
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeoutCause(ctx, opt.Timeout,
+			fmt.Errorf("%w: %s did not respond within %s", ErrToolCallTimeout, target, opt.Timeout))
+		defer cancel()
+	}
+
+	mcpCallResult, err := c.Call(callCtx, tool, args, mcp.CallOption{
 		ProgressToken: opt.ProgressToken,
-		Meta:          opt.Meta,
+		Meta:          meta,
 	})
 	if err != nil {
+		if cause := context.Cause(callCtx); ctx.Err() == nil && errors.Is(cause, ErrToolCallTimeout) {
+			return nil, cause
+		}
 		return nil, err
 	}
-	return &types.CallResult{
+
+	result := types.CallResult{
 		StructuredContent: mcpCallResult.StructuredContent,
 		Content:           mcpCallResult.Content,
 		IsError:           mcpCallResult.IsError,
-	}, nil
+	}
+	if cacheKey != "" && !result.IsError {
+		cached := result
+		if err := s.resultCache.Set(ctx, cacheKey, &cached, opt.CacheTTL); err != nil {
+			log.Errorf(ctx, "failed to cache result of %s: %v", target, err)
+		}
+	}
+	return &result, nil
 }
 
 type ListToolsOptions struct {
 	Servers []string
 	Tools   []string
+	// ExcludeDestructive drops the tools of MCP servers that may be destructive. Tools that are neither
+	// read-only nor annotated as not destructive are assumed to be destructive, like the spec does.
+	ExcludeDestructive bool
+	// ReadOnlyOnly drops the tools of MCP servers that are not annotated as read-only.
+	ReadOnlyOnly bool
 }
 
 type ListToolsResult struct {
@@ -773,8 +1022,9 @@ type ListToolsResult struct {
 
 func (s *Service) ListTools(ctx context.Context, opts ...ListToolsOptions) (result []ListToolsResult, _ error) {
 	var (
-		opt    ListToolsOptions
-		config = types.ConfigFromContext(ctx)
+		opt      ListToolsOptions
+		config   = types.ConfigFromContext(ctx)
+		disabled = types.DisabledTargetsFromContext(ctx)
 	)
 	for _, o := range opts {
 		for _, server := range o.Servers {
@@ -787,6 +1037,8 @@ func (s *Service) ListTools(ctx context.Context, opts ...ListToolsOptions) (resu
 				opt.Tools = append(opt.Tools, tool)
 			}
 		}
+		opt.ExcludeDestructive = opt.ExcludeDestructive || o.ExcludeDestructive
+		opt.ReadOnlyOnly = opt.ReadOnlyOnly || o.ReadOnlyOnly
 	}
 
 	serverList := slices.Sorted(maps.Keys(config.MCPServers))
@@ -795,22 +1047,48 @@ func (s *Service) ListTools(ctx context.Context, opts ...ListToolsOptions) (resu
 		opt.Servers = append(serverList, agentsList...)
 	}
 
+	var servers []string
 	for _, server := range opt.Servers {
-		if !slices.Contains(serverList, server) {
-			continue
+		if slices.Contains(serverList, server) && !disabled.IsDisabled(server, "") {
+			servers = append(servers, server)
 		}
+	}
 
-		c, err := s.GetClient(ctx, server)
-		if err != nil {
-			return nil, err
-		}
+	// Clients set the hook runner of the session when they are created, so set it before they are created
+	// concurrently.
+	if session := mcp.SessionFromContext(ctx).Root(); session != nil {
+		s.setHookRunner(session)
+	}
 
-		tools, err := c.ListTools(ctx)
-		if err != nil {
-			return nil, err
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, max(s.concurrency, 1))
+		listed    = make([]*mcp.ListToolsResult, len(servers))
+		errs      = make([]error, len(servers))
+	)
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			c, err := s.GetClient(ctx, server)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			listed[i], errs[i] = c.ListTools(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, server := range servers {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
 
-		tools = filterTools(tools, opt.Tools)
+		tools := filterDisabledTools(server, filterAnnotatedTools(filterTools(listed[i], opt.Tools), opt), disabled)
 
 		if len(tools.Tools) == 0 {
 			continue
@@ -824,7 +1102,7 @@ func (s *Service) ListTools(ctx context.Context, opts ...ListToolsOptions) (resu
 
 	for _, agentName := range opt.Servers {
 		agent, ok := config.Agents[agentName]
-		if !ok {
+		if !ok || disabled.IsDisabled(agentName, "") {
 			continue
 		}
 
@@ -864,9 +1142,68 @@ func filterTools(tools *mcp.ListToolsResult, filter []string) *mcp.ListToolsResu
 	return &filteredTools
 }
 
-func (s *Service) getMatches(ref string, tools []ListToolsResult, opts ...types.BuildToolMappingsOptions) types.ToolMappings {
+// filterAnnotatedTools drops the tools whose annotations do not match ExcludeDestructive and ReadOnlyOnly.
+// The chat tool of agents has no annotations and is not filtered.
+func filterAnnotatedTools(tools *mcp.ListToolsResult, opt ListToolsOptions) *mcp.ListToolsResult {
+	if !opt.ExcludeDestructive && !opt.ReadOnlyOnly {
+		return tools
+	}
+	var filteredTools mcp.ListToolsResult
+	for _, tool := range tools.Tools {
+		var annotations mcp.ToolAnnotations
+		if tool.Annotations != nil {
+			annotations = *tool.Annotations
+		}
+		if opt.ReadOnlyOnly && !annotations.ReadOnlyHint {
+			continue
+		}
+		if opt.ExcludeDestructive && !annotations.ReadOnlyHint && annotations.IsDestructive() {
+			continue
+		}
+		filteredTools.Tools = append(filteredTools.Tools, tool)
+	}
+	return &filteredTools
+}
+
+func filterDisabledTools(server string, tools *mcp.ListToolsResult, disabled types.DisabledTargets) *mcp.ListToolsResult {
+	if len(disabled.Tools) == 0 {
+		return tools
+	}
+	var filteredTools mcp.ListToolsResult
+	for _, tool := range tools.Tools {
+		if !disabled.IsDisabled(server, tool.Name) {
+			filteredTools.Tools = append(filteredTools.Tools, tool)
+		}
+	}
+	return &filteredTools
+}
+
+// ToolNameConflict is a published tool name that two references resolve to, with the server/tool of both.
+type ToolNameConflict struct {
+	Name   string `json:"name"`
+	First  string `json:"first"`
+	Second string `json:"second"`
+}
+
+// DuplicateToolNamesError is returned by BuildToolMappings when references publish different tools under
+// the same name, unless types.BuildToolMappingsOptions.AllowDuplicates is set.
+type DuplicateToolNamesError struct {
+	Conflicts []ToolNameConflict
+}
+
+func (e *DuplicateToolNamesError) Error() string {
+	var conflicts []string
+	for _, conflict := range e.Conflicts {
+		conflicts = append(conflicts, fmt.Sprintf("%s is both %s and %s", conflict.Name, conflict.First, conflict.Second))
+	}
+	return "duplicate tool names: " + strings.Join(conflicts, ", ")
+}
+
+// getMatches adds the tools the reference resolves to to result and returns the names that are already
+// used by a different tool. Those names keep the first tool, unless duplicates are allowed, then the last
+// tool wins.
+func (s *Service) getMatches(ref string, tools []ListToolsResult, result types.ToolMappings, opts ...types.BuildToolMappingsOptions) (conflicts []ToolNameConflict) {
 	toolRef := types.ParseToolRef(ref)
-	result := types.ToolMappings{}
 	opt := complete.Complete(opts...)
 
 	for _, t := range tools {
@@ -882,6 +1219,15 @@ func (s *Service) getMatches(ref string, tools []ListToolsResult, opts ...types.
 				if toolRef.As != "" {
 					tool.Name = toolRef.As
 				}
+				if existing, ok := result[tool.Name]; ok && !opt.AllowDuplicates &&
+					(existing.MCPServer != toolRef.Server || existing.TargetName != originalName) {
+					conflicts = append(conflicts, ToolNameConflict{
+						Name:   tool.Name,
+						First:  existing.MCPServer + "/" + existing.TargetName,
+						Second: toolRef.Server + "/" + originalName,
+					})
+					continue
+				}
 				result[tool.Name] = types.TargetMapping[types.TargetTool]{
 					MCPServer:  toolRef.Server,
 					TargetName: originalName,
@@ -893,7 +1239,7 @@ func (s *Service) getMatches(ref string, tools []ListToolsResult, opts ...types.
 		}
 	}
 
-	return result
+	return conflicts
 }
 
 func (s *Service) listToolsForReferences(ctx context.Context, toolList []string) ([]ListToolsResult, error) {
@@ -923,51 +1269,66 @@ func (s *Service) BuildToolMappings(ctx context.Context, toolList []string, opts
 		return nil, err
 	}
 
-	result := types.ToolMappings{}
+	return s.buildToolMappings(toolList, tools, opts...)
+}
+
+func (s *Service) buildToolMappings(toolList []string, tools []ListToolsResult, opts ...types.BuildToolMappingsOptions) (types.ToolMappings, error) {
+	var (
+		result    = types.ToolMappings{}
+		conflicts []ToolNameConflict
+	)
 	for _, ref := range toolList {
-		maps.Copy(result, s.getMatches(ref, tools, opts...))
+		conflicts = append(conflicts, s.getMatches(ref, tools, result, opts...)...)
+	}
+	if len(conflicts) > 0 {
+		return nil, &DuplicateToolNamesError{Conflicts: conflicts}
 	}
-
 	return result, nil
 }
 
 func hasOnlySampleKeys(args map[string]any) bool {
 	for key := range args {
-		if key != "prompt" && key != "attachments" && key != "_meta" {
+		if key != "prompt" && key != "attachments" && key != "resume" && key != "_meta" {
 			return false
 		}
 	}
 	return true
 }
 
-func (s *Service) convertToSampleRequest(config types.Config, agent string, args any) (*mcp.CreateMessageRequest, error) {
-	var (
-		sampleArgs types.SampleCallRequest
-	)
+func sampleCallArgs(args any) (types.SampleCallRequest, error) {
+	var sampleArgs types.SampleCallRequest
 	switch args := args.(type) {
 	case string:
 		sampleArgs.Prompt = args
 	case map[string]any:
 		if hasOnlySampleKeys(args) {
 			if err := mcp.JSONCoerce(args, &sampleArgs); err != nil {
-				return nil, fmt.Errorf("failed to marshal args: %w", err)
+				return sampleArgs, fmt.Errorf("failed to marshal args: %w", err)
 			}
 		} else {
 			if err := mcp.JSONCoerce(args, &sampleArgs.Prompt); err != nil {
-				return nil, fmt.Errorf("failed to marshal args to prompt: %w", err)
+				return sampleArgs, fmt.Errorf("failed to marshal args to prompt: %w", err)
 			}
 		}
 	case *types.SampleCallRequest:
 		if args != nil {
 			if err := mcp.JSONCoerce(*args, &sampleArgs); err != nil {
-				return nil, fmt.Errorf("failed to marshal args to prompt: %w", err)
+				return sampleArgs, fmt.Errorf("failed to marshal args to prompt: %w", err)
 			}
 		}
 	default:
 		if err := mcp.JSONCoerce(args, &sampleArgs); err != nil {
-			return nil, fmt.Errorf("failed to marshal args to prompt: %w", err)
+			return sampleArgs, fmt.Errorf("failed to marshal args to prompt: %w", err)
 		}
 	}
+	return sampleArgs, nil
+}
+
+func (s *Service) convertToSampleRequest(ctx context.Context, config types.Config, agent string, args any) (*mcp.CreateMessageRequest, error) {
+	sampleArgs, err := sampleCallArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
 	var sampleRequest = mcp.CreateMessageRequest{
 		MaxTokens: config.Agents[agent].MaxTokens,
@@ -989,23 +1350,46 @@ func (s *Service) convertToSampleRequest(config types.Config, agent string, args
 	}
 
 	for _, attachment := range sampleArgs.Attachments {
-		if !strings.HasPrefix(attachment.URL, "data:") {
-			return nil, fmt.Errorf("invalid attachment URL: %s, only data URI are supported", attachment.URL)
-		}
-		parts := strings.Split(strings.TrimPrefix(attachment.URL, "data:"), "base64,")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid attachment URL: %s, only data URI are supported", attachment.URL)
+		data, mimeType, err := s.attachmentData(ctx, attachment.URL)
+		if err != nil {
+			return nil, err
 		}
-		mimeType := strings.Split(parts[0], ";")[0]
 		if mimeType == "" {
 			mimeType = attachment.MimeType
 		}
-		data := parts[1]
+		if !mimeTypeAllowed(config.Agents[agent].MimeTypes, mimeType) {
+			err := fmt.Errorf("%w: attachment %s has type %q, agent %s accepts %s", ErrMimeTypeNotAllowed,
+				attachment.Name, mimeType, agent, strings.Join(config.Agents[agent].MimeTypes, ", "))
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("%v", err).WithError(err)
+		}
 		if mimeType == "" || strings.HasPrefix(mimeType, "image/") {
+			data, mimeType, err = downscaleImage(data, mimeType, s.maxImageDimension, s.imageQuality)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image attachment %s: %w", attachment.Name, err)
+			}
+			content := mcp.Content{
+				Type:     "image",
+				Data:     data,
+				MIMEType: mimeType,
+			}
+			if detail := complete.First(attachment.Detail, config.Agents[agent].ImageDetail); detail != "" {
+				if !slices.Contains(types.ImageDetails, detail) {
+					return nil, mcp.ErrRPCInvalidParams.WithMessage("attachment %s has invalid detail %q, must be one of %s",
+						attachment.Name, detail, strings.Join(types.ImageDetails, ", "))
+				}
+				content.Meta = map[string]any{
+					types.ImageDetailMetaKey: detail,
+				}
+			}
+			sampleRequest.Messages = append(sampleRequest.Messages, mcp.SamplingMessage{
+				Role:    "user",
+				Content: []mcp.Content{content},
+			})
+		} else if strings.HasPrefix(mimeType, "audio/") {
 			sampleRequest.Messages = append(sampleRequest.Messages, mcp.SamplingMessage{
 				Role: "user",
 				Content: []mcp.Content{{
-					Type:     "image",
+					Type:     "audio",
 					Data:     data,
 					MIMEType: mimeType,
 				}},
@@ -1031,11 +1415,32 @@ func (s *Service) convertToSampleRequest(config types.Config, agent string, args
 	return &sampleRequest, nil
 }
 
+// mimeTypeAllowed reports whether mimeType matches one of allowed, either exactly or as "type/*". An empty
+// allowed list accepts everything.
+func mimeTypeAllowed(allowed []string, mimeType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	for _, pattern := range allowed {
+		if pattern == "*" || pattern == "*/*" || strings.EqualFold(pattern, mimeType) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && mimeType != "" &&
+			strings.EqualFold(strings.Split(mimeType, "/")[0], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 type SampleCallOptions struct {
 	ProgressToken any
+	Resume        bool
 }
 
 func (s SampleCallOptions) Merge(other SampleCallOptions) (result SampleCallOptions) {
 	result.ProgressToken = complete.Last(s.ProgressToken, other.ProgressToken)
+	result.Resume = s.Resume || other.Resume
 	return
 }
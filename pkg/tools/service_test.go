@@ -0,0 +1,819 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var testToolList = []ListToolsResult{
+	{
+		Server: "fs",
+		Tools: []mcp.Tool{
+			{Name: "read"},
+			{Name: "write"},
+		},
+	},
+	{
+		Server: "search",
+		Tools: []mcp.Tool{
+			{Name: "query"},
+		},
+	},
+	{
+		Server: "helper",
+		Tools: []mcp.Tool{
+			{Name: types.AgentTool},
+		},
+	},
+}
+
+func TestBuildToolMappings_Wildcard(t *testing.T) {
+	s := &Service{}
+	mappings, err := s.buildToolMappings([]string{"fs", "search"}, testToolList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`query -> search/query
+read -> fs/read
+write -> fs/write
+`).Equal(t, mappings.String())
+}
+
+func TestBuildToolMappings_Aliases(t *testing.T) {
+	s := &Service{}
+	mappings, err := s.buildToolMappings([]string{"fs/read:cat", "search/query", "fs/write:save"}, testToolList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`cat -> fs/read
+query -> search/query
+save -> fs/write
+`).Equal(t, mappings.String())
+}
+
+func TestBuildToolMappings_DefaultAsToServer(t *testing.T) {
+	s := &Service{}
+	mappings, err := s.buildToolMappings([]string{"helper", "search/query:find"}, testToolList, types.BuildToolMappingsOptions{
+		DefaultAsToServer: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`find -> search/query
+helper -> helper/chat
+`).Equal(t, mappings.String())
+}
+
+func TestBuildToolMappings_UnknownReference(t *testing.T) {
+	s := &Service{}
+	mappings, err := s.buildToolMappings([]string{"missing", "fs/delete"}, testToolList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect("").Equal(t, mappings.String())
+}
+
+func TestBuildToolMappings_Duplicates(t *testing.T) {
+	s := &Service{}
+
+	// The same tool referenced twice is not a conflict
+	mappings, err := s.buildToolMappings([]string{"fs", "fs/read"}, testToolList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`read -> fs/read
+write -> fs/write
+`).Equal(t, mappings.String())
+
+	_, err = s.buildToolMappings([]string{"fs/read:lookup", "search/query:lookup", "fs/write:query", "search"}, testToolList)
+	autogold.Expect("duplicate tool names: lookup is both fs/read and search/query, query is both fs/write and search/query").Equal(t, err.Error())
+	var duplicates *DuplicateToolNamesError
+	autogold.Expect(true).Equal(t, errors.As(err, &duplicates))
+	autogold.Expect([]ToolNameConflict{
+		{Name: "lookup", First: "fs/read", Second: "search/query"},
+		{Name: "query", First: "fs/write", Second: "search/query"},
+	}).Equal(t, duplicates.Conflicts)
+
+	mappings, err = s.buildToolMappings([]string{"fs/read:lookup", "search/query:lookup"}, testToolList, types.BuildToolMappingsOptions{
+		AllowDuplicates: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`lookup -> search/query
+`).Equal(t, mappings.String())
+}
+
+func TestConvertToSampleRequest_AudioAttachment(t *testing.T) {
+	s := &Service{}
+	req, err := s.convertToSampleRequest(t.Context(), types.Config{}, "a", map[string]any{
+		"prompt": "transcribe this",
+		"attachments": []any{
+			map[string]any{"url": "data:audio/wav;base64,UklGRg=="},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(mcp.Content{Type: "audio", Data: "UklGRg==", MIMEType: "audio/wav"}).Equal(t, req.Messages[1].Content[0])
+}
+
+func TestSampleCallArgs_Resume(t *testing.T) {
+	args, err := sampleCallArgs(map[string]any{"prompt": "", "resume": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(types.SampleCallRequest{Resume: true}).Equal(t, args)
+}
+
+func TestConvertToSampleRequest_MimeTypes(t *testing.T) {
+	s := &Service{}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MimeTypes: []string{"image/*", "application/pdf"}},
+		},
+	}
+	attach := func(url string) map[string]any {
+		return map[string]any{
+			"attachments": []any{
+				map[string]any{"name": "file", "url": url},
+			},
+		}
+	}
+
+	_, err := s.convertToSampleRequest(t.Context(), config, "a", attach("data:text/plain;base64,aGk="))
+	autogold.Expect(`-32602: attachment type not allowed: attachment file has type "text/plain", agent a accepts image/*, application/pdf`).Equal(t, err.Error())
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrMimeTypeNotAllowed))
+
+	for _, url := range []string{"data:image/gif;base64,R0lG", "data:application/pdf;base64,JVBE"} {
+		if _, err := s.convertToSampleRequest(t.Context(), config, "a", attach(url)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No mimeTypes accepts everything
+	if _, err := s.convertToSampleRequest(t.Context(), config, "b", attach("data:text/plain;base64,aGk=")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertToSampleRequest_ImageDetail(t *testing.T) {
+	s := &Service{}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {ImageDetail: "low"},
+		},
+	}
+	req, err := s.convertToSampleRequest(t.Context(), config, "a", map[string]any{
+		"attachments": []any{
+			map[string]any{"url": "data:image/png;base64,AA=="},
+			map[string]any{"url": "data:image/png;base64,AQ==", "detail": "high"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]string{"low", "high"}).Equal(t, []string{
+		types.ImageDetail(req.Messages[0].Content[0], ""),
+		types.ImageDetail(req.Messages[1].Content[0], ""),
+	})
+
+	_, err = s.convertToSampleRequest(t.Context(), types.Config{}, "a", map[string]any{
+		"attachments": []any{
+			map[string]any{"name": "cat.png", "url": "data:image/png;base64,AA==", "detail": "ultra"},
+		},
+	})
+	autogold.Expect(`-32602: JSON RPC invalid params: attachment cat.png has invalid detail "ultra", must be one of low, high, auto`).Equal(t, err.Error())
+}
+
+func TestClientFactory_SerializeLastEventID(t *testing.T) {
+	factory := newClientFactory(nil)
+	deserialized, err := factory.Deserialize(map[string]any{
+		"id":          "session",
+		"lastEventID": "42",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The resumed client was not used yet, so the state it was resumed from is kept.
+	data, err := deserialized.(*clientFactory).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state mcp.SessionState
+	if err := mcp.JSONCoerce(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(mcp.SessionState{ID: "session", LastEventID: "42"}).Equal(t, state)
+}
+
+func TestConvertToSampleRequest_URLAttachment(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(png)
+		case "/notes.txt":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte("hi"))
+		case "/large.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write(make([]byte, 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	s := &Service{
+		maxAttachmentSize:      50,
+		attachmentFetchTimeout: 5 * time.Second,
+		fetchAttachments:       true,
+		// The test server is on a loopback address
+		attachmentClient: server.Client(),
+	}
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"a": {MimeTypes: []string{"image/*", "application/pdf"}},
+		},
+	}
+	attach := func(url string) map[string]any {
+		return map[string]any{
+			"attachments": []any{
+				map[string]any{"name": "file", "url": url},
+			},
+		}
+	}
+
+	req, err := s.convertToSampleRequest(t.Context(), config, "a", attach(server.URL+"/cat.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(mcp.Content{Type: "image", Data: base64.StdEncoding.EncodeToString(png), MIMEType: "image/png"}).Equal(t, req.Messages[0].Content[0])
+
+	_, err = s.convertToSampleRequest(t.Context(), config, "a", attach(server.URL+"/notes.txt"))
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrMimeTypeNotAllowed))
+
+	_, err = s.convertToSampleRequest(t.Context(), config, "a", attach(server.URL+"/large.pdf"))
+	autogold.Expect(fmt.Sprintf("attachment %s/large.pdf is 100 bytes, larger than the maximum of 50 bytes", server.URL)).Equal(t, err.Error())
+
+	_, err = s.convertToSampleRequest(t.Context(), config, "a", attach(server.URL+"/missing.png"))
+	autogold.Expect(fmt.Sprintf("failed to fetch attachment %s/missing.png: 404 Not Found", server.URL)).Equal(t, err.Error())
+
+	_, err = s.convertToSampleRequest(t.Context(), config, "a", attach("ftp://example.com/cat.png"))
+	autogold.Expect("invalid attachment URL: ftp://example.com/cat.png, only data URI and http(s) URLs are supported").Equal(t, err.Error())
+}
+
+func TestAttachmentData_InternalAddresses(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("internal"))
+	}))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+
+	_, _, err := NewToolsService().attachmentData(t.Context(), target.URL)
+	autogold.Expect(fmt.Sprintf("invalid attachment URL: %s, fetching http(s) URLs is not enabled", target.URL)).Equal(t, err.Error())
+
+	s := NewToolsService(Options{FetchAttachments: true})
+	_, _, err = s.attachmentData(t.Context(), target.URL)
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrAttachmentAddressNotAllowed))
+
+	// The redirecting server is allowed as if it was public, the address it redirects to is still checked
+	redirectAddr := netip.MustParseAddrPort(strings.TrimPrefix(redirect.URL, "http://"))
+	s.attachmentClient = newAttachmentClient(func(addr netip.AddrPort) bool {
+		return addr == redirectAddr || publicAddress(addr)
+	})
+	_, _, err = s.attachmentData(t.Context(), redirect.URL)
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrAttachmentAddressNotAllowed))
+
+	autogold.Expect([]bool{false, false, false, false, false, true}).Equal(t, []bool{
+		publicAddress(netip.MustParseAddrPort("127.0.0.1:80")),
+		publicAddress(netip.MustParseAddrPort("169.254.169.254:80")),
+		publicAddress(netip.MustParseAddrPort("10.0.0.1:80")),
+		publicAddress(netip.MustParseAddrPort("[::ffff:192.168.1.1]:80")),
+		publicAddress(netip.MustParseAddrPort("[fe80::1]:80")),
+		publicAddress(netip.MustParseAddrPort("93.184.216.34:443")),
+	})
+}
+
+// slowToolsServer lists a tool named after the server after a delay, tracking how many lists run at once.
+func slowToolsServer(name string, inFlight, maxSeen *atomic.Int64) testServer {
+	return testServer{
+		listTools: func(context.Context) (*mcp.ListToolsResult, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for seen := maxSeen.Load(); n > seen && !maxSeen.CompareAndSwap(seen, n); seen = maxSeen.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			if name == "broken" {
+				return nil, fmt.Errorf("server is broken")
+			}
+			return &mcp.ListToolsResult{Tools: []mcp.Tool{{Name: name + "-tool"}}}, nil
+		},
+	}
+}
+
+func TestListTools_Concurrent(t *testing.T) {
+	var inFlight, maxSeen atomic.Int64
+	svc := NewToolsService(Options{Concurrency: 2})
+	config := types.Config{MCPServers: map[string]mcp.Server{}}
+	for _, name := range []string{"d", "c", "b", "a", "broken"} {
+		config.MCPServers[name] = mcp.Server{}
+		svc.AddServer(name, func(name string) mcp.MessageHandler {
+			return slowToolsServer(name, &inFlight, &maxSeen)
+		})
+	}
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	result, err := svc.ListTools(ctx, ListToolsOptions{Servers: []string{"d", "c", "b", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tools []string
+	for _, r := range result {
+		tools = append(tools, r.Server+"/"+r.Tools[0].Name)
+	}
+	autogold.Expect([]string{"d/d-tool", "c/c-tool", "b/b-tool", "a/a-tool"}).Equal(t, tools)
+	autogold.Expect(int64(2)).Equal(t, maxSeen.Load())
+
+	_, err = svc.ListTools(ctx)
+	autogold.Expect("error from server: JSON RPC internal error").Equal(t, err.Error())
+}
+
+// hangingToolServer never responds to calls of its "wait" tool.
+func hangingToolServer() testServer {
+	return testServer{
+		calls: map[string]toolHandler{
+			"wait": func(ctx context.Context, _ mcp.Message, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+	}
+}
+
+func TestCall_Timeout(t *testing.T) {
+	svc := NewToolsService()
+	svc.AddServer("hanging", func(string) mcp.MessageHandler {
+		return waitForHandler(t, hangingToolServer())
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"hanging": {}}}
+	session := mcp.NewEmptySession(t.Context())
+
+	var results []types.ToolCallResult
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var progress mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+			var completion types.CompletionProgress
+			if err := mcp.JSONCoerce(progress.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Item.ToolCallResult != nil {
+				results = append(results, *completion.Item.ToolCallResult)
+			}
+		}
+		return nil, nil
+	})
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	_, err := svc.Call(ctx, "hanging", "wait", map[string]any{}, CallOptions{
+		ProgressToken: "token",
+		Timeout:       50 * time.Millisecond,
+	})
+	autogold.Expect("tool call timed out: hanging/wait did not respond within 50ms").Equal(t, err.Error())
+	autogold.Expect(true).Equal(t, errors.Is(err, ErrToolCallTimeout))
+
+	autogold.Expect(1).Equal(t, len(results))
+	autogold.Expect(types.CallResult{
+		IsError: true,
+		Content: []mcp.Content{{Type: "text", Text: "tool call timed out: hanging/wait did not respond within 50ms"}},
+	}).Equal(t, results[0].Output)
+}
+
+// countingToolServer counts the calls of its tools. "lookup" and "broken" are read-only, "broken" always
+// returns an error result, "echo" returns its text argument as is, "meta" the _meta of the request and
+// "login" its user and password arguments. The password is marked sensitive by its schema.
+func countingToolServer(calls *atomic.Int64) testServer {
+	readOnly := &mcp.ToolAnnotations{ReadOnlyHint: true}
+	counted := func(_ context.Context, _ mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		n := calls.Add(1)
+		return &mcp.CallToolResult{
+			IsError: req.Name == "broken",
+			Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("%s %v call %d", req.Name, req.Arguments["q"], n)}},
+		}, nil
+	}
+	return testServer{
+		tools: []mcp.Tool{
+			{Name: "lookup", Annotations: readOnly},
+			{Name: "broken", Annotations: readOnly},
+			{Name: "write"},
+			{Name: "echo", Annotations: &mcp.ToolAnnotations{DestructiveHint: new(bool)}},
+			{Name: "meta"},
+			{Name: "login", InputSchema: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "writeOnly": true}}}`)},
+		},
+		calls: map[string]toolHandler{
+			"lookup": counted,
+			"broken": counted,
+			"write":  counted,
+			"meta": func(_ context.Context, _ mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				calls.Add(1)
+				return &mcp.CallToolResult{StructuredContent: req.Meta}, nil
+			},
+			"login": func(_ context.Context, _ mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				calls.Add(1)
+				text := fmt.Sprintf("%v %v", req.Arguments["user"], req.Arguments["password"])
+				return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+			},
+			"echo": func(_ context.Context, _ mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				calls.Add(1)
+				text, _ := req.Arguments["text"].(string)
+				return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+			},
+		},
+	}
+}
+
+func TestCall_CacheTTL(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	call := func(tool, q string, ttl time.Duration) string {
+		t.Helper()
+		result, err := svc.Call(ctx, "counting", tool, map[string]any{"q": q}, CallOptions{CacheTTL: ttl})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content[0].Text
+	}
+
+	autogold.Expect("lookup a call 1").Equal(t, call("lookup", "a", time.Minute))
+	autogold.Expect("lookup a call 1").Equal(t, call("lookup", "a", time.Minute))
+	autogold.Expect("lookup b call 2").Equal(t, call("lookup", "b", time.Minute))
+	// Without a TTL the cache is not used
+	autogold.Expect("lookup a call 3").Equal(t, call("lookup", "a", 0))
+	// Tools that are not read-only and error results are not cached
+	autogold.Expect("write a call 4").Equal(t, call("write", "a", time.Minute))
+	autogold.Expect("write a call 5").Equal(t, call("write", "a", time.Minute))
+	autogold.Expect("broken a call 6").Equal(t, call("broken", "a", time.Minute))
+	autogold.Expect("broken a call 7").Equal(t, call("broken", "a", time.Minute))
+
+	autogold.Expect("lookup c call 8").Equal(t, call("lookup", "c", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	autogold.Expect("lookup c call 9").Equal(t, call("lookup", "c", time.Millisecond))
+}
+
+func TestMemoryResultCache(t *testing.T) {
+	cache := NewMemoryResultCache()
+	result := &types.CallResult{Content: []mcp.Content{{Type: "text", Text: "cached"}}}
+	if err := cache.Set(t.Context(), "key", result, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, ok, err := cache.Get(t.Context(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(true).Equal(t, ok)
+	autogold.Expect(result).Equal(t, cached)
+
+	_, ok, _ = cache.Get(t.Context(), "other")
+	autogold.Expect(false).Equal(t, ok)
+
+	keyA, _ := resultCacheKey(t.Context(), types.Config{}, "server", "tool", map[string]any{"a": 1, "b": 2})
+	keyB, _ := resultCacheKey(t.Context(), types.Config{}, "server", "tool", map[string]any{"b": 2, "a": 1})
+	keyC, _ := resultCacheKey(t.Context(), types.Config{}, "server", "other", map[string]any{"a": 1, "b": 2})
+	autogold.Expect(true).Equal(t, keyA == keyB)
+	autogold.Expect(false).Equal(t, keyA == keyC)
+}
+
+func TestCall_CacheTTLSessions(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+
+	call := func(accountID string, env map[string]string) string {
+		t.Helper()
+		session := mcp.NewEmptySession(t.Context())
+		session.Set(types.AccountIDSessionKey, accountID)
+		session.AddEnv(env)
+		ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+		result, err := svc.Call(ctx, "counting", "lookup", map[string]any{"q": "a"}, CallOptions{CacheTTL: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Content[0].Text
+	}
+
+	autogold.Expect("lookup a call 1").Equal(t, call("alice", nil))
+	autogold.Expect("lookup a call 2").Equal(t, call("bob", nil))
+	// The sessions of an account share the results if the server gets the same env
+	autogold.Expect("lookup a call 1").Equal(t, call("alice", nil))
+	autogold.Expect("lookup a call 3").Equal(t, call("alice", map[string]string{"TOKEN": "other"}))
+}
+
+func TestCall_ParseJSONContent(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	call := func(text string, parse bool) any {
+		t.Helper()
+		result, err := svc.Call(ctx, "counting", "echo", map[string]any{"text": text}, CallOptions{ParseJSONContent: parse})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Content[0].Text != text {
+			t.Fatalf("expected the text content to be kept, got %q", result.Content[0].Text)
+		}
+		return result.StructuredContent
+	}
+
+	autogold.Expect(nil).Equal(t, call(`{"answer": 42}`, false))
+	autogold.Expect(nil).Equal(t, call(`[1, 2]`, false))
+	autogold.Expect(map[string]any{"answer": 42.0}).Equal(t, call(`{"answer": 42}`, true))
+	autogold.Expect([]any{1.0, 2.0}).Equal(t, call(`[1, 2]`, true))
+	autogold.Expect(nil).Equal(t, call(`not {json}`, true))
+}
+
+func TestListTools_Annotations(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{"counting": {}},
+		Agents:     map[string]types.Agent{"agent": {}},
+	}
+	session := mcp.NewEmptySession(t.Context())
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	list := func(opt ListToolsOptions) (tools []string) {
+		t.Helper()
+		result, err := svc.ListTools(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range result {
+			for _, tool := range r.Tools {
+				tools = append(tools, r.Server+"/"+tool.Name)
+			}
+		}
+		return tools
+	}
+
+	autogold.Expect([]string{"counting/lookup", "counting/broken", "counting/write", "counting/echo", "counting/meta", "counting/login", "agent/chat"}).Equal(t, list(ListToolsOptions{}))
+	autogold.Expect([]string{"counting/lookup", "counting/broken", "counting/echo", "agent/chat"}).Equal(t, list(ListToolsOptions{ExcludeDestructive: true}))
+	autogold.Expect([]string{"counting/lookup", "counting/broken", "agent/chat"}).Equal(t, list(ListToolsOptions{ReadOnlyOnly: true}))
+}
+
+func TestCall_Tracing(t *testing.T) {
+	var (
+		calls    atomic.Int64
+		recorder = tracetest.NewSpanRecorder()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	)
+	svc := NewToolsService(Options{TracerProvider: provider})
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	ctx, parent := provider.Tracer("test").Start(ctx, "run")
+	result, err := svc.Call(ctx, "counting", "meta", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Call(ctx, "counting", "broken", map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	var (
+		traceID = parent.SpanContext().TraceID().String()
+		spans   []string
+	)
+	for _, span := range recorder.Ended() {
+		if span.Name() == "run" {
+			continue
+		}
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Fatalf("expected span %s to be part of the trace", span.Name())
+		}
+		desc := span.Name()
+		for _, attr := range span.Attributes() {
+			desc += fmt.Sprintf(" %s=%s", attr.Key, attr.Value.Emit())
+		}
+		if span.Status().Code == codes.Error {
+			desc += " error: " + span.Status().Description
+		}
+		spans = append(spans, desc)
+	}
+	autogold.Expect([]string{
+		"get client counting mcp.server=counting",
+		"call counting/meta mcp.server=counting mcp.tool=meta nanobot.target_type=tool",
+		"get client counting mcp.server=counting",
+		"call counting/broken mcp.server=counting mcp.tool=broken nanobot.target_type=tool error: tool returned an error result",
+	}).Equal(t, spans)
+
+	traceparent, _ := result.StructuredContent.(map[string]any)["traceparent"].(string)
+	var callSpanID string
+	for _, span := range recorder.Ended() {
+		if span.Name() == "call counting/meta" {
+			callSpanID = span.SpanContext().SpanID().String()
+		}
+	}
+	autogold.Expect(fmt.Sprintf("00-%s-%s-01", traceID, callSpanID)).Equal(t, traceparent)
+}
+
+func TestCall_MaskSensitiveArguments(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService(Options{SensitiveArguments: []string{"*token*"}})
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	args := map[string]any{
+		"user":     "bob",
+		"password": "hunter2",
+		"auth":     map[string]any{"AccessToken": "abc"},
+	}
+	argsData, _ := json.Marshal(args)
+
+	for name, opt := range map[string]CallOptions{
+		"direct": {},
+		"invocation": {ToolCallInvocation: &ToolCallInvocation{ToolCall: types.ToolCall{
+			CallID:    "call-1",
+			Name:      "login",
+			Arguments: string(argsData),
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			session := mcp.NewEmptySession(t.Context())
+			var arguments []string
+			session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+				var progress mcp.NotificationProgressRequest
+				if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+					var completion types.CompletionProgress
+					if err := mcp.JSONCoerce(progress.Meta[types.CompletionProgressMetaKey], &completion); err == nil && completion.Item.ToolCall != nil {
+						arguments = append(arguments, completion.Item.ToolCall.Arguments)
+					}
+				}
+				return nil, nil
+			})
+			ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+			opt.ProgressToken = "token"
+			result, err := svc.Call(ctx, "counting", "login", args, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			autogold.Expect("bob hunter2").Equal(t, result.Content[0].Text)
+
+			if len(arguments) == 0 {
+				t.Fatal("no progress was sent")
+			}
+			for _, sent := range arguments {
+				autogold.Expect(`{"auth":{"AccessToken":"********"},"password":"********","user":"bob"}`).Equal(t, sent)
+			}
+		})
+	}
+}
+
+func TestCall_MaxProgressSize(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService(Options{MaxProgressSize: 400})
+	svc.AddServer("counting", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"counting": {}}}
+	session := mcp.NewEmptySession(t.Context())
+
+	var results []types.CallResult
+	session.AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		var progress mcp.NotificationProgressRequest
+		if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &progress) == nil {
+			data, _ := json.Marshal(progress.Meta[types.CompletionProgressMetaKey])
+			if len(data) > 400 {
+				t.Errorf("progress of %d bytes is larger than the limit: %s", len(data), data)
+			}
+			var completion types.CompletionProgress
+			if err := json.Unmarshal(data, &completion); err == nil && completion.Item.ToolCallResult != nil {
+				results = append(results, completion.Item.ToolCallResult.Output)
+			}
+		}
+		return nil, nil
+	})
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), session)
+
+	text := strings.Repeat("x", 1000)
+	result, err := svc.Call(ctx, "counting", "echo", map[string]any{"text": text}, CallOptions{
+		ProgressToken: "token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(1000).Equal(t, len(result.Content[0].Text))
+
+	// The result is too large for a notification, so the client gets a notice instead
+	autogold.Expect([]types.CallResult{{
+		Content: []mcp.Content{{
+			Type: "text",
+			Text: "[omitted, 2263 bytes is larger than the progress limit of 400 bytes]",
+		}},
+	}}).Equal(t, results)
+}
+
+func TestRunHook_WebhookServerConfig(t *testing.T) {
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(mcp.WebhookSignatureHeader)
+		_, _ = w.Write([]byte(`{"accept":true}`))
+	}))
+	defer server.Close()
+
+	svc := NewToolsService(Options{WebhookSecret: "server-secret"})
+	session := mcp.NewEmptySession(t.Context())
+	// The env of a session is up to its client, it must not change how webhooks are signed
+	session.AddEnv(map[string]string{"NANOBOT_WEBHOOK_SECRET": "client-secret"})
+	ctx := mcp.WithSession(t.Context(), session)
+
+	var out mcp.SessionMessageHook
+	if _, err := svc.RunHook(ctx, mcp.SessionMessageHook{Accept: true}, &out, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(mcp.SessionMessageHook{Accept: true})
+	autogold.Expect(true).Equal(t, mcp.VerifyWebhookSignature("server-secret", body, signature))
+}
+
+func TestCall_Metrics(t *testing.T) {
+	var calls atomic.Int64
+	svc := NewToolsService()
+	svc.AddServer("metered", func(string) mcp.MessageHandler {
+		return countingToolServer(&calls)
+	})
+	config := types.Config{MCPServers: map[string]mcp.Server{"metered": {}}}
+	ctx := mcp.WithSession(types.WithConfig(t.Context(), config), mcp.NewEmptySession(t.Context()))
+
+	for _, tool := range []string{"lookup", "lookup", "broken"} {
+		if _, err := svc.Call(ctx, "metered", tool, map[string]any{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+
+	var lines []string
+	for _, line := range strings.Split(rw.Body.String(), "\n") {
+		if strings.HasPrefix(line, "nanobot_tool_call") && strings.Contains(line, `server="metered"`) && !strings.Contains(line, "_bucket") {
+			lines = append(lines, regexp.MustCompile(`_sum(\{.*\}) .*`).ReplaceAllString(line, "_sum$1 <duration>"))
+		}
+	}
+	autogold.Expect([]string{
+		`nanobot_tool_call_duration_seconds_sum{server="metered",tool="broken"} <duration>`,
+		`nanobot_tool_call_duration_seconds_count{server="metered",tool="broken"} 1`,
+		`nanobot_tool_call_duration_seconds_sum{server="metered",tool="lookup"} <duration>`,
+		`nanobot_tool_call_duration_seconds_count{server="metered",tool="lookup"} 2`,
+		`nanobot_tool_call_errors_total{server="metered",tool="broken"} 1`,
+		`nanobot_tool_calls_total{server="metered",tool="broken"} 1`,
+		`nanobot_tool_calls_total{server="metered",tool="lookup"} 2`,
+	}).Equal(t, lines)
+}
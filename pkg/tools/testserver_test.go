@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// toolHandler handles the calls of a tool of a testServer.
+type toolHandler func(ctx context.Context, msg mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// testServer is an MCP server for tests. It lists its tools and calls them with the handler of the tool
+// name, calling a tool without a handler fails.
+type testServer struct {
+	tools []mcp.Tool
+	calls map[string]toolHandler
+	// listTools, if set, lists the tools instead of returning tools.
+	listTools func(ctx context.Context) (*mcp.ListToolsResult, error)
+	// setLogLevel, if set, enables logging and is called with the level the client sets.
+	setLogLevel func(level string)
+}
+
+func (s testServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+			result := &mcp.InitializeResult{
+				ProtocolVersion: params.ProtocolVersion,
+				Capabilities: mcp.ServerCapabilities{
+					Tools: &mcp.ToolsServerCapability{},
+				},
+			}
+			if s.setLogLevel != nil {
+				result.Capabilities.Logging = &struct{}{}
+			}
+			return result, nil
+		})
+	case "notifications/initialized":
+	case "logging/setLevel":
+		if s.setLogLevel == nil {
+			msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+			return
+		}
+		mcp.Invoke(ctx, msg, func(_ context.Context, _ mcp.Message, req mcp.SetLogLevelRequest) (*mcp.SetLogLevelResult, error) {
+			s.setLogLevel(req.Level)
+			return &mcp.SetLogLevelResult{}, nil
+		})
+	case "tools/list":
+		mcp.Invoke(ctx, msg, func(ctx context.Context, _ mcp.Message, _ mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+			if s.listTools != nil {
+				return s.listTools(ctx)
+			}
+			return &mcp.ListToolsResult{Tools: s.tools}, nil
+		})
+	case "tools/call":
+		mcp.Invoke(ctx, msg, func(ctx context.Context, msg mcp.Message, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			handler, ok := s.calls[req.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tool %s", req.Name)
+			}
+			return handler(ctx, msg, req)
+		})
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+// waitForHandler returns handler, and the messages it is handling are waited for when the test finishes, so
+// none of them are replying after the test.
+func waitForHandler(t *testing.T, handler mcp.MessageHandler) mcp.MessageHandler {
+	var (
+		lock     sync.Mutex
+		handling sync.WaitGroup
+		done     bool
+	)
+	t.Cleanup(func() {
+		lock.Lock()
+		done = true
+		lock.Unlock()
+		handling.Wait()
+	})
+	return mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		lock.Lock()
+		if done {
+			lock.Unlock()
+			return
+		}
+		handling.Add(1)
+		lock.Unlock()
+		defer handling.Done()
+		handler.OnMessage(ctx, msg)
+	})
+}
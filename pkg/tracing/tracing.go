@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"maps"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// propagator writes the span context as the W3C traceparent and tracestate keys.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the named tracer of the provider, or a tracer that records nothing if provider is nil.
+func Tracer(provider trace.TracerProvider, name string) trace.Tracer {
+	if provider == nil {
+		provider = noop.NewTracerProvider()
+	}
+	return provider.Tracer(name)
+}
+
+// End records err, if any, as the error status of the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InjectMeta returns a copy of the MCP _meta with the span context of ctx added, so a remote server can
+// continue the trace. The meta is returned as is if ctx has no span.
+func InjectMeta(ctx context.Context, meta map[string]any) map[string]any {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return meta
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return meta
+	}
+
+	meta = maps.Clone(meta)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	for k, v := range carrier {
+		meta[k] = v
+	}
+	return meta
+}
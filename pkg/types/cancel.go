@@ -0,0 +1,109 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// cancelsSessionKey is the attribute of the root session that holds the runs and tool calls that can be
+// canceled with CancelAll.
+const cancelsSessionKey = "cancels"
+
+// ErrCanceledAll is the cause of the runs and tool calls canceled with CancelAll.
+var ErrCanceledAll = errors.New("all active runs of the session were canceled")
+
+// cancelsLock guards creating the registry of a session.
+var cancelsLock sync.Mutex
+
+type cancels struct {
+	lock    sync.Mutex
+	nextID  int
+	cancels map[int]context.CancelCauseFunc
+}
+
+type registrationsKey struct{}
+
+// registration is a run or tool call registered in the cancels of a session.
+type registration struct {
+	cancels *cancels
+	id      int
+}
+
+func sessionCancels(session *mcp.Session) *cancels {
+	cancelsLock.Lock()
+	defer cancelsLock.Unlock()
+
+	var c *cancels
+	if !session.Get(cancelsSessionKey, &c) {
+		c = &cancels{
+			cancels: map[int]context.CancelCauseFunc{},
+		}
+		session.Set(cancelsSessionKey, c)
+	}
+	return c
+}
+
+// WithCancel registers a run or tool call of the session in ctx, so CancelAll can cancel it. The returned
+// function must be called when it is done. Only the outermost run or tool call is registered: one that is
+// part of a registered one of the same session, like the tool calls of a run, is canceled with it, so it
+// isn't registered again and counted twice by CancelAll.
+func WithCancel(ctx context.Context) (context.Context, func()) {
+	session := mcp.SessionFromContext(ctx).Root()
+	if session == nil {
+		return ctx, func() {}
+	}
+
+	c := sessionCancels(session)
+	registrations, _ := ctx.Value(registrationsKey{}).([]registration)
+	if slices.ContainsFunc(registrations, func(r registration) bool { return r.cancels == c }) {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	c.lock.Lock()
+	id := c.nextID
+	c.nextID++
+	c.cancels[id] = cancel
+	c.lock.Unlock()
+
+	ctx = context.WithValue(ctx, registrationsKey{}, append(slices.Clone(registrations), registration{cancels: c, id: id}))
+
+	return ctx, func() {
+		c.lock.Lock()
+		delete(c.cancels, id)
+		c.lock.Unlock()
+		cancel(context.Canceled)
+	}
+}
+
+// CancelAll cancels the active runs and tool calls of the session in ctx and returns how many were
+// canceled. The runs and tool calls that ctx belongs to are not canceled, so the caller can still respond.
+func CancelAll(ctx context.Context) int {
+	session := mcp.SessionFromContext(ctx).Root()
+	if session == nil {
+		return 0
+	}
+
+	var (
+		c                = sessionCancels(session)
+		registrations, _ = ctx.Value(registrationsKey{}).([]registration)
+		count            int
+	)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for id, cancel := range c.cancels {
+		if slices.Contains(registrations, registration{cancels: c, id: id}) {
+			continue
+		}
+		cancel(ErrCanceledAll)
+		delete(c.cancels, id)
+		count++
+	}
+	return count
+}
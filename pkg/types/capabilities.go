@@ -0,0 +1,56 @@
+package types
+
+import (
+	"context"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const DisabledTargetsSessionKey = "disabledTargets"
+
+// DisabledTargets are the MCP servers and tools that have been turned off for a session at runtime,
+// overriding the config. Tools are referenced as "server/tool".
+type DisabledTargets struct {
+	Servers []string `json:"servers,omitempty"`
+	Tools   []string `json:"tools,omitempty"`
+}
+
+func (d *DisabledTargets) Serialize() (any, error) {
+	return d, nil
+}
+
+func (d *DisabledTargets) Deserialize(data any) (any, error) {
+	return d, mcp.JSONCoerce(data, &d)
+}
+
+// IsDisabled returns true if the server, or the tool on that server, is disabled. An empty tool
+// only checks the server.
+func (d DisabledTargets) IsDisabled(server, tool string) bool {
+	if slices.Contains(d.Servers, server) {
+		return true
+	}
+	return tool != "" && slices.Contains(d.Tools, server+"/"+tool)
+}
+
+// SetEnabled enables or disables the server, or the tool on that server if tool is set.
+func (d *DisabledTargets) SetEnabled(server, tool string, enabled bool) {
+	list, target := &d.Servers, server
+	if tool != "" {
+		list, target = &d.Tools, server+"/"+tool
+	}
+	*list = slices.DeleteFunc(*list, func(s string) bool {
+		return s == target
+	})
+	if !enabled {
+		*list = append(*list, target)
+		slices.Sort(*list)
+	} else if len(*list) == 0 {
+		*list = nil
+	}
+}
+
+func DisabledTargetsFromContext(ctx context.Context) (result DisabledTargets) {
+	mcp.SessionFromContext(ctx).Root().Get(DisabledTargetsSessionKey, &result)
+	return
+}
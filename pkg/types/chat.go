@@ -41,6 +41,10 @@ var ChatInputSchema = []byte(`{
 	      }
 	    }
 	  }
+    },
+    "resume": {
+      "description": "Continue the turn that was interrupted instead of starting a new one, the prompt is ignored",
+      "type": "boolean"
     }
   }
 }`)
@@ -48,6 +52,8 @@ var ChatInputSchema = []byte(`{
 type SampleCallRequest struct {
 	Prompt      string       `json:"prompt"`
 	Attachments []Attachment `json:"attachments,omitempty"`
+	// Resume continues the interrupted turn of the chat, the prompt is ignored.
+	Resume bool `json:"resume,omitempty"`
 }
 
 type SampleConfirmRequest struct {
@@ -59,6 +65,8 @@ type Attachment struct {
 	Name     string `json:"name,omitempty"`
 	URL      string `json:"url"`
 	MimeType string `json:"mimeType,omitempty"`
+	// Detail is the detail level ("low", "high" or "auto") for image attachments, overriding the agent's imageDetail.
+	Detail string `json:"detail,omitempty"`
 }
 
 func (a *Attachment) UnmarshalJSON(data []byte) error {
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// CitationsMetaKey is set on the content of a tool result to the sources the content was retrieved from, as
+// a list of Citation. The citations are listed to the model with the result and collected in
+// CompletionResponse.Citations.
+const CitationsMetaKey = "ai.nanobot.citations"
+
+type Citation struct {
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// ContentCitations returns the citations set on the content with CitationsMetaKey. Citations that can't be
+// decoded or have neither a title nor a URL are ignored.
+func ContentCitations(content mcp.Content) []Citation {
+	value, ok := content.Meta[CitationsMetaKey]
+	if !ok {
+		return nil
+	}
+
+	var citations []Citation
+	switch v := value.(type) {
+	case []Citation:
+		citations = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &citations); err != nil {
+			return nil
+		}
+	}
+
+	var result []Citation
+	for _, citation := range citations {
+		if citation.Title != "" || citation.URL != "" {
+			result = append(result, citation)
+		}
+	}
+	return result
+}
+
+// Citations returns the citations of all content of the result, without duplicates.
+func (c CallResult) Citations() (result []Citation) {
+	for _, content := range c.Content {
+		result = AppendCitations(result, ContentCitations(content)...)
+	}
+	return result
+}
+
+// AppendCitations appends the citations that are not in existing yet. Citations with the same URL, or the
+// same title if they have no URL, are duplicates.
+func AppendCitations(existing []Citation, citations ...Citation) []Citation {
+	for _, citation := range citations {
+		duplicate := false
+		for _, e := range existing {
+			if citationKey(e) == citationKey(citation) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			existing = append(existing, citation)
+		}
+	}
+	return existing
+}
+
+func citationKey(c Citation) string {
+	if c.URL != "" {
+		return "url:" + c.URL
+	}
+	return "title:" + c.Title
+}
+
+// CitationsText lists the citations as numbered sources, so they can be given to the model as text.
+func CitationsText(citations []Citation) string {
+	var buf strings.Builder
+	buf.WriteString("Sources:")
+	for i, citation := range citations {
+		fmt.Fprintf(&buf, "\n[%d]", i+1)
+		if citation.Title != "" {
+			buf.WriteString(" " + citation.Title)
+		}
+		if citation.URL != "" {
+			if citation.Title != "" {
+				buf.WriteString(" -")
+			}
+			buf.WriteString(" " + citation.URL)
+		}
+		if citation.Snippet != "" {
+			fmt.Fprintf(&buf, ": %q", citation.Snippet)
+		}
+	}
+	return buf.String()
+}
@@ -15,6 +15,22 @@ type Completer interface {
 	Complete(ctx context.Context, req CompletionRequest, opts ...CompletionOptions) (*CompletionResponse, error)
 }
 
+// Embedder creates embedding vectors for text.
+type Embedder interface {
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+type EmbeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Model string `json:"model,omitempty"`
+	// Embeddings has one vector per input, in the same order.
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
 type CompletionOptions struct {
 	ProgressToken      any
 	Chat               *bool
@@ -22,6 +38,8 @@ type CompletionOptions struct {
 	Tools              []mcp.Tool
 	ToolIncludeContext string
 	ToolSource         string
+	// MaxIterations overrides types.Agent.MaxIterations if set.
+	MaxIterations int
 }
 
 func (c CompletionOptions) Merge(other CompletionOptions) (result CompletionOptions) {
@@ -31,6 +49,7 @@ func (c CompletionOptions) Merge(other CompletionOptions) (result CompletionOpti
 	result.Tools = append(c.Tools, other.Tools...)
 	result.ToolIncludeContext = complete.Last(c.ToolIncludeContext, other.ToolIncludeContext)
 	result.ToolSource = complete.Last(c.ToolSource, other.ToolSource)
+	result.MaxIterations = complete.Last(c.MaxIterations, other.MaxIterations)
 	return
 }
 
@@ -52,6 +71,9 @@ type CompletionRequest struct {
 	Tools             []ToolUseDefinition  `json:"tools,omitzero"`
 	InputAsToolResult *bool                `json:"inputAsToolResult,omitempty"`
 	Reasoning         *AgentReasoning      `json:"reasoning,omitempty"`
+	Audio             *AgentAudio          `json:"audio,omitempty"`
+	AssistantPrefix   string               `json:"assistantPrefix,omitempty"`
+	Resume            bool                 `json:"resume,omitempty"`
 }
 
 func (r CompletionRequest) GetAgent() string {
@@ -65,6 +87,7 @@ func (r CompletionRequest) Reset() CompletionRequest {
 	r.Input = nil
 	r.InputAsToolResult = &[]bool{false}[0]
 	r.NewThread = false
+	r.Resume = false
 	return r
 }
 
@@ -81,6 +104,12 @@ type CompletionProgress struct {
 	MessageID string         `json:"messageID,omitempty"`
 	Role      string         `json:"role,omitempty"`
 	Item      CompletionItem `json:"item,omitempty"`
+	// Structured is the structured output parsed so far, set while streaming the text of a completion
+	// with an output schema.
+	Structured any `json:"structured,omitempty"`
+	// Usage and Cost are the totals of the turn so far, sent without an item after each LLM call.
+	Usage *Usage  `json:"usage,omitempty"`
+	Cost  float64 `json:"cost,omitempty"`
 }
 
 const CompletionProgressMetaKey = "ai.nanobot.progress/completion"
@@ -150,7 +179,10 @@ func (c *CompletionItem) UnmarshalJSON(data []byte) error {
 			return err
 		}
 	case "tool":
-		if _, ok := raw["name"]; ok {
+		_, hasName := raw["name"]
+		_, hasArguments := raw["arguments"]
+		// The partials of a streamed call after the first can have only arguments
+		if hasName || hasArguments {
 			c.ToolCall = &ToolCall{}
 			if err := json.Unmarshal(data, c.ToolCall); err != nil {
 				return err
@@ -256,16 +288,55 @@ type SummaryText struct {
 }
 
 type CompletionResponse struct {
-	Output           Message   `json:"output,omitempty"`
-	InternalMessages []Message `json:"internalMessages,omitempty"`
-	ChatResponse     bool      `json:"chatResponse,omitempty"`
-	Agent            string    `json:"agent,omitempty"`
-	Model            string    `json:"model,omitempty"`
-	HasMore          bool      `json:"hasMore,omitempty"`
-	Error            string    `json:"error,omitempty"`
-	ProgressToken    any       `json:"progressToken,omitempty"`
+	Output           Message    `json:"output,omitempty"`
+	InternalMessages []Message  `json:"internalMessages,omitempty"`
+	ChatResponse     bool       `json:"chatResponse,omitempty"`
+	Agent            string     `json:"agent,omitempty"`
+	Model            string     `json:"model,omitempty"`
+	HasMore          bool       `json:"hasMore,omitempty"`
+	Error            string     `json:"error,omitempty"`
+	ProgressToken    any        `json:"progressToken,omitempty"`
+	Citations        []Citation `json:"citations,omitempty"`
+	// StopReason is set if the turn was stopped before the LLM was done, like StopReasonMaxIterations.
+	StopReason string `json:"stopReason,omitempty"`
+	// Usage is the tokens used by the completion, of all LLM calls of the turn for the response of an agent.
+	Usage *Usage `json:"usage,omitempty"`
+	// Cost is the estimated cost of the usage, from the prices of the config. Models without a price add
+	// nothing to it.
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// Usage is the number of tokens used by completions. The prompt tokens include the cached tokens and the
+// completion tokens include the reasoning tokens.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens,omitempty"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	TotalTokens      int `json:"totalTokens,omitempty"`
+	CachedTokens     int `json:"cachedTokens,omitempty"`
+	ReasoningTokens  int `json:"reasoningTokens,omitempty"`
 }
 
+// Add returns the sum of the usages, nil if both are nil.
+func (u *Usage) Add(other *Usage) *Usage {
+	if u == nil {
+		return other
+	}
+	if other == nil {
+		return u
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// StopReasonMaxIterations is the stop reason of a turn that was stopped because the LLM was called
+// types.Agent.MaxIterations times and still called tools.
+const StopReasonMaxIterations = "maxIterations"
+
 func (c *CompletionResponse) Serialize() (any, error) {
 	return c, nil
 }
@@ -1,15 +1,20 @@
 package types
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 )
 
 const (
@@ -58,6 +63,32 @@ type Config struct {
 	Profiles   map[string]Config     `json:"profiles,omitempty"`
 	Prompts    map[string]Prompt     `json:"prompts,omitempty"`
 	Hooks      mcp.Hooks             `json:"hooks,omitempty"`
+	When       []ConditionalConfig   `json:"when,omitempty"`
+	Prices     map[string]TokenPrice `json:"prices,omitempty"`
+
+	// SystemPromptPrefix and SystemPromptSuffix are added before and after the instructions of every
+	// agent that does not set SkipSystemPromptWrap.
+	SystemPromptPrefix string `json:"systemPromptPrefix,omitempty"`
+	SystemPromptSuffix string `json:"systemPromptSuffix,omitempty"`
+}
+
+// ConditionalConfig is merged into the config at load time only if the If expression evaluates to true.
+// The expression is evaluated with the environment available as env, for example ${env.ENV === "prod"}.
+type ConditionalConfig struct {
+	If     string `json:"if,omitempty"`
+	Config Config `json:"config,omitzero"`
+}
+
+// TokenPrice is the price of a model per million input and output tokens, used to estimate the cost of
+// completions. The currency is up to the config.
+type TokenPrice struct {
+	Input  float64 `json:"input,omitempty"`
+	Output float64 `json:"output,omitempty"`
+}
+
+// Cost returns the estimated cost of the usage.
+func (p TokenPrice) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1_000_000
 }
 
 type ConfigFactory func(ctx context.Context, profiles string) (Config, error)
@@ -69,31 +100,28 @@ func (c Config) Validate(allowLocal bool) error {
 	)
 
 	if len(c.Publish.Entrypoint) == 0 && len(c.Agents) > 1 {
-		errs = append(errs, fmt.Errorf("publish must have at least one entrypoint agent set if there are multiple agents"))
+		errs = append(errs, problems("", "", fmt.Errorf("publish must have at least one entrypoint agent set if there are multiple agents"))...)
 	}
 
 	for _, extend := range c.Extends {
 		if strings.HasPrefix(strings.TrimSpace(extend), "/") {
-			errs = append(errs, fmt.Errorf("extends cannot be an absolute path: %s", c.Extends))
+			errs = append(errs, problems("", "", fmt.Errorf("extends cannot be an absolute path: %s", c.Extends))...)
 		}
 	}
 
 	for agentName, agent := range c.Agents {
-		if err := checkDup(seenNames, "agents", agentName); err != nil {
-			errs = append(errs, err)
-		}
-		if err := agent.validate(agentName, c); err != nil {
-			errs = append(errs, err)
-		}
+		errs = append(errs, problems(agentName, "", checkDup(seenNames, "agents", agentName))...)
+		errs = append(errs, problems(agentName, "", agent.validate(agentName, c))...)
 	}
 
+	errs = append(errs, validateAgentCycles(c)...)
+
 	for mcpServerName, mcpServer := range c.MCPServers {
-		if err := checkDup(seenNames, "mcpServers", mcpServerName); err != nil {
-			errs = append(errs, err)
-		}
-		if err := validateMCPServer(mcpServerName, mcpServer, allowLocal); err != nil {
-			errs = append(errs, err)
+		errs = append(errs, problems("", mcpServerName, checkDup(seenNames, "mcpServers", mcpServerName))...)
+		if mcpServer.LogLevel != "" && !mcp.IsLogLevel(mcpServer.LogLevel) {
+			errs = append(errs, problems("", mcpServerName, fmt.Errorf("mcpServer %q has invalid log level %q, must be one of %s", mcpServerName, mcpServer.LogLevel, strings.Join(mcp.LogLevels, ", ")))...)
 		}
+		errs = append(errs, problems("", mcpServerName, validateMCPServer(mcpServerName, mcpServer, allowLocal))...)
 	}
 
 	return errors.Join(errs...)
@@ -309,12 +337,37 @@ func (t *ToolMappings) Deserialize(data any) (any, error) {
 	return t, mcp.JSONCoerce(data, &t)
 }
 
+// String returns a stable, sorted representation of the mappings, one per line, in the
+// form "name -> server/target". This is useful for display and for snapshot tests.
+func (t ToolMappings) String() string {
+	var buf strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(t)) {
+		mapping := t[key]
+		buf.WriteString(key)
+		buf.WriteString(" -> ")
+		buf.WriteString(mapping.MCPServer)
+		if mapping.TargetName != "" {
+			buf.WriteString("/")
+			buf.WriteString(mapping.TargetName)
+		}
+		if mapping.Target.External {
+			buf.WriteString(" (external)")
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
 type BuildToolMappingsOptions struct {
 	DefaultAsToServer bool
+	// AllowDuplicates lets the last reference win when references publish different tools under the same
+	// name, instead of returning an error.
+	AllowDuplicates bool
 }
 
 func (b BuildToolMappingsOptions) Merge(other BuildToolMappingsOptions) BuildToolMappingsOptions {
 	b.DefaultAsToServer = complete.Last(b.DefaultAsToServer, other.DefaultAsToServer)
+	b.AllowDuplicates = complete.Last(b.AllowDuplicates, other.AllowDuplicates)
 	return b
 }
 
@@ -342,31 +395,48 @@ func (s *StringList) UnmarshalJSON(data []byte) error {
 }
 
 type Agent struct {
-	Name            string                    `json:"name,omitempty"`
-	ShortName       string                    `json:"shortName,omitempty"`
-	Description     string                    `json:"description,omitempty"`
-	Icon            string                    `json:"icon,omitempty"`
-	IconDark        string                    `json:"iconDark,omitempty"`
-	StarterMessages StringList                `json:"starterMessages,omitempty"`
-	Instructions    DynamicInstructions       `json:"instructions,omitzero"`
-	Model           string                    `json:"model,omitempty"`
-	MCPServers      StringList                `json:"mcpServers,omitempty"`
-	Tools           StringList                `json:"tools,omitempty"`
-	Agents          StringList                `json:"agents,omitempty"`
-	Prompts         StringList                `json:"prompts,omitzero"`
-	Resources       StringList                `json:"resources,omitzero"`
-	Reasoning       *AgentReasoning           `json:"reasoning,omitempty"`
-	ThreadName      string                    `json:"threadName,omitempty"`
-	Chat            *bool                     `json:"chat,omitempty"`
-	ToolExtensions  map[string]map[string]any `json:"toolExtensions,omitempty"`
-	ToolChoice      string                    `json:"toolChoice,omitempty"`
-	Temperature     *json.Number              `json:"temperature,omitempty"`
-	TopP            *json.Number              `json:"topP,omitempty"`
-	Output          *OutputSchema             `json:"output,omitempty"`
-	Truncation      string                    `json:"truncation,omitempty"`
-	MaxTokens       int                       `json:"maxTokens,omitempty"`
-	MimeTypes       []string                  `json:"mimeTypes,omitempty"`
-	Hooks           mcp.Hooks                 `json:"hooks,omitempty"`
+	Name            string              `json:"name,omitempty"`
+	ShortName       string              `json:"shortName,omitempty"`
+	Description     string              `json:"description,omitempty"`
+	Icon            string              `json:"icon,omitempty"`
+	IconDark        string              `json:"iconDark,omitempty"`
+	StarterMessages StringList          `json:"starterMessages,omitempty"`
+	Instructions    DynamicInstructions `json:"instructions,omitzero"`
+	// SkipSystemPromptWrap excludes the agent from the systemPromptPrefix and systemPromptSuffix of the config.
+	SkipSystemPromptWrap bool       `json:"skipSystemPromptWrap,omitempty"`
+	Model                string     `json:"model,omitempty"`
+	MCPServers           StringList `json:"mcpServers,omitempty"`
+	Tools                StringList `json:"tools,omitempty"`
+	// StopTools end the turn when the agent calls them. The tool is executed and its result is returned
+	// instead of being sent back to the LLM.
+	StopTools            StringList                 `json:"stopTools,omitempty"`
+	Agents               StringList                 `json:"agents,omitempty"`
+	Prompts              StringList                 `json:"prompts,omitzero"`
+	Resources            StringList                 `json:"resources,omitzero"`
+	Reasoning            *AgentReasoning            `json:"reasoning,omitempty"`
+	Audio                *AgentAudio                `json:"audio,omitempty"`
+	Sanitize             *AgentSanitize             `json:"sanitize,omitempty"`
+	Examples             []AgentExample             `json:"examples,omitempty"`
+	ThreadName           string                     `json:"threadName,omitempty"`
+	Chat                 *bool                      `json:"chat,omitempty"`
+	ToolExtensions       map[string]map[string]any  `json:"toolExtensions,omitempty"`
+	ToolExtensionSchemas map[string]json.RawMessage `json:"toolExtensionSchemas,omitempty"`
+	ToolChoice           string                     `json:"toolChoice,omitempty"`
+	AssistantPrefix      string                     `json:"assistantPrefix,omitempty"`
+	FirstToolChoice      string                     `json:"firstToolChoice,omitempty"`
+	Temperature          *json.Number               `json:"temperature,omitempty"`
+	TopP                 *json.Number               `json:"topP,omitempty"`
+	SamplingSchedule     []AgentSampling            `json:"samplingSchedule,omitempty"`
+	Hedge                *AgentHedge                `json:"hedge,omitempty"`
+	Output               *OutputSchema              `json:"output,omitempty"`
+	Truncation           string                     `json:"truncation,omitempty"`
+	MaxTokens            int                        `json:"maxTokens,omitempty"`
+	MaxToolResultSize    int                        `json:"maxToolResultSize,omitempty"`
+	InlineResourceLinks  int                        `json:"inlineResourceLinks,omitempty"`
+	MaxIterations        int                        `json:"maxIterations,omitempty"`
+	MimeTypes            []string                   `json:"mimeTypes,omitempty"`
+	ImageDetail          string                     `json:"imageDetail,omitempty"`
+	Hooks                mcp.Hooks                  `json:"hooks,omitempty"`
 
 	// Selection criteria fields
 
@@ -381,6 +451,73 @@ type AgentReasoning struct {
 	Summary string `json:"summary,omitempty"`
 }
 
+// AgentAudio enables audio output for models that support it. The audio is returned as audio content
+// next to the transcript.
+type AgentAudio struct {
+	Voice  string `json:"voice,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// AgentSanitize removes unsafe HTML from the text the agent responds with, so it can be rendered as
+// markdown by UIs. HTML tags that are not in AllowedTags are removed, and the content of tags like
+// script and style is removed with them.
+type AgentSanitize struct {
+	// AllowedTags are the HTML tags that are kept, defaults to common formatting tags.
+	AllowedTags []string `json:"allowedTags,omitempty"`
+}
+
+// AgentSampling sets the sampling parameters of an agent from the turn FromTurn of a conversation on,
+// counting from 0. Values that are not set are taken from the agent.
+type AgentSampling struct {
+	FromTurn    int          `json:"fromTurn,omitempty"`
+	Temperature *json.Number `json:"temperature,omitempty"`
+	TopP        *json.Number `json:"topP,omitempty"`
+}
+
+// AgentHedge sends a completion request again if the first one did not start to respond after Delay, and
+// uses whichever responds first. This lowers the tail latency of the agent at the cost of paying for some
+// requests twice.
+type AgentHedge struct {
+	// Delay is how long to wait for the first token of a response before hedging, like "2s".
+	Delay string `json:"delay,omitempty"`
+	// Model is the model the hedged request is sent to, defaults to the model of the agent.
+	Model string `json:"model,omitempty"`
+}
+
+// AgentExample is a user message and the assistant response to it that is shown to the LLM as an example
+// before the conversation. Examples are sent with every request but are not part of the chat history.
+type AgentExample struct {
+	User      string `json:"user,omitempty"`
+	Assistant string `json:"assistant,omitempty"`
+}
+
+// ToolExamplesExtension is the tool extension with examples of calling the tool, a list of ToolExample. The
+// examples are appended to the description of the tool instead of being sent to the provider.
+const ToolExamplesExtension = "examples"
+
+// ToolExample is the arguments of an example call of a tool and when the call is made.
+type ToolExample struct {
+	Description string         `json:"description,omitempty"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+}
+
+// ToolExamples returns the examples in the extensions of a tool, see ToolExamplesExtension.
+func ToolExamples(extensions map[string]any) ([]ToolExample, error) {
+	raw, ok := extensions[ToolExamplesExtension]
+	if !ok {
+		return nil, nil
+	}
+	var examples []ToolExample
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &examples)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool examples: %w", err)
+	}
+	return examples, nil
+}
+
 func (a Agent) ToDisplay(id string) AgentDisplay {
 	agent := AgentDisplay{
 		ID:              id,
@@ -450,6 +587,51 @@ func validateReferences(c Config, tools, agents StringList) (bool, map[string]st
 	return unknownNames, resolvedToolNames, errs
 }
 
+// validateAgentCycles reports each cycle of agents referencing each other through Agent.Agents, such as
+// "a -> b -> a", which would recurse until the agent call depth limit at runtime.
+func validateAgentCycles(c Config) (errs []error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	var (
+		state = map[string]int{}
+		path  []string
+		visit func(name string)
+	)
+
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, ref := range c.Agents[name].Agents {
+			next := ParseToolRef(ref).Server
+			if _, ok := c.Agents[next]; !ok {
+				// Unknown agents are reported by validateReferences
+				continue
+			}
+			switch state[next] {
+			case visiting:
+				// Each reference is only followed once, so each cycle is found once, at the reference that closes it
+				cycle := append(slices.Clone(path[slices.Index(path, next):]), next)
+				errs = append(errs, problems(next, "", fmt.Errorf("agent %q references itself through %s", next, strings.Join(cycle, " -> ")))...)
+			case 0:
+				visit(next)
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = done
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Agents)) {
+		if state[name] == 0 {
+			visit(name)
+		}
+	}
+	return errs
+}
+
 func (a Agent) validate(agentName string, c Config) error {
 	unknownNames, resolvedToolNames, errs := validateReferences(c, a.Tools, a.Agents)
 
@@ -470,15 +652,92 @@ func (a Agent) validate(agentName string, c Config) error {
 		}
 	}
 
+	if a.ImageDetail != "" && !slices.Contains(ImageDetails, a.ImageDetail) {
+		errs = append(errs, fmt.Errorf("agent %q has invalid image detail %q, must be one of %s", agentName, a.ImageDetail, strings.Join(ImageDetails, ", ")))
+	}
+
+	if a.Hedge != nil {
+		if delay, err := time.ParseDuration(a.Hedge.Delay); err != nil {
+			errs = append(errs, fmt.Errorf("agent %q has invalid hedge delay %q: %w", agentName, a.Hedge.Delay, err))
+		} else if delay <= 0 {
+			errs = append(errs, fmt.Errorf("agent %q has hedge delay %q that is not positive", agentName, a.Hedge.Delay))
+		}
+	}
+
+	if a.Output != nil {
+		if a.Output.Mode != "" && !slices.Contains(OutputModes, a.Output.Mode) {
+			errs = append(errs, fmt.Errorf("agent %q has invalid output mode %q, must be one of %s", agentName, a.Output.Mode, strings.Join(OutputModes, ", ")))
+		}
+		if a.Output.JSONMode() && a.Output.Strict {
+			errs = append(errs, fmt.Errorf("agent %q has output mode %q that can not be strict", agentName, OutputModeJSON))
+		}
+	}
+
 	if !unknownNames && a.ToolChoice != "" && a.ToolChoice != "none" && a.ToolChoice != "auto" {
 		if _, ok := resolvedToolNames[a.ToolChoice]; !ok {
 			errs = append(errs, fmt.Errorf("agent %q has tool choice %q that is not defined in tools", agentName, a.ToolChoice))
 		}
 	}
 
+	// The tools of MCP servers that are referenced as a whole are only known at runtime
+	if !unknownNames && len(a.MCPServers) == 0 && !slices.ContainsFunc(a.Tools, func(ref string) bool {
+		return ParseToolRef(ref).Tool == ""
+	}) {
+		for _, toolName := range slices.Sorted(maps.Keys(a.ToolExtensions)) {
+			if _, ok := resolvedToolNames[toolName]; !ok {
+				errs = append(errs, fmt.Errorf("agent %q has tool extension for tool %q that is not defined in tools", agentName, toolName))
+			}
+		}
+	}
+
+	for _, toolName := range slices.Sorted(maps.Keys(a.ToolExtensions)) {
+		if _, err := ToolExamples(a.ToolExtensions[toolName]); err != nil {
+			errs = append(errs, fmt.Errorf("agent %q has tool extension for tool %q with %w", agentName, toolName, err))
+		}
+	}
+
+	errs = append(errs, a.validateToolExtensionSchemas(agentName)...)
+
 	return errors.Join(errs...)
 }
 
+// validateToolExtensionSchemas checks the attributes of each tool extension against the JSON Schema in
+// ToolExtensionSchemas for the same tool, if there is one.
+func (a Agent) validateToolExtensionSchemas(agentName string) (errs []error) {
+	for _, toolName := range slices.Sorted(maps.Keys(a.ToolExtensionSchemas)) {
+		c := jsonschema.NewCompiler()
+		schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(a.ToolExtensionSchemas[toolName]))
+		if err == nil {
+			err = c.AddResource("urn:nanobot:tool-extension", schemaDoc)
+		}
+		var schema *jsonschema.Schema
+		if err == nil {
+			schema, err = c.Compile("urn:nanobot:tool-extension")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %q has invalid tool extension schema for tool %q: %w", agentName, toolName, err))
+			continue
+		}
+
+		extension, ok := a.ToolExtensions[toolName]
+		if !ok {
+			continue
+		}
+		// The attributes are compared as JSON, so numbers have the types the schema validator expects
+		data, err := json.Marshal(extension)
+		if err == nil {
+			var attributes any
+			if attributes, err = jsonschema.UnmarshalJSON(bytes.NewReader(data)); err == nil {
+				err = schema.Validate(attributes)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %q has tool extension for tool %q that does not match its schema: %w", agentName, toolName, err))
+		}
+	}
+	return errs
+}
+
 type DynamicInstructions struct {
 	Instructions string            `json:"-"`
 	MCPServer    string            `json:"mcpServer,omitempty"`
@@ -515,18 +774,85 @@ func (a DynamicInstructions) MarshalJSON() ([]byte, error) {
 	return json.Marshal(Alias(a))
 }
 
+const (
+	// OutputModeSchema constrains the output to the schema of the OutputSchema, the default.
+	OutputModeSchema = "schema"
+	// OutputModeJSON only asks the provider for valid JSON, the "JSON mode" of providers that support it.
+	// A schema is not required and, if set, not sent to the provider, so the instructions should describe the
+	// expected JSON.
+	OutputModeJSON = "json"
+)
+
+// OutputModes are the valid output modes.
+var OutputModes = []string{OutputModeSchema, OutputModeJSON}
+
 type OutputSchema struct {
 	Name        string           `json:"name,omitempty"`
 	Description string           `json:"description,omitempty"`
+	Mode        string           `json:"mode,omitempty"`
 	Schema      json.RawMessage  `json:"schema,omitzero"`
 	Strict      bool             `json:"strict,omitempty"`
 	Fields      map[string]Field `json:"fields,omitempty"`
+	// OneOf are the alternative shapes of the output, used instead of Fields. The providers require the
+	// output to be an object, so the output is an object with the alternative as its "value" property.
+	OneOf []Field `json:"oneOf,omitempty"`
+}
+
+// OutputValueProperty is the property of the output that holds the alternative of an OutputSchema with OneOf.
+const OutputValueProperty = "value"
+
+// JSONMode returns true if the output only has to be valid JSON instead of matching the schema.
+func (o OutputSchema) JSONMode() bool {
+	return o.Mode == OutputModeJSON
 }
 
 type Field struct {
 	Description string           `json:"description,omitempty"`
 	Fields      map[string]Field `json:"fields,omitempty"`
 	Required    *bool            `json:"required,omitempty"`
+	// OneOf are the alternatives of the field, each a string or, if it has fields, an object.
+	OneOf []Field `json:"oneOf,omitempty"`
+
+	// Default and the constraints are emitted as the JSON Schema keywords of the same name.
+
+	Default   any          `json:"default,omitempty"`
+	Minimum   *json.Number `json:"minimum,omitempty"`
+	Maximum   *json.Number `json:"maximum,omitempty"`
+	MinLength *int         `json:"minLength,omitempty"`
+	MaxLength *int         `json:"maxLength,omitempty"`
+	Pattern   string       `json:"pattern,omitempty"`
+}
+
+// hasConstraints returns true if a default or constraint is set, which the string form can't express.
+func (f Field) hasConstraints() bool {
+	return f.Default != nil ||
+		f.Minimum != nil ||
+		f.Maximum != nil ||
+		f.MinLength != nil ||
+		f.MaxLength != nil ||
+		f.Pattern != ""
+}
+
+// addConstraints sets the JSON Schema keywords of the default and constraints of the field on schema.
+func (f Field) addConstraints(schema map[string]any) {
+	if f.Default != nil {
+		schema["default"] = f.Default
+	}
+	if f.Minimum != nil {
+		schema["minimum"] = *f.Minimum
+	}
+	if f.Maximum != nil {
+		schema["maximum"] = *f.Maximum
+	}
+	if f.MinLength != nil {
+		schema["minLength"] = *f.MinLength
+	}
+	if f.MaxLength != nil {
+		schema["maxLength"] = *f.MaxLength
+	}
+	if f.Pattern != "" {
+		schema["pattern"] = f.Pattern
+	}
 }
 
 func (f *Field) UnmarshalJSON(data []byte) error {
@@ -544,7 +870,7 @@ func (f *Field) UnmarshalJSON(data []byte) error {
 }
 
 func (f Field) MarshalJSON() ([]byte, error) {
-	if len(f.Fields) > 0 {
+	if len(f.Fields) > 0 || len(f.OneOf) > 0 || f.hasConstraints() {
 		type Alias Field
 		return json.Marshal(Alias(f))
 	}
@@ -552,6 +878,12 @@ func (f Field) MarshalJSON() ([]byte, error) {
 }
 
 func (o OutputSchema) ToSchema() json.RawMessage {
+	if len(o.OneOf) > 0 {
+		data, _ := json.Marshal(BuildSimpleSchema(o.Name, o.Description, map[string]Field{
+			OutputValueProperty: {OneOf: o.OneOf},
+		}))
+		return data
+	}
 	if len(o.Fields) > 0 {
 		data, _ := json.Marshal(BuildSimpleSchema(o.Name, o.Description, o.Fields))
 		return data
@@ -574,6 +906,36 @@ func (i InputSchema) ToSchema() json.RawMessage {
 	return i.Schema
 }
 
+// oneOfSchema returns a schema that matches one of the alternatives. It uses anyOf, as strict structured
+// outputs don't support oneOf.
+func oneOfSchema(description string, alternatives []Field) map[string]any {
+	anyOf := make([]any, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		var schema map[string]any
+		switch {
+		case len(alternative.OneOf) > 0:
+			schema = oneOfSchema(alternative.Description, alternative.OneOf)
+		case len(alternative.Fields) > 0:
+			schema = BuildSimpleSchema("", alternative.Description, alternative.Fields)
+		default:
+			schema = map[string]any{
+				"type":        "string",
+				"description": alternative.Description,
+			}
+		}
+		alternative.addConstraints(schema)
+		anyOf = append(anyOf, schema)
+	}
+
+	schema := map[string]any{
+		"anyOf": anyOf,
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	return schema
+}
+
 // enumSyntaxRegexp is string like name(option1,option2,option3). This is not a complete regex for enum syntax,
 // but it is used to detect if a field is an enum based on the presence of parentheses.
 var enumSyntaxRegexp = regexp.MustCompile(`^.+\(.+,`)
@@ -604,7 +966,10 @@ func BuildSimpleSchema(name, description string, args map[string]Field) map[stri
 					"type": "string",
 				},
 			}
-			if len(field.Fields) > 0 {
+			if len(field.OneOf) > 0 {
+				jsonschema["properties"].(map[string]any)[name].(map[string]any)["items"] =
+					oneOfSchema("", field.OneOf)
+			} else if len(field.Fields) > 0 {
 				jsonschema["properties"].(map[string]any)[name].(map[string]any)["items"] =
 					BuildSimpleSchema("", "", field.Fields)
 			}
@@ -627,11 +992,12 @@ func BuildSimpleSchema(name, description string, args map[string]Field) map[stri
 				"description": field.Description,
 			}
 		} else if enumSyntaxRegexp.MatchString(name) {
-			name, args, _ := strings.Cut(name, "(")
 			var (
-				enum []string
+				enum   []string
+				values string
 			)
-			for _, arg := range strings.Split(strings.TrimSuffix(args, ")"), ",") {
+			name, values, _ = strings.Cut(name, "(")
+			for _, arg := range strings.Split(strings.TrimSuffix(values, ")"), ",") {
 				enum = append(enum, strings.TrimSpace(arg))
 			}
 			jsonschema["properties"].(map[string]any)[name] = map[string]any{
@@ -639,6 +1005,8 @@ func BuildSimpleSchema(name, description string, args map[string]Field) map[stri
 				"description": field.Description,
 				"enum":        enum,
 			}
+		} else if len(field.OneOf) > 0 {
+			jsonschema["properties"].(map[string]any)[name] = oneOfSchema(field.Description, field.OneOf)
 		} else if len(field.Fields) > 0 {
 			jsonschema["properties"].(map[string]any)[name] = BuildSimpleSchema("", field.Description, field.Fields)
 		} else {
@@ -648,6 +1016,8 @@ func BuildSimpleSchema(name, description string, args map[string]Field) map[stri
 			}
 		}
 
+		field.addConstraints(jsonschema["properties"].(map[string]any)[name].(map[string]any))
+
 		if field.Required == nil || *field.Required {
 			required = append(required, name)
 		}
@@ -0,0 +1,324 @@
+package types
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func TestValidateAgentCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		agents map[string]Agent
+		want   autogold.Value
+	}{
+		{
+			name: "no cycle",
+			agents: map[string]Agent{
+				"a": {Agents: StringList{"b", "c"}},
+				"b": {Agents: StringList{"c"}},
+				"c": {},
+			},
+			want: autogold.Expect([]string{}),
+		},
+		{
+			name: "self reference",
+			agents: map[string]Agent{
+				"a": {Agents: StringList{"a"}},
+			},
+			want: autogold.Expect([]string{`agent "a" references itself through a -> a`}),
+		},
+		{
+			name: "two agent cycle",
+			agents: map[string]Agent{
+				"a": {Agents: StringList{"b"}},
+				"b": {Agents: StringList{"a"}},
+			},
+			want: autogold.Expect([]string{`agent "a" references itself through a -> b -> a`}),
+		},
+		{
+			name: "longer cycle and unknown agent",
+			agents: map[string]Agent{
+				"main":    {Agents: StringList{"planner"}},
+				"planner": {Agents: StringList{"writer", "missing"}},
+				"writer":  {Agents: StringList{"planner"}},
+			},
+			want: autogold.Expect([]string{`agent "planner" references itself through planner -> writer -> planner`}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, err := range validateAgentCycles(Config{Agents: tt.agents}) {
+				got = append(got, err.Error())
+			}
+			tt.want.Equal(t, got)
+		})
+	}
+}
+
+func TestConfigValidate_AgentCycle(t *testing.T) {
+	err := Config{
+		Publish: Publish{Entrypoint: StringList{"a"}},
+		Agents: map[string]Agent{
+			"a": {Agents: StringList{"b"}},
+			"b": {Agents: StringList{"a"}},
+		},
+	}.Validate(true)
+	autogold.Expect(`agent "a" references itself through a -> b -> a`).Equal(t, err.Error())
+}
+
+func TestConfigValidate_LogLevel(t *testing.T) {
+	err := Config{
+		MCPServers: map[string]mcp.Server{
+			"fs": {Command: "fs", LogLevel: "verbose"},
+		},
+	}.Validate(true)
+	autogold.Expect(`mcpServer "fs" has invalid log level "verbose", must be one of debug, info, notice, warning, error, critical, alert, emergency`).Equal(t, err.Error())
+}
+
+func TestConfigValidate_ToolExtensions(t *testing.T) {
+	config := Config{
+		Agents: map[string]Agent{
+			"a": {
+				Tools: StringList{"fs/read", "fs/write:save"},
+				ToolExtensions: map[string]map[string]any{
+					"read":   {"cache_control": map[string]any{"type": "ephemeral"}},
+					"save":   {"cache_control": map[string]any{"type": "ephemeral"}},
+					"delete": {"cache_control": map[string]any{"type": "ephemeral"}},
+				},
+			},
+		},
+		MCPServers: map[string]mcp.Server{
+			"fs": {Command: "fs"},
+		},
+	}
+	err := config.Validate(true)
+	autogold.Expect(`agent "a" has tool extension for tool "delete" that is not defined in tools`).Equal(t, err.Error())
+
+	// The tools of a whole server are not known, so the extension can't be checked
+	agent := config.Agents["a"]
+	agent.Tools = append(agent.Tools, "fs")
+	config.Agents["a"] = agent
+	if err := config.Validate(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigValidate_ToolExamples(t *testing.T) {
+	err := Config{
+		Agents: map[string]Agent{
+			"a": {
+				Tools: StringList{"fs/read"},
+				ToolExtensions: map[string]map[string]any{
+					"read": {ToolExamplesExtension: "read a file"},
+				},
+			},
+		},
+		MCPServers: map[string]mcp.Server{
+			"fs": {Command: "fs"},
+		},
+	}.Validate(true)
+	autogold.Expect(`agent "a" has tool extension for tool "read" with invalid tool examples: json: cannot unmarshal string into Go value of type []types.ToolExample`).Equal(t, err.Error())
+}
+
+func TestConfigValidate_ToolExtensionSchemas(t *testing.T) {
+	err := Config{
+		Agents: map[string]Agent{
+			"a": {
+				Tools: StringList{"fs/read", "fs/write"},
+				ToolExtensions: map[string]map[string]any{
+					"read":  {"ttl": 60.0},
+					"write": {"ttl": "forever"},
+				},
+				ToolExtensionSchemas: map[string]json.RawMessage{
+					"read":  json.RawMessage(`{"type": "object", "properties": {"ttl": {"type": "integer"}}}`),
+					"write": json.RawMessage(`{"type": "object", "properties": {"ttl": {"type": "integer"}}}`),
+				},
+			},
+		},
+		MCPServers: map[string]mcp.Server{
+			"fs": {Command: "fs"},
+		},
+	}.Validate(true)
+	autogold.Expect(`agent "a" has tool extension for tool "write" that does not match its schema: jsonschema validation failed with 'urn:nanobot:tool-extension#'
+- at '/ttl': got string, want integer`).Equal(t, err.Error())
+}
+
+func TestConfigValidate_OutputMode(t *testing.T) {
+	for _, test := range []struct {
+		output OutputSchema
+		err    autogold.Value
+	}{
+		{OutputSchema{Mode: "xml"}, autogold.Expect(`agent "a" has invalid output mode "xml", must be one of schema, json`)},
+		{OutputSchema{Mode: OutputModeJSON, Strict: true}, autogold.Expect(`agent "a" has output mode "json" that can not be strict`)},
+		{OutputSchema{Mode: OutputModeJSON}, autogold.Expect("")},
+	} {
+		t.Run(test.output.Mode, func(t *testing.T) {
+			err := Config{
+				Agents: map[string]Agent{
+					"a": {Output: &test.output},
+				},
+			}.Validate(true)
+			var msg string
+			if err != nil {
+				msg = err.Error()
+			}
+			test.err.Equal(t, msg)
+		})
+	}
+}
+
+func TestConfigValidate_HedgeDelay(t *testing.T) {
+	for _, test := range []struct {
+		delay string
+		err   autogold.Value
+	}{
+		{"soon", autogold.Expect(`agent "a" has invalid hedge delay "soon": time: invalid duration "soon"`)},
+		{"-1s", autogold.Expect(`agent "a" has hedge delay "-1s" that is not positive`)},
+		{"2s", autogold.Expect("")},
+	} {
+		t.Run(test.delay, func(t *testing.T) {
+			err := Config{
+				Agents: map[string]Agent{
+					"a": {Hedge: &AgentHedge{Delay: test.delay, Model: "fast"}},
+				},
+			}.Validate(true)
+			var msg string
+			if err != nil {
+				msg = err.Error()
+			}
+			test.err.Equal(t, msg)
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	err := Config{
+		Agents: map[string]Agent{
+			"a": {MCPServers: []string{"missing"}},
+		},
+		MCPServers: map[string]mcp.Server{
+			"fs": {Command: "fs", LogLevel: "verbose"},
+		},
+	}.Validate(true)
+
+	var got [][]string
+	for _, validationErr := range ValidationErrors(err) {
+		got = append(got, []string{validationErr.Agent, validationErr.MCPServer})
+	}
+	autogold.Expect([][]string{{"a", ""}, {"", "fs"}}).Equal(t, got)
+}
+
+func TestBuildSimpleSchema_Constraints(t *testing.T) {
+	var fields map[string]Field
+	if err := json.Unmarshal([]byte(`{
+		"name": "The name",
+		"age(int)": {"description": "The age", "minimum": 0, "maximum": 150, "default": 18},
+		"code": {"description": "The code", "pattern": "^[A-Z]+$", "minLength": 2, "maxLength": 4, "required": false},
+		"color(red, blue)": {"description": "The color", "default": "red"}
+	}`), &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(BuildSimpleSchema("", "", fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(map[string]any{
+		"age": map[string]any{
+			"default": 18, "description": "The age", "maximum": 150,
+			"minimum": 0, "type": "integer",
+		},
+		"code": map[string]any{
+			"description": "The code", "maxLength": 4, "minLength": 2,
+			"pattern": "^[A-Z]+$", "type": "string",
+		},
+		"color": map[string]any{
+			"default": "red", "description": "The color", "enum": []any{"red", "blue"},
+			"type": "string",
+		},
+		"name": map[string]any{"description": "The name", "type": "string"},
+	}).Equal(t, schema["properties"])
+	var required []string
+	for _, name := range schema["required"].([]any) {
+		required = append(required, name.(string))
+	}
+	slices.Sort(required)
+	autogold.Expect([]string{"age", "color", "name"}).Equal(t, required)
+
+	// The string form is kept for fields without constraints
+	data, err = json.Marshal(fields["name"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`"The name"`).Equal(t, string(data))
+}
+
+func TestBuildSimpleSchema_OneOf(t *testing.T) {
+	var output OutputSchema
+	if err := json.Unmarshal([]byte(`{
+		"name": "result",
+		"oneOf": [
+			{"fields": {"answer": "The answer"}},
+			{"fields": {"error": "Why there is no answer", "retry(bool)": {"description": "If asking again can help", "required": false}}}
+		]
+	}`), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema any
+	if err := json.Unmarshal(output.ToSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(map[string]interface{}{
+		"additionalProperties": false, "properties": map[string]interface{}{"value": map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{
+				"additionalProperties": false,
+				"properties": map[string]interface{}{"answer": map[string]interface{}{
+					"description": "The answer",
+					"type":        "string",
+				}},
+				"required": []interface{}{"answer"},
+				"type":     "object",
+			},
+			map[string]interface{}{
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"error": map[string]interface{}{
+						"description": "Why there is no answer",
+						"type":        "string",
+					},
+					"retry": map[string]interface{}{
+						"description": "If asking again can help",
+						"type":        "boolean",
+					},
+				},
+				"required": []interface{}{"error"},
+				"type":     "object",
+			},
+		}}},
+		"required": []interface{}{"value"},
+		"title":    "result",
+		"type":     "object",
+	}).Equal(t, schema)
+
+	var fields map[string]Field
+	if err := json.Unmarshal([]byte(`{
+		"id": {"description": "A name or a number", "oneOf": ["A name", {"fields": {"number(int)": "The number"}}]}
+	}`), &fields); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(BuildSimpleSchema("", "", fields)["properties"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect(`{"id":{"anyOf":[{"description":"A name","type":"string"},{"additionalProperties":false,"properties":{"number":{"description":"The number","type":"integer"}},"required":["number"],"type":"object"}],"description":"A name or a number"}}`).Equal(t, string(data))
+}
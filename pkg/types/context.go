@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"math"
 
 	"github.com/obot-platform/mcp-oauth-proxy/pkg/providers"
 )
@@ -24,3 +25,32 @@ func NanobotContext(ctx context.Context) Context {
 	c, _ := ctx.Value(contextKey{}).(Context)
 	return c
 }
+
+type agentDepthKey struct{}
+
+// WithAgentDepth records how many agents deep the current call is, where the agent called by a client is
+// depth 1 and an agent it calls is depth 2.
+func WithAgentDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, agentDepthKey{}, depth)
+}
+
+func AgentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(agentDepthKey{}).(int)
+	return depth
+}
+
+// AgentDepthFromMeta reads the depth a caller passed under AgentDepthMetaKey in the request _meta. The value
+// comes from the client, so it can only add to the depth already in ctx: it is never below zero or the depth
+// of ctx, otherwise a client could reset the depth and recurse without the limit.
+func AgentDepthFromMeta(ctx context.Context, meta map[string]any) int {
+	var depth int
+	switch v := meta[AgentDepthMetaKey].(type) {
+	case int:
+		depth = v
+	case float64:
+		if v > 0 && v < math.MaxInt32 {
+			depth = int(v)
+		}
+	}
+	return max(depth, AgentDepth(ctx), 0)
+}
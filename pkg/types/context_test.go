@@ -0,0 +1,20 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+)
+
+func TestAgentDepthFromMeta(t *testing.T) {
+	ctx := WithAgentDepth(t.Context(), 3)
+	autogold.Expect([]int{0, 2, 2, 0, 3, 5}).Equal(t, []int{
+		AgentDepthFromMeta(t.Context(), nil),
+		AgentDepthFromMeta(t.Context(), map[string]any{AgentDepthMetaKey: 2}),
+		AgentDepthFromMeta(t.Context(), map[string]any{AgentDepthMetaKey: float64(2)}),
+		// A client can not reset the depth
+		AgentDepthFromMeta(t.Context(), map[string]any{AgentDepthMetaKey: float64(-100)}),
+		AgentDepthFromMeta(ctx, map[string]any{AgentDepthMetaKey: 0}),
+		AgentDepthFromMeta(ctx, map[string]any{AgentDepthMetaKey: 5}),
+	})
+}
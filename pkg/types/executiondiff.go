@@ -0,0 +1,110 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// TurnStartExecutionKey is the session attribute that holds the Execution a chat turn started from, if
+// turns are recorded for debugging. Like PreviousExecutionKey it is suffixed with "/" and the thread name
+// for named threads.
+const TurnStartExecutionKey = "turnStart"
+
+// ExecutionChange is a difference between two executions at the JSON pointer Path. Op is "add", "remove"
+// or "replace", with Before and After the values that were removed and added.
+type ExecutionChange struct {
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// DiffExecutions returns the changes from before to after, ordered by path. The executions are compared as
+// JSON, so objects are compared by key and arrays by index. A nil execution is treated as empty.
+func DiffExecutions(before, after *Execution) ([]ExecutionChange, error) {
+	from, err := executionJSON(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := executionJSON(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ExecutionChange
+	diffJSON("", from, to, &changes)
+	return changes, nil
+}
+
+func executionJSON(e *Execution) (any, error) {
+	if e == nil {
+		e = &Execution{}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal execution: %w", err)
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal execution: %w", err)
+	}
+	return result, nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func diffJSON(path string, before, after any, changes *[]ExecutionChange) {
+	switch from := before.(type) {
+	case map[string]any:
+		to, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := slices.Collect(maps.Keys(from))
+		for key := range to {
+			if _, ok := from[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fromValue, inFrom := from[key]
+			toValue, inTo := to[key]
+			keyPath := path + "/" + pointerEscaper.Replace(key)
+			switch {
+			case !inFrom:
+				*changes = append(*changes, ExecutionChange{Op: "add", Path: keyPath, After: toValue})
+			case !inTo:
+				*changes = append(*changes, ExecutionChange{Op: "remove", Path: keyPath, Before: fromValue})
+			default:
+				diffJSON(keyPath, fromValue, toValue, changes)
+			}
+		}
+		return
+	case []any:
+		to, ok := after.([]any)
+		if !ok {
+			break
+		}
+		for i := range max(len(from), len(to)) {
+			indexPath := fmt.Sprintf("%s/%d", path, i)
+			switch {
+			case i >= len(from):
+				*changes = append(*changes, ExecutionChange{Op: "add", Path: indexPath, After: to[i]})
+			case i >= len(to):
+				*changes = append(*changes, ExecutionChange{Op: "remove", Path: indexPath, Before: from[i]})
+			default:
+				diffJSON(indexPath, from[i], to[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, ExecutionChange{Op: "replace", Path: path, Before: before, After: after})
+	}
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/hexops/autogold/v2"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func textMessage(id, role, text string) Message {
+	return Message{
+		ID:   id,
+		Role: role,
+		Items: []CompletionItem{
+			{
+				ID: id + "_0",
+				Content: &mcp.Content{
+					Type: "text",
+					Text: text,
+				},
+			},
+		},
+	}
+}
+
+func TestDiffExecutions(t *testing.T) {
+	before := &Execution{
+		Request: CompletionRequest{
+			Model: "agent",
+			Input: []Message{textMessage("1", "user", "first")},
+		},
+		Done: true,
+	}
+	after := &Execution{
+		Request: CompletionRequest{
+			Model: "agent",
+			Input: []Message{textMessage("2", "user", "second")},
+		},
+		Done: true,
+		ToolOutputs: map[string]ToolOutput{
+			"call1": {
+				Output: textMessage("3", "user", "result"),
+				Done:   true,
+			},
+		},
+		Response: &CompletionResponse{
+			Output: textMessage("4", "assistant", "answer"),
+		},
+	}
+
+	changes, err := DiffExecutions(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Op+" "+change.Path)
+	}
+	autogold.Expect([]string{
+		"replace /request/input/0/id", "replace /request/input/0/items/0/id",
+		"replace /request/input/0/items/0/text",
+		"add /response",
+		"add /toolOutputs",
+	}).Equal(t, got)
+	autogold.Expect(map[string]interface{}{"output": map[string]interface{}{
+		"id": "4", "items": []interface{}{map[string]interface{}{
+			"hasMore": false, "id": "4_0", "partial": false, "text": "answer",
+			"type": "text",
+		}},
+		"role": "assistant",
+	}}).Equal(t, changes[len(changes)-2].After)
+	autogold.Expect(map[string]interface{}{"call1": map[string]interface{}{"done": true, "output": map[string]interface{}{
+		"id": "3", "items": []interface{}{map[string]interface{}{
+			"hasMore": false, "id": "3_0", "partial": false, "text": "result",
+			"type": "text",
+		}},
+		"role": "user",
+	}}}).Equal(t, changes[len(changes)-1].After)
+}
+
+func TestDiffExecutions_Nil(t *testing.T) {
+	changes, err := DiffExecutions(nil, &Execution{Done: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.Expect([]ExecutionChange{{Op: "add", Path: "/done", After: true}}).Equal(t, changes)
+}
@@ -1,5 +1,7 @@
 package types
 
+import "github.com/nanobot-ai/nanobot/pkg/mcp"
+
 const (
 	MessageMimeType    = "application/vnd.nanobot.chat.message+json"
 	HistoryMimeType    = "application/vnd.nanobot.chat.history+json"
@@ -7,15 +9,33 @@ const (
 	ErrorMimeType      = "application/vnd.nanobot.error+json"
 	AgentMimeType      = "application/vnd.nanobot.agent+json"
 	WorkspaceMimeType  = "application/vnd.nanobot.workspace+json"
+	LogsMimeType       = "application/vnd.nanobot.logs+json"
 	MetaNanobot        = "ai.nanobot"
 
 	MessageURI  = "chat://message/%s"
 	HistoryURI  = "chat://history"
 	ProgressURI = "chat://progress"
+	LogsURI     = "nanobot://logs"
 
 	AsyncMetaKey = "ai.nanobot.async"
+	// AgentDepthMetaKey carries the agent call depth to agents called over MCP.
+	AgentDepthMetaKey = "ai.nanobot.agentDepth"
+	// ImageDetailMetaKey is set on image content to the detail level ("low", "high" or "auto") the
+	// provider should use for it.
+	ImageDetailMetaKey = "ai.nanobot.imageDetail"
 )
 
+// ImageDetails are the valid image detail levels.
+var ImageDetails = []string{"low", "high", "auto"}
+
+// ImageDetail returns the detail level set on image content with ImageDetailMetaKey, or def if none is set.
+func ImageDetail(content mcp.Content, def string) string {
+	if detail, ok := content.Meta[ImageDetailMetaKey].(string); ok && detail != "" {
+		return detail
+	}
+	return def
+}
+
 var (
 	ImageMimeTypes = map[string]struct{}{
 		"image/png":  {},
@@ -13,3 +13,51 @@ func checkDup(seen map[string]string, category string, keys ...string) error {
 	}
 	return nil
 }
+
+// ValidationError is a problem reported by Config.Validate. Agent or MCPServer is the name of the agent or
+// MCP server the problem was found in, both are empty for problems of the config as a whole.
+type ValidationError struct {
+	Agent     string
+	MCPServer string
+	Err       error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors returns the problems in an error returned by Config.Validate, nil if there are none.
+func ValidationErrors(err error) (result []*ValidationError) {
+	switch e := err.(type) {
+	case *ValidationError:
+		return []*ValidationError{e}
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			result = append(result, ValidationErrors(err)...)
+		}
+	}
+	return result
+}
+
+// problems wraps the error, or each of the errors joined in it, in a ValidationError of the agent or MCP
+// server.
+func problems(agent, mcpServer string, err error) (result []error) {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			result = append(result, problems(agent, mcpServer, err)...)
+		}
+		return result
+	}
+	return []error{&ValidationError{
+		Agent:     agent,
+		MCPServer: mcpServer,
+		Err:       err,
+	}}
+}